@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"secretary/alpha/internal/repository"
+	"secretary/alpha/internal/service"
+)
+
+func runAudit() {
+	if len(os.Args) < 3 {
+		printAuditUsage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[2] {
+	case "verify":
+		err = runAuditVerify(os.Args[3:])
+	default:
+		fmt.Printf("Unknown audit command: %q\n", os.Args[2])
+		printAuditUsage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runAuditVerify walks the audit log chain in the server's database and
+// checks it against the signed checkpoints, exiting with status 2 at the
+// first break.
+func runAuditVerify(args []string) error {
+	verifyCmd := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	dbPath := verifyCmd.String("db", os.Getenv("SECRETARY_DB_PATH"), "Server database (default: SECRETARY_DB_PATH, or ./data/secretary.db)")
+	publicKey := verifyCmd.String("public-key", "", "Base64 Ed25519 public key from GET /api/audit-logs/signing-key (default: the server's signing keys)")
+	verifyCmd.Parse(args)
+
+	if *dbPath == "" {
+		*dbPath = "./data/secretary.db"
+	}
+
+	trustedKeys, err := trustedPublicKeys(*publicKey)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		return err
+	}
+	db, err := repository.InitDB("sqlite3", *dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	verification, err := service.VerifyAuditChain(
+		repository.NewAuditLogRepository(db),
+		repository.NewAuditCheckpointRepository(db),
+		trustedKeys...,
+	)
+	if err != nil {
+		return err
+	}
+	if err := printJSON(verification); err != nil {
+		return err
+	}
+	if !verification.Valid {
+		os.Exit(2)
+	}
+	return nil
+}
+
+func printAuditUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  secretary audit verify [-db FILE] [-public-key KEY]  Check the audit log chain against its signed checkpoints")
+	fmt.Println("\nRun a command with -h for its options.")
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"secretary/alpha/internal/middleware"
+	"secretary/alpha/internal/service"
+)
+
+func runEvidence() {
+	if len(os.Args) < 3 {
+		printEvidenceUsage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[2] {
+	case "export":
+		err = runEvidenceExport(os.Args[3:])
+	case "verify":
+		err = runEvidenceVerify(os.Args[3:])
+	default:
+		fmt.Printf("Unknown evidence command: %q\n", os.Args[2])
+		printEvidenceUsage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runEvidenceExport downloads an evidence bundle from a running server.
+// Alerts and command approvals are only kept in the server's memory, so
+// bundles cannot be put together from the database alone.
+func runEvidenceExport(args []string) error {
+	exportCmd := flag.NewFlagSet("evidence export", flag.ExitOnError)
+	server := exportCmd.String("server", "http://localhost:6080", "Base URL of the server")
+	token := exportCmd.String("token", os.Getenv("SECRETARY_SESSION_TOKEN"), "Session token of an admin, as returned by /api/login (default: SECRETARY_SESSION_TOKEN)")
+	sessions := exportCmd.String("sessions", "", "Comma-separated IDs of the sessions to export (required)")
+	out := exportCmd.String("out", "", "File to write the bundle to (default: the name the server gives it)")
+	exportCmd.Parse(args)
+
+	if *sessions == "" {
+		return fmt.Errorf("-sessions is required")
+	}
+	if *token == "" {
+		return fmt.Errorf("-token or SECRETARY_SESSION_TOKEN is required")
+	}
+
+	query := url.Values{}
+	for _, id := range strings.Split(*sessions, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			query.Add("session_id", id)
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(*server, "/")+"/api/evidence/export?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.AddCookie(&http.Cookie{Name: middleware.SessionCookieName, Value: *token})
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Message string      `json:"message"`
+			Data    interface{} `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		if body.Data != nil {
+			return fmt.Errorf("server returned %s: %s: %v", resp.Status, body.Message, body.Data)
+		}
+		return fmt.Errorf("server returned %s: %s", resp.Status, body.Message)
+	}
+
+	if *out == "" {
+		*out = "evidence.zip"
+		if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+			*out = filepath.Base(params["filename"])
+		}
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Evidence bundle written to %s\n", *out)
+	return nil
+}
+
+// runEvidenceVerify checks an evidence bundle against its signed manifest,
+// exiting with status 2 if the bundle does not match it.
+func runEvidenceVerify(args []string) error {
+	verifyCmd := flag.NewFlagSet("evidence verify", flag.ExitOnError)
+	filePath := verifyCmd.String("file", "", "Evidence bundle (required)")
+	publicKey := verifyCmd.String("public-key", "", "Base64 Ed25519 public key from GET /api/evidence/signing-key (default: the server's signing keys)")
+	verifyCmd.Parse(args)
+
+	if *filePath == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	trustedKeys, err := trustedPublicKeys(*publicKey)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(*filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	verification, err := service.VerifyEvidenceBundle(file, info.Size(), trustedKeys...)
+	if err != nil {
+		return err
+	}
+	if err := printJSON(verification); err != nil {
+		return err
+	}
+	if !verification.Valid {
+		os.Exit(2)
+	}
+	return nil
+}
+
+func printEvidenceUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  secretary evidence export -sessions IDS [-server URL] [-token TOKEN] [-out FILE]  Download a signed evidence bundle")
+	fmt.Println("  secretary evidence verify -file FILE [-public-key KEY]                           Check a bundle against its signed manifest")
+	fmt.Println("\nRun a command with -h for its options.")
+}
@@ -32,6 +32,14 @@ func main() {
 	switch command {
 	case "server":
 		runServer()
+	case "policy":
+		runPolicy()
+	case "recording":
+		runRecording()
+	case "evidence":
+		runEvidence()
+	case "audit":
+		runAudit()
 	default:
 		fmt.Printf("Unknown command: %q\n", command)
 		printUsage()
@@ -39,6 +47,46 @@ func main() {
 	}
 }
 
+// newRecordingStores returns the stores of hot and archived recordings, or
+// nil to keep them as local files. Uploads cut off by a crash are completed
+// so that what reached object storage can be read.
+func newRecordingStores(cfg config.RecordingConfig) (domain.RecordingStore, domain.RecordingStore) {
+	if cfg.Store != "s3" {
+		return nil, nil
+	}
+
+	options := service.S3StoreOptions{
+		Endpoint:  cfg.S3.Endpoint,
+		Region:    cfg.S3.Region,
+		Bucket:    cfg.S3.Bucket,
+		Prefix:    cfg.S3.Prefix,
+		AccessKey: cfg.S3.AccessKey,
+		SecretKey: cfg.S3.SecretKey,
+		PartSize:  cfg.S3.PartSize,
+		SpoolPath: cfg.S3.SpoolPath,
+	}
+	store, err := service.NewS3RecordingStore(options)
+	if err != nil {
+		utils.Fatalf("Failed to configure recording store: %v", err)
+	}
+	options.Bucket, options.Prefix = cfg.S3.ArchiveBucket, cfg.S3.ArchivePrefix
+	archive, err := service.NewS3RecordingStore(options)
+	if err != nil {
+		utils.Fatalf("Failed to configure recording archive: %v", err)
+	}
+
+	if recoverer, ok := store.(service.RecordingUploadRecoverer); ok {
+		recovered, err := recoverer.RecoverUploads(context.Background())
+		if err != nil {
+			utils.Errorf("Failed to recover interrupted recording uploads: %v", err)
+		}
+		for _, key := range recovered {
+			utils.Warnf("Recovered interrupted recording upload %s", store.Location(key))
+		}
+	}
+	return store, archive
+}
+
 func generateRandomPassword(length int) (string, error) {
 	bytes := make([]byte, length)
 	if _, err := rand.Read(bytes); err != nil {
@@ -77,21 +125,192 @@ func runServer() {
 	accessRequestRepo := repository.NewAccessRequestRepository(db)
 	sessionRepo := repository.NewSessionRepository(db)
 	ephemeralCredentialRepo := repository.NewEphemeralCredentialRepository(db)
+	sessionCommandRepo := repository.NewSessionCommandRepository(db)
+	auditLogRepo := repository.NewAuditLogRepository(db)
+	auditCheckpointRepo := repository.NewAuditCheckpointRepository(db)
+	legalHoldRepo := repository.NewLegalHoldRepository(db)
+	sessionRecordingRepo := repository.NewSessionRecordingRepository(db)
+	securityAlertRepo := repository.NewSecurityAlertRepository(db)
+	incidentRepo := repository.NewIncidentRepository(db)
+	commandApprovalRepo := repository.NewCommandApprovalRepository(db)
+	notificationDeadLetterRepo := repository.NewNotificationDeadLetterRepository(db)
+
+	// Audit checkpoints, recording manifests and evidence bundles are all
+	// signed with the same key, kept across restarts
+	signingKey, trustedKeys, err := service.LoadSigningKey(cfg.Recording.SigningKeyPath, cfg.Recording.SigningKey)
+	if err != nil {
+		utils.Fatalf("Failed to load signing key: %v", err)
+	}
 
 	// Initialize services
-	userService := service.NewUserService(userRepo)
+	auditLogService := service.NewAuditLogService(auditLogRepo, auditCheckpointRepo, service.AuditLogOptions{
+		SigningKey:         signingKey,
+		TrustedKeys:        trustedKeys,
+		CheckpointInterval: cfg.Audit.CheckpointInterval,
+	})
+	userService := service.NewUserService(userRepo, auditLogService)
 	resourceService := service.NewResourceService(resourceRepo)
-	credentialService := service.NewCredentialService(credentialRepo)
+	credentialService := service.NewCredentialService(credentialRepo, auditLogService)
 	permissionService := service.NewPermissionService(permissionRepo)
-	accessRequestService := service.NewAccessRequestService(accessRequestRepo)
+	accessRequestService := service.NewAccessRequestService(accessRequestRepo, auditLogService)
 	sessionService := service.NewSessionService(sessionRepo)
 	ephemeralCredentialService := service.NewEphemeralCredentialService(ephemeralCredentialRepo)
 
 	// Initialize session monitoring services
-	sessionCommandService := service.NewSessionCommandService()
-	sessionRecordingService := service.NewSessionRecordingService()
-	securityAlertService := service.NewSecurityAlertService()
-	proxyService := service.NewProxyService(sessionCommandService, sessionRecordingService, securityAlertService)
+	sessionCommandService := service.NewSessionCommandService(sessionCommandRepo)
+	secretDetector := service.DefaultSecretDetector()
+	recordingStore, archiveStore := newRecordingStores(cfg.Recording)
+	sessionRecordingService := service.NewSessionRecordingService(sessionRecordingRepo, sessionService, service.RecordingOptions{
+		BasePath:       cfg.Recording.Path,
+		Format:         cfg.Recording.Format,
+		RecordInput:    cfg.Recording.RecordInput,
+		Width:          cfg.Recording.Width,
+		Height:         cfg.Recording.Height,
+		SecretDetector: secretDetector,
+		MasterKey:      cfg.Recording.MasterKey,
+		SigningKey:     signingKey,
+		TrustedKeys:    trustedKeys,
+		ArchivePath:    cfg.Recording.ArchivePath,
+		Store:          recordingStore,
+		ArchiveStore:   archiveStore,
+	})
+	// Recordings left without a record, such as by a crash, are picked up
+	if picked, err := sessionRecordingService.ReconcileRecordings(context.Background()); err != nil {
+		utils.Errorf("Failed to reconcile session recordings: %v", err)
+	} else if picked > 0 {
+		utils.Infof("Picked up %d orphaned session recordings", picked)
+	}
+	retentionPolicy := service.DefaultRetentionPolicy()
+	if cfg.Recording.RetentionPolicy != "" {
+		policy, err := service.LoadRetentionPolicy(cfg.Recording.RetentionPolicy)
+		if err != nil {
+			utils.Fatalf("Failed to load recording retention policy: %v", err)
+		}
+		retentionPolicy = *policy
+	}
+	recordingRetentionService := service.NewRecordingRetentionService(
+		sessionRecordingService,
+		sessionService,
+		resourceService,
+		legalHoldRepo,
+		auditLogService,
+		service.RetentionOptions{
+			Policy:   retentionPolicy,
+			Interval: cfg.Recording.RetentionInterval,
+		},
+	)
+	securityAlertService := service.NewSecurityAlertService(securityAlertRepo)
+	correlationPolicy := service.DefaultCorrelationPolicy()
+	if cfg.Incident.CorrelationPolicy != "" {
+		policy, err := service.LoadCorrelationPolicy(cfg.Incident.CorrelationPolicy)
+		if err != nil {
+			utils.Fatalf("Failed to load correlation policy: %v", err)
+		}
+		correlationPolicy = *policy
+	}
+	incidentService := service.NewIncidentService(incidentRepo, correlationPolicy)
+	securityAlertService.SetCorrelator(incidentService)
+	var notificationPolicy domain.NotificationPolicy
+	if cfg.Notification.Policy != "" {
+		policy, err := service.LoadNotificationPolicy(cfg.Notification.Policy)
+		if err != nil {
+			utils.Fatalf("Failed to load notification policy: %v", err)
+		}
+		notificationPolicy = *policy
+	}
+	notificationService := service.NewNotificationService(notificationDeadLetterRepo, service.NotificationOptions{
+		Policy:         notificationPolicy,
+		MaxAttempts:    cfg.Notification.MaxAttempts,
+		InitialBackoff: cfg.Notification.Backoff,
+	})
+	securityAlertService.AddIncidentObserver(notificationService)
+	commandApprovalService := service.NewCommandApprovalService(commandApprovalRepo, auditLogService)
+	holdPolicy := service.CommandHoldPolicy{
+		ResourceIDs: cfg.Proxy.HoldResources,
+		MinRisk:     cfg.Proxy.HoldMinRisk,
+		Timeout:     cfg.Proxy.ApprovalTimeout,
+	}
+	sessionRiskService := service.NewSessionRiskService(securityAlertService, service.SessionRiskPolicy{
+		HalfLife:           cfg.Risk.HalfLife,
+		AlertThreshold:     cfg.Risk.AlertThreshold,
+		NotifyThreshold:    cfg.Risk.NotifyThreshold,
+		InterruptThreshold: cfg.Risk.InterruptThreshold,
+	})
+	proxyService := service.NewProxyService(
+		sessionCommandService,
+		sessionRecordingService,
+		securityAlertService,
+		sessionService,
+		commandApprovalService,
+		sessionRiskService,
+		holdPolicy,
+		secretDetector,
+	)
+	proxyService.AddObserver(auditLogService)
+	sessionMonitorService := service.NewSessionMonitorService(
+		sessionService,
+		sessionCommandService,
+		securityAlertService,
+		sessionRiskService,
+		proxyService,
+	)
+	sessionRiskService.SetInterrupter(sessionMonitorService)
+	sessionRiskService.SetNotifier(notificationService)
+	sessionService.AddObserver(sessionRiskService)
+	policySimulationService := service.NewPolicySimulationService(sessionCommandService, holdPolicy)
+	anomalyDetectionService := service.NewAnomalyDetectionService(
+		sessionService,
+		sessionCommandService,
+		securityAlertService,
+		sessionRiskService,
+		service.AnomalyPolicy{
+			Window:          cfg.Anomaly.Window,
+			RefreshInterval: cfg.Anomaly.RefreshInterval,
+			MinSessions:     cfg.Anomaly.MinSessions,
+			Deviations:      cfg.Anomaly.Deviations,
+			Weight:          cfg.Anomaly.Weight,
+		},
+	)
+	sessionCommandService.AddObserver(anomalyDetectionService)
+	evidenceService := service.NewEvidenceService(
+		sessionService,
+		sessionCommandService,
+		securityAlertService,
+		commandApprovalService,
+		accessRequestService,
+		sessionRecordingService,
+		auditLogService,
+		service.EvidenceOptions{SigningKey: signingKey},
+	)
+
+	// Session, proxy, command, alert and audit events go out on the event bus
+	eventBus := service.NewEventBus()
+	sessionService.AddObserver(eventBus)
+	proxyService.AddObserver(eventBus)
+	sessionCommandService.AddObserver(eventBus)
+	securityAlertService.AddObserver(eventBus)
+	auditLogService.AddObserver(eventBus)
+
+	// Background workers stop when the server shuts down
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go anomalyDetectionService.Run(workerCtx)
+	go recordingRetentionService.Run(workerCtx)
+	go auditLogService.Run(workerCtx)
+	go notificationService.Run(workerCtx)
+	if cfg.Syslog.Addr != "" {
+		syslogExporter := service.NewSyslogExporter(eventBus, service.SyslogOptions{
+			Network:    cfg.Syslog.Network,
+			Addr:       cfg.Syslog.Addr,
+			Format:     cfg.Syslog.Format,
+			Facility:   cfg.Syslog.Facility,
+			TLSConfig:  cfg.Syslog.TLS,
+			BufferSize: cfg.Syslog.BufferSize,
+		})
+		go syslogExporter.Run(workerCtx)
+		utils.Infof("Forwarding events to syslog collector %s over %s", cfg.Syslog.Addr, cfg.Syslog.Network)
+	}
+	eventStreamService := service.NewEventStreamService(eventBus, service.DefaultEventStreamOptions())
 
 	// Create admin user in development mode
 	if *devMode {
@@ -130,7 +349,25 @@ func runServer() {
 	accessRequestHandler := handlers.NewAccessRequestHandler(accessRequestService)
 	sessionHandler := handlers.NewSessionHandler(sessionService)
 	ephemeralCredentialHandler := handlers.NewEphemeralCredentialHandler(ephemeralCredentialService)
-	sessionMonitorHandler := handlers.NewSessionMonitorHandler(sessionCommandService, sessionRecordingService, proxyService, securityAlertService)
+	sessionMonitorHandler := handlers.NewSessionMonitorHandler(
+		sessionCommandService,
+		sessionRecordingService,
+		proxyService,
+		securityAlertService,
+		sessionMonitorService,
+		sessionRiskService,
+		userService,
+		sessionService,
+	)
+	commandApprovalHandler := handlers.NewCommandApprovalHandler(commandApprovalService, userService)
+	policyHandler := handlers.NewPolicyHandler(policySimulationService, userService)
+	anomalyHandler := handlers.NewAnomalyHandler(anomalyDetectionService)
+	retentionHandler := handlers.NewRetentionHandler(recordingRetentionService, userService)
+	evidenceHandler := handlers.NewEvidenceHandler(evidenceService, sessionService, userService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService, userService)
+	incidentHandler := handlers.NewIncidentHandler(incidentService, securityAlertService, userService)
+	eventStreamHandler := handlers.NewEventStreamHandler(eventStreamService, userService)
+	auditLogHandler := handlers.NewAuditLogHandler(auditLogService, userService)
 
 	// Initialize router
 	router := handlers.NewRouter()
@@ -143,6 +380,15 @@ func runServer() {
 		sessionHandler,
 		ephemeralCredentialHandler,
 		sessionMonitorHandler,
+		commandApprovalHandler,
+		policyHandler,
+		anomalyHandler,
+		retentionHandler,
+		evidenceHandler,
+		notificationHandler,
+		incidentHandler,
+		eventStreamHandler,
+		auditLogHandler,
 	)
 
 	// Add middleware
@@ -207,6 +453,10 @@ func runServer() {
 func printUsage() {
 	fmt.Println("Usage:")
 	fmt.Println("  secretary server [--dev]  Start the server")
+	fmt.Println("  secretary policy ...      Simulate command policies (see secretary policy)")
+	fmt.Println("  secretary recording ...   Verify session recordings (see secretary recording)")
+	fmt.Println("  secretary evidence ...    Export and verify incident evidence bundles (see secretary evidence)")
+	fmt.Println("  secretary audit ...       Verify the audit log chain (see secretary audit)")
 	fmt.Println("\nOptions:")
 	fmt.Println("  --dev  Run in development mode with admin user")
 }
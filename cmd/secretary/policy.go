@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+	"secretary/alpha/internal/service"
+)
+
+func runPolicy() {
+	if len(os.Args) < 3 {
+		printPolicyUsage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[2] {
+	case "current":
+		err = runPolicyCurrent()
+	case "simulate":
+		err = runPolicySimulate(os.Args[3:])
+	case "replay":
+		err = runPolicyReplay(os.Args[3:])
+	default:
+		fmt.Printf("Unknown policy command: %q\n", os.Args[2])
+		printPolicyUsage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runPolicyCurrent() error {
+	simulator := service.NewPolicySimulationService(nil, service.CommandHoldPolicy{})
+	return printJSON(simulator.CurrentPolicy(context.Background()))
+}
+
+func runPolicySimulate(args []string) error {
+	simulateCmd := flag.NewFlagSet("policy simulate", flag.ExitOnError)
+	policyPath := simulateCmd.String("policy", "", "Candidate policy JSON file (default: current policy)")
+	commandsPath := simulateCmd.String("commands", "", "JSON file with an array of {command, command_type, resource_id}")
+	commandType := simulateCmd.String("type", "shell", "Command type of commands given as arguments")
+	resourceID := simulateCmd.String("resource", "", "Resource ID of commands given as arguments")
+	holdPolicy := holdPolicyFlags(simulateCmd)
+	simulateCmd.Parse(args)
+
+	policy, err := loadPolicy(*policyPath)
+	if err != nil {
+		return err
+	}
+
+	var commands []domain.PolicyCommand
+	if *commandsPath != "" {
+		if err := readJSONFile(*commandsPath, &commands); err != nil {
+			return err
+		}
+	}
+	for _, command := range simulateCmd.Args() {
+		commands = append(commands, domain.PolicyCommand{Command: command, CommandType: *commandType, ResourceID: *resourceID})
+	}
+
+	simulator := service.NewPolicySimulationService(nil, holdPolicy())
+	evaluations, err := simulator.Simulate(context.Background(), policy, commands)
+	if err != nil {
+		return err
+	}
+	return printJSON(evaluations)
+}
+
+func runPolicyReplay(args []string) error {
+	replayCmd := flag.NewFlagSet("policy replay", flag.ExitOnError)
+	policyPath := replayCmd.String("policy", "", "Candidate policy JSON file (required)")
+	historyPath := replayCmd.String("history", "", "JSON file with recorded session commands")
+	dbPath := replayCmd.String("db", "", "Read recorded session commands from this database instead of -history")
+	from := replayCmd.String("from", "", "Replay commands recorded at or after this RFC 3339 time")
+	to := replayCmd.String("to", "", "Replay commands recorded before this RFC 3339 time")
+	all := replayCmd.Bool("all", false, "Include commands whose outcome does not change")
+	holdPolicy := holdPolicyFlags(replayCmd)
+	replayCmd.Parse(args)
+
+	if *policyPath == "" || (*historyPath == "") == (*dbPath == "") {
+		return fmt.Errorf("-policy and one of -history or -db are required")
+	}
+
+	policy, err := loadPolicy(*policyPath)
+	if err != nil {
+		return err
+	}
+
+	fromTime, toTime := time.Time{}, time.Now()
+	if *from != "" {
+		if fromTime, err = time.Parse(time.RFC3339, *from); err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if *to != "" {
+		if toTime, err = time.Parse(time.RFC3339, *to); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+
+	commands, err := loadCommandHistory(*historyPath, *dbPath, fromTime, toTime)
+	if err != nil {
+		return err
+	}
+
+	simulator := service.NewPolicySimulationService(nil, holdPolicy())
+	report, err := simulator.Compare(context.Background(), policy, commands, *all)
+	if err != nil {
+		return err
+	}
+	report.From = fromTime
+	report.To = toTime
+	return printJSON(report)
+}
+
+// loadCommandHistory returns the commands recorded between from and to,
+// either from a JSON file or from the server's database.
+func loadCommandHistory(historyPath, dbPath string, from, to time.Time) ([]*domain.SessionCommand, error) {
+	if dbPath != "" {
+		if _, err := os.Stat(dbPath); err != nil {
+			return nil, err
+		}
+		db, err := repository.InitDB("sqlite3", dbPath)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		return service.NewSessionCommandService(repository.NewSessionCommandRepository(db)).GetCommandsBetween(context.Background(), from, to)
+	}
+
+	// Accept both a plain array and a page saved from the commands API
+	var raw json.RawMessage
+	if err := readJSONFile(historyPath, &raw); err != nil {
+		return nil, err
+	}
+	var history []*domain.SessionCommand
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		var page domain.CommandPage
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", historyPath, err)
+		}
+		history = page.Commands
+	} else if err := json.Unmarshal(raw, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", historyPath, err)
+	}
+
+	commands := make([]*domain.SessionCommand, 0, len(history))
+	for _, command := range history {
+		if !command.Timestamp.Before(from) && command.Timestamp.Before(to) {
+			commands = append(commands, command)
+		}
+	}
+	return commands, nil
+}
+
+// holdPolicyFlags registers the hold policy flags, defaulting to the
+// server's environment configuration.
+func holdPolicyFlags(flags *flag.FlagSet) func() service.CommandHoldPolicy {
+	resources := flags.String("hold-resources", os.Getenv("SECRETARY_HOLD_RESOURCES"), "Comma-separated resource IDs whose risky commands are held")
+	minRisk := flags.String("hold-min-risk", "high", "Lowest risk that is held")
+
+	return func() service.CommandHoldPolicy {
+		policy := service.CommandHoldPolicy{MinRisk: *minRisk}
+		for _, id := range strings.Split(*resources, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				policy.ResourceIDs = append(policy.ResourceIDs, id)
+			}
+		}
+		return policy
+	}
+}
+
+func loadPolicy(path string) (*domain.Policy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	var policy domain.Policy
+	if err := readJSONFile(path, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// readJSONFile decodes a JSON file into v. Files saved from the API are
+// accepted as well: their response envelope is unwrapped.
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var envelope struct {
+		Success *bool           `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(data, &envelope); err == nil && envelope.Success != nil {
+			data = envelope.Data
+		}
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func printPolicyUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  secretary policy current                                           Print the enforced policy")
+	fmt.Println("  secretary policy simulate [options] [command ...]                  Evaluate commands against a policy")
+	fmt.Println("  secretary policy replay -policy FILE -history FILE|-db FILE [...]  Diff recorded commands against a policy")
+	fmt.Println("\nRun a command with -h for its options.")
+}
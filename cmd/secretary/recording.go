@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/service"
+)
+
+func runRecording() {
+	if len(os.Args) < 3 {
+		printRecordingUsage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[2] {
+	case "verify":
+		err = runRecordingVerify(os.Args[3:])
+	default:
+		fmt.Printf("Unknown recording command: %q\n", os.Args[2])
+		printRecordingUsage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runRecordingVerify checks a recording file against its signed manifest,
+// exiting with status 2 if the recording does not match it.
+func runRecordingVerify(args []string) error {
+	verifyCmd := flag.NewFlagSet("recording verify", flag.ExitOnError)
+	filePath := verifyCmd.String("file", "", "Recording file as stored by the server (required)")
+	manifestPath := verifyCmd.String("manifest", "", "Manifest file, or one saved from the manifest API (default: FILE.manifest.json)")
+	publicKey := verifyCmd.String("public-key", "", "Base64 Ed25519 public key from GET /api/recordings/signing-key (default: the server's signing keys)")
+	verifyCmd.Parse(args)
+
+	if *filePath == "" {
+		return fmt.Errorf("-file is required")
+	}
+	if *manifestPath == "" {
+		*manifestPath = *filePath + ".manifest.json"
+	}
+
+	trustedKeys, err := trustedPublicKeys(*publicKey)
+	if err != nil {
+		return err
+	}
+
+	var manifest domain.RecordingManifest
+	if err := readJSONFile(*manifestPath, &manifest); err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	file, err := os.Open(*filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	verification, err := service.VerifyRecordingFile(file, &manifest, trustedKeys...)
+	if err != nil {
+		return err
+	}
+	if err := printJSON(verification); err != nil {
+		return err
+	}
+	if !verification.Valid {
+		os.Exit(2)
+	}
+	return nil
+}
+
+// trustedPublicKeys returns the keys manifests may be signed with: the one
+// given, or else the server's, from SECRETARY_RECORDING_SIGNING_KEY and the
+// keys listed next to SECRETARY_RECORDING_SIGNING_KEY_PATH
+func trustedPublicKeys(value string) ([]ed25519.PublicKey, error) {
+	if value != "" {
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("-public-key must be a 32-byte Ed25519 key, base64 encoded")
+		}
+		return []ed25519.PublicKey{key}, nil
+	}
+
+	var keys []ed25519.PublicKey
+	if value := os.Getenv("SECRETARY_RECORDING_SIGNING_KEY"); value != "" {
+		seed, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("SECRETARY_RECORDING_SIGNING_KEY must be a 32-byte seed, base64 encoded")
+		}
+		keys = append(keys, ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+	}
+	keyPath := os.Getenv("SECRETARY_RECORDING_SIGNING_KEY_PATH")
+	if keyPath == "" {
+		keyPath = "./data/signing.key"
+	}
+	trusted, err := service.LoadTrustedSigningKeys(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, trusted...)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("-public-key, SECRETARY_RECORDING_SIGNING_KEY or the server's %s.pub is required", keyPath)
+	}
+	return keys, nil
+}
+
+func printRecordingUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  secretary recording verify -file FILE [-manifest FILE] [-public-key KEY]  Check a recording against its signed manifest")
+	fmt.Println("\nRun a command with -h for its options.")
+}
@@ -12,6 +12,8 @@ require (
 
 require golang.org/x/crypto v0.39.0
 
+require github.com/klauspost/compress v1.18.0
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
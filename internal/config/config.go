@@ -1,9 +1,14 @@
 package config
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"secretary/alpha/pkg/utils"
@@ -11,9 +16,17 @@ import (
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Security SecurityConfig
+	Server       ServerConfig
+	Database     DatabaseConfig
+	Security     SecurityConfig
+	Proxy        ProxyConfig
+	Risk         RiskConfig
+	Anomaly      AnomalyConfig
+	Recording    RecordingConfig
+	Incident     IncidentConfig
+	Notification NotificationConfig
+	Syslog       SyslogConfig
+	Audit        AuditConfig
 }
 
 // ServerConfig holds server-specific configuration
@@ -39,6 +52,120 @@ type SecurityConfig struct {
 	JWTExpiration time.Duration
 }
 
+// ProxyConfig holds configuration for the session proxy
+type ProxyConfig struct {
+	// HoldResources lists the resource IDs whose risky commands are held
+	// for reviewer approval; "*" holds on every resource
+	HoldResources   []string
+	HoldMinRisk     string
+	ApprovalTimeout time.Duration
+}
+
+// RiskConfig holds configuration for cumulative session risk scoring. A
+// threshold of zero disables its action.
+type RiskConfig struct {
+	HalfLife           time.Duration
+	AlertThreshold     float64
+	NotifyThreshold    float64
+	InterruptThreshold float64
+}
+
+// AnomalyConfig holds configuration for behavioural anomaly detection
+type AnomalyConfig struct {
+	Window          time.Duration
+	RefreshInterval time.Duration
+	MinSessions     int
+	Deviations      float64
+	Weight          float64
+}
+
+// RecordingConfig holds configuration for session recordings
+type RecordingConfig struct {
+	// Store is "local" (files under Path) or "s3"
+	Store string
+	Path  string
+	S3    S3Config
+	// Format is "asciinema" or "text"
+	Format      string
+	RecordInput bool
+	Width       int
+	Height      int
+	// MasterKey wraps the keys recordings are encrypted with; none leaves
+	// recordings unencrypted
+	MasterKey []byte
+	// SigningKey signs recording manifests, audit checkpoints and evidence
+	// bundles; without one, a key is generated once and kept at
+	// SigningKeyPath. Every key signed with is listed in SigningKeyPath.pub.
+	SigningKey     ed25519.PrivateKey
+	SigningKeyPath string
+	// ArchivePath is where archived recordings are moved; empty uses the
+	// archive directory next to the recordings
+	ArchivePath string
+	// RetentionPolicy is a JSON file of retention rules; empty compresses
+	// recordings after a week and keeps them
+	RetentionPolicy   string
+	RetentionInterval time.Duration
+}
+
+// IncidentConfig holds configuration for alert correlation
+type IncidentConfig struct {
+	// CorrelationPolicy is a JSON file of correlation rules; empty groups
+	// alerts of the same type, user and resource less than 15 minutes apart
+	CorrelationPolicy string
+}
+
+// NotificationConfig holds configuration for alert notifications
+type NotificationConfig struct {
+	// Policy is a JSON file of notification channels and routes; empty
+	// sends no notifications
+	Policy string
+	// MaxAttempts is the number of times a delivery is tried before it is
+	// dead-lettered, waiting Backoff after the first failure and doubling
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// AuditConfig holds configuration for the audit log
+type AuditConfig struct {
+	// CheckpointInterval is how often the head of the audit log chain is
+	// signed with the recording signing key
+	CheckpointInterval time.Duration
+}
+
+// SyslogConfig holds the syslog collector events are forwarded to
+type SyslogConfig struct {
+	// Addr is the collector's host:port; empty forwards nothing
+	Addr string
+	// Network is "udp", "tcp" or "tls"
+	Network string
+	// Format is "cef" or "json"
+	Format   string
+	Facility int
+	// BufferSize is the number of messages held while the collector is
+	// unavailable
+	BufferSize int
+	// TLS verifies the collector against SECRETARY_SYSLOG_CA_FILE, or the
+	// system roots without one
+	TLS *tls.Config
+}
+
+// S3Config holds the S3-compatible bucket recordings are stored in.
+// Archived recordings go to ArchiveBucket under ArchivePrefix.
+type S3Config struct {
+	Endpoint      string
+	Region        string
+	Bucket        string
+	Prefix        string
+	ArchiveBucket string
+	ArchivePrefix string
+	AccessKey     string
+	SecretKey     string
+	// PartSize is the size of the parts recordings are uploaded in, in bytes
+	PartSize int
+	// SpoolPath is where recordings are spooled until their upload completes
+	SpoolPath string
+}
+
 // Load loads configuration from environment variables
 func Load() *Config {
 	// Security: Generate secure secrets if not provided
@@ -95,6 +222,144 @@ func Load() *Config {
 		utils.Fatalf("Invalid SECRETARY_JWT_EXPIRATION: %v", err)
 	}
 
+	approvalTimeout, err := time.ParseDuration(getEnv("SECRETARY_APPROVAL_TIMEOUT", "2m"))
+	if err != nil {
+		utils.Fatalf("Invalid SECRETARY_APPROVAL_TIMEOUT: %v", err)
+	}
+
+	holdMinRisk := getEnv("SECRETARY_HOLD_MIN_RISK", "high")
+	switch holdMinRisk {
+	case "low", "medium", "high", "critical":
+	default:
+		utils.Fatalf("Invalid SECRETARY_HOLD_MIN_RISK: %q", holdMinRisk)
+	}
+
+	riskHalfLife, err := time.ParseDuration(getEnv("SECRETARY_RISK_HALF_LIFE", "10m"))
+	if err != nil {
+		utils.Fatalf("Invalid SECRETARY_RISK_HALF_LIFE: %v", err)
+	}
+
+	riskAlertThreshold := getEnvFloat("SECRETARY_RISK_ALERT_THRESHOLD", 40)
+	riskNotifyThreshold := getEnvFloat("SECRETARY_RISK_NOTIFY_THRESHOLD", 70)
+	riskInterruptThreshold := getEnvFloat("SECRETARY_RISK_INTERRUPT_THRESHOLD", 100)
+
+	anomalyWindow, err := time.ParseDuration(getEnv("SECRETARY_ANOMALY_WINDOW", "720h"))
+	if err != nil {
+		utils.Fatalf("Invalid SECRETARY_ANOMALY_WINDOW: %v", err)
+	}
+
+	anomalyRefreshInterval, err := time.ParseDuration(getEnv("SECRETARY_ANOMALY_REFRESH_INTERVAL", "1h"))
+	if err != nil {
+		utils.Fatalf("Invalid SECRETARY_ANOMALY_REFRESH_INTERVAL: %v", err)
+	}
+
+	recordingFormat := getEnv("SECRETARY_RECORDING_FORMAT", "asciinema")
+	switch recordingFormat {
+	case "asciinema", "text":
+	default:
+		utils.Fatalf("Invalid SECRETARY_RECORDING_FORMAT: %q", recordingFormat)
+	}
+
+	recordInput, err := strconv.ParseBool(getEnv("SECRETARY_RECORDING_INPUT", "false"))
+	if err != nil {
+		utils.Fatalf("Invalid SECRETARY_RECORDING_INPUT: %v", err)
+	}
+
+	// Security: Recordings are encrypted with a base64 encoded 256-bit key
+	var recordingMasterKey []byte
+	if value := os.Getenv("SECRETARY_RECORDING_MASTER_KEY"); value != "" {
+		recordingMasterKey, err = base64.StdEncoding.DecodeString(value)
+		if err != nil || len(recordingMasterKey) != 32 {
+			utils.Fatalf("SECRETARY_RECORDING_MASTER_KEY must be 32 bytes, base64 encoded")
+		}
+	}
+
+	// Security: Recording manifests are signed with an Ed25519 key, given as
+	// its base64 encoded 32-byte seed
+	var recordingSigningKey ed25519.PrivateKey
+	if value := os.Getenv("SECRETARY_RECORDING_SIGNING_KEY"); value != "" {
+		seed, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			utils.Fatalf("SECRETARY_RECORDING_SIGNING_KEY must be a 32-byte seed, base64 encoded")
+		}
+		recordingSigningKey = ed25519.NewKeyFromSeed(seed)
+	}
+
+	recordingStore := getEnv("SECRETARY_RECORDING_STORE", "local")
+	var recordingS3 S3Config
+	switch recordingStore {
+	case "local":
+	case "s3":
+		recordingS3 = S3Config{
+			Endpoint:  os.Getenv("SECRETARY_RECORDING_S3_ENDPOINT"),
+			Region:    getEnv("SECRETARY_RECORDING_S3_REGION", "us-east-1"),
+			Bucket:    os.Getenv("SECRETARY_RECORDING_S3_BUCKET"),
+			Prefix:    os.Getenv("SECRETARY_RECORDING_S3_PREFIX"),
+			AccessKey: os.Getenv("SECRETARY_RECORDING_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("SECRETARY_RECORDING_S3_SECRET_KEY"),
+			PartSize:  getEnvInt("SECRETARY_RECORDING_S3_PART_SIZE_MB", 5) * 1024 * 1024,
+			SpoolPath: getEnv("SECRETARY_RECORDING_S3_SPOOL_PATH", "./data/recordings-spool"),
+		}
+		recordingS3.ArchiveBucket = getEnv("SECRETARY_RECORDING_S3_ARCHIVE_BUCKET", recordingS3.Bucket)
+		recordingS3.ArchivePrefix = getEnv("SECRETARY_RECORDING_S3_ARCHIVE_PREFIX", recordingS3.Prefix+"archive/")
+		if recordingS3.Endpoint == "" || recordingS3.Bucket == "" || recordingS3.AccessKey == "" || recordingS3.SecretKey == "" {
+			utils.Fatalf("SECRETARY_RECORDING_S3_ENDPOINT, _BUCKET, _ACCESS_KEY and _SECRET_KEY are required with SECRETARY_RECORDING_STORE=s3")
+		}
+		if recordingS3.PartSize < 5*1024*1024 {
+			utils.Fatalf("SECRETARY_RECORDING_S3_PART_SIZE_MB must be at least 5")
+		}
+	default:
+		utils.Fatalf("Invalid SECRETARY_RECORDING_STORE: %q", recordingStore)
+	}
+
+	retentionInterval, err := time.ParseDuration(getEnv("SECRETARY_RECORDING_RETENTION_INTERVAL", "1h"))
+	if err != nil || retentionInterval <= 0 {
+		utils.Fatalf("Invalid SECRETARY_RECORDING_RETENTION_INTERVAL: %q", os.Getenv("SECRETARY_RECORDING_RETENTION_INTERVAL"))
+	}
+
+	auditCheckpointInterval, err := time.ParseDuration(getEnv("SECRETARY_AUDIT_CHECKPOINT_INTERVAL", "1h"))
+	if err != nil || auditCheckpointInterval <= 0 {
+		utils.Fatalf("Invalid SECRETARY_AUDIT_CHECKPOINT_INTERVAL: %q", os.Getenv("SECRETARY_AUDIT_CHECKPOINT_INTERVAL"))
+	}
+
+	notificationBackoff, err := time.ParseDuration(getEnv("SECRETARY_NOTIFICATION_BACKOFF", "2s"))
+	if err != nil || notificationBackoff <= 0 {
+		utils.Fatalf("Invalid SECRETARY_NOTIFICATION_BACKOFF: %q", os.Getenv("SECRETARY_NOTIFICATION_BACKOFF"))
+	}
+
+	syslogNetwork := getEnv("SECRETARY_SYSLOG_NETWORK", "udp")
+	switch syslogNetwork {
+	case "udp", "tcp", "tls":
+	default:
+		utils.Fatalf("Invalid SECRETARY_SYSLOG_NETWORK: %q", syslogNetwork)
+	}
+	syslogFormat := getEnv("SECRETARY_SYSLOG_FORMAT", "cef")
+	switch syslogFormat {
+	case "cef", "json":
+	default:
+		utils.Fatalf("Invalid SECRETARY_SYSLOG_FORMAT: %q", syslogFormat)
+	}
+	syslogFacility := getEnvInt("SECRETARY_SYSLOG_FACILITY", 13)
+	if syslogFacility > 23 {
+		utils.Fatalf("SECRETARY_SYSLOG_FACILITY must be between 0 and 23")
+	}
+
+	// Security: The syslog collector is verified against a CA bundle if given
+	var syslogTLS *tls.Config
+	if syslogNetwork == "tls" {
+		syslogTLS = &tls.Config{MinVersion: tls.VersionTLS12}
+		if path := os.Getenv("SECRETARY_SYSLOG_CA_FILE"); path != "" {
+			pem, err := os.ReadFile(path)
+			if err != nil {
+				utils.Fatalf("Failed to read SECRETARY_SYSLOG_CA_FILE: %v", err)
+			}
+			syslogTLS.RootCAs = x509.NewCertPool()
+			if !syslogTLS.RootCAs.AppendCertsFromPEM(pem) {
+				utils.Fatalf("SECRETARY_SYSLOG_CA_FILE contains no certificates")
+			}
+		}
+	}
+
 	// Security: TLS configuration
 	tlsCertPath := os.Getenv("SECRETARY_TLS_CERT_PATH")
 	tlsKeyPath := os.Getenv("SECRETARY_TLS_KEY_PATH")
@@ -124,6 +389,59 @@ func Load() *Config {
 			JWTSecret:     secret,
 			JWTExpiration: jwtExpiration,
 		},
+		Proxy: ProxyConfig{
+			HoldResources:   splitList(os.Getenv("SECRETARY_HOLD_RESOURCES")),
+			HoldMinRisk:     holdMinRisk,
+			ApprovalTimeout: approvalTimeout,
+		},
+		Risk: RiskConfig{
+			HalfLife:           riskHalfLife,
+			AlertThreshold:     riskAlertThreshold,
+			NotifyThreshold:    riskNotifyThreshold,
+			InterruptThreshold: riskInterruptThreshold,
+		},
+		Anomaly: AnomalyConfig{
+			Window:          anomalyWindow,
+			RefreshInterval: anomalyRefreshInterval,
+			MinSessions:     getEnvInt("SECRETARY_ANOMALY_MIN_SESSIONS", 5),
+			Deviations:      getEnvFloat("SECRETARY_ANOMALY_DEVIATIONS", 3),
+			Weight:          getEnvFloat("SECRETARY_ANOMALY_WEIGHT", 15),
+		},
+		Recording: RecordingConfig{
+			Store:          recordingStore,
+			Path:           getEnv("SECRETARY_RECORDING_PATH", "./data/recordings"),
+			S3:             recordingS3,
+			Format:         recordingFormat,
+			RecordInput:    recordInput,
+			MasterKey:      recordingMasterKey,
+			SigningKey:     recordingSigningKey,
+			SigningKeyPath: getEnv("SECRETARY_RECORDING_SIGNING_KEY_PATH", "./data/signing.key"),
+			Width:          getEnvInt("SECRETARY_RECORDING_WIDTH", 80),
+			Height:         getEnvInt("SECRETARY_RECORDING_HEIGHT", 24),
+
+			ArchivePath:       os.Getenv("SECRETARY_RECORDING_ARCHIVE_PATH"),
+			RetentionPolicy:   os.Getenv("SECRETARY_RECORDING_RETENTION_POLICY"),
+			RetentionInterval: retentionInterval,
+		},
+		Incident: IncidentConfig{
+			CorrelationPolicy: os.Getenv("SECRETARY_CORRELATION_POLICY"),
+		},
+		Notification: NotificationConfig{
+			Policy:      os.Getenv("SECRETARY_NOTIFICATION_POLICY"),
+			MaxAttempts: getEnvInt("SECRETARY_NOTIFICATION_MAX_ATTEMPTS", 5),
+			Backoff:     notificationBackoff,
+		},
+		Syslog: SyslogConfig{
+			Addr:       os.Getenv("SECRETARY_SYSLOG_ADDR"),
+			Network:    syslogNetwork,
+			Format:     syslogFormat,
+			Facility:   syslogFacility,
+			BufferSize: getEnvInt("SECRETARY_SYSLOG_BUFFER_SIZE", 10000),
+			TLS:        syslogTLS,
+		},
+		Audit: AuditConfig{
+			CheckpointInterval: auditCheckpointInterval,
+		},
 	}
 }
 
@@ -134,3 +452,42 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvFloat gets a non-negative number from an environment variable or
+// returns a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		utils.Fatalf("Invalid %s: %q", key, value)
+	}
+	return number
+}
+
+// getEnvInt gets a non-negative integer from an environment variable or
+// returns a default value
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		utils.Fatalf("Invalid %s: %q", key, value)
+	}
+	return number
+}
+
+// splitList splits a comma-separated environment value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "context"
+
+type auditContextKey struct{}
+
+// AuditContext is who made an API request and from where. Audit entries
+// written while serving the request are attributed to it.
+type AuditContext struct {
+	UserID    string
+	IP        string
+	UserAgent string
+}
+
+// WithAuditContext returns a copy of ctx carrying audit
+func WithAuditContext(ctx context.Context, audit AuditContext) context.Context {
+	return context.WithValue(ctx, auditContextKey{}, audit)
+}
+
+// AuditContextFrom returns the audit context of ctx, or an empty one
+func AuditContextFrom(ctx context.Context) AuditContext {
+	audit, _ := ctx.Value(auditContextKey{}).(AuditContext)
+	return audit
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"io"
 	"time"
 )
 
@@ -97,6 +98,13 @@ type SessionService interface {
 	Update(ctx context.Context, session *Session) error
 	Terminate(ctx context.Context, id string) error
 	List(ctx context.Context) ([]*Session, error)
+	AddObserver(observer SessionObserver)
+}
+
+// SessionObserver is notified when a session starts and when it ends;
+// event is EventSessionStarted or EventSessionEnded
+type SessionObserver interface {
+	ObserveSession(ctx context.Context, event string, session *Session)
 }
 
 // SessionRepository defines the interface for session-related data operations
@@ -163,32 +171,187 @@ type SessionStore interface {
 
 // AuditLogService defines the interface for audit log operations
 type AuditLogService interface {
+	Create(ctx context.Context, log *AuditLog) error
 	List(ctx context.Context) ([]*AuditLog, error)
 	GetByID(ctx context.Context, id string) (*AuditLog, error)
 	GetByUserID(ctx context.Context, userID string) ([]*AuditLog, error)
 	GetByResourceID(ctx context.Context, resourceID string) ([]*AuditLog, error)
 	GetByAction(ctx context.Context, action string) ([]*AuditLog, error)
 	GetByDateRange(ctx context.Context, startDate, endDate time.Time) ([]*AuditLog, error)
+	GetBySession(ctx context.Context, sessionID, userID string, start, end time.Time) ([]*AuditLog, error)
+	AddObserver(observer AuditLogObserver)
+	// ProxyObserver records proxies starting and stopping
+	ProxyObserver
+	// Run signs a checkpoint of the chain periodically until ctx is done
+	Run(ctx context.Context)
+	Checkpoint(ctx context.Context) (*AuditCheckpoint, error)
+	ListCheckpoints(ctx context.Context) ([]*AuditCheckpoint, error)
+	Verify(ctx context.Context) (*AuditChainVerification, error)
+	SigningPublicKey() []byte
+}
+
+// AuditLogObserver is notified of every audit log entry once it is stored
+type AuditLogObserver interface {
+	ObserveAuditLog(ctx context.Context, log *AuditLog)
+}
+
+// AuditLogRepository defines the interface for audit log data operations
+type AuditLogRepository interface {
+	Create(log *AuditLog) error
+	FindByID(id string) (*AuditLog, error)
+	FindAll() ([]*AuditLog, error)
+	FindByUserID(userID string) ([]*AuditLog, error)
+	FindByResourceID(resourceID string) ([]*AuditLog, error)
+	FindByAction(action string) ([]*AuditLog, error)
+	FindByDateRange(startDate, endDate time.Time) ([]*AuditLog, error)
+	// FindBySession returns the entries of userID from start to end and
+	// every entry whose details mention sessionID, oldest first
+	FindBySession(sessionID, userID string, start, end time.Time) ([]*AuditLog, error)
+	// FindLast returns the entry at the head of the chain, or nil if there
+	// is none
+	FindLast() (*AuditLog, error)
+	// FindChain returns up to limit chained entries after the given
+	// sequence, in sequence order
+	FindChain(afterSequence int64, limit int) ([]*AuditLog, error)
+	CountUnchained() (int64, error)
+}
+
+// AuditCheckpointRepository defines the interface for audit checkpoint data operations
+type AuditCheckpointRepository interface {
+	Create(checkpoint *AuditCheckpoint) error
+	FindAll() ([]*AuditCheckpoint, error)
+	// FindLast returns the checkpoint of the highest sequence, or nil if
+	// there is none
+	FindLast() (*AuditCheckpoint, error)
 }
 
 // SessionCommandService defines the interface for session command operations
 type SessionCommandService interface {
 	RecordCommand(ctx context.Context, command *SessionCommand) error
+	UpdateCommand(ctx context.Context, command *SessionCommand) error
 	GetSessionCommands(ctx context.Context, sessionID string) ([]*SessionCommand, error)
 	GetCommandsByUser(ctx context.Context, userID string) ([]*SessionCommand, error)
 	GetCommandsByResource(ctx context.Context, resourceID string) ([]*SessionCommand, error)
 	GetHighRiskCommands(ctx context.Context) ([]*SessionCommand, error)
+	GetCommandsBetween(ctx context.Context, from, to time.Time) ([]*SessionCommand, error)
+	SearchCommands(ctx context.Context, filter CommandFilter) (*CommandPage, error)
 	AnalyzeCommand(ctx context.Context, command string, commandType string) (risk string, shouldBlock bool, err error)
+	AddObserver(observer CommandObserver)
+}
+
+// CommandObserver is notified of every command recorded in a session
+type CommandObserver interface {
+	ObserveCommand(ctx context.Context, command *SessionCommand)
+}
+
+// SessionCommandRepository defines the interface for session command data
+// operations
+type SessionCommandRepository interface {
+	Create(command *SessionCommand) error
+	Update(command *SessionCommand) error
+	FindByID(id string) (*SessionCommand, error)
+	Search(filter CommandFilter) ([]*SessionCommand, int, error)
+}
+
+// CommandApprovalService defines the interface for holding commands until a
+// reviewer approves them
+type CommandApprovalService interface {
+	RequestApproval(ctx context.Context, approval *CommandApproval, timeout time.Duration) error
+	WaitForDecision(ctx context.Context, id string) (*CommandApproval, error)
+	Approve(ctx context.Context, id string, reviewerID string, notes string) error
+	Deny(ctx context.Context, id string, reviewerID string, notes string) error
+	GetApproval(ctx context.Context, id string) (*CommandApproval, error)
+	ListPending(ctx context.Context) ([]*CommandApproval, error)
+	ListBySession(ctx context.Context, sessionID string) ([]*CommandApproval, error)
+}
+
+// CommandApprovalRepository defines the interface for command approval data operations
+type CommandApprovalRepository interface {
+	Create(approval *CommandApproval) error
+	Update(approval *CommandApproval) error
+	FindByID(id string) (*CommandApproval, error)
+	// FindPending returns the approvals still pending at now, oldest first
+	FindPending(now time.Time) ([]*CommandApproval, error)
+	FindBySessionID(sessionID string) ([]*CommandApproval, error)
 }
 
 // SessionRecordingService defines the interface for session recording operations
 type SessionRecordingService interface {
-	StartRecording(ctx context.Context, sessionID string) (*SessionRecording, error)
+	StartRecording(ctx context.Context, sessionID string, protocol string) (*SessionRecording, error)
 	StopRecording(ctx context.Context, sessionID string) error
+	WriteOutput(ctx context.Context, sessionID string, data []byte) error
+	WriteInput(ctx context.Context, sessionID string, data []byte) error
+	Resize(ctx context.Context, sessionID string, width, height int) error
+	RecordCommand(ctx context.Context, command *SessionCommand) error
+	RecordQuery(ctx context.Context, sessionID string, entry *QueryLogEntry) error
 	GetRecording(ctx context.Context, sessionID string) (*SessionRecording, error)
+	GetRecordingByID(ctx context.Context, recordingID string) (*SessionRecording, error)
+	// IsRecording reports whether a recording is still being written
+	IsRecording(ctx context.Context, recordingID string) bool
 	GetRecordingFile(ctx context.Context, recordingID string) ([]byte, error)
+	OpenRecordingFile(ctx context.Context, recordingID string) (io.ReadCloser, error)
+	PlayRecording(ctx context.Context, recordingID string, options PlaybackOptions, emit func(*PlaybackFrame) error) error
+	GetQueryLog(ctx context.Context, recordingID string) ([]*QueryLogEntry, error)
+	GetRecordingManifest(ctx context.Context, recordingID string) (*RecordingManifest, error)
+	VerifyRecording(ctx context.Context, recordingID string) (*RecordingVerification, error)
+	SigningPublicKey() []byte
+	CompressRecording(ctx context.Context, recordingID string, codec string) error
+	ArchiveRecording(ctx context.Context, recordingID string) error
 	DeleteRecording(ctx context.Context, recordingID string) error
-	ListRecordings(ctx context.Context, userID string) ([]*SessionRecording, error)
+	ListRecordings(ctx context.Context, filter RecordingFilter) ([]*SessionRecording, error)
+	ReconcileRecordings(ctx context.Context) (int, error)
+}
+
+// SessionRecordingRepository defines the interface for session recording
+// data operations
+type SessionRecordingRepository interface {
+	Create(recording *SessionRecording) error
+	Update(recording *SessionRecording) error
+	Delete(id string) error
+	FindByID(id string) (*SessionRecording, error)
+	FindLatestBySessionID(sessionID string) (*SessionRecording, error)
+	List(filter RecordingFilter) ([]*SessionRecording, error)
+}
+
+// RecordingStore keeps recording files and their manifests under
+// slash-separated keys. Objects are written as a stream and are complete
+// once the writer is closed. Deleting a missing object is not an error.
+type RecordingStore interface {
+	Create(ctx context.Context, key string) (io.WriteCloser, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Put(ctx context.Context, key string, data []byte) error
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose keys start with prefix
+	List(ctx context.Context, prefix string) ([]RecordingObject, error)
+	// Location describes where an object is kept, such as its path
+	Location(key string) string
+}
+
+// RecordingRetentionService enforces how long session recordings are kept
+type RecordingRetentionService interface {
+	Run(ctx context.Context)
+	Enforce(ctx context.Context) (*RetentionReport, error)
+	GetPolicy(ctx context.Context) *RetentionPolicy
+	PlaceLegalHold(ctx context.Context, hold *LegalHold) error
+	ReleaseLegalHold(ctx context.Context, id string, userID string) error
+	ListLegalHolds(ctx context.Context) ([]*LegalHold, error)
+}
+
+// LegalHoldRepository defines the interface for legal hold data operations
+type LegalHoldRepository interface {
+	Create(hold *LegalHold) error
+	Delete(id string) error
+	FindByID(id string) (*LegalHold, error)
+	FindBySessionID(sessionID string) ([]*LegalHold, error)
+	FindAll() ([]*LegalHold, error)
+}
+
+// EvidenceService collects what is known about sessions into signed
+// evidence bundles for incident response
+type EvidenceService interface {
+	// ExportBundle writes a bundle of the sessions to w as a zip archive
+	ExportBundle(ctx context.Context, sessionIDs []string, exportedBy string, w io.Writer) (*EvidenceManifest, error)
+	SigningPublicKey() []byte
 }
 
 // ProxyService defines the interface for proxy operations
@@ -199,6 +362,13 @@ type ProxyService interface {
 	GetActiveProxies(ctx context.Context) ([]*ProxyConnection, error)
 	GetProxyBySession(ctx context.Context, sessionID string) (*ProxyConnection, error)
 	UpdateProxyStats(ctx context.Context, proxyID string, bytesIn, bytesOut int64) error
+	AddObserver(observer ProxyObserver)
+}
+
+// ProxyObserver is notified when a proxy starts listening and when it stops;
+// event is EventProxyStarted or EventProxyStopped
+type ProxyObserver interface {
+	ObserveProxy(ctx context.Context, event string, proxy *ProxyConnection)
 }
 
 // SecurityAlertService defines the interface for security alert operations
@@ -207,7 +377,114 @@ type SecurityAlertService interface {
 	GetAlerts(ctx context.Context, sessionID string) ([]*SecurityAlert, error)
 	GetAlertsByUser(ctx context.Context, userID string) ([]*SecurityAlert, error)
 	GetAlertsBySeverity(ctx context.Context, severity string) ([]*SecurityAlert, error)
-	MarkAlertAsReviewed(ctx context.Context, alertID string) error
+	GetAlert(ctx context.Context, alertID string) (*SecurityAlert, error)
+	SearchAlerts(ctx context.Context, filter AlertFilter) (*AlertPage, error)
+	// UpdateAlertStatus moves an alert along its triage lifecycle
+	UpdateAlertStatus(ctx context.Context, alertID string, status string, reviewerID string, notes string) (*SecurityAlert, error)
+	AssignAlert(ctx context.Context, alertID string, assigneeID string, reviewerID string) (*SecurityAlert, error)
+	AddObserver(observer AlertObserver)
+	// SetCorrelator makes every new alert part of an incident
+	SetCorrelator(correlator AlertCorrelator)
+	AddIncidentObserver(observer IncidentObserver)
+}
+
+// AlertObserver is notified of every security alert once it is stored
+type AlertObserver interface {
+	ObserveAlert(ctx context.Context, alert *SecurityAlert)
+}
+
+// AlertCorrelator assigns alerts to incidents
+type AlertCorrelator interface {
+	// Correlate adds the alert to the incident it belongs to, opening one
+	// if there is none, and reports whether it opened one
+	Correlate(ctx context.Context, alert *SecurityAlert) (*Incident, bool, error)
+}
+
+// IncidentObserver is notified when an alert opens an incident, once the
+// alert is stored
+type IncidentObserver interface {
+	ObserveIncident(ctx context.Context, incident *Incident, alert *SecurityAlert)
+}
+
+// IncidentService correlates alerts into incidents and manages them
+type IncidentService interface {
+	AlertCorrelator
+	GetIncident(ctx context.Context, id string) (*Incident, error)
+	SearchIncidents(ctx context.Context, filter IncidentFilter) (*IncidentPage, error)
+	UpdateIncidentStatus(ctx context.Context, id string, status string, userID string) (*Incident, error)
+	GetPolicy(ctx context.Context) *CorrelationPolicy
+}
+
+// IncidentRepository defines the interface for incident data operations
+type IncidentRepository interface {
+	Create(incident *Incident) error
+	Update(incident *Incident) error
+	FindByID(id string) (*Incident, error)
+	// FindOpenByKey returns the most recently active open incident with key
+	FindOpenByKey(key string) (*Incident, error)
+	Search(filter IncidentFilter) ([]*Incident, int, error)
+}
+
+// NotificationService sends the alert that opens each incident to the
+// channels its routes name, retrying failed deliveries and keeping those
+// that never succeed in a dead-letter log
+type NotificationService interface {
+	IncidentObserver
+	AlertNotifier
+	Run(ctx context.Context)
+	GetPolicy(ctx context.Context) *NotificationPolicy
+	// SendTest delivers a test alert to a channel once, without retrying
+	SendTest(ctx context.Context, channel string) error
+	ListDeadLetters(ctx context.Context) ([]*NotificationDeadLetter, error)
+	GetDeadLetter(ctx context.Context, id string) (*NotificationDeadLetter, error)
+	RetryDeadLetter(ctx context.Context, id string) error
+	DeleteDeadLetter(ctx context.Context, id string) error
+}
+
+// EventBus fans session, proxy, command, alert and audit events out to its
+// subscribers. It observes the services that raise them.
+type EventBus interface {
+	SessionObserver
+	ProxyObserver
+	CommandObserver
+	AlertObserver
+	AuditLogObserver
+	Publish(ctx context.Context, event *Event)
+	// Subscribe returns a channel of the events published from now on,
+	// holding up to buffer of them, and a function that ends the
+	// subscription. Events are dropped when a subscriber falls behind.
+	Subscribe(buffer int) (<-chan *Event, func())
+}
+
+// EventStreamService streams live session, proxy, high-risk command and
+// alert events to API clients
+type EventStreamService interface {
+	// Subscribe returns the events matching filter from now on. The
+	// channel is closed once ctx is done.
+	Subscribe(ctx context.Context, filter EventFilter) (<-chan *Event, error)
+}
+
+// SyslogExporter forwards events from the event bus to a syslog collector
+type SyslogExporter interface {
+	Run(ctx context.Context)
+}
+
+// NotificationDeadLetterRepository defines the interface for dead-lettered
+// notification data operations
+type NotificationDeadLetterRepository interface {
+	Create(letter *NotificationDeadLetter) error
+	FindByID(id string) (*NotificationDeadLetter, error)
+	FindAll() ([]*NotificationDeadLetter, error)
+	Delete(id string) error
+}
+
+// SecurityAlertRepository defines the interface for security alert data
+// operations
+type SecurityAlertRepository interface {
+	Create(alert *SecurityAlert) error
+	Update(alert *SecurityAlert) error
+	FindByID(id string) (*SecurityAlert, error)
+	Search(filter AlertFilter) ([]*SecurityAlert, int, error)
 }
 
 // SessionMonitorService defines the interface for real-time session monitoring
@@ -218,3 +495,48 @@ type SessionMonitorService interface {
 	InterruptSession(ctx context.Context, sessionID string, reason string) error
 	GetSessionMetrics(ctx context.Context, sessionID string) (map[string]interface{}, error)
 }
+
+// SessionInterrupter interrupts a live session
+type SessionInterrupter interface {
+	InterruptSession(ctx context.Context, sessionID string, reason string) error
+}
+
+// AlertNotifier sends an alert to the notification channels of the routes
+// it matches
+type AlertNotifier interface {
+	NotifyAlert(ctx context.Context, alert *SecurityAlert) error
+}
+
+// SessionRiskService defines the interface for cumulative session risk
+// scoring
+type SessionRiskService interface {
+	SessionObserver
+	RecordCommand(ctx context.Context, command *SessionCommand) (*SessionRiskScore, error)
+	RecordAlert(ctx context.Context, alert *SecurityAlert) (*SessionRiskScore, error)
+	RecordAnomaly(ctx context.Context, sessionID string, weight float64, reason string) (*SessionRiskScore, error)
+	GetScore(ctx context.Context, sessionID string) (*SessionRiskScore, error)
+	ListScores(ctx context.Context) ([]*SessionRiskScore, error)
+	ResetScore(ctx context.Context, sessionID string) error
+	SetInterrupter(interrupter SessionInterrupter)
+	SetNotifier(notifier AlertNotifier)
+}
+
+// AnomalyDetectionService defines the interface for learning user
+// behaviour baselines and flagging sessions that deviate from them
+type AnomalyDetectionService interface {
+	CommandObserver
+	Run(ctx context.Context)
+	RefreshBaselines(ctx context.Context) error
+	GetBaseline(ctx context.Context, userID, resourceID string) (*BehaviorBaseline, error)
+	ListBaselines(ctx context.Context, userID string) ([]*BehaviorBaseline, error)
+	Analyze(ctx context.Context, command *SessionCommand) ([]*SecurityAlert, error)
+}
+
+// PolicySimulationService defines the interface for evaluating candidate
+// command policies without enforcing them
+type PolicySimulationService interface {
+	CurrentPolicy(ctx context.Context) *Policy
+	Simulate(ctx context.Context, policy *Policy, commands []PolicyCommand) ([]*PolicyEvaluation, error)
+	Replay(ctx context.Context, policy *Policy, from, to time.Time, includeUnchanged bool) (*PolicyReplayReport, error)
+	Compare(ctx context.Context, policy *Policy, commands []*SessionCommand, includeUnchanged bool) (*PolicyReplayReport, error)
+}
@@ -22,6 +22,7 @@ type Resource struct {
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
 	Type        string    `json:"type"`
+	Sensitivity string    `json:"sensitivity,omitempty"` // e.g. "low", "medium", "high", "critical"
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -98,7 +99,10 @@ type EphemeralCredential struct {
 	Used       bool      `json:"used"`
 }
 
-// AuditLog represents an audit log entry
+// AuditLog represents an audit log entry. Entries form a hash chain in
+// Sequence order: Hash covers the entry together with PrevHash, the hash of
+// the entry before it. Entries written before the chain was introduced have
+// no sequence.
 type AuditLog struct {
 	ID         string    `json:"id"`
 	UserID     string    `json:"user_id"`
@@ -108,6 +112,42 @@ type AuditLog struct {
 	IP         string    `json:"ip"`
 	UserAgent  string    `json:"user_agent"`
 	CreatedAt  time.Time `json:"created_at"`
+	Sequence   int64     `json:"sequence,omitempty"`
+	PrevHash   string    `json:"prev_hash,omitempty"` // SHA-256, hex encoded; empty for the first entry
+	Hash       string    `json:"hash,omitempty"`
+}
+
+// AuditCheckpoint anchors the audit log chain at the entry with Sequence.
+// Signature is the server's Ed25519 signature of the checkpoint without
+// it, so the entries up to it cannot be rewritten without the signing key.
+type AuditCheckpoint struct {
+	ID        string    `json:"id"`
+	Sequence  int64     `json:"sequence"`
+	Hash      string    `json:"hash"`       // Hash of the entry at Sequence
+	PublicKey string    `json:"public_key"` // Base64 Ed25519 public key of the signer
+	Signature string    `json:"signature,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditChainVerification is the outcome of walking the audit log chain and
+// checking it against the signed checkpoints. Break is the first entry or
+// checkpoint found not to match, if any.
+type AuditChainVerification struct {
+	Valid          bool             `json:"valid"`
+	Entries        int64            `json:"entries"`   // Chained entries checked
+	Unchained      int64            `json:"unchained"` // Entries written before the chain was introduced
+	Checkpoints    int              `json:"checkpoints"`
+	LastCheckpoint *AuditCheckpoint `json:"last_checkpoint,omitempty"`
+	Break          *AuditChainBreak `json:"break,omitempty"`
+	VerifiedAt     time.Time        `json:"verified_at"`
+}
+
+// AuditChainBreak is where the audit log chain stops matching
+type AuditChainBreak struct {
+	Sequence     int64  `json:"sequence"`
+	EntryID      string `json:"entry_id,omitempty"`
+	CheckpointID string `json:"checkpoint_id,omitempty"`
+	Reason       string `json:"reason"`
 }
 
 // SessionCommand represents a command executed during a session
@@ -119,11 +159,111 @@ type SessionCommand struct {
 	Command     string    `json:"command"`
 	CommandType string    `json:"command_type"` // "sql", "ssh", "shell", etc.
 	Response    string    `json:"response,omitempty"`
-	Status      string    `json:"status"` // "executed", "blocked", "failed"
+	Status      string    `json:"status"` // "executed", "blocked", "failed", "pending_approval", "denied", "expired"
 	Risk        string    `json:"risk"`   // "low", "medium", "high", "critical"
 	Timestamp   time.Time `json:"timestamp"`
-	Duration    int64     `json:"duration_ms"` // Command execution time in milliseconds
+	Duration    int64     `json:"duration_ms"` // Time from forwarding the command to the server's complete reply, in milliseconds
 	CreatedAt   time.Time `json:"created_at"`
+
+	// Server reply
+	RowsAffected      int64 `json:"rows_affected"`                // Rows changed or returned
+	ResponseTruncated bool  `json:"response_truncated,omitempty"` // Response holds only the start of the reply
+
+	// Decision trail
+	Action         string    `json:"action,omitempty"`          // "allowed", "blocked", "held"
+	DecisionReason string    `json:"decision_reason,omitempty"` // Why the command was blocked, approved or denied
+	ApprovalID     string    `json:"approval_id,omitempty"`
+	ApprovalStatus string    `json:"approval_status,omitempty"` // "pending", "approved", "denied", "expired", "cancelled"
+	ReviewerID     string    `json:"reviewer_id,omitempty"`
+	DecidedAt      time.Time `json:"decided_at,omitempty"`
+}
+
+// CommandFilter selects session commands from the command history. Empty
+// fields match everything; Query is a full-text search over the command text.
+type CommandFilter struct {
+	SessionID   string    `json:"session_id,omitempty"`
+	UserID      string    `json:"user_id,omitempty"`
+	ResourceID  string    `json:"resource_id,omitempty"`
+	CommandType string    `json:"command_type,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	Risks       []string  `json:"risks,omitempty"`
+	From        time.Time `json:"from,omitempty"` // Inclusive
+	To          time.Time `json:"to,omitempty"`   // Exclusive
+	Query       string    `json:"query,omitempty"`
+	Descending  bool      `json:"descending,omitempty"` // Newest first
+	Limit       int       `json:"limit,omitempty"`      // Zero means no limit
+	Offset      int       `json:"offset,omitempty"`
+}
+
+// CommandPage is one page of a command history search
+type CommandPage struct {
+	Commands []*SessionCommand `json:"commands"`
+	Total    int               `json:"total"`
+	Limit    int               `json:"limit"`
+	Offset   int               `json:"offset"`
+}
+
+// CommandApproval represents a command held by the proxy until a reviewer
+// approves or denies it
+type CommandApproval struct {
+	ID          string    `json:"id"`
+	SessionID   string    `json:"session_id"`
+	CommandID   string    `json:"command_id"`
+	UserID      string    `json:"user_id"`
+	ResourceID  string    `json:"resource_id"`
+	Command     string    `json:"command"`
+	CommandType string    `json:"command_type"`
+	Risk        string    `json:"risk"`
+	Status      string    `json:"status"` // "pending", "approved", "denied", "expired", "cancelled"
+	ReviewerID  string    `json:"reviewer_id,omitempty"`
+	ReviewNotes string    `json:"review_notes,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+	ReviewedAt  time.Time `json:"reviewed_at,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// SessionRiskScore is the cumulative, time-decayed risk of a session. It
+// grows with every risky command, alert and anomaly in the session and halves
+// every half-life without new events.
+type SessionRiskScore struct {
+	SessionID    string    `json:"session_id"`
+	UserID       string    `json:"user_id"`
+	ResourceID   string    `json:"resource_id"`
+	Score        float64   `json:"score"`
+	PeakScore    float64   `json:"peak_score"`
+	Level        string    `json:"level"` // "normal", "alert", "notify", "interrupt"
+	CommandCount int       `json:"command_count"`
+	AlertCount   int       `json:"alert_count"`
+	AnomalyCount int       `json:"anomaly_count"`
+	Interrupted  bool      `json:"interrupted"`
+	LastEventAt  time.Time `json:"last_event_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// BehaviorBaseline is the usual behaviour of a user, across all resources
+// or on one resource, learned from the stored sessions and commands of the
+// baseline window.
+type BehaviorBaseline struct {
+	UserID       string         `json:"user_id"`
+	ResourceID   string         `json:"resource_id,omitempty"` // Empty for the user's overall baseline
+	SessionCount int            `json:"session_count"`
+	CommandCount int            `json:"command_count"`
+	Hours        [24]int        `json:"hours"`               // Commands per hour of the day (UTC)
+	Resources    map[string]int `json:"resources,omitempty"` // Sessions per resource
+	CommandTypes map[string]int `json:"command_types"`       // Commands per command type
+	// SessionMinutes is the length of finished sessions and CommandRate the
+	// most commands each session sent within one minute
+	SessionMinutes BaselineStat `json:"session_minutes"`
+	CommandRate    BaselineStat `json:"command_rate"`
+	From           time.Time    `json:"from"`
+	To             time.Time    `json:"to"`
+}
+
+// BaselineStat summarizes one measurement over the sessions of a baseline
+type BaselineStat struct {
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"std_dev"`
+	Samples int     `json:"samples"`
 }
 
 // SessionRecording represents a complete session recording
@@ -132,12 +272,211 @@ type SessionRecording struct {
 	SessionID     string    `json:"session_id"`
 	UserID        string    `json:"user_id"`
 	ResourceID    string    `json:"resource_id"`
-	RecordingPath string    `json:"recording_path"` // Path to the recording file
-	Format        string    `json:"format"`         // "asciinema", "text", "binary"
+	RecordingPath string    `json:"recording_path"` // Where the recording file is stored
+	StorageKey    string    `json:"storage_key"`    // Key of the recording file in its tier's store
+	Format        string    `json:"format"`         // "asciinema", "text" or "jsonl"
 	Size          int64     `json:"size"`           // File size in bytes
-	Duration      int64     `json:"duration"`       // Session duration in seconds
+	Duration      int64     `json:"duration"`       // Seconds from the start to the latest recorded data
 	CommandCount  int       `json:"command_count"`  // Total commands executed
 	CreatedAt     time.Time `json:"created_at"`
+	// ManifestPath is the signed manifest written when the recording stops
+	ManifestPath string                 `json:"manifest_path,omitempty"`
+	Verification *RecordingVerification `json:"verification,omitempty"` // Result of the latest verification
+	Compressed   bool                   `json:"compressed"`
+	Tier         string                 `json:"tier"` // "hot" or "archive"
+}
+
+// RecordingFilter selects session recordings. Empty fields match every
+// recording; From is inclusive and To exclusive, on CreatedAt. MaxSize of
+// zero means no upper limit.
+type RecordingFilter struct {
+	SessionID  string    `json:"session_id,omitempty"`
+	UserID     string    `json:"user_id,omitempty"`
+	ResourceID string    `json:"resource_id,omitempty"`
+	From       time.Time `json:"from,omitempty"`
+	To         time.Time `json:"to,omitempty"`
+	MinSize    int64     `json:"min_size,omitempty"`
+	MaxSize    int64     `json:"max_size,omitempty"`
+	Limit      int       `json:"limit,omitempty"`
+	Offset     int       `json:"offset,omitempty"`
+}
+
+// RecordingObject is an object found in a recording store
+type RecordingObject struct {
+	Key        string    `json:"key"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// Recording storage tiers
+const (
+	RecordingTierHot     = "hot"
+	RecordingTierArchive = "archive"
+)
+
+// RecordingManifest describes a finished recording file so that it can be
+// shown not to have been changed since. The file is hashed in chunks of
+// ChunkSize bytes; ChainHash links the chunk hashes in order, and Signature
+// is the server's Ed25519 signature of the manifest without it.
+type RecordingManifest struct {
+	Version     int       `json:"version"`
+	RecordingID string    `json:"recording_id"`
+	SessionID   string    `json:"session_id"`
+	Format      string    `json:"format"`
+	Encrypted   bool      `json:"encrypted"`
+	CreatedAt   time.Time `json:"created_at"`
+	StoppedAt   time.Time `json:"stopped_at"`
+	Size        int64     `json:"size"`
+	ChunkSize   int       `json:"chunk_size"`
+	Chunks      []string  `json:"chunks"`     // SHA-256 of each chunk, hex encoded
+	ChainHash   string    `json:"chain_hash"` // Last link of the hash chain over the chunks
+	PublicKey   string    `json:"public_key"` // Base64 Ed25519 public key of the signer
+	Signature   string    `json:"signature,omitempty"`
+	Compressed  bool      `json:"compressed,omitempty"`
+	Codec       string    `json:"codec,omitempty"` // "gzip" or "zstd" when compressed
+	// Previous is the SHA-256 of the manifest this one replaced when the file
+	// was rewritten, hex encoded
+	Previous string `json:"previous,omitempty"`
+}
+
+// Recording verification statuses
+const (
+	RecordingVerified         = "verified"
+	RecordingModified         = "modified"
+	RecordingTruncated        = "truncated"
+	RecordingExtended         = "extended"
+	RecordingReordered        = "reordered"
+	RecordingInvalidSignature = "invalid_signature"
+	RecordingUntrustedKey     = "untrusted_key"
+	RecordingUnsigned         = "unsigned"
+)
+
+// RecordingVerification is the outcome of checking a recording file against
+// its manifest. Chunk is the first chunk found to differ, if any.
+type RecordingVerification struct {
+	RecordingID string    `json:"recording_id"`
+	Status      string    `json:"status"`
+	Valid       bool      `json:"valid"`
+	Chunk       *int      `json:"chunk,omitempty"`
+	Details     string    `json:"details"`
+	VerifiedAt  time.Time `json:"verified_at"`
+}
+
+// QueryLogEntry is one statement in the query log recorded for a database
+// session: what was run, with which parameters, and how the server
+// answered. Statements that were not forwarded have no reply.
+type QueryLogEntry struct {
+	CommandID  string    `json:"command_id"`
+	Time       time.Time `json:"time"`   // When the statement was sent
+	Offset     float64   `json:"offset"` // Seconds from the start of the recording
+	Statement  string    `json:"statement"`
+	Parameters []string  `json:"parameters,omitempty"` // Bound parameter values, NULL for null
+	DurationMs int64     `json:"duration_ms"`
+	Rows       int64     `json:"rows"` // Rows returned or affected
+	Error      string    `json:"error,omitempty"`
+	Risk       string    `json:"risk"`
+	Status     string    `json:"status"`
+	Action     string    `json:"action,omitempty"`
+	Reason     string    `json:"reason,omitempty"` // Why the statement was blocked or denied
+}
+
+// PlaybackOptions controls how a recording is played back. Playback starts
+// Offset into the recording, with everything before it replayed at once so
+// that the screen is complete. Speed multiplies the original pace (zero
+// means 1), and pauses longer than IdleLimit are cut short to it (zero
+// keeps them).
+type PlaybackOptions struct {
+	Offset    time.Duration
+	Speed     float64
+	IdleLimit time.Duration
+}
+
+// PlaybackFrame is one event of a recording being played back
+type PlaybackFrame struct {
+	Time float64 `json:"time"` // Seconds from the start of the recording
+	Type string  `json:"type"` // "o" output, "i" input, "r" resize ("<cols>x<rows>"), "m" command marker
+	Data string  `json:"data"`
+}
+
+// RetentionRule says how long recordings of matching resources are kept.
+// A rule matches a resource whose type is one of ResourceTypes and whose
+// sensitivity is one of Sensitivities; an empty list matches anything.
+// Recordings are compressed, moved to the archive tier and deleted that
+// many days after they were made; zero skips the step. Codec is what they
+// are compressed with, "gzip" (the default) or "zstd".
+type RetentionRule struct {
+	Name              string   `json:"name"`
+	ResourceTypes     []string `json:"resource_types,omitempty"`
+	Sensitivities     []string `json:"sensitivities,omitempty"`
+	CompressAfterDays int      `json:"compress_after_days,omitempty"`
+	Codec             string   `json:"codec,omitempty"`
+	ArchiveAfterDays  int      `json:"archive_after_days,omitempty"`
+	DeleteAfterDays   int      `json:"delete_after_days,omitempty"`
+}
+
+// RetentionPolicy is an ordered list of retention rules; the first rule
+// matching a recording's resource applies to it
+type RetentionPolicy struct {
+	Rules []RetentionRule `json:"rules"`
+}
+
+// RetentionAction is something a retention run did, or could not do, to a
+// recording
+type RetentionAction struct {
+	RecordingID string `json:"recording_id"`
+	SessionID   string `json:"session_id"`
+	Rule        string `json:"rule"`
+	Action      string `json:"action"` // "compressed", "archived", "deleted", "held" or "failed"
+	Details     string `json:"details,omitempty"`
+}
+
+// RetentionReport summarizes a retention run
+type RetentionReport struct {
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Actions    []RetentionAction `json:"actions"`
+}
+
+// LegalHold exempts the recordings of a session from deletion until it is
+// released
+type LegalHold struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EvidenceManifest lists the files of an evidence bundle with their
+// SHA-256 hashes. It is signed like a recording manifest: the signature
+// covers the manifest as JSON, without the signature.
+type EvidenceManifest struct {
+	Version    int            `json:"version"`
+	BundleID   string         `json:"bundle_id"`
+	SessionIDs []string       `json:"session_ids"`
+	ExportedBy string         `json:"exported_by,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	Files      []EvidenceFile `json:"files"`
+	PublicKey  string         `json:"public_key"` // Base64 Ed25519 public key of the signer
+	Signature  string         `json:"signature,omitempty"`
+}
+
+// EvidenceFile is a file in an evidence bundle
+type EvidenceFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"` // Hex encoded
+}
+
+// EvidenceVerification is the outcome of checking an evidence bundle
+// against its manifest. Problems lists every file that is missing, added or
+// changed.
+type EvidenceVerification struct {
+	BundleID   string    `json:"bundle_id"`
+	Valid      bool      `json:"valid"`
+	Details    string    `json:"details"`
+	Problems   []string  `json:"problems,omitempty"`
+	VerifiedAt time.Time `json:"verified_at"`
 }
 
 // ProxyConnection represents an active proxy connection
@@ -170,5 +509,287 @@ type SecurityAlert struct {
 	Description string    `json:"description"`
 	RawData     string    `json:"raw_data"` // The actual command or data that triggered the alert
 	Action      string    `json:"action"`   // "logged", "blocked", "terminated"
+	IncidentID  string    `json:"incident_id,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
+
+	// Triage
+	Status         string    `json:"status"` // "open", "acknowledged", "investigating", "resolved", "false_positive"
+	AssigneeID     string    `json:"assignee_id,omitempty"`
+	ReviewerID     string    `json:"reviewer_id,omitempty"` // Who last changed the alert's status
+	ReviewNotes    string    `json:"review_notes,omitempty"`
+	AcknowledgedAt time.Time `json:"acknowledged_at,omitempty"` // When the alert first left the open state
+	ResolvedAt     time.Time `json:"resolved_at,omitempty"`     // When the alert was resolved or dismissed
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Security alert statuses
+const (
+	AlertOpen          = "open"
+	AlertAcknowledged  = "acknowledged"
+	AlertInvestigating = "investigating"
+	AlertResolved      = "resolved"
+	AlertFalsePositive = "false_positive"
+)
+
+// AlertFilter narrows an alert search. Empty fields match any alert.
+type AlertFilter struct {
+	SessionID  string    `json:"session_id,omitempty"`
+	UserID     string    `json:"user_id,omitempty"`
+	ResourceID string    `json:"resource_id,omitempty"`
+	AssigneeID string    `json:"assignee_id,omitempty"`
+	IncidentID string    `json:"incident_id,omitempty"`
+	Severities []string  `json:"severities,omitempty"`
+	Statuses   []string  `json:"statuses,omitempty"`
+	From       time.Time `json:"from,omitempty"`  // Inclusive
+	To         time.Time `json:"to,omitempty"`    // Exclusive
+	Limit      int       `json:"limit,omitempty"` // Zero means no limit
+	Offset     int       `json:"offset,omitempty"`
+}
+
+// AlertPage is one page of an alert search, newest first
+type AlertPage struct {
+	Alerts []*SecurityAlert `json:"alerts"`
+	Total  int              `json:"total"`
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+// Incident statuses
+const (
+	IncidentOpen     = "open"
+	IncidentResolved = "resolved"
+)
+
+// Alert fields correlation rules group by
+const (
+	CorrelateUser     = "user"
+	CorrelateResource = "resource"
+	CorrelateType     = "type"
+	CorrelateSession  = "session"
+)
+
+// CorrelationRule groups alerts into incidents. An alert whose severity is
+// one of Severities and whose type is one of AlertTypes (an empty list
+// matches anything) joins the open incident of the rule that agrees with it
+// on every GroupBy field, if that incident's last alert was at most
+// WindowMinutes earlier, and opens a new incident otherwise.
+type CorrelationRule struct {
+	Name          string   `json:"name"`
+	Severities    []string `json:"severities,omitempty"`
+	AlertTypes    []string `json:"alert_types,omitempty"`
+	GroupBy       []string `json:"group_by"` // "user", "resource", "type" and "session"
+	WindowMinutes int      `json:"window_minutes"`
+}
+
+// CorrelationPolicy is the correlation rules; the first one an alert
+// matches applies. An alert no rule matches is an incident of its own.
+type CorrelationPolicy struct {
+	Rules []CorrelationRule `json:"rules"`
+}
+
+// Incident is a group of correlated alerts. Its user, resource, session,
+// type and title are those of its first alert, its severity the highest of
+// its alerts.
+type Incident struct {
+	ID         string    `json:"id"`
+	Rule       string    `json:"rule,omitempty"` // The correlation rule that grouped the alerts
+	Key        string    `json:"-"`              // The rule and the values of its group-by fields
+	UserID     string    `json:"user_id"`
+	ResourceID string    `json:"resource_id"`
+	SessionID  string    `json:"session_id"`
+	AlertType  string    `json:"alert_type"`
+	Severity   string    `json:"severity"`
+	Title      string    `json:"title"`
+	Status     string    `json:"status"` // "open" or "resolved"
+	AlertCount int       `json:"alert_count"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	ResolvedBy string    `json:"resolved_by,omitempty"`
+	ResolvedAt time.Time `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// IncidentFilter narrows an incident search. Empty fields match any
+// incident; From and To bound the time of its last alert.
+type IncidentFilter struct {
+	UserID     string    `json:"user_id,omitempty"`
+	ResourceID string    `json:"resource_id,omitempty"`
+	Severities []string  `json:"severities,omitempty"`
+	Statuses   []string  `json:"statuses,omitempty"`
+	From       time.Time `json:"from,omitempty"`  // Inclusive
+	To         time.Time `json:"to,omitempty"`    // Exclusive
+	Limit      int       `json:"limit,omitempty"` // Zero means no limit
+	Offset     int       `json:"offset,omitempty"`
+}
+
+// IncidentPage is one page of an incident search, most recently active first
+type IncidentPage struct {
+	Incidents []*Incident `json:"incidents"`
+	Total     int         `json:"total"`
+	Limit     int         `json:"limit"`
+	Offset    int         `json:"offset"`
+}
+
+// Notification channel types
+const (
+	NotificationWebhook = "webhook"
+	NotificationSlack   = "slack"
+	NotificationTeams   = "teams"
+	NotificationEmail   = "email"
+)
+
+// NotificationChannel is somewhere alerts are sent: a generic webhook, whose
+// requests are signed with HMAC-SHA256 of Secret, a Slack or Teams incoming
+// webhook, or email through an SMTP server.
+type NotificationChannel struct {
+	Name   string        `json:"name"`
+	Type   string        `json:"type"` // "webhook", "slack", "teams" or "email"
+	URL    string        `json:"url,omitempty"`
+	Secret string        `json:"secret,omitempty"`
+	SMTP   *SMTPSettings `json:"smtp,omitempty"`
+}
+
+// SMTPSettings is the mail server and addresses of an email channel.
+// STARTTLS is used when the server offers it; credentials are only sent
+// over TLS or to localhost.
+type SMTPSettings struct {
+	Addr     string   `json:"addr"` // host:port
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// NotificationRoute sends alerts to Channels. A route matches an alert whose
+// severity is one of Severities, whose type is one of AlertTypes and whose
+// resource is one of ResourceIDs; an empty list matches anything.
+type NotificationRoute struct {
+	Name        string   `json:"name"`
+	Severities  []string `json:"severities,omitempty"`
+	AlertTypes  []string `json:"alert_types,omitempty"`
+	ResourceIDs []string `json:"resource_ids,omitempty"`
+	Channels    []string `json:"channels"`
+}
+
+// NotificationPolicy is the notification channels and the routes to them.
+// An alert is sent to the channels of every route it matches, once each.
+type NotificationPolicy struct {
+	Channels []NotificationChannel `json:"channels"`
+	Routes   []NotificationRoute   `json:"routes"`
+}
+
+// NotificationDeadLetter is an alert that could not be delivered to a
+// channel after every attempt
+type NotificationDeadLetter struct {
+	ID        string         `json:"id"`
+	AlertID   string         `json:"alert_id"`
+	Channel   string         `json:"channel"`
+	Attempts  int            `json:"attempts"`
+	LastError string         `json:"last_error"`
+	Alert     *SecurityAlert `json:"alert"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Event types published on the event bus
+const (
+	EventSessionStarted = "session.started"
+	EventSessionEnded   = "session.ended"
+	EventProxyStarted   = "proxy.started"
+	EventProxyStopped   = "proxy.stopped"
+	EventCommand        = "command"
+	EventAlert          = "alert"
+	EventAuditLog       = "audit"
+)
+
+// Event is something that happened, as published on the event bus. Exactly
+// one of Session, Proxy, Command, Alert and AuditLog is set, according to
+// Type; UserID, ResourceID, SessionID and Severity are copied from it.
+type Event struct {
+	ID         string           `json:"id"`
+	Type       string           `json:"type"`
+	Time       time.Time        `json:"time"`
+	UserID     string           `json:"user_id,omitempty"`
+	ResourceID string           `json:"resource_id,omitempty"`
+	SessionID  string           `json:"session_id,omitempty"`
+	Severity   string           `json:"severity,omitempty"` // risk of commands and severity of alerts
+	Session    *Session         `json:"session,omitempty"`
+	Proxy      *ProxyConnection `json:"proxy,omitempty"`
+	Command    *SessionCommand  `json:"command,omitempty"`
+	Alert      *SecurityAlert   `json:"alert,omitempty"`
+	AuditLog   *AuditLog        `json:"audit_log,omitempty"`
+}
+
+// EventFilter selects the events of a live event stream. Empty fields match
+// any event; Severities only narrows the events that have a severity,
+// alerts and commands.
+type EventFilter struct {
+	Types      []string
+	UserID     string
+	ResourceID string
+	Severities []string
+}
+
+// PolicyRule is a command analysis rule. Pattern is a regular expression
+// matched against the command text.
+type PolicyRule struct {
+	ID         string `json:"id"`
+	Pattern    string `json:"pattern"`
+	IgnoreCase bool   `json:"ignore_case,omitempty"`
+	Risk       string `json:"risk"` // "low", "medium", "high", "critical"
+	Block      bool   `json:"block,omitempty"`
+}
+
+// Policy is a complete set of command analysis rules, one list per command
+// family
+type Policy struct {
+	Name    string       `json:"name,omitempty"`
+	SQL     []PolicyRule `json:"sql"`
+	Shell   []PolicyRule `json:"shell"`
+	Generic []PolicyRule `json:"generic"`
+}
+
+// PolicyCommand is a command submitted for policy simulation
+type PolicyCommand struct {
+	Command     string `json:"command"`
+	CommandType string `json:"command_type"`
+	ResourceID  string `json:"resource_id,omitempty"`
+}
+
+// PolicyEvaluation is the outcome of evaluating a command against a policy
+type PolicyEvaluation struct {
+	Command      string   `json:"command"`
+	CommandType  string   `json:"command_type"`
+	Risk         string   `json:"risk"`
+	Action       string   `json:"action"` // "allowed", "held", "blocked"
+	MatchedRules []string `json:"matched_rules"`
+}
+
+// PolicyReplayEntry compares the outcome of a recorded command under the
+// current and a candidate policy
+type PolicyReplayEntry struct {
+	CommandID      string           `json:"command_id"`
+	SessionID      string           `json:"session_id"`
+	UserID         string           `json:"user_id"`
+	ResourceID     string           `json:"resource_id"`
+	Timestamp      time.Time        `json:"timestamp"`
+	RecordedRisk   string           `json:"recorded_risk"`
+	RecordedStatus string           `json:"recorded_status"`
+	Current        PolicyEvaluation `json:"current"`
+	Candidate      PolicyEvaluation `json:"candidate"`
+	Changed        bool             `json:"changed"`
+}
+
+// PolicyReplayReport summarizes a replay of recorded commands against a
+// candidate policy
+type PolicyReplayReport struct {
+	From         time.Time            `json:"from"`
+	To           time.Time            `json:"to"`
+	Total        int                  `json:"total"`
+	Changed      int                  `json:"changed"`
+	NewlyBlocked int                  `json:"newly_blocked"`
+	NewlyAllowed int                  `json:"newly_allowed"`
+	RiskRaised   int                  `json:"risk_raised"`
+	RiskLowered  int                  `json:"risk_lowered"`
+	Entries      []*PolicyReplayEntry `json:"entries"`
 }
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// adminRole may see every user's sessions, commands, recordings and
+// events, and use the audit, policy, retention, evidence and notification
+// endpoints. Other users only see what is their own.
+const adminRole = "admin"
+
+// reviewerRole may decide on held commands and triage alerts and
+// incidents, as may admins
+const reviewerRole = "reviewer"
+
+// currentUser returns the user making a request, replying with an error if
+// there is none
+func currentUser(userService domain.UserService, w http.ResponseWriter, r *http.Request) (*domain.User, bool) {
+	session, ok := r.Context().Value("session").(*domain.Session)
+	if !ok || session == nil {
+		utils.Unauthorized(w, "No active session")
+		return nil, false
+	}
+
+	user, err := userService.GetByID(r.Context(), session.UserID)
+	if err != nil {
+		utils.Unauthorized(w, "User not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// ownUserID returns the user ID a listing asked for userID is limited to.
+// Admins may ask for anyone, or everyone with an empty userID; other users
+// always get their own, and asking for someone else's is refused.
+func ownUserID(w http.ResponseWriter, user *domain.User, userID string) (string, bool) {
+	if user.Role == adminRole {
+		return userID, true
+	}
+	if userID != "" && userID != user.ID {
+		utils.Forbidden(w, "Insufficient permissions")
+		return "", false
+	}
+	return user.ID, true
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type AnomalyHandler struct {
+	anomalyDetectionService domain.AnomalyDetectionService
+}
+
+func NewAnomalyHandler(anomalyDetectionService domain.AnomalyDetectionService) *AnomalyHandler {
+	return &AnomalyHandler{
+		anomalyDetectionService: anomalyDetectionService,
+	}
+}
+
+func (h *AnomalyHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/baselines", h.ListBaselines).Methods("GET")
+	r.HandleFunc("/baselines/refresh", h.RefreshBaselines).Methods("POST")
+	r.HandleFunc("/users/{user_id}/baseline", h.GetBaseline).Methods("GET")
+}
+
+func (h *AnomalyHandler) ListBaselines(w http.ResponseWriter, r *http.Request) {
+	baselines, err := h.anomalyDetectionService.ListBaselines(r.Context(), r.URL.Query().Get("user_id"))
+	if err != nil {
+		utils.InternalError(w, "Failed to get behaviour baselines", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Behaviour baselines retrieved successfully", baselines)
+}
+
+func (h *AnomalyHandler) RefreshBaselines(w http.ResponseWriter, r *http.Request) {
+	if err := h.anomalyDetectionService.RefreshBaselines(r.Context()); err != nil {
+		utils.InternalError(w, "Failed to refresh behaviour baselines", err.Error())
+		return
+	}
+
+	baselines, err := h.anomalyDetectionService.ListBaselines(r.Context(), "")
+	if err != nil {
+		utils.InternalError(w, "Failed to get behaviour baselines", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Behaviour baselines refreshed successfully", baselines)
+}
+
+func (h *AnomalyHandler) GetBaseline(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+
+	baseline, err := h.anomalyDetectionService.GetBaseline(r.Context(), userID, r.URL.Query().Get("resource_id"))
+	if err != nil {
+		utils.NotFound(w, "Behaviour baseline not found")
+		return
+	}
+
+	utils.SuccessResponse(w, "Behaviour baseline retrieved successfully", baseline)
+}
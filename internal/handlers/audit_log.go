@@ -1,11 +1,12 @@
 package handlers
 
 import (
-	"encoding/json"
+	"encoding/base64"
 	"net/http"
 	"time"
 
 	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/middleware"
 	"secretary/alpha/pkg/utils"
 
 	"github.com/gorilla/mux"
@@ -13,26 +14,29 @@ import (
 
 type AuditLogHandler struct {
 	auditLogService domain.AuditLogService
+	userService     domain.UserService
 }
 
-func NewAuditLogHandler(auditLogService domain.AuditLogService) *AuditLogHandler {
+func NewAuditLogHandler(auditLogService domain.AuditLogService, userService domain.UserService) *AuditLogHandler {
 	return &AuditLogHandler{
 		auditLogService: auditLogService,
+		userService:     userService,
 	}
 }
 
 func (h *AuditLogHandler) RegisterRoutes(r *mux.Router) {
-	r.HandleFunc("/api/audit-logs", h.List).Methods("GET")
-	r.HandleFunc("/api/audit-logs/{id}", h.GetByID).Methods("GET")
-	r.HandleFunc("/api/audit-logs/user/{userID}", h.GetByUserID).Methods("GET")
-	r.HandleFunc("/api/audit-logs/resource/{resourceID}", h.GetByResourceID).Methods("GET")
-	r.HandleFunc("/api/audit-logs/action/{action}", h.GetByAction).Methods("GET")
-	r.HandleFunc("/api/audit-logs/date-range", h.GetByDateRange).Methods("GET")
-}
-
-type dateRangeRequest struct {
-	StartDate time.Time `json:"startDate"`
-	EndDate   time.Time `json:"endDate"`
+	logs := r.PathPrefix("/audit-logs").Subrouter()
+	logs.Use(middleware.RBAC(h.userService, adminRole))
+	logs.HandleFunc("", h.List).Methods("GET")
+	logs.HandleFunc("/date-range", h.GetByDateRange).Methods("GET")
+	logs.HandleFunc("/user/{userID}", h.GetByUserID).Methods("GET")
+	logs.HandleFunc("/resource/{resourceID}", h.GetByResourceID).Methods("GET")
+	logs.HandleFunc("/action/{action}", h.GetByAction).Methods("GET")
+	logs.HandleFunc("/verify", h.Verify).Methods("GET")
+	logs.HandleFunc("/checkpoints", h.ListCheckpoints).Methods("GET")
+	logs.HandleFunc("/checkpoints", h.Checkpoint).Methods("POST")
+	logs.HandleFunc("/signing-key", h.GetSigningKey).Methods("GET")
+	logs.HandleFunc("/{id}", h.GetByID).Methods("GET")
 }
 
 func (h *AuditLogHandler) List(w http.ResponseWriter, r *http.Request) {
@@ -97,14 +101,22 @@ func (h *AuditLogHandler) GetByAction(w http.ResponseWriter, r *http.Request) {
 	utils.SuccessResponse(w, "Audit logs retrieved successfully", logs)
 }
 
+// GetByDateRange returns the entries written from start (inclusive) to end
+// (exclusive), both RFC 3339 times
 func (h *AuditLogHandler) GetByDateRange(w http.ResponseWriter, r *http.Request) {
-	var req dateRangeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.BadRequest(w, "Invalid request body", err.Error())
+	query := r.URL.Query()
+	start, err := time.Parse(time.RFC3339, query.Get("start"))
+	if err != nil {
+		utils.BadRequest(w, "Invalid query parameters", "start must be an RFC 3339 time")
+		return
+	}
+	end, err := time.Parse(time.RFC3339, query.Get("end"))
+	if err != nil {
+		utils.BadRequest(w, "Invalid query parameters", "end must be an RFC 3339 time")
 		return
 	}
 
-	logs, err := h.auditLogService.GetByDateRange(r.Context(), req.StartDate, req.EndDate)
+	logs, err := h.auditLogService.GetByDateRange(r.Context(), start, end)
 	if err != nil {
 		utils.InternalError(w, "Failed to get audit logs by date range", err.Error())
 		return
@@ -112,3 +124,46 @@ func (h *AuditLogHandler) GetByDateRange(w http.ResponseWriter, r *http.Request)
 
 	utils.SuccessResponse(w, "Audit logs retrieved successfully", logs)
 }
+
+// Verify walks the audit log chain and reports the first break. A broken
+// chain is still a successful verification; valid tells the two apart.
+func (h *AuditLogHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	verification, err := h.auditLogService.Verify(r.Context())
+	if err != nil {
+		utils.InternalError(w, "Failed to verify audit log", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Audit log verified", verification)
+}
+
+func (h *AuditLogHandler) ListCheckpoints(w http.ResponseWriter, r *http.Request) {
+	checkpoints, err := h.auditLogService.ListCheckpoints(r.Context())
+	if err != nil {
+		utils.InternalError(w, "Failed to list audit checkpoints", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Audit checkpoints retrieved successfully", checkpoints)
+}
+
+// Checkpoint signs the head of the chain now instead of waiting for the next
+// scheduled checkpoint.
+func (h *AuditLogHandler) Checkpoint(w http.ResponseWriter, r *http.Request) {
+	checkpoint, err := h.auditLogService.Checkpoint(r.Context())
+	if err != nil {
+		utils.InternalError(w, "Failed to checkpoint audit log", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Audit log checkpointed successfully", checkpoint)
+}
+
+// GetSigningKey returns the public key audit checkpoints are signed with, for
+// verifying the chain offline
+func (h *AuditLogHandler) GetSigningKey(w http.ResponseWriter, r *http.Request) {
+	utils.SuccessResponse(w, "Audit signing key retrieved successfully", map[string]string{
+		"algorithm":  "ed25519",
+		"public_key": base64.StdEncoding.EncodeToString(h.auditLogService.SigningPublicKey()),
+	})
+}
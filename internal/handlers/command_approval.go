@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/middleware"
+	"secretary/alpha/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type CommandApprovalHandler struct {
+	commandApprovalService domain.CommandApprovalService
+	userService            domain.UserService
+}
+
+func NewCommandApprovalHandler(commandApprovalService domain.CommandApprovalService, userService domain.UserService) *CommandApprovalHandler {
+	return &CommandApprovalHandler{
+		commandApprovalService: commandApprovalService,
+		userService:            userService,
+	}
+}
+
+// RegisterRoutes registers the approval routes. Only reviewers see held
+// commands and decide on them.
+func (h *CommandApprovalHandler) RegisterRoutes(r *mux.Router) {
+	approvals := r.PathPrefix("/command-approvals").Subrouter()
+	approvals.Use(middleware.RBAC(h.userService, adminRole, reviewerRole))
+	approvals.HandleFunc("/pending", h.GetPending).Methods("GET")
+	approvals.HandleFunc("/{id}", h.GetByID).Methods("GET")
+	approvals.HandleFunc("/{id}/approve", h.Approve).Methods("POST")
+	approvals.HandleFunc("/{id}/deny", h.Deny).Methods("POST")
+
+	sessionApprovals := r.PathPrefix("/sessions/{session_id}/command-approvals").Subrouter()
+	sessionApprovals.Use(middleware.RBAC(h.userService, adminRole, reviewerRole))
+	sessionApprovals.HandleFunc("", h.GetBySession).Methods("GET")
+}
+
+type decideCommandApprovalRequest struct {
+	Notes string `json:"notes"`
+}
+
+func (h *CommandApprovalHandler) GetPending(w http.ResponseWriter, r *http.Request) {
+	approvals, err := h.commandApprovalService.ListPending(r.Context())
+	if err != nil {
+		utils.InternalError(w, "Failed to get pending command approvals", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Pending command approvals retrieved successfully", approvals)
+}
+
+func (h *CommandApprovalHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	approval, err := h.commandApprovalService.GetApproval(r.Context(), id)
+	if err != nil {
+		utils.NotFound(w, "Command approval not found")
+		return
+	}
+
+	utils.SuccessResponse(w, "Command approval retrieved successfully", approval)
+}
+
+func (h *CommandApprovalHandler) GetBySession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["session_id"]
+
+	approvals, err := h.commandApprovalService.ListBySession(r.Context(), sessionID)
+	if err != nil {
+		utils.InternalError(w, "Failed to get session command approvals", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Session command approvals retrieved successfully", approvals)
+}
+
+func (h *CommandApprovalHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var req decideCommandApprovalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+
+	// Get reviewer ID from session
+	session, ok := r.Context().Value("session").(*domain.Session)
+	if !ok || session == nil {
+		utils.Unauthorized(w, "No active session")
+		return
+	}
+
+	if err := h.commandApprovalService.Approve(r.Context(), id, session.UserID, req.Notes); err != nil {
+		utils.BadRequest(w, "Failed to approve command", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Command approved successfully", nil)
+}
+
+func (h *CommandApprovalHandler) Deny(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var req decideCommandApprovalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+
+	// Get reviewer ID from session
+	session, ok := r.Context().Value("session").(*domain.Session)
+	if !ok || session == nil {
+		utils.Unauthorized(w, "No active session")
+		return
+	}
+
+	if err := h.commandApprovalService.Deny(r.Context(), id, session.UserID, req.Notes); err != nil {
+		utils.BadRequest(w, "Failed to deny command", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Command denied successfully", nil)
+}
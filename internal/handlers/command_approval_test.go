@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"secretary/alpha/internal/domain"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeCommandApprovalService records the approvals decided through it
+type fakeCommandApprovalService struct {
+	domain.CommandApprovalService
+	approved []string
+}
+
+func (f *fakeCommandApprovalService) Approve(ctx context.Context, id string, reviewerID string, notes string) error {
+	f.approved = append(f.approved, id)
+	return nil
+}
+
+func (f *fakeCommandApprovalService) ListPending(ctx context.Context) ([]*domain.CommandApproval, error) {
+	return []*domain.CommandApproval{{ID: "approval-1", Command: "DROP TABLE accounts"}}, nil
+}
+
+func TestCommandApprovalHandler_ReviewersOnly(t *testing.T) {
+	tests := []struct {
+		name           string
+		role           string
+		expectedStatus int
+	}{
+		{name: "admin", role: "admin", expectedStatus: http.StatusOK},
+		{name: "reviewer", role: "reviewer", expectedStatus: http.StatusOK},
+		{name: "user", role: "user", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &domain.User{ID: "user-id", Role: tt.role}
+			userService := new(MockUserService)
+			userService.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			approvals := &fakeCommandApprovalService{}
+			router := mux.NewRouter()
+			NewCommandApprovalHandler(approvals, userService).RegisterRoutes(router)
+
+			for _, req := range []*http.Request{
+				httptest.NewRequest("GET", "/command-approvals/pending", nil),
+				httptest.NewRequest("POST", "/command-approvals/approval-1/approve", strings.NewReader(`{"notes": "ok"}`)),
+			} {
+				req = req.WithContext(context.WithValue(req.Context(), "session", &domain.Session{UserID: user.ID}))
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, tt.expectedStatus, w.Code, req.URL.Path)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, []string{"approval-1"}, approvals.approved)
+			} else {
+				assert.Empty(t, approvals.approved)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// eventStreamKeepAlive is how often an idle stream sends a comment so
+// proxies in between do not time it out
+const eventStreamKeepAlive = 30 * time.Second
+
+type EventStreamHandler struct {
+	eventStreamService domain.EventStreamService
+	userService        domain.UserService
+}
+
+func NewEventStreamHandler(eventStreamService domain.EventStreamService, userService domain.UserService) *EventStreamHandler {
+	return &EventStreamHandler{
+		eventStreamService: eventStreamService,
+		userService:        userService,
+	}
+}
+
+func (h *EventStreamHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/events/stream", h.StreamEvents).Methods("GET")
+}
+
+// StreamEvents pushes live events as server-sent events, each named after
+// its type, until the client goes away. type and severity take
+// comma-separated lists. Users other than admins only receive events about
+// themselves.
+func (h *EventStreamHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(h.userService, w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := domain.EventFilter{
+		UserID:     query.Get("user_id"),
+		ResourceID: query.Get("resource_id"),
+	}
+	if filter.UserID, ok = ownUserID(w, user, filter.UserID); !ok {
+		return
+	}
+	for name, dest := range map[string]*[]string{"type": &filter.Types, "severity": &filter.Severities} {
+		for _, value := range strings.Split(query.Get(name), ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				*dest = append(*dest, value)
+			}
+		}
+	}
+
+	events, err := h.eventStreamService.Subscribe(r.Context(), filter)
+	if err != nil {
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
+		return
+	}
+
+	// The stream outlasts the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeServerSentEvent(w, event.Type, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
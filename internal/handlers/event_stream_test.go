@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"secretary/alpha/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeEventStreamService records the filter of each subscription and
+// returns a closed channel, so streams end at once
+type fakeEventStreamService struct {
+	filters []domain.EventFilter
+}
+
+func (f *fakeEventStreamService) Subscribe(ctx context.Context, filter domain.EventFilter) (<-chan *domain.Event, error) {
+	f.filters = append(f.filters, filter)
+	events := make(chan *domain.Event)
+	close(events)
+	return events, nil
+}
+
+func TestEventStreamHandler_StreamEvents(t *testing.T) {
+	tests := []struct {
+		name           string
+		user           *domain.User
+		query          string
+		expectedStatus int
+		expectedUserID string
+	}{
+		{
+			name:           "admin streams everyone",
+			user:           &domain.User{ID: "admin-id", Role: "admin"},
+			expectedStatus: http.StatusOK,
+			expectedUserID: "",
+		},
+		{
+			name:           "admin streams another user",
+			user:           &domain.User{ID: "admin-id", Role: "admin"},
+			query:          "?user_id=other-id",
+			expectedStatus: http.StatusOK,
+			expectedUserID: "other-id",
+		},
+		{
+			name:           "user streams their own events",
+			user:           &domain.User{ID: "user-id", Role: "user"},
+			expectedStatus: http.StatusOK,
+			expectedUserID: "user-id",
+		},
+		{
+			name:           "user asks for another user",
+			user:           &domain.User{ID: "user-id", Role: "user"},
+			query:          "?user_id=other-id",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userService := new(MockUserService)
+			userService.On("GetByID", mock.Anything, tt.user.ID).Return(tt.user, nil)
+			events := &fakeEventStreamService{}
+			handler := NewEventStreamHandler(events, userService)
+
+			req := httptest.NewRequest("GET", "/api/events/stream"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), "session", &domain.Session{UserID: tt.user.ID}))
+			w := httptest.NewRecorder()
+
+			handler.StreamEvents(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assert.Empty(t, events.filters)
+				return
+			}
+			if assert.Len(t, events.filters, 1) {
+				assert.Equal(t, tt.expectedUserID, events.filters[0].UserID)
+			}
+		})
+	}
+}
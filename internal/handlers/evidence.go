@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/middleware"
+	"secretary/alpha/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type EvidenceHandler struct {
+	evidenceService domain.EvidenceService
+	sessionService  domain.SessionService
+	userService     domain.UserService
+}
+
+func NewEvidenceHandler(evidenceService domain.EvidenceService, sessionService domain.SessionService, userService domain.UserService) *EvidenceHandler {
+	return &EvidenceHandler{
+		evidenceService: evidenceService,
+		sessionService:  sessionService,
+		userService:     userService,
+	}
+}
+
+func (h *EvidenceHandler) RegisterRoutes(r *mux.Router) {
+	evidence := r.PathPrefix("/evidence").Subrouter()
+	evidence.Use(middleware.RBAC(h.userService, adminRole))
+	evidence.HandleFunc("/export", h.Export).Methods("GET")
+	evidence.HandleFunc("/signing-key", h.GetSigningKey).Methods("GET")
+}
+
+// Export downloads a signed evidence bundle of the sessions given as
+// session_id, repeated or comma separated.
+func (h *EvidenceHandler) Export(w http.ResponseWriter, r *http.Request) {
+	var sessionIDs []string
+	for _, value := range r.URL.Query()["session_id"] {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				sessionIDs = append(sessionIDs, id)
+			}
+		}
+	}
+	if len(sessionIDs) == 0 {
+		utils.BadRequest(w, "Invalid query parameters", "session_id is required")
+		return
+	}
+	for _, id := range sessionIDs {
+		if _, err := h.sessionService.GetByID(r.Context(), id); err != nil {
+			utils.NotFound(w, "Session not found: "+id)
+			return
+		}
+	}
+
+	// The bundle is spooled to disk rather than memory, so that a failed
+	// export can still be reported as an error
+	bundle, err := os.CreateTemp("", "evidence-*.zip")
+	if err != nil {
+		utils.InternalError(w, "Failed to export evidence", err.Error())
+		return
+	}
+	defer os.Remove(bundle.Name())
+	defer bundle.Close()
+
+	session := r.Context().Value("session").(*domain.Session)
+	manifest, err := h.evidenceService.ExportBundle(r.Context(), sessionIDs, session.UserID, bundle)
+	if err != nil {
+		utils.InternalError(w, "Failed to export evidence", err.Error())
+		return
+	}
+	if _, err := bundle.Seek(0, io.SeekStart); err != nil {
+		utils.InternalError(w, "Failed to export evidence", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=evidence_"+manifest.BundleID+".zip")
+	io.Copy(w, bundle)
+}
+
+// GetSigningKey returns the public key evidence bundles are signed with, for
+// verifying them offline
+func (h *EvidenceHandler) GetSigningKey(w http.ResponseWriter, r *http.Request) {
+	utils.SuccessResponse(w, "Evidence signing key retrieved successfully", map[string]string{
+		"algorithm":  "ed25519",
+		"public_key": base64.StdEncoding.EncodeToString(h.evidenceService.SigningPublicKey()),
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/middleware"
+	"secretary/alpha/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type IncidentHandler struct {
+	incidentService      domain.IncidentService
+	securityAlertService domain.SecurityAlertService
+	userService          domain.UserService
+}
+
+type updateIncidentStatusRequest struct {
+	Status string `json:"status"`
+}
+
+func NewIncidentHandler(incidentService domain.IncidentService, securityAlertService domain.SecurityAlertService, userService domain.UserService) *IncidentHandler {
+	return &IncidentHandler{
+		incidentService:      incidentService,
+		securityAlertService: securityAlertService,
+		userService:          userService,
+	}
+}
+
+func (h *IncidentHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/incidents", h.SearchIncidents).Methods("GET")
+	r.HandleFunc("/incidents/rules", h.GetPolicy).Methods("GET")
+	r.HandleFunc("/incidents/{incident_id}", h.GetIncident).Methods("GET")
+	r.HandleFunc("/incidents/{incident_id}/alerts", h.GetIncidentAlerts).Methods("GET")
+
+	// Only reviewers resolve or reopen incidents, as they triage alerts
+	triage := r.PathPrefix("/incidents/{incident_id}").Subrouter()
+	triage.Use(middleware.RBAC(h.userService, adminRole, reviewerRole))
+	triage.HandleFunc("/status", h.UpdateIncidentStatus).Methods("POST")
+}
+
+// GetPolicy returns the rules alerts are correlated by
+func (h *IncidentHandler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	utils.SuccessResponse(w, "Correlation rules retrieved successfully", h.incidentService.GetPolicy(r.Context()))
+}
+
+func (h *IncidentHandler) SearchIncidents(w http.ResponseWriter, r *http.Request) {
+	filter, err := incidentFilterFromQuery(r)
+	if err != nil {
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
+		return
+	}
+
+	page, err := h.incidentService.SearchIncidents(r.Context(), filter)
+	if err != nil {
+		utils.BadRequest(w, "Failed to get incidents", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Incidents retrieved successfully", page)
+}
+
+func (h *IncidentHandler) GetIncident(w http.ResponseWriter, r *http.Request) {
+	incident, err := h.incidentService.GetIncident(r.Context(), mux.Vars(r)["incident_id"])
+	if err != nil {
+		utils.NotFound(w, "Incident not found")
+		return
+	}
+
+	utils.SuccessResponse(w, "Incident retrieved successfully", incident)
+}
+
+// GetIncidentAlerts returns one page of the alerts grouped into an incident.
+// It takes the same query parameters as the alert search.
+func (h *IncidentHandler) GetIncidentAlerts(w http.ResponseWriter, r *http.Request) {
+	incidentID := mux.Vars(r)["incident_id"]
+	if _, err := h.incidentService.GetIncident(r.Context(), incidentID); err != nil {
+		utils.NotFound(w, "Incident not found")
+		return
+	}
+
+	filter, err := alertFilterFromQuery(r)
+	if err != nil {
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
+		return
+	}
+	filter.IncidentID = incidentID
+
+	page, err := h.securityAlertService.SearchAlerts(r.Context(), filter)
+	if err != nil {
+		utils.BadRequest(w, "Failed to get alerts", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Incident alerts retrieved successfully", page)
+}
+
+// UpdateIncidentStatus resolves or reopens an incident
+func (h *IncidentHandler) UpdateIncidentStatus(w http.ResponseWriter, r *http.Request) {
+	incidentID := mux.Vars(r)["incident_id"]
+
+	var req updateIncidentStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	if req.Status == "" {
+		utils.BadRequest(w, "Invalid request body", "status is required")
+		return
+	}
+
+	if _, err := h.incidentService.GetIncident(r.Context(), incidentID); err != nil {
+		utils.NotFound(w, "Incident not found")
+		return
+	}
+
+	session := r.Context().Value("session").(*domain.Session)
+	incident, err := h.incidentService.UpdateIncidentStatus(r.Context(), incidentID, req.Status, session.UserID)
+	if err != nil {
+		utils.BadRequest(w, "Failed to update incident status", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Incident status updated successfully", incident)
+}
+
+// incidentFilterFromQuery reads an incident filter from the query string.
+// status and severity take comma-separated lists.
+func incidentFilterFromQuery(r *http.Request) (domain.IncidentFilter, error) {
+	query := r.URL.Query()
+	filter := domain.IncidentFilter{
+		UserID:     query.Get("user_id"),
+		ResourceID: query.Get("resource_id"),
+	}
+
+	for name, dest := range map[string]*[]string{"severity": &filter.Severities, "status": &filter.Statuses} {
+		for _, value := range strings.Split(query.Get(name), ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				*dest = append(*dest, value)
+			}
+		}
+	}
+
+	for name, dest := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*dest = t
+		}
+	}
+
+	for name, dest := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return filter, fmt.Errorf("%s must be a non-negative integer", name)
+			}
+			*dest = n
+		}
+	}
+
+	return filter, nil
+}
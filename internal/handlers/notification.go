@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/middleware"
+	"secretary/alpha/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type NotificationHandler struct {
+	notificationService domain.NotificationService
+	userService         domain.UserService
+}
+
+func NewNotificationHandler(notificationService domain.NotificationService, userService domain.UserService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+		userService:         userService,
+	}
+}
+
+func (h *NotificationHandler) RegisterRoutes(r *mux.Router) {
+	notifications := r.PathPrefix("/notifications").Subrouter()
+	notifications.Use(middleware.RBAC(h.userService, adminRole))
+	notifications.HandleFunc("/policy", h.GetPolicy).Methods("GET")
+	notifications.HandleFunc("/channels/{name}/test", h.TestChannel).Methods("POST")
+	notifications.HandleFunc("/dead-letters", h.ListDeadLetters).Methods("GET")
+	notifications.HandleFunc("/dead-letters/{id}/retry", h.RetryDeadLetter).Methods("POST")
+	notifications.HandleFunc("/dead-letters/{id}", h.DeleteDeadLetter).Methods("DELETE")
+}
+
+// GetPolicy returns the notification channels and routes, without secrets
+func (h *NotificationHandler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	utils.SuccessResponse(w, "Notification policy retrieved successfully", h.notificationService.GetPolicy(r.Context()))
+}
+
+// TestChannel sends a test alert to a channel and reports whether it was
+// delivered
+func (h *NotificationHandler) TestChannel(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	found := false
+	for _, channel := range h.notificationService.GetPolicy(r.Context()).Channels {
+		found = found || channel.Name == name
+	}
+	if !found {
+		utils.NotFound(w, "Notification channel not found")
+		return
+	}
+
+	if err := h.notificationService.SendTest(r.Context(), name); err != nil {
+		utils.ErrorResponse(w, http.StatusBadGateway, "Failed to send test notification", err.Error())
+		return
+	}
+	utils.SuccessResponse(w, "Test notification sent successfully", nil)
+}
+
+func (h *NotificationHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	letters, err := h.notificationService.ListDeadLetters(r.Context())
+	if err != nil {
+		utils.InternalError(w, "Failed to list dead letters", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Dead letters retrieved successfully", letters)
+}
+
+// RetryDeadLetter sends a dead-lettered alert again, removing it from the
+// dead-letter log once it is delivered
+func (h *NotificationHandler) RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, err := h.notificationService.GetDeadLetter(r.Context(), id); err != nil {
+		utils.NotFound(w, "Dead letter not found")
+		return
+	}
+
+	if err := h.notificationService.RetryDeadLetter(r.Context(), id); err != nil {
+		utils.ErrorResponse(w, http.StatusBadGateway, "Failed to deliver notification", err.Error())
+		return
+	}
+	utils.SuccessResponse(w, "Notification delivered successfully", nil)
+}
+
+func (h *NotificationHandler) DeleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if err := h.notificationService.DeleteDeadLetter(r.Context(), mux.Vars(r)["id"]); err != nil {
+		utils.NotFound(w, "Dead letter not found")
+		return
+	}
+
+	utils.SuccessResponse(w, "Dead letter deleted successfully", nil)
+}
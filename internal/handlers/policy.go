@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/middleware"
+	"secretary/alpha/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type PolicyHandler struct {
+	policySimulationService domain.PolicySimulationService
+	userService             domain.UserService
+}
+
+func NewPolicyHandler(policySimulationService domain.PolicySimulationService, userService domain.UserService) *PolicyHandler {
+	return &PolicyHandler{
+		policySimulationService: policySimulationService,
+		userService:             userService,
+	}
+}
+
+// RegisterRoutes registers the policy routes. The rules and the recorded
+// commands they are replayed against are only shown to admins.
+func (h *PolicyHandler) RegisterRoutes(r *mux.Router) {
+	policies := r.PathPrefix("/policies").Subrouter()
+	policies.Use(middleware.RBAC(h.userService, adminRole))
+	policies.HandleFunc("/current", h.GetCurrent).Methods("GET")
+	policies.HandleFunc("/simulate", h.Simulate).Methods("POST")
+	policies.HandleFunc("/replay", h.Replay).Methods("POST")
+}
+
+type simulatePolicyRequest struct {
+	// Policy is the candidate policy; the current policy is used when it is
+	// omitted
+	Policy   *domain.Policy         `json:"policy"`
+	Commands []domain.PolicyCommand `json:"commands"`
+}
+
+type replayPolicyRequest struct {
+	Policy           *domain.Policy `json:"policy"`
+	From             time.Time      `json:"from"`
+	To               time.Time      `json:"to"`
+	IncludeUnchanged bool           `json:"include_unchanged"`
+}
+
+func (h *PolicyHandler) GetCurrent(w http.ResponseWriter, r *http.Request) {
+	utils.SuccessResponse(w, "Current policy retrieved successfully", h.policySimulationService.CurrentPolicy(r.Context()))
+}
+
+func (h *PolicyHandler) Simulate(w http.ResponseWriter, r *http.Request) {
+	var req simulatePolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+
+	evaluations, err := h.policySimulationService.Simulate(r.Context(), req.Policy, req.Commands)
+	if err != nil {
+		utils.BadRequest(w, "Failed to simulate policy", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Policy simulated successfully", evaluations)
+}
+
+func (h *PolicyHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	var req replayPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	if req.Policy == nil {
+		utils.BadRequest(w, "Invalid request body", "policy is required")
+		return
+	}
+	if req.From.IsZero() {
+		utils.BadRequest(w, "Invalid request body", "from is required")
+		return
+	}
+	if req.To.IsZero() {
+		req.To = time.Now()
+	}
+
+	report, err := h.policySimulationService.Replay(r.Context(), req.Policy, req.From, req.To, req.IncludeUnchanged)
+	if err != nil {
+		utils.BadRequest(w, "Failed to replay commands", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Commands replayed successfully", report)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"secretary/alpha/internal/domain"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakePolicySimulationService counts the replays run through it
+type fakePolicySimulationService struct {
+	domain.PolicySimulationService
+	replays int
+}
+
+func (f *fakePolicySimulationService) CurrentPolicy(ctx context.Context) *domain.Policy {
+	return &domain.Policy{Name: "current"}
+}
+
+func (f *fakePolicySimulationService) Replay(ctx context.Context, policy *domain.Policy, from, to time.Time, includeUnchanged bool) (*domain.PolicyReplayReport, error) {
+	f.replays++
+	return &domain.PolicyReplayReport{From: from, To: to}, nil
+}
+
+func TestPolicyHandler_AdminsOnly(t *testing.T) {
+	tests := []struct {
+		name           string
+		role           string
+		expectedStatus int
+	}{
+		{name: "admin", role: "admin", expectedStatus: http.StatusOK},
+		{name: "reviewer", role: "reviewer", expectedStatus: http.StatusForbidden},
+		{name: "user", role: "user", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &domain.User{ID: "user-id", Role: tt.role}
+			userService := new(MockUserService)
+			userService.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			policies := &fakePolicySimulationService{}
+			router := mux.NewRouter()
+			NewPolicyHandler(policies, userService).RegisterRoutes(router)
+
+			for _, req := range []*http.Request{
+				httptest.NewRequest("GET", "/policies/current", nil),
+				httptest.NewRequest("POST", "/policies/replay", strings.NewReader(`{"policy": {}, "from": "2024-01-01T00:00:00Z"}`)),
+			} {
+				req = req.WithContext(context.WithValue(req.Context(), "session", &domain.Session{UserID: user.ID}))
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, tt.expectedStatus, w.Code, req.URL.Path)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, 1, policies.replays)
+			} else {
+				assert.Zero(t, policies.replays)
+			}
+		})
+	}
+}
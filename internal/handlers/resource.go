@@ -32,6 +32,7 @@ type createResourceRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Type        string `json:"type"`
+	Sensitivity string `json:"sensitivity"`
 }
 
 func (h *ResourceHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -45,6 +46,7 @@ func (h *ResourceHandler) Create(w http.ResponseWriter, r *http.Request) {
 		Name:        req.Name,
 		Description: req.Description,
 		Type:        req.Type,
+		Sensitivity: req.Sensitivity,
 	}
 
 	if err := h.resourceService.CreateResource(r.Context(), resource); err != nil {
@@ -82,6 +84,7 @@ type updateResourceRequest struct {
 	Name        string `json:"name,omitempty"`
 	Description string `json:"description,omitempty"`
 	Type        string `json:"type,omitempty"`
+	Sensitivity string `json:"sensitivity,omitempty"`
 }
 
 func (h *ResourceHandler) Update(w http.ResponseWriter, r *http.Request) {
@@ -109,6 +112,9 @@ func (h *ResourceHandler) Update(w http.ResponseWriter, r *http.Request) {
 	if req.Type != "" {
 		resource.Type = req.Type
 	}
+	if req.Sensitivity != "" {
+		resource.Sensitivity = req.Sensitivity
+	}
 
 	if err := h.resourceService.UpdateResource(r.Context(), resource); err != nil {
 		utils.InternalError(w, "Failed to update resource", err.Error())
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/middleware"
+	"secretary/alpha/pkg/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type RetentionHandler struct {
+	recordingRetentionService domain.RecordingRetentionService
+	userService               domain.UserService
+}
+
+func NewRetentionHandler(recordingRetentionService domain.RecordingRetentionService, userService domain.UserService) *RetentionHandler {
+	return &RetentionHandler{
+		recordingRetentionService: recordingRetentionService,
+		userService:               userService,
+	}
+}
+
+func (h *RetentionHandler) RegisterRoutes(r *mux.Router) {
+	retention := r.PathPrefix("/recordings/retention").Subrouter()
+	retention.Use(middleware.RBAC(h.userService, adminRole))
+	retention.HandleFunc("/policy", h.GetPolicy).Methods("GET")
+	retention.HandleFunc("/run", h.Run).Methods("POST")
+
+	holds := r.PathPrefix("/legal-holds").Subrouter()
+	holds.Use(middleware.RBAC(h.userService, adminRole))
+	holds.HandleFunc("", h.ListLegalHolds).Methods("GET")
+	holds.HandleFunc("", h.PlaceLegalHold).Methods("POST")
+	holds.HandleFunc("/{id}", h.ReleaseLegalHold).Methods("DELETE")
+}
+
+type placeLegalHoldRequest struct {
+	SessionID string `json:"session_id"`
+	Reason    string `json:"reason"`
+}
+
+func (h *RetentionHandler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	utils.SuccessResponse(w, "Retention policy retrieved successfully", h.recordingRetentionService.GetPolicy(r.Context()))
+}
+
+// Run enforces the retention policy now instead of waiting for the next
+// scheduled run.
+func (h *RetentionHandler) Run(w http.ResponseWriter, r *http.Request) {
+	report, err := h.recordingRetentionService.Enforce(r.Context())
+	if err != nil {
+		utils.InternalError(w, "Failed to enforce retention policy", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Retention policy enforced successfully", report)
+}
+
+func (h *RetentionHandler) ListLegalHolds(w http.ResponseWriter, r *http.Request) {
+	holds, err := h.recordingRetentionService.ListLegalHolds(r.Context())
+	if err != nil {
+		utils.InternalError(w, "Failed to list legal holds", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Legal holds retrieved successfully", holds)
+}
+
+func (h *RetentionHandler) PlaceLegalHold(w http.ResponseWriter, r *http.Request) {
+	var req placeLegalHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	if req.SessionID == "" || req.Reason == "" {
+		utils.BadRequest(w, "Invalid request body", "session_id and reason are required")
+		return
+	}
+
+	session := r.Context().Value("session").(*domain.Session)
+	hold := &domain.LegalHold{
+		SessionID: req.SessionID,
+		Reason:    req.Reason,
+		CreatedBy: session.UserID,
+	}
+	if err := h.recordingRetentionService.PlaceLegalHold(r.Context(), hold); err != nil {
+		utils.InternalError(w, "Failed to place legal hold", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Legal hold placed successfully", hold)
+}
+
+func (h *RetentionHandler) ReleaseLegalHold(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	session := r.Context().Value("session").(*domain.Session)
+	if err := h.recordingRetentionService.ReleaseLegalHold(r.Context(), id, session.UserID); err != nil {
+		utils.NotFound(w, "Legal hold not found")
+		return
+	}
+
+	utils.SuccessResponse(w, "Legal hold released successfully", nil)
+}
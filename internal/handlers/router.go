@@ -27,7 +27,20 @@ func (r *Router) RegisterHandlers(
 	sessionHandler *SessionHandler,
 	ephemeralCredentialHandler *EphemeralCredentialHandler,
 	sessionMonitorHandler *SessionMonitorHandler,
+	commandApprovalHandler *CommandApprovalHandler,
+	policyHandler *PolicyHandler,
+	anomalyHandler *AnomalyHandler,
+	retentionHandler *RetentionHandler,
+	evidenceHandler *EvidenceHandler,
+	notificationHandler *NotificationHandler,
+	incidentHandler *IncidentHandler,
+	eventStreamHandler *EventStreamHandler,
+	auditLogHandler *AuditLogHandler,
 ) {
+	// Audit entries written while serving a request carry the client's IP
+	// and user agent
+	r.router.Use(middleware.AuditContext)
+
 	// PUBLIC ROUTES - No authentication required (ONLY health and login)
 	// Health check
 	healthHandler := NewHealthHandler()
@@ -40,6 +53,8 @@ func (r *Router) RegisterHandlers(
 	api := r.router.PathPrefix("/api").Subrouter()
 	api.Use(middleware.SessionMiddleware)
 	api.Use(middleware.RateLimitMiddleware)
+	// Every state-changing call is recorded in the audit log
+	api.Use(middleware.Audit(auditLogHandler.auditLogService))
 
 	// Register endpoint - protected (requires authentication)
 	// In production, this should require admin role
@@ -110,6 +125,33 @@ func (r *Router) RegisterHandlers(
 	// Session monitoring routes
 	sessionMonitorHandler.RegisterRoutes(api)
 
+	// Held command approval routes
+	commandApprovalHandler.RegisterRoutes(api)
+
+	// Policy simulation routes
+	policyHandler.RegisterRoutes(api)
+
+	// Behaviour baseline routes
+	anomalyHandler.RegisterRoutes(api)
+
+	// Recording retention and legal hold routes (admin only)
+	retentionHandler.RegisterRoutes(api)
+
+	// Incident evidence export routes (admin only)
+	evidenceHandler.RegisterRoutes(api)
+
+	// Alert notification routes (admin only)
+	notificationHandler.RegisterRoutes(api)
+
+	// Correlated alert incident routes
+	incidentHandler.RegisterRoutes(api)
+
+	// Live event stream routes
+	eventStreamHandler.RegisterRoutes(api)
+
+	// Audit log routes (admin only)
+	auditLogHandler.RegisterRoutes(api)
+
 	// Add documentation handler - protected for security
 	docsHandler := NewDocsHandler()
 	api.HandleFunc("/docs", docsHandler.SwaggerUI).Methods("GET")
@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/middleware"
 	"secretary/alpha/pkg/utils"
 
 	"github.com/gorilla/mux"
@@ -15,6 +22,10 @@ type SessionMonitorHandler struct {
 	sessionRecordingService domain.SessionRecordingService
 	proxyService            domain.ProxyService
 	securityAlertService    domain.SecurityAlertService
+	sessionMonitorService   domain.SessionMonitorService
+	sessionRiskService      domain.SessionRiskService
+	userService             domain.UserService
+	sessionService          domain.SessionService
 }
 
 func NewSessionMonitorHandler(
@@ -22,17 +33,26 @@ func NewSessionMonitorHandler(
 	sessionRecordingService domain.SessionRecordingService,
 	proxyService domain.ProxyService,
 	securityAlertService domain.SecurityAlertService,
+	sessionMonitorService domain.SessionMonitorService,
+	sessionRiskService domain.SessionRiskService,
+	userService domain.UserService,
+	sessionService domain.SessionService,
 ) *SessionMonitorHandler {
 	return &SessionMonitorHandler{
 		sessionCommandService:   sessionCommandService,
 		sessionRecordingService: sessionRecordingService,
 		proxyService:            proxyService,
 		securityAlertService:    securityAlertService,
+		sessionMonitorService:   sessionMonitorService,
+		sessionRiskService:      sessionRiskService,
+		userService:             userService,
+		sessionService:          sessionService,
 	}
 }
 
 func (h *SessionMonitorHandler) RegisterRoutes(r *mux.Router) {
 	// Session Command routes
+	r.HandleFunc("/commands", h.SearchCommands).Methods("GET")
 	r.HandleFunc("/sessions/{session_id}/commands", h.GetSessionCommands).Methods("GET")
 	r.HandleFunc("/users/{user_id}/commands", h.GetUserCommands).Methods("GET")
 	r.HandleFunc("/resources/{resource_id}/commands", h.GetResourceCommands).Methods("GET")
@@ -42,7 +62,14 @@ func (h *SessionMonitorHandler) RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/sessions/{session_id}/recording/start", h.StartRecording).Methods("POST")
 	r.HandleFunc("/sessions/{session_id}/recording/stop", h.StopRecording).Methods("POST")
 	r.HandleFunc("/sessions/{session_id}/recording", h.GetRecording).Methods("GET")
+	r.HandleFunc("/sessions/{session_id}/recording/resize", h.ResizeRecording).Methods("POST")
+	r.HandleFunc("/sessions/{session_id}/timeline", h.GetSessionTimeline).Methods("GET")
+	r.HandleFunc("/recordings", h.ListRecordings).Methods("GET")
 	r.HandleFunc("/recordings/{recording_id}/download", h.DownloadRecording).Methods("GET")
+	r.HandleFunc("/recordings/{recording_id}/play", h.PlayRecording).Methods("GET")
+	r.HandleFunc("/recordings/{recording_id}/manifest", h.GetRecordingManifest).Methods("GET")
+	r.HandleFunc("/recordings/{recording_id}/verify", h.VerifyRecording).Methods("POST")
+	r.HandleFunc("/recordings/signing-key", h.GetRecordingSigningKey).Methods("GET")
 	r.HandleFunc("/users/{user_id}/recordings", h.GetUserRecordings).Methods("GET")
 
 	// Proxy routes
@@ -55,68 +82,160 @@ func (h *SessionMonitorHandler) RegisterRoutes(r *mux.Router) {
 	// Security Alert routes
 	r.HandleFunc("/sessions/{session_id}/alerts", h.GetSessionAlerts).Methods("GET")
 	r.HandleFunc("/users/{user_id}/alerts", h.GetUserAlerts).Methods("GET")
+	r.HandleFunc("/alerts", h.SearchAlerts).Methods("GET")
 	r.HandleFunc("/alerts/severity/{severity}", h.GetAlertsBySeverity).Methods("GET")
-	r.HandleFunc("/alerts/{alert_id}/review", h.MarkAlertAsReviewed).Methods("POST")
+	r.HandleFunc("/alerts/{alert_id}", h.GetAlert).Methods("GET")
+
+	// Only reviewers triage alerts
+	triage := r.PathPrefix("/alerts/{alert_id}").Subrouter()
+	triage.Use(middleware.RBAC(h.userService, adminRole, reviewerRole))
+	triage.HandleFunc("/review", h.MarkAlertAsReviewed).Methods("POST")
+	triage.HandleFunc("/status", h.UpdateAlertStatus).Methods("POST")
+	triage.HandleFunc("/assign", h.AssignAlert).Methods("POST")
+
+	// Live session routes
+	r.HandleFunc("/sessions/{session_id}/metrics", h.GetSessionMetrics).Methods("GET")
+	r.HandleFunc("/sessions/{session_id}/interrupt", h.InterruptSession).Methods("POST")
+	r.HandleFunc("/risk/sessions", h.GetSessionRiskScores).Methods("GET")
 }
 
 // Session Command Handlers
 
+func (h *SessionMonitorHandler) SearchCommands(w http.ResponseWriter, r *http.Request) {
+	filter, err := commandFilterFromQuery(r, true)
+	if err != nil {
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
+		return
+	}
+	h.searchCommands(w, r, filter, "Commands retrieved successfully")
+}
+
+// searchCommands replies with one page of the commands matching filter.
+// Users other than admins only see their own commands.
+func (h *SessionMonitorHandler) searchCommands(w http.ResponseWriter, r *http.Request, filter domain.CommandFilter, message string) {
+	user, ok := currentUser(h.userService, w, r)
+	if !ok {
+		return
+	}
+	if filter.UserID, ok = ownUserID(w, user, filter.UserID); !ok {
+		return
+	}
+
+	page, err := h.sessionCommandService.SearchCommands(r.Context(), filter)
+	if err != nil {
+		utils.BadRequest(w, "Failed to search commands", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, message, page)
+}
+
 func (h *SessionMonitorHandler) GetSessionCommands(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	sessionID := vars["session_id"]
 
-	commands, err := h.sessionCommandService.GetSessionCommands(r.Context(), sessionID)
+	// A session's commands read oldest first unless asked otherwise
+	filter, err := commandFilterFromQuery(r, false)
 	if err != nil {
-		utils.InternalError(w, "Failed to get session commands", err.Error())
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
 		return
 	}
+	filter.SessionID = sessionID
+	h.searchCommands(w, r, filter, "Session commands retrieved successfully")
+}
 
-	utils.SuccessResponse(w, "Session commands retrieved successfully", commands)
+// commandFilterFromQuery reads a command history filter from the query
+// string. descending is the sort order used when no order is given.
+func commandFilterFromQuery(r *http.Request, descending bool) (domain.CommandFilter, error) {
+	query := r.URL.Query()
+	filter := domain.CommandFilter{
+		SessionID:   query.Get("session_id"),
+		UserID:      query.Get("user_id"),
+		ResourceID:  query.Get("resource_id"),
+		CommandType: query.Get("command_type"),
+		Status:      query.Get("status"),
+		Query:       query.Get("q"),
+		Descending:  descending,
+	}
+
+	for _, risk := range strings.Split(query.Get("risk"), ",") {
+		if risk = strings.TrimSpace(risk); risk != "" {
+			filter.Risks = append(filter.Risks, risk)
+		}
+	}
+
+	for name, dest := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*dest = t
+		}
+	}
+
+	for name, dest := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return filter, fmt.Errorf("%s must be a non-negative integer", name)
+			}
+			*dest = n
+		}
+	}
+
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		filter.Descending = false
+	case "desc":
+		filter.Descending = true
+	default:
+		return filter, errors.New("order must be asc or desc")
+	}
+
+	return filter, nil
 }
 
 func (h *SessionMonitorHandler) GetUserCommands(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	userID := vars["user_id"]
-
-	commands, err := h.sessionCommandService.GetCommandsByUser(r.Context(), userID)
+	filter, err := commandFilterFromQuery(r, true)
 	if err != nil {
-		utils.InternalError(w, "Failed to get user commands", err.Error())
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
 		return
 	}
-
-	utils.SuccessResponse(w, "User commands retrieved successfully", commands)
+	filter.UserID = mux.Vars(r)["user_id"]
+	h.searchCommands(w, r, filter, "User commands retrieved successfully")
 }
 
 func (h *SessionMonitorHandler) GetResourceCommands(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	resourceID := vars["resource_id"]
-
-	commands, err := h.sessionCommandService.GetCommandsByResource(r.Context(), resourceID)
+	filter, err := commandFilterFromQuery(r, true)
 	if err != nil {
-		utils.InternalError(w, "Failed to get resource commands", err.Error())
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
 		return
 	}
-
-	utils.SuccessResponse(w, "Resource commands retrieved successfully", commands)
+	filter.ResourceID = mux.Vars(r)["resource_id"]
+	h.searchCommands(w, r, filter, "Resource commands retrieved successfully")
 }
 
 func (h *SessionMonitorHandler) GetHighRiskCommands(w http.ResponseWriter, r *http.Request) {
-	commands, err := h.sessionCommandService.GetHighRiskCommands(r.Context())
+	filter, err := commandFilterFromQuery(r, true)
 	if err != nil {
-		utils.InternalError(w, "Failed to get high risk commands", err.Error())
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
 		return
 	}
-
-	utils.SuccessResponse(w, "High risk commands retrieved successfully", commands)
+	filter.Risks = []string{"high", "critical"}
+	h.searchCommands(w, r, filter, "High risk commands retrieved successfully")
 }
 
 // Session Recording Handlers
 
+// StartRecording starts recording a session. The protocol query parameter
+// picks the format: database protocols are recorded as query logs.
 func (h *SessionMonitorHandler) StartRecording(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	sessionID := vars["session_id"]
 
-	recording, err := h.sessionRecordingService.StartRecording(r.Context(), sessionID)
+	recording, err := h.sessionRecordingService.StartRecording(r.Context(), sessionID, r.URL.Query().Get("protocol"))
 	if err != nil {
 		utils.InternalError(w, "Failed to start recording", err.Error())
 		return
@@ -147,36 +266,379 @@ func (h *SessionMonitorHandler) GetRecording(w http.ResponseWriter, r *http.Requ
 		utils.NotFound(w, "Recording not found")
 		return
 	}
+	if !h.canViewRecording(w, r, recording) {
+		return
+	}
 
 	utils.SuccessResponse(w, "Recording retrieved successfully", recording)
 }
 
+type resizeRecordingRequest struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// ResizeRecording records a change of the client's terminal size in the
+// session's recording.
+func (h *SessionMonitorHandler) ResizeRecording(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["session_id"]
+
+	var req resizeRecordingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	if req.Width <= 0 || req.Height <= 0 {
+		utils.BadRequest(w, "Invalid request body", "width and height must be positive")
+		return
+	}
+
+	if err := h.sessionRecordingService.Resize(r.Context(), sessionID, req.Width, req.Height); err != nil {
+		utils.NotFound(w, "No active recording for session")
+		return
+	}
+
+	utils.SuccessResponse(w, "Recording resized successfully", nil)
+}
+
 func (h *SessionMonitorHandler) DownloadRecording(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	recordingID := vars["recording_id"]
 
+	recording, err := h.sessionRecordingService.GetRecordingByID(r.Context(), recordingID)
+	if err != nil {
+		utils.NotFound(w, "Recording not found")
+		return
+	}
+	if !h.canViewRecording(w, r, recording) {
+		return
+	}
+
 	data, err := h.sessionRecordingService.GetRecordingFile(r.Context(), recordingID)
 	if err != nil {
-		utils.NotFound(w, "Recording file not found")
+		utils.InternalError(w, "Failed to read recording", err.Error())
 		return
 	}
 
+	// Compressed recordings are downloaded decompressed, so the name comes
+	// from the format rather than the stored file
+	extension := ".txt"
+	switch recording.Format {
+	case "asciinema":
+		extension = ".cast"
+	case "jsonl":
+		extension = ".jsonl"
+	}
 	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", "attachment; filename=session_recording_"+recordingID+".txt")
+	w.Header().Set("Content-Disposition", "attachment; filename=session_recording_"+recordingID+extension)
 	w.Write(data)
 }
 
+// PlayRecording streams a recording with its original timing as server-sent
+// events. Playback can start at an offset in seconds or at a command of the
+// session (command_id), run at a multiple of the original speed and cut
+// pauses longer than idle_limit seconds short.
+func (h *SessionMonitorHandler) PlayRecording(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	recordingID := vars["recording_id"]
+
+	options, err := playbackOptionsFromQuery(r)
+	if err != nil {
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
+		return
+	}
+
+	recording, err := h.sessionRecordingService.GetRecordingByID(r.Context(), recordingID)
+	if err != nil {
+		utils.NotFound(w, "Recording not found")
+		return
+	}
+	if !h.canViewRecording(w, r, recording) {
+		return
+	}
+	if recording.Format != "asciinema" {
+		utils.BadRequest(w, "Recording cannot be played", "only asciinema recordings have timing")
+		return
+	}
+
+	if commandID := r.URL.Query().Get("command_id"); commandID != "" {
+		commands, err := h.sessionCommandService.GetSessionCommands(r.Context(), recording.SessionID)
+		if err != nil {
+			utils.InternalError(w, "Failed to get session commands", err.Error())
+			return
+		}
+		found := false
+		for _, command := range commands {
+			if command.ID == commandID {
+				options.Offset = max(command.Timestamp.Sub(recording.CreatedAt), 0)
+				found = true
+				break
+			}
+		}
+		if !found {
+			utils.NotFound(w, "Command not found in recording")
+			return
+		}
+	}
+
+	// Playback outlasts the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	err = h.sessionRecordingService.PlayRecording(r.Context(), recordingID, options, func(frame *domain.PlaybackFrame) error {
+		if err := writeServerSentEvent(w, "frame", frame); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if err != nil {
+		if r.Context().Err() == nil {
+			writeServerSentEvent(w, "error", map[string]string{"error": err.Error()})
+			rc.Flush()
+		}
+		return
+	}
+	writeServerSentEvent(w, "end", map[string]int64{"duration": recording.Duration})
+	rc.Flush()
+}
+
+// GetSessionTimeline returns the query log of a database session's latest
+// recording: its statements in the order they were sent, each with its
+// parameters, timing, rows, error and risk.
+func (h *SessionMonitorHandler) GetSessionTimeline(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["session_id"]
+
+	recording, err := h.sessionRecordingService.GetRecording(r.Context(), sessionID)
+	if err != nil {
+		utils.NotFound(w, "Recording not found")
+		return
+	}
+	if !h.canViewRecording(w, r, recording) {
+		return
+	}
+	if recording.Format != "jsonl" {
+		utils.BadRequest(w, "Session has no timeline", "only database sessions are recorded as query logs")
+		return
+	}
+
+	entries, err := h.sessionRecordingService.GetQueryLog(r.Context(), recording.ID)
+	if err != nil {
+		utils.InternalError(w, "Failed to read query log", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Session timeline retrieved successfully", map[string]interface{}{
+		"session_id":   sessionID,
+		"recording_id": recording.ID,
+		"entries":      entries,
+	})
+}
+
+// canViewRecording reports whether the requesting user may read the content
+// of a recording, writing the error response if not.
+func (h *SessionMonitorHandler) canViewRecording(w http.ResponseWriter, r *http.Request, recording *domain.SessionRecording) bool {
+	user, ok := currentUser(h.userService, w, r)
+	if !ok {
+		return false
+	}
+	if user.Role == adminRole || recording.UserID == user.ID {
+		return true
+	}
+
+	// Recordings made before their user was stored are matched by session
+	recorded, err := h.sessionService.GetByID(r.Context(), recording.SessionID)
+	if err == nil && recorded != nil && recorded.UserID == user.ID {
+		return true
+	}
+	utils.Forbidden(w, "Insufficient permissions")
+	return false
+}
+
+// playbackOptionsFromQuery reads playback options from the query string
+func playbackOptionsFromQuery(r *http.Request) (domain.PlaybackOptions, error) {
+	query := r.URL.Query()
+	var options domain.PlaybackOptions
+
+	seconds := map[string]*time.Duration{"offset": &options.Offset, "idle_limit": &options.IdleLimit}
+	for name, dest := range seconds {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || n < 0 {
+				return options, fmt.Errorf("%s must be a non-negative number of seconds", name)
+			}
+			*dest = time.Duration(n * float64(time.Second))
+		}
+	}
+	if query.Get("offset") != "" && query.Get("command_id") != "" {
+		return options, errors.New("offset and command_id cannot be combined")
+	}
+
+	if value := query.Get("speed"); value != "" {
+		speed, err := strconv.ParseFloat(value, 64)
+		if err != nil || speed <= 0 || speed > 64 {
+			return options, errors.New("speed must be greater than 0 and at most 64")
+		}
+		options.Speed = speed
+	}
+
+	return options, nil
+}
+
+// writeServerSentEvent writes one server-sent event with a JSON payload
+func writeServerSentEvent(w http.ResponseWriter, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// GetRecordingManifest returns the signed manifest of a finished recording
+func (h *SessionMonitorHandler) GetRecordingManifest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	recordingID := vars["recording_id"]
+
+	recording, err := h.sessionRecordingService.GetRecordingByID(r.Context(), recordingID)
+	if err != nil {
+		utils.NotFound(w, "Recording not found")
+		return
+	}
+	if !h.canViewRecording(w, r, recording) {
+		return
+	}
+
+	manifest, err := h.sessionRecordingService.GetRecordingManifest(r.Context(), recordingID)
+	if err != nil {
+		utils.NotFound(w, "Recording manifest not found")
+		return
+	}
+
+	utils.SuccessResponse(w, "Recording manifest retrieved successfully", manifest)
+}
+
+// VerifyRecording checks a recording against its signed manifest. The
+// result is also kept in the recording's metadata.
+func (h *SessionMonitorHandler) VerifyRecording(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	recordingID := vars["recording_id"]
+
+	recording, err := h.sessionRecordingService.GetRecordingByID(r.Context(), recordingID)
+	if err != nil {
+		utils.NotFound(w, "Recording not found")
+		return
+	}
+	if !h.canViewRecording(w, r, recording) {
+		return
+	}
+
+	verification, err := h.sessionRecordingService.VerifyRecording(r.Context(), recordingID)
+	if err != nil {
+		utils.BadRequest(w, "Failed to verify recording", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Recording verified", verification)
+}
+
+// GetRecordingSigningKey returns the public key recording manifests are
+// signed with, for verifying recordings offline
+func (h *SessionMonitorHandler) GetRecordingSigningKey(w http.ResponseWriter, r *http.Request) {
+	utils.SuccessResponse(w, "Recording signing key retrieved successfully", map[string]string{
+		"algorithm":  "ed25519",
+		"public_key": base64.StdEncoding.EncodeToString(h.sessionRecordingService.SigningPublicKey()),
+	})
+}
+
+// recordingFilterFromQuery reads a recording filter from the query string
+func recordingFilterFromQuery(r *http.Request) (domain.RecordingFilter, error) {
+	query := r.URL.Query()
+	filter := domain.RecordingFilter{
+		SessionID:  query.Get("session_id"),
+		UserID:     query.Get("user_id"),
+		ResourceID: query.Get("resource_id"),
+	}
+
+	for name, dest := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*dest = t
+		}
+	}
+
+	for name, dest := range map[string]*int64{"min_size": &filter.MinSize, "max_size": &filter.MaxSize} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 0 {
+				return filter, fmt.Errorf("%s must be a non-negative integer", name)
+			}
+			*dest = n
+		}
+	}
+
+	for name, dest := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return filter, fmt.Errorf("%s must be a non-negative integer", name)
+			}
+			*dest = n
+		}
+	}
+
+	return filter, nil
+}
+
+// ListRecordings lists recordings, newest first, filtered by session,
+// user, resource, date and size. Users other than admins only see their
+// own recordings.
+func (h *SessionMonitorHandler) ListRecordings(w http.ResponseWriter, r *http.Request) {
+	filter, err := recordingFilterFromQuery(r)
+	if err != nil {
+		utils.BadRequest(w, "Invalid recording filter", err.Error())
+		return
+	}
+	h.listRecordings(w, r, filter, "Recordings retrieved successfully")
+}
+
 func (h *SessionMonitorHandler) GetUserRecordings(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	userID := vars["user_id"]
 
-	recordings, err := h.sessionRecordingService.ListRecordings(r.Context(), userID)
+	filter, err := recordingFilterFromQuery(r)
+	if err != nil {
+		utils.BadRequest(w, "Invalid recording filter", err.Error())
+		return
+	}
+	filter.UserID = vars["user_id"]
+	h.listRecordings(w, r, filter, "User recordings retrieved successfully")
+}
+
+func (h *SessionMonitorHandler) listRecordings(w http.ResponseWriter, r *http.Request, filter domain.RecordingFilter, message string) {
+	user, ok := currentUser(h.userService, w, r)
+	if !ok {
+		return
+	}
+	if filter.UserID, ok = ownUserID(w, user, filter.UserID); !ok {
+		return
+	}
+
+	recordings, err := h.sessionRecordingService.ListRecordings(r.Context(), filter)
 	if err != nil {
-		utils.InternalError(w, "Failed to get user recordings", err.Error())
+		utils.InternalError(w, "Failed to get recordings", err.Error())
 		return
 	}
 
-	utils.SuccessResponse(w, "User recordings retrieved successfully", recordings)
+	utils.SuccessResponse(w, message, recordings)
 }
 
 // Proxy Handlers
@@ -263,54 +725,254 @@ func (h *SessionMonitorHandler) GetSessionProxy(w http.ResponseWriter, r *http.R
 
 // Security Alert Handlers
 
-func (h *SessionMonitorHandler) GetSessionAlerts(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	sessionID := vars["session_id"]
+type updateAlertStatusRequest struct {
+	Status string `json:"status"`
+	Notes  string `json:"notes"`
+}
+
+type reviewAlertRequest struct {
+	Notes string `json:"notes"`
+}
+
+type assignAlertRequest struct {
+	AssigneeID string `json:"assignee_id"`
+}
 
-	alerts, err := h.securityAlertService.GetAlerts(r.Context(), sessionID)
+// SearchAlerts lists alerts across sessions, newest first, for working the
+// triage queue
+func (h *SessionMonitorHandler) SearchAlerts(w http.ResponseWriter, r *http.Request) {
+	filter, err := alertFilterFromQuery(r)
 	if err != nil {
-		utils.InternalError(w, "Failed to get session alerts", err.Error())
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
 		return
 	}
+	h.searchAlerts(w, r, filter, "Alerts retrieved successfully")
+}
 
-	utils.SuccessResponse(w, "Session alerts retrieved successfully", alerts)
+func (h *SessionMonitorHandler) GetSessionAlerts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	filter, err := alertFilterFromQuery(r)
+	if err != nil {
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
+		return
+	}
+	filter.SessionID = vars["session_id"]
+	h.searchAlerts(w, r, filter, "Session alerts retrieved successfully")
 }
 
 func (h *SessionMonitorHandler) GetUserAlerts(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	userID := vars["user_id"]
+	filter, err := alertFilterFromQuery(r)
+	if err != nil {
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
+		return
+	}
+	filter.UserID = vars["user_id"]
+	h.searchAlerts(w, r, filter, "User alerts retrieved successfully")
+}
 
-	alerts, err := h.securityAlertService.GetAlertsByUser(r.Context(), userID)
+func (h *SessionMonitorHandler) GetAlertsBySeverity(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	filter, err := alertFilterFromQuery(r)
 	if err != nil {
-		utils.InternalError(w, "Failed to get user alerts", err.Error())
+		utils.BadRequest(w, "Invalid query parameters", err.Error())
 		return
 	}
+	filter.Severities = []string{vars["severity"]}
+	h.searchAlerts(w, r, filter, "Alerts retrieved successfully")
+}
 
-	utils.SuccessResponse(w, "User alerts retrieved successfully", alerts)
+// searchAlerts responds with one page of the alerts matching filter
+func (h *SessionMonitorHandler) searchAlerts(w http.ResponseWriter, r *http.Request, filter domain.AlertFilter, message string) {
+	page, err := h.securityAlertService.SearchAlerts(r.Context(), filter)
+	if err != nil {
+		utils.BadRequest(w, "Failed to get alerts", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, message, page)
 }
 
-func (h *SessionMonitorHandler) GetAlertsBySeverity(w http.ResponseWriter, r *http.Request) {
+func (h *SessionMonitorHandler) GetAlert(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	severity := vars["severity"]
+	alertID := vars["alert_id"]
 
-	alerts, err := h.securityAlertService.GetAlertsBySeverity(r.Context(), severity)
+	alert, err := h.securityAlertService.GetAlert(r.Context(), alertID)
 	if err != nil {
-		utils.InternalError(w, "Failed to get alerts by severity", err.Error())
+		utils.NotFound(w, "Alert not found")
 		return
 	}
 
-	utils.SuccessResponse(w, "Alerts retrieved successfully", alerts)
+	utils.SuccessResponse(w, "Alert retrieved successfully", alert)
 }
 
+// MarkAlertAsReviewed acknowledges an open alert, with optional notes
 func (h *SessionMonitorHandler) MarkAlertAsReviewed(w http.ResponseWriter, r *http.Request) {
+	var req reviewAlertRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.BadRequest(w, "Invalid request body", err.Error())
+			return
+		}
+	}
+	h.updateAlertStatus(w, r, domain.AlertAcknowledged, req.Notes, "Alert marked as reviewed successfully")
+}
+
+// UpdateAlertStatus moves an alert along its triage lifecycle
+func (h *SessionMonitorHandler) UpdateAlertStatus(w http.ResponseWriter, r *http.Request) {
+	var req updateAlertStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	if req.Status == "" {
+		utils.BadRequest(w, "Invalid request body", "status is required")
+		return
+	}
+	h.updateAlertStatus(w, r, req.Status, req.Notes, "Alert status updated successfully")
+}
+
+func (h *SessionMonitorHandler) updateAlertStatus(w http.ResponseWriter, r *http.Request, status, notes, message string) {
 	vars := mux.Vars(r)
 	alertID := vars["alert_id"]
 
-	err := h.securityAlertService.MarkAlertAsReviewed(r.Context(), alertID)
+	if _, err := h.securityAlertService.GetAlert(r.Context(), alertID); err != nil {
+		utils.NotFound(w, "Alert not found")
+		return
+	}
+
+	session := r.Context().Value("session").(*domain.Session)
+	alert, err := h.securityAlertService.UpdateAlertStatus(r.Context(), alertID, status, session.UserID, notes)
+	if err != nil {
+		utils.BadRequest(w, "Failed to update alert status", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, message, alert)
+}
+
+// AssignAlert hands an alert to a user, or unassigns it with an empty
+// assignee_id
+func (h *SessionMonitorHandler) AssignAlert(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	alertID := vars["alert_id"]
+
+	var req assignAlertRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	if req.AssigneeID != "" {
+		if _, err := h.userService.GetByID(r.Context(), req.AssigneeID); err != nil {
+			utils.BadRequest(w, "Invalid request body", "assignee_id is not a known user")
+			return
+		}
+	}
+
+	if _, err := h.securityAlertService.GetAlert(r.Context(), alertID); err != nil {
+		utils.NotFound(w, "Alert not found")
+		return
+	}
+
+	session := r.Context().Value("session").(*domain.Session)
+	alert, err := h.securityAlertService.AssignAlert(r.Context(), alertID, req.AssigneeID, session.UserID)
+	if err != nil {
+		utils.BadRequest(w, "Failed to assign alert", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Alert assigned successfully", alert)
+}
+
+// alertFilterFromQuery reads an alert filter from the query string. status
+// and severity take comma-separated lists.
+func alertFilterFromQuery(r *http.Request) (domain.AlertFilter, error) {
+	query := r.URL.Query()
+	filter := domain.AlertFilter{
+		SessionID:  query.Get("session_id"),
+		UserID:     query.Get("user_id"),
+		ResourceID: query.Get("resource_id"),
+		AssigneeID: query.Get("assignee_id"),
+		IncidentID: query.Get("incident_id"),
+	}
+
+	for name, dest := range map[string]*[]string{"severity": &filter.Severities, "status": &filter.Statuses} {
+		for _, value := range strings.Split(query.Get(name), ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				*dest = append(*dest, value)
+			}
+		}
+	}
+
+	for name, dest := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*dest = t
+		}
+	}
+
+	for name, dest := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return filter, fmt.Errorf("%s must be a non-negative integer", name)
+			}
+			*dest = n
+		}
+	}
+
+	return filter, nil
+}
+
+// Live Session Handlers
+
+type interruptSessionRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (h *SessionMonitorHandler) GetSessionMetrics(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["session_id"]
+
+	metrics, err := h.sessionMonitorService.GetSessionMetrics(r.Context(), sessionID)
+	if err != nil {
+		utils.InternalError(w, "Failed to get session metrics", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Session metrics retrieved successfully", metrics)
+}
+
+func (h *SessionMonitorHandler) InterruptSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["session_id"]
+
+	var req interruptSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "interrupted by reviewer"
+	}
+
+	if err := h.sessionMonitorService.InterruptSession(r.Context(), sessionID, req.Reason); err != nil {
+		utils.InternalError(w, "Failed to interrupt session", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Session interrupted successfully", nil)
+}
+
+func (h *SessionMonitorHandler) GetSessionRiskScores(w http.ResponseWriter, r *http.Request) {
+	scores, err := h.sessionRiskService.ListScores(r.Context())
 	if err != nil {
-		utils.InternalError(w, "Failed to mark alert as reviewed", err.Error())
+		utils.InternalError(w, "Failed to get session risk scores", err.Error())
 		return
 	}
 
-	utils.SuccessResponse(w, "Alert marked as reviewed successfully", nil)
+	utils.SuccessResponse(w, "Session risk scores retrieved successfully", scores)
 }
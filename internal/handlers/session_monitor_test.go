@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"secretary/alpha/internal/domain"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSessionMonitorHandler_CanViewRecording(t *testing.T) {
+	recording := &domain.SessionRecording{ID: "recording-1", SessionID: "recorded-session"}
+
+	tests := []struct {
+		name           string
+		user           *domain.User
+		recordedUserID string
+		expected       bool
+		expectedStatus int
+	}{
+		{
+			name:           "admin",
+			user:           &domain.User{ID: "admin-id", Role: "admin"},
+			recordedUserID: "other-id",
+			expected:       true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "session owner",
+			user:           &domain.User{ID: "owner-id", Role: "user"},
+			recordedUserID: "owner-id",
+			expected:       true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "other user",
+			user:           &domain.User{ID: "user-id", Role: "user"},
+			recordedUserID: "other-id",
+			expected:       false,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userService := new(MockUserService)
+			userService.On("GetByID", mock.Anything, tt.user.ID).Return(tt.user, nil)
+			sessionService := new(MockSessionService)
+			sessionService.On("GetByID", mock.Anything, "recorded-session").
+				Return(&domain.Session{ID: "recorded-session", UserID: tt.recordedUserID}, nil)
+
+			handler := NewSessionMonitorHandler(nil, nil, nil, nil, nil, nil, userService, sessionService)
+
+			req := httptest.NewRequest("GET", "/api/recordings/recording-1/download", nil)
+			req = req.WithContext(context.WithValue(req.Context(), "session", &domain.Session{UserID: tt.user.ID}))
+			w := httptest.NewRecorder()
+
+			assert.Equal(t, tt.expected, handler.canViewRecording(w, req, recording))
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+
+	t.Run("unknown user", func(t *testing.T) {
+		userService := new(MockUserService)
+		userService.On("GetByID", mock.Anything, "gone").Return(nil, errors.New("user not found"))
+		handler := NewSessionMonitorHandler(nil, nil, nil, nil, nil, nil, userService, new(MockSessionService))
+
+		req := httptest.NewRequest("GET", "/api/recordings/recording-1/download", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "session", &domain.Session{UserID: "gone"}))
+		w := httptest.NewRecorder()
+
+		assert.False(t, handler.canViewRecording(w, req, recording))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// fakeSecurityAlertService records the status changes made through it
+type fakeSecurityAlertService struct {
+	domain.SecurityAlertService
+	statuses []string
+}
+
+func (f *fakeSecurityAlertService) GetAlert(ctx context.Context, alertID string) (*domain.SecurityAlert, error) {
+	return &domain.SecurityAlert{ID: alertID, UserID: "alice"}, nil
+}
+
+func (f *fakeSecurityAlertService) UpdateAlertStatus(ctx context.Context, alertID string, status string, reviewerID string, notes string) (*domain.SecurityAlert, error) {
+	f.statuses = append(f.statuses, status)
+	return &domain.SecurityAlert{ID: alertID, Status: status}, nil
+}
+
+func TestSessionMonitorHandler_AlertTriageReviewersOnly(t *testing.T) {
+	tests := []struct {
+		name           string
+		role           string
+		expectedStatus int
+	}{
+		{name: "reviewer", role: "reviewer", expectedStatus: http.StatusOK},
+		{name: "user", role: "user", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &domain.User{ID: "user-id", Role: tt.role}
+			userService := new(MockUserService)
+			userService.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			alerts := &fakeSecurityAlertService{}
+			router := mux.NewRouter()
+			NewSessionMonitorHandler(nil, nil, nil, alerts, nil, nil, userService, nil).RegisterRoutes(router)
+
+			req := httptest.NewRequest("POST", "/alerts/alert-1/status", strings.NewReader(`{"status": "false_positive"}`))
+			req = req.WithContext(context.WithValue(req.Context(), "session", &domain.Session{UserID: user.ID}))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, []string{"false_positive"}, alerts.statuses)
+			} else {
+				assert.Empty(t, alerts.statuses)
+			}
+		})
+	}
+}
+
+// fakeRecordingService serves one recording of another user's session
+type fakeRecordingService struct {
+	domain.SessionRecordingService
+	verified bool
+}
+
+func (f *fakeRecordingService) GetRecordingByID(ctx context.Context, id string) (*domain.SessionRecording, error) {
+	return &domain.SessionRecording{ID: id, SessionID: "recorded-session", UserID: "owner-id"}, nil
+}
+
+func (f *fakeRecordingService) GetRecordingManifest(ctx context.Context, id string) (*domain.RecordingManifest, error) {
+	return &domain.RecordingManifest{}, nil
+}
+
+func (f *fakeRecordingService) VerifyRecording(ctx context.Context, id string) (*domain.RecordingVerification, error) {
+	f.verified = true
+	return &domain.RecordingVerification{}, nil
+}
+
+func TestSessionMonitorHandler_RecordingMetadataOwnersOnly(t *testing.T) {
+	user := &domain.User{ID: "user-id", Role: "user"}
+	userService := new(MockUserService)
+	userService.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	sessionService := new(MockSessionService)
+	sessionService.On("GetByID", mock.Anything, "recorded-session").
+		Return(&domain.Session{ID: "recorded-session", UserID: "owner-id"}, nil)
+	recordings := &fakeRecordingService{}
+	router := mux.NewRouter()
+	NewSessionMonitorHandler(nil, recordings, nil, nil, nil, nil, userService, sessionService).RegisterRoutes(router)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/recordings/recording-1/manifest", nil),
+		httptest.NewRequest("POST", "/recordings/recording-1/verify", nil),
+	} {
+		req = req.WithContext(context.WithValue(req.Context(), "session", &domain.Session{UserID: user.ID}))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, req.URL.Path)
+	}
+	assert.False(t, recordings.verified)
+}
+
+// fakeSessionCommandService records the filters searched through it
+type fakeSessionCommandService struct {
+	domain.SessionCommandService
+	filters []domain.CommandFilter
+}
+
+func (f *fakeSessionCommandService) SearchCommands(ctx context.Context, filter domain.CommandFilter) (*domain.CommandPage, error) {
+	f.filters = append(f.filters, filter)
+	return &domain.CommandPage{Commands: []*domain.SessionCommand{}}, nil
+}
+
+func TestSessionMonitorHandler_CommandsOwnOnly(t *testing.T) {
+	tests := []struct {
+		name           string
+		role           string
+		path           string
+		expectedStatus int
+		expectedUserID string
+	}{
+		{name: "admin searches everyone", role: "admin", path: "/commands?q=drop", expectedStatus: http.StatusOK},
+		{name: "user searches their own", role: "user", path: "/commands?q=drop", expectedStatus: http.StatusOK, expectedUserID: "user-id"},
+		{name: "user lists a resource", role: "user", path: "/resources/db-1/commands", expectedStatus: http.StatusOK, expectedUserID: "user-id"},
+		{name: "user lists high-risk", role: "user", path: "/commands/high-risk", expectedStatus: http.StatusOK, expectedUserID: "user-id"},
+		{name: "user lists another user", role: "user", path: "/users/other-id/commands", expectedStatus: http.StatusForbidden},
+		{name: "admin lists another user", role: "admin", path: "/users/other-id/commands", expectedStatus: http.StatusOK, expectedUserID: "other-id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &domain.User{ID: "user-id", Role: tt.role}
+			userService := new(MockUserService)
+			userService.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+			commands := &fakeSessionCommandService{}
+			router := mux.NewRouter()
+			NewSessionMonitorHandler(commands, nil, nil, nil, nil, nil, userService, nil).RegisterRoutes(router)
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req = req.WithContext(context.WithValue(req.Context(), "session", &domain.Session{UserID: user.ID}))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assert.Empty(t, commands.filters)
+				return
+			}
+			if assert.Len(t, commands.filters, 1) {
+				assert.Equal(t, tt.expectedUserID, commands.filters[0].UserID)
+			}
+		})
+	}
+}
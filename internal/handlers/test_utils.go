@@ -103,3 +103,7 @@ func (m *MockSessionService) List(ctx context.Context) ([]*domain.Session, error
 	}
 	return args.Get(0).([]*domain.Session), args.Error(1)
 }
+
+func (m *MockSessionService) AddObserver(observer domain.SessionObserver) {
+	m.Called(observer)
+}
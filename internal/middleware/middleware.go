@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	"secretary/alpha/internal/domain"
@@ -16,6 +19,10 @@ const (
 	SessionCookieName = "session_id"
 )
 
+// auditAPIRequest is the audit log action written for state-changing API
+// calls
+const auditAPIRequest = "api_request"
+
 // RateLimitMiddleware implements rate limiting for API endpoints
 func RateLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -115,6 +122,12 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap returns the wrapped writer, so that http.ResponseController can
+// reach its Flush and deadline methods
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // CORS middleware for handling Cross-Origin Resource Sharing
 func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -182,6 +195,71 @@ func RBAC(userService domain.UserService, roles ...string) mux.MiddlewareFunc {
 	}
 }
 
+// AuditContext attributes audit entries written while serving a request to
+// the client's IP and user agent
+func AuditContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		ctx := domain.WithAuditContext(r.Context(), domain.AuditContext{IP: ip, UserAgent: r.UserAgent()})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Audit records every state-changing API call with the caller, the resource
+// it targets, the route and the response status. It runs after
+// SessionMiddleware, and attributes the audit entries written while serving
+// the call to the caller.
+func Audit(auditLogService domain.AuditLogService) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			audit := domain.AuditContextFrom(r.Context())
+			if session, ok := r.Context().Value("session").(*domain.Session); ok {
+				audit.UserID = session.UserID
+			}
+			r = r.WithContext(domain.WithAuditContext(r.Context(), audit))
+
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rw := &responseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+			next.ServeHTTP(rw, r)
+
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+			vars := mux.Vars(r)
+			resourceID := vars["resource_id"]
+			if resourceID == "" && strings.HasPrefix(route, "/api/resources/{id}") {
+				resourceID = vars["id"]
+			}
+
+			details, _ := json.Marshal(map[string]interface{}{
+				"method": r.Method,
+				"path":   r.URL.Path,
+				"route":  route,
+				"status": rw.statusCode,
+			})
+			entry := &domain.AuditLog{ResourceID: resourceID, Action: auditAPIRequest, Details: string(details)}
+			if err := auditLogService.Create(r.Context(), entry); err != nil {
+				utils.Errorf("Failed to write audit entry for %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		})
+	}
+}
+
 func HTTPRequest(method, uri, remoteAddr string, statusCode int, duration time.Duration) {
 	utils.Infof("HTTP %s %s %s %d %s", method, uri, remoteAddr, statusCode, duration)
 }
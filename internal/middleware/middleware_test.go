@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -12,7 +13,9 @@ import (
 
 	"secretary/alpha/internal/domain"
 
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLogger(t *testing.T) {
@@ -324,3 +327,64 @@ func TestSecurityHeadersWithTLS(t *testing.T) {
 		t.Error("SecurityHeaders() Strict-Transport-Security should be set for TLS requests")
 	}
 }
+
+// fakeAuditLogService records the entries written through it together with
+// the audit context they were written in
+type fakeAuditLogService struct {
+	domain.AuditLogService
+	logs     []*domain.AuditLog
+	contexts []domain.AuditContext
+}
+
+func (s *fakeAuditLogService) Create(ctx context.Context, log *domain.AuditLog) error {
+	s.logs = append(s.logs, log)
+	s.contexts = append(s.contexts, domain.AuditContextFrom(ctx))
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	auditLogs := &fakeAuditLogService{}
+
+	session := &domain.Session{ID: "audit-session", UserID: "alice", ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, domain.GetSessionStore().Set(session))
+	defer domain.GetSessionStore().Delete(session.ID)
+
+	router := mux.NewRouter()
+	router.Use(AuditContext)
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(SessionMiddleware)
+	api.Use(Audit(auditLogs))
+	api.HandleFunc("/resources/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// Entries written by the handler are attributed to the caller
+		require.NoError(t, auditLogs.Create(r.Context(), &domain.AuditLog{Action: "resource_changed"}))
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("PUT", "GET")
+
+	for _, method := range []string{"GET", "PUT"} {
+		req := httptest.NewRequest(method, "/api/resources/db-1", nil)
+		req.RemoteAddr = "10.0.0.1:40000"
+		req.Header.Set("User-Agent", "secretary-cli")
+		req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: session.ID})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	}
+
+	// GET wrote the handler's entry; PUT wrote it and the recorded call
+	require.Len(t, auditLogs.logs, 3, "only state-changing calls are recorded")
+	caller := domain.AuditContext{UserID: "alice", IP: "10.0.0.1", UserAgent: "secretary-cli"}
+	for _, audit := range auditLogs.contexts {
+		assert.Equal(t, caller, audit)
+	}
+	assert.Equal(t, "resource_changed", auditLogs.logs[0].Action)
+	assert.Equal(t, "resource_changed", auditLogs.logs[1].Action)
+
+	call := auditLogs.logs[2]
+	assert.Equal(t, auditAPIRequest, call.Action)
+	assert.Equal(t, "db-1", call.ResourceID)
+	var details map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(call.Details), &details))
+	assert.Equal(t, "PUT", details["method"])
+	assert.Equal(t, "/api/resources/{id}", details["route"])
+	assert.Equal(t, float64(http.StatusNoContent), details["status"])
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+)
+
+const auditCheckpointColumns = `id, sequence, hash, public_key, signature, created_at`
+
+type auditCheckpointRepository struct {
+	db *sql.DB
+}
+
+func NewAuditCheckpointRepository(db *sql.DB) domain.AuditCheckpointRepository {
+	return &auditCheckpointRepository{db: db}
+}
+
+func (r *auditCheckpointRepository) Create(checkpoint *domain.AuditCheckpoint) error {
+	if checkpoint.ID == "" {
+		checkpoint.ID = uuid.New().String()
+	}
+	if checkpoint.CreatedAt.IsZero() {
+		checkpoint.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO audit_checkpoints (` + auditCheckpointColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query,
+		checkpoint.ID,
+		checkpoint.Sequence,
+		checkpoint.Hash,
+		checkpoint.PublicKey,
+		checkpoint.Signature,
+		checkpoint.CreatedAt.UTC(),
+	)
+	return err
+}
+
+// FindAll returns every checkpoint in sequence order
+func (r *auditCheckpointRepository) FindAll() ([]*domain.AuditCheckpoint, error) {
+	rows, err := r.db.Query(`SELECT ` + auditCheckpointColumns + ` FROM audit_checkpoints ORDER BY sequence, created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []*domain.AuditCheckpoint
+	for rows.Next() {
+		checkpoint, err := scanAuditCheckpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, rows.Err()
+}
+
+// FindLast returns the checkpoint of the highest sequence, or nil if there
+// is none
+func (r *auditCheckpointRepository) FindLast() (*domain.AuditCheckpoint, error) {
+	query := `SELECT ` + auditCheckpointColumns + ` FROM audit_checkpoints ORDER BY sequence DESC, created_at DESC LIMIT 1`
+	checkpoint, err := scanAuditCheckpoint(r.db.QueryRow(query))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return checkpoint, err
+}
+
+func scanAuditCheckpoint(row rowScanner) (*domain.AuditCheckpoint, error) {
+	checkpoint := &domain.AuditCheckpoint{}
+	err := row.Scan(
+		&checkpoint.ID,
+		&checkpoint.Sequence,
+		&checkpoint.Hash,
+		&checkpoint.PublicKey,
+		&checkpoint.Signature,
+		&checkpoint.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
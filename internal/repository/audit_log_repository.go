@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+)
+
+const auditLogColumns = `id, COALESCE(user_id, ''), COALESCE(resource_id, ''), action,
+			COALESCE(details, ''), COALESCE(ip, ''), COALESCE(user_agent, ''), created_at,
+			COALESCE(sequence, 0), COALESCE(prev_hash, ''), COALESCE(hash, '')`
+
+type auditLogRepository struct {
+	db *sql.DB
+}
+
+func NewAuditLogRepository(db *sql.DB) domain.AuditLogRepository {
+	return &auditLogRepository{db: db}
+}
+
+func (r *auditLogRepository) Create(log *domain.AuditLog) error {
+	if log.ID == "" {
+		log.ID = uuid.New().String()
+	}
+	if log.CreatedAt.IsZero() {
+		log.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO audit_logs (id, user_id, resource_id, action, details, ip, user_agent, created_at,
+			sequence, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query,
+		log.ID,
+		log.UserID,
+		log.ResourceID,
+		log.Action,
+		log.Details,
+		log.IP,
+		log.UserAgent,
+		log.CreatedAt.UTC(),
+		nullSequence(log.Sequence),
+		log.PrevHash,
+		log.Hash,
+	)
+	return err
+}
+
+func (r *auditLogRepository) FindByID(id string) (*domain.AuditLog, error) {
+	query := `SELECT ` + auditLogColumns + ` FROM audit_logs WHERE id = ?`
+	log, err := scanAuditLog(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("audit log not found")
+	}
+	return log, err
+}
+
+func (r *auditLogRepository) FindAll() ([]*domain.AuditLog, error) {
+	return r.query(`SELECT ` + auditLogColumns + ` FROM audit_logs ORDER BY created_at DESC`)
+}
+
+func (r *auditLogRepository) FindByUserID(userID string) ([]*domain.AuditLog, error) {
+	return r.query(`SELECT `+auditLogColumns+` FROM audit_logs WHERE user_id = ? ORDER BY created_at DESC`, userID)
+}
+
+func (r *auditLogRepository) FindByResourceID(resourceID string) ([]*domain.AuditLog, error) {
+	return r.query(`SELECT `+auditLogColumns+` FROM audit_logs WHERE resource_id = ? ORDER BY created_at DESC`, resourceID)
+}
+
+func (r *auditLogRepository) FindByAction(action string) ([]*domain.AuditLog, error) {
+	return r.query(`SELECT `+auditLogColumns+` FROM audit_logs WHERE action = ? ORDER BY created_at DESC`, action)
+}
+
+func (r *auditLogRepository) FindByDateRange(startDate, endDate time.Time) ([]*domain.AuditLog, error) {
+	return r.query(`SELECT `+auditLogColumns+` FROM audit_logs WHERE created_at >= ? AND created_at < ? ORDER BY created_at DESC`,
+		startDate.UTC(), endDate.UTC())
+}
+
+func (r *auditLogRepository) FindBySession(sessionID, userID string, start, end time.Time) ([]*domain.AuditLog, error) {
+	return r.query(`SELECT `+auditLogColumns+` FROM audit_logs
+		WHERE (user_id = ? AND created_at >= ? AND created_at <= ?) OR details LIKE ? ESCAPE '\'
+		ORDER BY created_at`,
+		userID, start.UTC(), end.UTC(), "%"+likeEscaper.Replace(sessionID)+"%")
+}
+
+// nullSequence stores entries outside the chain without a sequence, so the
+// unique index only applies to chained entries
+func nullSequence(sequence int64) interface{} {
+	if sequence == 0 {
+		return nil
+	}
+	return sequence
+}
+
+// FindLast returns the entry at the head of the chain, or nil if there is none
+func (r *auditLogRepository) FindLast() (*domain.AuditLog, error) {
+	query := `SELECT ` + auditLogColumns + ` FROM audit_logs WHERE sequence IS NOT NULL ORDER BY sequence DESC LIMIT 1`
+	log, err := scanAuditLog(r.db.QueryRow(query))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return log, err
+}
+
+// FindChain returns up to limit chained entries after afterSequence, in
+// sequence order
+func (r *auditLogRepository) FindChain(afterSequence int64, limit int) ([]*domain.AuditLog, error) {
+	return r.query(`SELECT `+auditLogColumns+` FROM audit_logs WHERE sequence > ? ORDER BY sequence LIMIT ?`,
+		afterSequence, limit)
+}
+
+// CountUnchained returns the number of entries written before the chain was
+// introduced
+func (r *auditLogRepository) CountUnchained() (int64, error) {
+	var count int64
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM audit_logs WHERE sequence IS NULL`).Scan(&count)
+	return count, err
+}
+
+func (r *auditLogRepository) query(query string, args ...interface{}) ([]*domain.AuditLog, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []*domain.AuditLog
+	for rows.Next() {
+		log, err := scanAuditLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+	return logs, rows.Err()
+}
+
+func scanAuditLog(row rowScanner) (*domain.AuditLog, error) {
+	log := &domain.AuditLog{}
+	err := row.Scan(
+		&log.ID,
+		&log.UserID,
+		&log.ResourceID,
+		&log.Action,
+		&log.Details,
+		&log.IP,
+		&log.UserAgent,
+		&log.CreatedAt,
+		&log.Sequence,
+		&log.PrevHash,
+		&log.Hash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return log, nil
+}
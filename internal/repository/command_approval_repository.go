@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+)
+
+const commandApprovalColumns = `id, session_id, command_id, user_id, resource_id, command, command_type, risk,
+			status, reviewer_id, review_notes, requested_at, reviewed_at, expires_at`
+
+type commandApprovalRepository struct {
+	db *sql.DB
+}
+
+func NewCommandApprovalRepository(db *sql.DB) domain.CommandApprovalRepository {
+	return &commandApprovalRepository{db: db}
+}
+
+func (r *commandApprovalRepository) Create(approval *domain.CommandApproval) error {
+	if approval.ID == "" {
+		approval.ID = uuid.New().String()
+	}
+	if approval.RequestedAt.IsZero() {
+		approval.RequestedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO command_approvals (` + commandApprovalColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query,
+		approval.ID,
+		approval.SessionID,
+		approval.CommandID,
+		approval.UserID,
+		approval.ResourceID,
+		approval.Command,
+		approval.CommandType,
+		approval.Risk,
+		approval.Status,
+		approval.ReviewerID,
+		approval.ReviewNotes,
+		approval.RequestedAt.UTC(),
+		nullTime(approval.ReviewedAt),
+		approval.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+// Update stores the outcome of an approval
+func (r *commandApprovalRepository) Update(approval *domain.CommandApproval) error {
+	query := `
+		UPDATE command_approvals
+		SET status = ?, reviewer_id = ?, review_notes = ?, reviewed_at = ?
+		WHERE id = ?
+	`
+	result, err := r.db.Exec(query,
+		approval.Status,
+		approval.ReviewerID,
+		approval.ReviewNotes,
+		nullTime(approval.ReviewedAt),
+		approval.ID,
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("command approval not found")
+	}
+	return nil
+}
+
+func (r *commandApprovalRepository) FindByID(id string) (*domain.CommandApproval, error) {
+	query := `SELECT ` + commandApprovalColumns + ` FROM command_approvals WHERE id = ?`
+	approval, err := scanCommandApproval(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("command approval not found")
+	}
+	return approval, err
+}
+
+// FindPending returns the approvals still pending at now, oldest first
+func (r *commandApprovalRepository) FindPending(now time.Time) ([]*domain.CommandApproval, error) {
+	return r.query(`SELECT `+commandApprovalColumns+` FROM command_approvals
+		WHERE status = 'pending' AND expires_at > ? ORDER BY requested_at`, now.UTC())
+}
+
+func (r *commandApprovalRepository) FindBySessionID(sessionID string) ([]*domain.CommandApproval, error) {
+	return r.query(`SELECT `+commandApprovalColumns+` FROM command_approvals WHERE session_id = ? ORDER BY requested_at`, sessionID)
+}
+
+func (r *commandApprovalRepository) query(query string, args ...interface{}) ([]*domain.CommandApproval, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	approvals := make([]*domain.CommandApproval, 0)
+	for rows.Next() {
+		approval, err := scanCommandApproval(rows)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, approval)
+	}
+	return approvals, rows.Err()
+}
+
+func scanCommandApproval(row rowScanner) (*domain.CommandApproval, error) {
+	approval := &domain.CommandApproval{}
+	var reviewerID, reviewNotes sql.NullString
+	var reviewedAt sql.NullTime
+
+	err := row.Scan(
+		&approval.ID,
+		&approval.SessionID,
+		&approval.CommandID,
+		&approval.UserID,
+		&approval.ResourceID,
+		&approval.Command,
+		&approval.CommandType,
+		&approval.Risk,
+		&approval.Status,
+		&reviewerID,
+		&reviewNotes,
+		&approval.RequestedAt,
+		&reviewedAt,
+		&approval.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	approval.ReviewerID = reviewerID.String
+	approval.ReviewNotes = reviewNotes.String
+	if reviewedAt.Valid {
+		approval.ReviewedAt = reviewedAt.Time
+	}
+	return approval, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"secretary/alpha/internal/domain"
+)
+
+func TestCommandApprovalRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewCommandApprovalRepository(db)
+	now := time.Now()
+
+	approval := &domain.CommandApproval{
+		SessionID:   "session-1",
+		UserID:      "user-1",
+		ResourceID:  "prod-db",
+		Command:     "DELETE FROM accounts",
+		CommandType: "postgresql",
+		Risk:        "high",
+		Status:      "pending",
+		ExpiresAt:   now.Add(time.Minute),
+	}
+	if err := repo.Create(approval); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if approval.ID == "" || approval.RequestedAt.IsZero() {
+		t.Fatal("Create() should set ID and RequestedAt")
+	}
+	expired := &domain.CommandApproval{SessionID: "session-2", Command: "DROP TABLE accounts", Status: "pending", ExpiresAt: now.Add(-time.Second)}
+	if err := repo.Create(expired); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	pending, err := repo.FindPending(now)
+	if err != nil {
+		t.Fatalf("FindPending() error = %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != approval.ID {
+		t.Errorf("FindPending() = %+v, want only the unexpired approval", pending)
+	}
+
+	approval.Status = "approved"
+	approval.ReviewerID = "reviewer-1"
+	approval.ReviewNotes = "ok"
+	approval.ReviewedAt = now
+	if err := repo.Update(approval); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	found, err := repo.FindByID(approval.ID)
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	if found.Status != "approved" || found.ReviewerID != "reviewer-1" || found.ReviewNotes != "ok" || found.ReviewedAt.IsZero() {
+		t.Errorf("FindByID() = %+v, want the decided approval", found)
+	}
+	if found.Command != "DELETE FROM accounts" || found.Risk != "high" || found.ResourceID != "prod-db" {
+		t.Errorf("FindByID() = %+v, want the held command", found)
+	}
+
+	approvals, err := repo.FindBySessionID("session-1")
+	if err != nil {
+		t.Fatalf("FindBySessionID() error = %v", err)
+	}
+	if len(approvals) != 1 || approvals[0].ID != approval.ID {
+		t.Errorf("FindBySessionID() = %+v, want the session's approval", approvals)
+	}
+
+	if _, err := repo.FindByID("missing"); err == nil {
+		t.Error("FindByID() should fail for an unknown approval")
+	}
+	if err := repo.Update(&domain.CommandApproval{ID: "missing"}); err == nil {
+		t.Error("Update() should fail for an unknown approval")
+	}
+}
@@ -45,6 +45,7 @@ func runMigrations(db *sql.DB) error {
 		name TEXT NOT NULL UNIQUE,
 		description TEXT,
 		type TEXT,
+		sensitivity TEXT,
 		created_at DATETIME NOT NULL,
 		updated_at DATETIME NOT NULL
 	);
@@ -113,6 +114,177 @@ func runMigrations(db *sql.DB) error {
 		duration TEXT NOT NULL,
 		used BOOLEAN NOT NULL DEFAULT FALSE
 	);
+
+	CREATE TABLE IF NOT EXISTS session_commands (
+		id TEXT PRIMARY KEY,
+		session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+		user_id TEXT NOT NULL,
+		resource_id TEXT NOT NULL,
+		command TEXT NOT NULL,
+		command_type TEXT NOT NULL,
+		response TEXT,
+		status TEXT NOT NULL,
+		risk TEXT NOT NULL,
+		timestamp DATETIME NOT NULL,
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		rows_affected INTEGER NOT NULL DEFAULT 0,
+		response_truncated BOOLEAN NOT NULL DEFAULT FALSE,
+		action TEXT,
+		decision_reason TEXT,
+		approval_id TEXT,
+		approval_status TEXT,
+		reviewer_id TEXT,
+		decided_at DATETIME,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_logs (
+		id TEXT PRIMARY KEY,
+		user_id TEXT,
+		resource_id TEXT,
+		action TEXT NOT NULL,
+		details TEXT,
+		ip TEXT,
+		user_agent TEXT,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS session_recordings (
+		id TEXT PRIMARY KEY,
+		session_id TEXT NOT NULL,
+		user_id TEXT NOT NULL DEFAULT '',
+		resource_id TEXT NOT NULL DEFAULT '',
+		recording_path TEXT NOT NULL,
+		storage_key TEXT NOT NULL,
+		format TEXT NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		duration INTEGER NOT NULL DEFAULT 0,
+		command_count INTEGER NOT NULL DEFAULT 0,
+		manifest_path TEXT,
+		verification TEXT,
+		compressed BOOLEAN NOT NULL DEFAULT FALSE,
+		tier TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS command_approvals (
+		id TEXT PRIMARY KEY,
+		session_id TEXT NOT NULL,
+		command_id TEXT NOT NULL DEFAULT '',
+		user_id TEXT NOT NULL DEFAULT '',
+		resource_id TEXT NOT NULL DEFAULT '',
+		command TEXT NOT NULL,
+		command_type TEXT NOT NULL DEFAULT '',
+		risk TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		reviewer_id TEXT,
+		review_notes TEXT,
+		requested_at DATETIME NOT NULL,
+		reviewed_at DATETIME,
+		expires_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_checkpoints (
+		id TEXT PRIMARY KEY,
+		sequence INTEGER NOT NULL,
+		hash TEXT NOT NULL,
+		public_key TEXT NOT NULL,
+		signature TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS legal_holds (
+		id TEXT PRIMARY KEY,
+		session_id TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS security_alerts (
+		id TEXT PRIMARY KEY,
+		session_id TEXT NOT NULL,
+		command_id TEXT,
+		user_id TEXT NOT NULL DEFAULT '',
+		resource_id TEXT NOT NULL DEFAULT '',
+		alert_type TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT,
+		raw_data TEXT,
+		action TEXT,
+		incident_id TEXT,
+		status TEXT NOT NULL,
+		assignee_id TEXT,
+		reviewer_id TEXT,
+		review_notes TEXT,
+		acknowledged_at DATETIME,
+		resolved_at DATETIME,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS incidents (
+		id TEXT PRIMARY KEY,
+		rule TEXT NOT NULL,
+		correlation_key TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		resource_id TEXT NOT NULL,
+		session_id TEXT NOT NULL,
+		alert_type TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		title TEXT NOT NULL,
+		status TEXT NOT NULL,
+		alert_count INTEGER NOT NULL,
+		first_seen DATETIME NOT NULL,
+		last_seen DATETIME NOT NULL,
+		resolved_by TEXT,
+		resolved_at DATETIME,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS notification_dead_letters (
+		id TEXT PRIMARY KEY,
+		alert_id TEXT NOT NULL,
+		channel TEXT NOT NULL,
+		attempts INTEGER NOT NULL,
+		last_error TEXT NOT NULL,
+		alert TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_command_approvals_session ON command_approvals(session_id, requested_at);
+	CREATE INDEX IF NOT EXISTS idx_command_approvals_status ON command_approvals(status, expires_at);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_checkpoints_sequence ON audit_checkpoints(sequence);
+
+	CREATE INDEX IF NOT EXISTS idx_legal_holds_session ON legal_holds(session_id);
+
+	CREATE INDEX IF NOT EXISTS idx_security_alerts_session ON security_alerts(session_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_security_alerts_user ON security_alerts(user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_security_alerts_status ON security_alerts(status, created_at);
+	CREATE INDEX IF NOT EXISTS idx_security_alerts_assignee ON security_alerts(assignee_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_security_alerts_created ON security_alerts(created_at);
+
+	CREATE INDEX IF NOT EXISTS idx_incidents_key ON incidents(correlation_key, status, last_seen);
+	CREATE INDEX IF NOT EXISTS idx_incidents_last_seen ON incidents(last_seen);
+
+	CREATE INDEX IF NOT EXISTS idx_session_recordings_session ON session_recordings(session_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_session_recordings_user ON session_recordings(user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_session_recordings_resource ON session_recordings(resource_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_session_recordings_created ON session_recordings(created_at);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_logs_user ON audit_logs(user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action, created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_logs_created ON audit_logs(created_at);
+
+	CREATE INDEX IF NOT EXISTS idx_session_commands_session ON session_commands(session_id, timestamp);
+	CREATE INDEX IF NOT EXISTS idx_session_commands_user ON session_commands(user_id, timestamp);
+	CREATE INDEX IF NOT EXISTS idx_session_commands_resource ON session_commands(resource_id, timestamp);
+	CREATE INDEX IF NOT EXISTS idx_session_commands_risk ON session_commands(risk, timestamp);
+	CREATE INDEX IF NOT EXISTS idx_session_commands_timestamp ON session_commands(timestamp);
 	`
 
 	_, err := db.Exec(createTablesSQL)
@@ -121,7 +293,52 @@ func runMigrations(db *sql.DB) error {
 	}
 
 	// Run additional migrations for existing databases
-	err = runAdditionalMigrations(db)
+	if err := runAdditionalMigrations(db); err != nil {
+		return err
+	}
+
+	return runCommandSearchMigrations(db)
+}
+
+// runCommandSearchMigrations sets up the FTS5 index over session command
+// text. FTS5 is only available when the SQLite driver is built with the
+// sqlite_fts5 tag; without it the index triggers are dropped, so that
+// commands can still be written, and searches fall back to LIKE.
+func runCommandSearchMigrations(db *sql.DB) error {
+	_, err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS session_commands_fts USING fts5(id UNINDEXED, command)`)
+	if err != nil {
+		_, err = db.Exec(`
+		DROP TRIGGER IF EXISTS session_commands_fts_insert;
+		DROP TRIGGER IF EXISTS session_commands_fts_delete;
+		DROP TRIGGER IF EXISTS session_commands_fts_update;
+		`)
+		return err
+	}
+
+	_, err = db.Exec(`
+	CREATE TRIGGER IF NOT EXISTS session_commands_fts_insert AFTER INSERT ON session_commands BEGIN
+		INSERT INTO session_commands_fts (id, command) VALUES (new.id, new.command);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS session_commands_fts_delete AFTER DELETE ON session_commands BEGIN
+		DELETE FROM session_commands_fts WHERE id = old.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS session_commands_fts_update AFTER UPDATE OF command ON session_commands BEGIN
+		DELETE FROM session_commands_fts WHERE id = old.id;
+		INSERT INTO session_commands_fts (id, command) VALUES (new.id, new.command);
+	END;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create command search triggers: %w", err)
+	}
+
+	// Index commands written while the index was unavailable
+	_, err = db.Exec(`
+		INSERT INTO session_commands_fts (id, command)
+		SELECT id, command FROM session_commands
+		WHERE id NOT IN (SELECT id FROM session_commands_fts)
+	`)
 	return err
 }
 
@@ -134,9 +351,16 @@ func runAdditionalMigrations(db *sql.DB) error {
 	}{
 		{"users", "name", "TEXT"},
 		{"resources", "type", "TEXT"},
+		{"resources", "sensitivity", "TEXT"},
 		{"credentials", "type", "TEXT"},
 		{"credentials", "secret", "TEXT"},
 		{"permissions", "role", "TEXT"},
+		{"session_commands", "rows_affected", "INTEGER NOT NULL DEFAULT 0"},
+		{"session_commands", "response_truncated", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"security_alerts", "incident_id", "TEXT"},
+		{"audit_logs", "sequence", "INTEGER"},
+		{"audit_logs", "prev_hash", "TEXT"},
+		{"audit_logs", "hash", "TEXT"},
 	}
 
 	for _, migration := range migrations {
@@ -155,6 +379,15 @@ func runAdditionalMigrations(db *sql.DB) error {
 		}
 	}
 
+	// Indexes on added columns
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_security_alerts_incident ON security_alerts(incident_id, created_at)`); err != nil {
+		return fmt.Errorf("failed to create index on security_alerts.incident_id: %w", err)
+	}
+	// A unique sequence keeps two writers from forking the audit log chain
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_sequence ON audit_logs(sequence)`); err != nil {
+		return fmt.Errorf("failed to create index on audit_logs.sequence: %w", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+)
+
+const incidentColumns = `id, rule, correlation_key, user_id, resource_id, session_id, alert_type, severity, title,
+			status, alert_count, first_seen, last_seen, resolved_by, resolved_at, created_at, updated_at`
+
+type incidentRepository struct {
+	db *sql.DB
+}
+
+func NewIncidentRepository(db *sql.DB) domain.IncidentRepository {
+	return &incidentRepository{db: db}
+}
+
+func (r *incidentRepository) Create(incident *domain.Incident) error {
+	if incident.ID == "" {
+		incident.ID = uuid.New().String()
+	}
+	if incident.CreatedAt.IsZero() {
+		incident.CreatedAt = time.Now()
+	}
+	if incident.UpdatedAt.IsZero() {
+		incident.UpdatedAt = incident.CreatedAt
+	}
+	if incident.Status == "" {
+		incident.Status = domain.IncidentOpen
+	}
+
+	query := `
+		INSERT INTO incidents (` + incidentColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query,
+		incident.ID,
+		incident.Rule,
+		incident.Key,
+		incident.UserID,
+		incident.ResourceID,
+		incident.SessionID,
+		incident.AlertType,
+		incident.Severity,
+		incident.Title,
+		incident.Status,
+		incident.AlertCount,
+		incident.FirstSeen.UTC(),
+		incident.LastSeen.UTC(),
+		incident.ResolvedBy,
+		nullTime(incident.ResolvedAt),
+		incident.CreatedAt.UTC(),
+		incident.UpdatedAt.UTC(),
+	)
+	return err
+}
+
+// Update stores an incident's aggregates and status; what it groups does
+// not change
+func (r *incidentRepository) Update(incident *domain.Incident) error {
+	query := `
+		UPDATE incidents SET
+			severity = ?, status = ?, alert_count = ?, last_seen = ?,
+			resolved_by = ?, resolved_at = ?, updated_at = ?
+		WHERE id = ?
+	`
+	result, err := r.db.Exec(query,
+		incident.Severity,
+		incident.Status,
+		incident.AlertCount,
+		incident.LastSeen.UTC(),
+		incident.ResolvedBy,
+		nullTime(incident.ResolvedAt),
+		incident.UpdatedAt.UTC(),
+		incident.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("incident not found")
+	}
+	return nil
+}
+
+func (r *incidentRepository) FindByID(id string) (*domain.Incident, error) {
+	query := `SELECT ` + incidentColumns + ` FROM incidents WHERE id = ?`
+	incident, err := scanIncident(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("incident not found")
+	}
+	return incident, err
+}
+
+func (r *incidentRepository) FindOpenByKey(key string) (*domain.Incident, error) {
+	query := `SELECT ` + incidentColumns + ` FROM incidents
+		WHERE correlation_key = ? AND status = ?
+		ORDER BY last_seen DESC LIMIT 1`
+	incident, err := scanIncident(r.db.QueryRow(query, key, domain.IncidentOpen))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("incident not found")
+	}
+	return incident, err
+}
+
+// Search returns the incidents matching filter, most recently active first
+// and one page at a time, along with the total number of matches.
+func (r *incidentRepository) Search(filter domain.IncidentFilter) ([]*domain.Incident, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.ResourceID != "" {
+		conditions = append(conditions, "resource_id = ?")
+		args = append(args, filter.ResourceID)
+	}
+	in := []struct {
+		column string
+		values []string
+	}{
+		{"severity", filter.Severities},
+		{"status", filter.Statuses},
+	}
+	for _, e := range in {
+		if len(e.values) > 0 {
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(e.values)), ", ")
+			conditions = append(conditions, e.column+" IN ("+placeholders+")")
+			for _, value := range e.values {
+				args = append(args, value)
+			}
+		}
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "last_seen >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "last_seen < ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM incidents`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + incidentColumns + ` FROM incidents` + where + " ORDER BY last_seen DESC, id"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	} else if filter.Offset > 0 {
+		query += " LIMIT -1 OFFSET ?"
+		args = append(args, filter.Offset)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	incidents := make([]*domain.Incident, 0)
+	for rows.Next() {
+		incident, err := scanIncident(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		incidents = append(incidents, incident)
+	}
+	return incidents, total, rows.Err()
+}
+
+func scanIncident(row rowScanner) (*domain.Incident, error) {
+	incident := &domain.Incident{}
+	var resolvedBy sql.NullString
+	var resolvedAt sql.NullTime
+
+	err := row.Scan(
+		&incident.ID,
+		&incident.Rule,
+		&incident.Key,
+		&incident.UserID,
+		&incident.ResourceID,
+		&incident.SessionID,
+		&incident.AlertType,
+		&incident.Severity,
+		&incident.Title,
+		&incident.Status,
+		&incident.AlertCount,
+		&incident.FirstSeen,
+		&incident.LastSeen,
+		&resolvedBy,
+		&resolvedAt,
+		&incident.CreatedAt,
+		&incident.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	incident.ResolvedBy = resolvedBy.String
+	if resolvedAt.Valid {
+		incident.ResolvedAt = resolvedAt.Time
+	}
+	return incident, nil
+}
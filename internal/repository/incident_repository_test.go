@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"secretary/alpha/internal/domain"
+)
+
+func TestIncidentRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewIncidentRepository(db)
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	incidents := []*domain.Incident{
+		{Key: "default|alice", UserID: "alice", Severity: "high", FirstSeen: base, LastSeen: base},
+		{Key: "default|alice", UserID: "alice", Severity: "critical", FirstSeen: base, LastSeen: base.Add(time.Hour), Status: domain.IncidentResolved},
+		{Key: "default|bob", UserID: "bob", Severity: "high", FirstSeen: base, LastSeen: base.Add(2 * time.Hour)},
+	}
+	for _, incident := range incidents {
+		incident.Rule = "default"
+		incident.AlertType = "suspicious_command"
+		incident.Title = incident.Severity + " incident"
+		incident.AlertCount = 1
+		if err := repo.Create(incident); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if incident.ID == "" {
+			t.Fatal("Create() should set ID")
+		}
+	}
+
+	// Resolved incidents do not take new alerts
+	open, err := repo.FindOpenByKey("default|alice")
+	if err != nil {
+		t.Fatalf("FindOpenByKey() error = %v", err)
+	}
+	if open.ID != incidents[0].ID {
+		t.Errorf("FindOpenByKey() = %s, want the open incident %s", open.ID, incidents[0].ID)
+	}
+	if _, err := repo.FindOpenByKey("default|carol"); err == nil {
+		t.Error("FindOpenByKey() should fail without an open incident")
+	}
+
+	tests := []struct {
+		name      string
+		filter    domain.IncidentFilter
+		want      []*domain.Incident
+		wantTotal int
+	}{
+		{"all, most recently active first", domain.IncidentFilter{}, []*domain.Incident{incidents[2], incidents[1], incidents[0]}, 3},
+		{"by user", domain.IncidentFilter{UserID: "alice"}, []*domain.Incident{incidents[1], incidents[0]}, 2},
+		{"by status", domain.IncidentFilter{Statuses: []string{domain.IncidentOpen}}, []*domain.Incident{incidents[2], incidents[0]}, 2},
+		{"by severity", domain.IncidentFilter{Severities: []string{"critical"}}, []*domain.Incident{incidents[1]}, 1},
+		{"to", domain.IncidentFilter{To: base.Add(time.Hour)}, []*domain.Incident{incidents[0]}, 1},
+		{"paged", domain.IncidentFilter{Limit: 1, Offset: 2}, []*domain.Incident{incidents[0]}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, total, err := repo.Search(tt.filter)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("Search() total = %d, want %d", total, tt.wantTotal)
+			}
+			if len(found) != len(tt.want) {
+				t.Fatalf("Search() returned %d incidents, want %d", len(found), len(tt.want))
+			}
+			for i := range found {
+				if found[i].ID != tt.want[i].ID {
+					t.Errorf("Search()[%d] = %s, want %s", i, found[i].ID, tt.want[i].ID)
+				}
+			}
+		})
+	}
+
+	incident := incidents[0]
+	incident.AlertCount = 40
+	incident.Severity = "critical"
+	incident.LastSeen = base.Add(3 * time.Hour)
+	incident.Status = domain.IncidentResolved
+	incident.ResolvedBy = "carol"
+	incident.ResolvedAt = base.Add(4 * time.Hour)
+	if err := repo.Update(incident); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	found, err := repo.FindByID(incident.ID)
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	if found.AlertCount != 40 || found.Severity != "critical" || found.Status != domain.IncidentResolved ||
+		found.ResolvedBy != "carol" || !found.ResolvedAt.Equal(incident.ResolvedAt) || !found.LastSeen.Equal(incident.LastSeen) {
+		t.Errorf("FindByID() = %+v, want the updated incident", found)
+	}
+	if found.Key != "default|alice" {
+		t.Errorf("FindByID() key = %q, want %q", found.Key, "default|alice")
+	}
+
+	if _, err := repo.FindByID("missing"); err == nil {
+		t.Error("FindByID() should fail for a missing incident")
+	}
+	if err := repo.Update(&domain.Incident{ID: "missing"}); err == nil {
+		t.Error("Update() should fail for a missing incident")
+	}
+}
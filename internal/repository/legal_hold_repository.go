@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+)
+
+const legalHoldColumns = `id, session_id, reason, created_by, created_at`
+
+type legalHoldRepository struct {
+	db *sql.DB
+}
+
+func NewLegalHoldRepository(db *sql.DB) domain.LegalHoldRepository {
+	return &legalHoldRepository{db: db}
+}
+
+func (r *legalHoldRepository) Create(hold *domain.LegalHold) error {
+	if hold.ID == "" {
+		hold.ID = uuid.New().String()
+	}
+	if hold.CreatedAt.IsZero() {
+		hold.CreatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO legal_holds (` + legalHoldColumns + `)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query, hold.ID, hold.SessionID, hold.Reason, hold.CreatedBy, hold.CreatedAt.UTC())
+	return err
+}
+
+func (r *legalHoldRepository) Delete(id string) error {
+	result, err := r.db.Exec(`DELETE FROM legal_holds WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("legal hold not found")
+	}
+	return nil
+}
+
+func (r *legalHoldRepository) FindByID(id string) (*domain.LegalHold, error) {
+	query := `SELECT ` + legalHoldColumns + ` FROM legal_holds WHERE id = ?`
+	hold, err := scanLegalHold(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("legal hold not found")
+	}
+	return hold, err
+}
+
+func (r *legalHoldRepository) FindBySessionID(sessionID string) ([]*domain.LegalHold, error) {
+	return r.query(`SELECT `+legalHoldColumns+` FROM legal_holds WHERE session_id = ? ORDER BY created_at`, sessionID)
+}
+
+func (r *legalHoldRepository) FindAll() ([]*domain.LegalHold, error) {
+	return r.query(`SELECT ` + legalHoldColumns + ` FROM legal_holds ORDER BY created_at DESC`)
+}
+
+func (r *legalHoldRepository) query(query string, args ...interface{}) ([]*domain.LegalHold, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []*domain.LegalHold
+	for rows.Next() {
+		hold, err := scanLegalHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, rows.Err()
+}
+
+func scanLegalHold(row rowScanner) (*domain.LegalHold, error) {
+	hold := &domain.LegalHold{}
+	err := row.Scan(&hold.ID, &hold.SessionID, &hold.Reason, &hold.CreatedBy, &hold.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"testing"
+
+	"secretary/alpha/internal/domain"
+)
+
+func TestLegalHoldRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewLegalHoldRepository(db)
+
+	hold := &domain.LegalHold{SessionID: "session-1", Reason: "litigation", CreatedBy: "admin-1"}
+	if err := repo.Create(hold); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if hold.ID == "" || hold.CreatedAt.IsZero() {
+		t.Fatal("Create() should set ID and CreatedAt")
+	}
+	if err := repo.Create(&domain.LegalHold{SessionID: "session-2", Reason: "audit", CreatedBy: "admin-1"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	found, err := repo.FindByID(hold.ID)
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	if found.SessionID != "session-1" || found.Reason != "litigation" || found.CreatedBy != "admin-1" {
+		t.Errorf("FindByID() = %+v, want the created hold", found)
+	}
+
+	holds, err := repo.FindBySessionID("session-1")
+	if err != nil {
+		t.Fatalf("FindBySessionID() error = %v", err)
+	}
+	if len(holds) != 1 || holds[0].ID != hold.ID {
+		t.Errorf("FindBySessionID() = %+v, want the session's hold", holds)
+	}
+
+	all, err := repo.FindAll()
+	if err != nil {
+		t.Fatalf("FindAll() error = %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("FindAll() returned %d holds, want 2", len(all))
+	}
+
+	if err := repo.Delete(hold.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.FindByID(hold.ID); err == nil {
+		t.Error("FindByID() should fail for a released hold")
+	}
+	if err := repo.Delete(hold.ID); err == nil {
+		t.Error("Delete() should fail for a missing hold")
+	}
+}
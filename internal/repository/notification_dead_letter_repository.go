@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+)
+
+const notificationDeadLetterColumns = `id, alert_id, channel, attempts, last_error, alert, created_at`
+
+type notificationDeadLetterRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationDeadLetterRepository(db *sql.DB) domain.NotificationDeadLetterRepository {
+	return &notificationDeadLetterRepository{db: db}
+}
+
+func (r *notificationDeadLetterRepository) Create(letter *domain.NotificationDeadLetter) error {
+	if letter.ID == "" {
+		letter.ID = uuid.New().String()
+	}
+	if letter.CreatedAt.IsZero() {
+		letter.CreatedAt = time.Now()
+	}
+	alert, err := json.Marshal(letter.Alert)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO notification_dead_letters (` + notificationDeadLetterColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = r.db.Exec(query,
+		letter.ID,
+		letter.AlertID,
+		letter.Channel,
+		letter.Attempts,
+		letter.LastError,
+		string(alert),
+		letter.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (r *notificationDeadLetterRepository) FindByID(id string) (*domain.NotificationDeadLetter, error) {
+	query := `SELECT ` + notificationDeadLetterColumns + ` FROM notification_dead_letters WHERE id = ?`
+	letter, err := scanNotificationDeadLetter(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("dead letter not found")
+	}
+	return letter, err
+}
+
+func (r *notificationDeadLetterRepository) FindAll() ([]*domain.NotificationDeadLetter, error) {
+	rows, err := r.db.Query(`SELECT ` + notificationDeadLetterColumns + ` FROM notification_dead_letters ORDER BY created_at DESC, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := make([]*domain.NotificationDeadLetter, 0)
+	for rows.Next() {
+		letter, err := scanNotificationDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+func (r *notificationDeadLetterRepository) Delete(id string) error {
+	result, err := r.db.Exec(`DELETE FROM notification_dead_letters WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("dead letter not found")
+	}
+	return nil
+}
+
+func scanNotificationDeadLetter(row rowScanner) (*domain.NotificationDeadLetter, error) {
+	letter := &domain.NotificationDeadLetter{}
+	var alert string
+	err := row.Scan(&letter.ID, &letter.AlertID, &letter.Channel, &letter.Attempts, &letter.LastError, &alert, &letter.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(alert), &letter.Alert); err != nil {
+		return nil, err
+	}
+	return letter, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"testing"
+
+	"secretary/alpha/internal/domain"
+)
+
+func TestNotificationDeadLetterRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewNotificationDeadLetterRepository(db)
+
+	letter := &domain.NotificationDeadLetter{
+		AlertID:   "alert-1",
+		Channel:   "oncall",
+		Attempts:  5,
+		LastError: "503 Service Unavailable",
+		Alert:     &domain.SecurityAlert{ID: "alert-1", Severity: "critical", Title: "Destructive command"},
+	}
+	if err := repo.Create(letter); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if letter.ID == "" || letter.CreatedAt.IsZero() {
+		t.Fatal("Create() should set ID and CreatedAt")
+	}
+
+	found, err := repo.FindByID(letter.ID)
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	if found.Channel != "oncall" || found.Attempts != 5 || found.Alert == nil || found.Alert.Title != "Destructive command" {
+		t.Errorf("FindByID() = %+v, want the created dead letter with its alert", found)
+	}
+
+	all, err := repo.FindAll()
+	if err != nil {
+		t.Fatalf("FindAll() error = %v", err)
+	}
+	if len(all) != 1 {
+		t.Errorf("FindAll() returned %d dead letters, want 1", len(all))
+	}
+
+	if err := repo.Delete(letter.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.FindByID(letter.ID); err == nil {
+		t.Error("FindByID() should fail for a deleted dead letter")
+	}
+	if err := repo.Delete(letter.ID); err == nil {
+		t.Error("Delete() should fail for a missing dead letter")
+	}
+}
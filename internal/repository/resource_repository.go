@@ -29,16 +29,16 @@ func (r *resourceRepository) Create(resource *domain.Resource) error {
 		resource.UpdatedAt = time.Now()
 	}
 	query := `
-		INSERT INTO resources (id, name, description, type, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO resources (id, name, description, type, sensitivity, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := r.db.Exec(query, resource.ID, resource.Name, resource.Description, resource.Type, resource.CreatedAt, resource.UpdatedAt)
+	_, err := r.db.Exec(query, resource.ID, resource.Name, resource.Description, resource.Type, resource.Sensitivity, resource.CreatedAt, resource.UpdatedAt)
 	return err
 }
 
 func (r *resourceRepository) FindByID(id string) (*domain.Resource, error) {
 	query := `
-		SELECT id, name, description, type, created_at, updated_at
+		SELECT id, name, description, type, COALESCE(sensitivity, ''), created_at, updated_at
 		FROM resources
 		WHERE id = ?
 	`
@@ -48,6 +48,7 @@ func (r *resourceRepository) FindByID(id string) (*domain.Resource, error) {
 		&resource.Name,
 		&resource.Description,
 		&resource.Type,
+		&resource.Sensitivity,
 		&resource.CreatedAt,
 		&resource.UpdatedAt,
 	)
@@ -59,7 +60,7 @@ func (r *resourceRepository) FindByID(id string) (*domain.Resource, error) {
 
 func (r *resourceRepository) FindAll() ([]*domain.Resource, error) {
 	query := `
-		SELECT id, name, description, type, created_at, updated_at
+		SELECT id, name, description, type, COALESCE(sensitivity, ''), created_at, updated_at
 		FROM resources
 		ORDER BY created_at DESC
 	`
@@ -77,6 +78,7 @@ func (r *resourceRepository) FindAll() ([]*domain.Resource, error) {
 			&resource.Name,
 			&resource.Description,
 			&resource.Type,
+			&resource.Sensitivity,
 			&resource.CreatedAt,
 			&resource.UpdatedAt,
 		)
@@ -92,13 +94,14 @@ func (r *resourceRepository) Update(resource *domain.Resource) error {
 	resource.UpdatedAt = time.Now()
 	query := `
 		UPDATE resources
-		SET name = ?, description = ?, type = ?, updated_at = ?
+		SET name = ?, description = ?, type = ?, sensitivity = ?, updated_at = ?
 		WHERE id = ?
 	`
 	result, err := r.db.Exec(query,
 		resource.Name,
 		resource.Description,
 		resource.Type,
+		resource.Sensitivity,
 		resource.UpdatedAt,
 		resource.ID,
 	)
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+)
+
+const securityAlertColumns = `id, session_id, command_id, user_id, resource_id, alert_type, severity, title,
+			description, raw_data, action, incident_id, status, assignee_id, reviewer_id, review_notes,
+			acknowledged_at, resolved_at, created_at, updated_at`
+
+type securityAlertRepository struct {
+	db *sql.DB
+}
+
+func NewSecurityAlertRepository(db *sql.DB) domain.SecurityAlertRepository {
+	return &securityAlertRepository{db: db}
+}
+
+func (r *securityAlertRepository) Create(alert *domain.SecurityAlert) error {
+	if alert.ID == "" {
+		alert.ID = uuid.New().String()
+	}
+	if alert.CreatedAt.IsZero() {
+		alert.CreatedAt = time.Now()
+	}
+	if alert.UpdatedAt.IsZero() {
+		alert.UpdatedAt = alert.CreatedAt
+	}
+	if alert.Status == "" {
+		alert.Status = domain.AlertOpen
+	}
+
+	query := `
+		INSERT INTO security_alerts (` + securityAlertColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query,
+		alert.ID,
+		alert.SessionID,
+		alert.CommandID,
+		alert.UserID,
+		alert.ResourceID,
+		alert.AlertType,
+		alert.Severity,
+		alert.Title,
+		alert.Description,
+		alert.RawData,
+		alert.Action,
+		alert.IncidentID,
+		alert.Status,
+		alert.AssigneeID,
+		alert.ReviewerID,
+		alert.ReviewNotes,
+		nullTime(alert.AcknowledgedAt),
+		nullTime(alert.ResolvedAt),
+		alert.CreatedAt.UTC(),
+		alert.UpdatedAt.UTC(),
+	)
+	return err
+}
+
+// Update stores the triage state of an alert; what raised it does not change
+func (r *securityAlertRepository) Update(alert *domain.SecurityAlert) error {
+	query := `
+		UPDATE security_alerts SET
+			status = ?, assignee_id = ?, reviewer_id = ?, review_notes = ?,
+			acknowledged_at = ?, resolved_at = ?, updated_at = ?
+		WHERE id = ?
+	`
+	result, err := r.db.Exec(query,
+		alert.Status,
+		alert.AssigneeID,
+		alert.ReviewerID,
+		alert.ReviewNotes,
+		nullTime(alert.AcknowledgedAt),
+		nullTime(alert.ResolvedAt),
+		alert.UpdatedAt.UTC(),
+		alert.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("security alert not found")
+	}
+	return nil
+}
+
+func (r *securityAlertRepository) FindByID(id string) (*domain.SecurityAlert, error) {
+	query := `SELECT ` + securityAlertColumns + ` FROM security_alerts WHERE id = ?`
+	alert, err := scanSecurityAlert(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("security alert not found")
+	}
+	return alert, err
+}
+
+// Search returns the alerts matching filter, newest first and one page at a
+// time, along with the total number of matches.
+func (r *securityAlertRepository) Search(filter domain.AlertFilter) ([]*domain.SecurityAlert, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	equals := []struct {
+		column string
+		value  string
+	}{
+		{"session_id", filter.SessionID},
+		{"user_id", filter.UserID},
+		{"resource_id", filter.ResourceID},
+		{"assignee_id", filter.AssigneeID},
+		{"incident_id", filter.IncidentID},
+	}
+	for _, e := range equals {
+		if e.value != "" {
+			conditions = append(conditions, e.column+" = ?")
+			args = append(args, e.value)
+		}
+	}
+	in := []struct {
+		column string
+		values []string
+	}{
+		{"severity", filter.Severities},
+		{"status", filter.Statuses},
+	}
+	for _, e := range in {
+		if len(e.values) > 0 {
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(e.values)), ", ")
+			conditions = append(conditions, e.column+" IN ("+placeholders+")")
+			for _, value := range e.values {
+				args = append(args, value)
+			}
+		}
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM security_alerts`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + securityAlertColumns + ` FROM security_alerts` + where + " ORDER BY created_at DESC, id"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	} else if filter.Offset > 0 {
+		query += " LIMIT -1 OFFSET ?"
+		args = append(args, filter.Offset)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	alerts := make([]*domain.SecurityAlert, 0)
+	for rows.Next() {
+		alert, err := scanSecurityAlert(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, total, rows.Err()
+}
+
+func scanSecurityAlert(row rowScanner) (*domain.SecurityAlert, error) {
+	alert := &domain.SecurityAlert{}
+	var commandID, description, rawData, action, incidentID, assigneeID, reviewerID, reviewNotes sql.NullString
+	var acknowledgedAt, resolvedAt sql.NullTime
+
+	err := row.Scan(
+		&alert.ID,
+		&alert.SessionID,
+		&commandID,
+		&alert.UserID,
+		&alert.ResourceID,
+		&alert.AlertType,
+		&alert.Severity,
+		&alert.Title,
+		&description,
+		&rawData,
+		&action,
+		&incidentID,
+		&alert.Status,
+		&assigneeID,
+		&reviewerID,
+		&reviewNotes,
+		&acknowledgedAt,
+		&resolvedAt,
+		&alert.CreatedAt,
+		&alert.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	alert.CommandID = commandID.String
+	alert.Description = description.String
+	alert.RawData = rawData.String
+	alert.Action = action.String
+	alert.IncidentID = incidentID.String
+	alert.AssigneeID = assigneeID.String
+	alert.ReviewerID = reviewerID.String
+	alert.ReviewNotes = reviewNotes.String
+	if acknowledgedAt.Valid {
+		alert.AcknowledgedAt = acknowledgedAt.Time
+	}
+	if resolvedAt.Valid {
+		alert.ResolvedAt = resolvedAt.Time
+	}
+	return alert, nil
+}
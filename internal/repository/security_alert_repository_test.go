@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"secretary/alpha/internal/domain"
+)
+
+func TestSecurityAlertRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewSecurityAlertRepository(db)
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	alerts := []*domain.SecurityAlert{
+		{SessionID: "session-1", UserID: "alice", Severity: "high", CreatedAt: base},
+		{SessionID: "session-1", UserID: "alice", Severity: "critical", CreatedAt: base.Add(time.Hour)},
+		{SessionID: "session-2", UserID: "bob", Severity: "high", CreatedAt: base.Add(2 * time.Hour), Status: domain.AlertInvestigating, AssigneeID: "carol"},
+	}
+	for _, alert := range alerts {
+		alert.AlertType = "suspicious_command"
+		alert.Title = alert.Severity + " alert"
+		if err := repo.Create(alert); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if alert.ID == "" {
+			t.Fatal("Create() should set ID")
+		}
+	}
+	if alerts[0].Status != domain.AlertOpen {
+		t.Errorf("Create() status = %q, want %q", alerts[0].Status, domain.AlertOpen)
+	}
+
+	tests := []struct {
+		name      string
+		filter    domain.AlertFilter
+		want      []*domain.SecurityAlert
+		wantTotal int
+	}{
+		{"all, newest first", domain.AlertFilter{}, []*domain.SecurityAlert{alerts[2], alerts[1], alerts[0]}, 3},
+		{"by session", domain.AlertFilter{SessionID: "session-1"}, []*domain.SecurityAlert{alerts[1], alerts[0]}, 2},
+		{"by severity", domain.AlertFilter{Severities: []string{"high"}}, []*domain.SecurityAlert{alerts[2], alerts[0]}, 2},
+		{"by status", domain.AlertFilter{Statuses: []string{domain.AlertOpen}}, []*domain.SecurityAlert{alerts[1], alerts[0]}, 2},
+		{"by assignee", domain.AlertFilter{AssigneeID: "carol"}, []*domain.SecurityAlert{alerts[2]}, 1},
+		{"from", domain.AlertFilter{From: base.Add(time.Hour)}, []*domain.SecurityAlert{alerts[2], alerts[1]}, 2},
+		{"paged", domain.AlertFilter{Limit: 1, Offset: 1}, []*domain.SecurityAlert{alerts[1]}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, total, err := repo.Search(tt.filter)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("Search() total = %d, want %d", total, tt.wantTotal)
+			}
+			if len(found) != len(tt.want) {
+				t.Fatalf("Search() returned %d alerts, want %d", len(found), len(tt.want))
+			}
+			for i := range found {
+				if found[i].ID != tt.want[i].ID {
+					t.Errorf("Search()[%d] = %s, want %s", i, found[i].ID, tt.want[i].ID)
+				}
+			}
+		})
+	}
+
+	alert := alerts[0]
+	alert.Status = domain.AlertResolved
+	alert.ReviewerID = "carol"
+	alert.ReviewNotes = "expected maintenance"
+	alert.AcknowledgedAt = base.Add(time.Minute)
+	alert.ResolvedAt = base.Add(2 * time.Minute)
+	alert.UpdatedAt = alert.ResolvedAt
+	if err := repo.Update(alert); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	found, err := repo.FindByID(alert.ID)
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	if found.Status != domain.AlertResolved || found.ReviewNotes != "expected maintenance" || !found.ResolvedAt.Equal(alert.ResolvedAt) {
+		t.Errorf("FindByID() = %+v, want the updated alert", found)
+	}
+
+	if _, err := repo.FindByID("missing"); err == nil {
+		t.Error("FindByID() should fail for a missing alert")
+	}
+	if err := repo.Update(&domain.SecurityAlert{ID: "missing"}); err == nil {
+		t.Error("Update() should fail for a missing alert")
+	}
+}
@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+)
+
+const sessionCommandColumns = `id, session_id, user_id, resource_id, command, command_type, response,
+			status, risk, timestamp, duration_ms, rows_affected, response_truncated, action,
+			decision_reason, approval_id, approval_status, reviewer_id, decided_at, created_at`
+
+type sessionCommandRepository struct {
+	db *sql.DB
+	// fts is set when the FTS5 command index is available
+	fts bool
+}
+
+func NewSessionCommandRepository(db *sql.DB) domain.SessionCommandRepository {
+	_, err := db.Exec(`SELECT id FROM session_commands_fts LIMIT 0`)
+	return &sessionCommandRepository{db: db, fts: err == nil}
+}
+
+func (r *sessionCommandRepository) Create(command *domain.SessionCommand) error {
+	if command.ID == "" {
+		command.ID = uuid.New().String()
+	}
+	if command.CreatedAt.IsZero() {
+		command.CreatedAt = time.Now()
+	}
+	if command.Timestamp.IsZero() {
+		command.Timestamp = command.CreatedAt
+	}
+
+	// Times are stored in UTC so that range filters compare correctly
+	query := `
+		INSERT INTO session_commands (` + sessionCommandColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query,
+		command.ID,
+		command.SessionID,
+		command.UserID,
+		command.ResourceID,
+		command.Command,
+		command.CommandType,
+		command.Response,
+		command.Status,
+		command.Risk,
+		command.Timestamp.UTC(),
+		command.Duration,
+		command.RowsAffected,
+		command.ResponseTruncated,
+		command.Action,
+		command.DecisionReason,
+		command.ApprovalID,
+		command.ApprovalStatus,
+		command.ReviewerID,
+		nullTime(command.DecidedAt),
+		command.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (r *sessionCommandRepository) Update(command *domain.SessionCommand) error {
+	query := `
+		UPDATE session_commands SET
+			response = ?, status = ?, risk = ?, duration_ms = ?, rows_affected = ?,
+			response_truncated = ?, action = ?, decision_reason = ?, approval_id = ?,
+			approval_status = ?, reviewer_id = ?, decided_at = ?
+		WHERE id = ?
+	`
+	result, err := r.db.Exec(query,
+		command.Response,
+		command.Status,
+		command.Risk,
+		command.Duration,
+		command.RowsAffected,
+		command.ResponseTruncated,
+		command.Action,
+		command.DecisionReason,
+		command.ApprovalID,
+		command.ApprovalStatus,
+		command.ReviewerID,
+		nullTime(command.DecidedAt),
+		command.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("session command not found")
+	}
+	return nil
+}
+
+func (r *sessionCommandRepository) FindByID(id string) (*domain.SessionCommand, error) {
+	query := `SELECT ` + sessionCommandColumns + ` FROM session_commands WHERE id = ?`
+
+	command, err := scanSessionCommand(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("session command not found")
+	}
+	return command, err
+}
+
+// Search returns the commands matching filter, one page at a time, along with
+// the total number of matches.
+func (r *sessionCommandRepository) Search(filter domain.CommandFilter) ([]*domain.SessionCommand, int, error) {
+	where, args := r.where(filter)
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM session_commands`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := " ORDER BY timestamp ASC, created_at ASC"
+	if filter.Descending {
+		order = " ORDER BY timestamp DESC, created_at DESC"
+	}
+	query := `SELECT ` + sessionCommandColumns + ` FROM session_commands` + where + order
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	} else if filter.Offset > 0 {
+		query += " LIMIT -1 OFFSET ?"
+		args = append(args, filter.Offset)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	commands := make([]*domain.SessionCommand, 0)
+	for rows.Next() {
+		command, err := scanSessionCommand(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		commands = append(commands, command)
+	}
+	return commands, total, rows.Err()
+}
+
+// where builds the WHERE clause for filter. Every value is passed as a query
+// parameter.
+func (r *sessionCommandRepository) where(filter domain.CommandFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	equals := []struct {
+		column string
+		value  string
+	}{
+		{"session_id", filter.SessionID},
+		{"user_id", filter.UserID},
+		{"resource_id", filter.ResourceID},
+		{"command_type", filter.CommandType},
+		{"status", filter.Status},
+	}
+	for _, e := range equals {
+		if e.value != "" {
+			conditions = append(conditions, e.column+" = ?")
+			args = append(args, e.value)
+		}
+	}
+
+	if len(filter.Risks) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.Risks)), ", ")
+		conditions = append(conditions, "risk IN ("+placeholders+")")
+		for _, risk := range filter.Risks {
+			args = append(args, risk)
+		}
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	if terms := strings.Fields(filter.Query); len(terms) > 0 {
+		if r.fts {
+			conditions = append(conditions, "id IN (SELECT id FROM session_commands_fts WHERE session_commands_fts MATCH ?)")
+			args = append(args, ftsQuery(terms))
+		} else {
+			for _, term := range terms {
+				conditions = append(conditions, `command LIKE ? ESCAPE '\'`)
+				args = append(args, "%"+likeEscaper.Replace(term)+"%")
+			}
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// ftsQuery quotes every search term as an FTS5 string, so that operators and
+// punctuation in user input are matched rather than interpreted. All terms
+// must match.
+func ftsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSessionCommand(row rowScanner) (*domain.SessionCommand, error) {
+	command := &domain.SessionCommand{}
+	var response, action, decisionReason, approvalID, approvalStatus, reviewerID sql.NullString
+	var decidedAt sql.NullTime
+
+	err := row.Scan(
+		&command.ID,
+		&command.SessionID,
+		&command.UserID,
+		&command.ResourceID,
+		&command.Command,
+		&command.CommandType,
+		&response,
+		&command.Status,
+		&command.Risk,
+		&command.Timestamp,
+		&command.Duration,
+		&command.RowsAffected,
+		&command.ResponseTruncated,
+		&action,
+		&decisionReason,
+		&approvalID,
+		&approvalStatus,
+		&reviewerID,
+		&decidedAt,
+		&command.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	command.Response = response.String
+	command.Action = action.String
+	command.DecisionReason = decisionReason.String
+	command.ApprovalID = approvalID.String
+	command.ApprovalStatus = approvalStatus.String
+	command.ReviewerID = reviewerID.String
+	if decidedAt.Valid {
+		command.DecidedAt = decidedAt.Time
+	}
+	return command, nil
+}
+
+// nullTime stores zero times as NULL
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
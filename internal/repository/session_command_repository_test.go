@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"secretary/alpha/internal/domain"
+)
+
+func createTestCommands(t *testing.T, repo domain.SessionCommandRepository, start time.Time) {
+	commands := []*domain.SessionCommand{
+		{SessionID: "session-1", UserID: "user-1", ResourceID: "db-1", Command: "SELECT * FROM users", CommandType: "postgresql", Status: "executed", Risk: "low"},
+		{SessionID: "session-1", UserID: "user-1", ResourceID: "db-1", Command: "DROP TABLE users", CommandType: "postgresql", Status: "blocked", Risk: "critical"},
+		{SessionID: "session-2", UserID: "user-2", ResourceID: "host-1", Command: "rm -rf /var/log/app_%", CommandType: "shell", Status: "executed", Risk: "high"},
+		{SessionID: "session-2", UserID: "user-2", ResourceID: "host-1", Command: "ls -la", CommandType: "shell", Status: "executed", Risk: "low"},
+	}
+	for i, command := range commands {
+		command.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		if err := repo.Create(command); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+}
+
+func TestSessionCommandRepository_CreateAndFind(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewSessionCommandRepository(db)
+
+	command := &domain.SessionCommand{
+		SessionID:      "session-1",
+		UserID:         "user-1",
+		ResourceID:     "db-1",
+		Command:        "DELETE FROM orders",
+		CommandType:    "postgresql",
+		Status:         "pending_approval",
+		Risk:           "high",
+		Action:         "held",
+		ApprovalID:     "approval-1",
+		ApprovalStatus: "pending",
+	}
+	if err := repo.Create(command); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if command.ID == "" {
+		t.Fatal("Create() should set command ID")
+	}
+
+	command.Status = "denied"
+	command.ApprovalStatus = "denied"
+	command.ReviewerID = "reviewer-1"
+	command.DecisionReason = "not during business hours"
+	command.DecidedAt = time.Now()
+	if err := repo.Update(command); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	found, err := repo.FindByID(command.ID)
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	if found.Status != "denied" || found.ReviewerID != "reviewer-1" || found.DecisionReason != "not during business hours" {
+		t.Errorf("FindByID() = %+v, want the updated decision", found)
+	}
+	if found.DecidedAt.IsZero() {
+		t.Error("FindByID() should return DecidedAt")
+	}
+
+	if _, err := repo.FindByID("missing"); err == nil {
+		t.Error("FindByID() should fail for a missing command")
+	}
+	if err := repo.Update(&domain.SessionCommand{ID: "missing"}); err == nil {
+		t.Error("Update() should fail for a missing command")
+	}
+}
+
+func TestSessionCommandRepository_Search(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewSessionCommandRepository(db)
+	start := time.Now().Add(-time.Hour)
+	createTestCommands(t, repo, start)
+
+	tests := []struct {
+		name      string
+		filter    domain.CommandFilter
+		wantTotal int
+		want      []string
+	}{
+		{"all", domain.CommandFilter{}, 4, []string{"SELECT * FROM users", "DROP TABLE users", "rm -rf /var/log/app_%", "ls -la"}},
+		{"session", domain.CommandFilter{SessionID: "session-2"}, 2, []string{"rm -rf /var/log/app_%", "ls -la"}},
+		{"user and type", domain.CommandFilter{UserID: "user-1", CommandType: "postgresql"}, 2, []string{"SELECT * FROM users", "DROP TABLE users"}},
+		{"resource", domain.CommandFilter{ResourceID: "host-1", Status: "executed"}, 2, []string{"rm -rf /var/log/app_%", "ls -la"}},
+		{"risks", domain.CommandFilter{Risks: []string{"high", "critical"}}, 2, []string{"DROP TABLE users", "rm -rf /var/log/app_%"}},
+		{"time range", domain.CommandFilter{From: start.Add(time.Minute), To: start.Add(3 * time.Minute)}, 2, []string{"DROP TABLE users", "rm -rf /var/log/app_%"}},
+		{"descending page", domain.CommandFilter{Descending: true, Limit: 2, Offset: 1}, 4, []string{"rm -rf /var/log/app_%", "DROP TABLE users"}},
+		{"offset only", domain.CommandFilter{Offset: 3}, 4, []string{"ls -la"}},
+		{"text", domain.CommandFilter{Query: "users"}, 2, []string{"SELECT * FROM users", "DROP TABLE users"}},
+		{"all terms", domain.CommandFilter{Query: "drop users"}, 1, []string{"DROP TABLE users"}},
+		{"wildcards", domain.CommandFilter{Query: "app_%"}, 1, []string{"rm -rf /var/log/app_%"}},
+		{"operators", domain.CommandFilter{Query: `users" OR "ls`}, 0, nil},
+		{"no match", domain.CommandFilter{Query: "truncate"}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, total, err := repo.Search(tt.filter)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("Search() total = %d, want %d", total, tt.wantTotal)
+			}
+			if len(commands) != len(tt.want) {
+				t.Fatalf("Search() returned %d commands, want %d", len(commands), len(tt.want))
+			}
+			for i, command := range commands {
+				if command.Command != tt.want[i] {
+					t.Errorf("Search()[%d] = %q, want %q", i, command.Command, tt.want[i])
+				}
+			}
+		})
+	}
+}
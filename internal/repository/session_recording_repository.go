@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+)
+
+const sessionRecordingColumns = `id, session_id, user_id, resource_id, recording_path, storage_key, format,
+			size, duration, command_count, manifest_path, verification, compressed, tier, created_at`
+
+type sessionRecordingRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRecordingRepository(db *sql.DB) domain.SessionRecordingRepository {
+	return &sessionRecordingRepository{db: db}
+}
+
+func (r *sessionRecordingRepository) Create(recording *domain.SessionRecording) error {
+	if recording.ID == "" {
+		recording.ID = uuid.New().String()
+	}
+	if recording.CreatedAt.IsZero() {
+		recording.CreatedAt = time.Now()
+	}
+	verification, err := marshalVerification(recording.Verification)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO session_recordings (` + sessionRecordingColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = r.db.Exec(query,
+		recording.ID,
+		recording.SessionID,
+		recording.UserID,
+		recording.ResourceID,
+		recording.RecordingPath,
+		recording.StorageKey,
+		recording.Format,
+		recording.Size,
+		recording.Duration,
+		recording.CommandCount,
+		recording.ManifestPath,
+		verification,
+		recording.Compressed,
+		recording.Tier,
+		recording.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (r *sessionRecordingRepository) Update(recording *domain.SessionRecording) error {
+	verification, err := marshalVerification(recording.Verification)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE session_recordings SET
+			user_id = ?, resource_id = ?, recording_path = ?, storage_key = ?, size = ?,
+			duration = ?, command_count = ?, manifest_path = ?, verification = ?,
+			compressed = ?, tier = ?
+		WHERE id = ?
+	`
+	result, err := r.db.Exec(query,
+		recording.UserID,
+		recording.ResourceID,
+		recording.RecordingPath,
+		recording.StorageKey,
+		recording.Size,
+		recording.Duration,
+		recording.CommandCount,
+		recording.ManifestPath,
+		verification,
+		recording.Compressed,
+		recording.Tier,
+		recording.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("session recording not found")
+	}
+	return nil
+}
+
+func (r *sessionRecordingRepository) Delete(id string) error {
+	result, err := r.db.Exec(`DELETE FROM session_recordings WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("session recording not found")
+	}
+	return nil
+}
+
+func (r *sessionRecordingRepository) FindByID(id string) (*domain.SessionRecording, error) {
+	query := `SELECT ` + sessionRecordingColumns + ` FROM session_recordings WHERE id = ?`
+	recording, err := scanSessionRecording(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("session recording not found")
+	}
+	return recording, err
+}
+
+// FindLatestBySessionID returns the most recent recording of a session
+func (r *sessionRecordingRepository) FindLatestBySessionID(sessionID string) (*domain.SessionRecording, error) {
+	query := `SELECT ` + sessionRecordingColumns + ` FROM session_recordings
+		WHERE session_id = ? ORDER BY created_at DESC LIMIT 1`
+	recording, err := scanSessionRecording(r.db.QueryRow(query, sessionID))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("session recording not found")
+	}
+	return recording, err
+}
+
+// List returns the recordings matching filter, newest first
+func (r *sessionRecordingRepository) List(filter domain.RecordingFilter) ([]*domain.SessionRecording, error) {
+	var conditions []string
+	var args []interface{}
+
+	equals := []struct {
+		column string
+		value  string
+	}{
+		{"session_id", filter.SessionID},
+		{"user_id", filter.UserID},
+		{"resource_id", filter.ResourceID},
+	}
+	for _, e := range equals {
+		if e.value != "" {
+			conditions = append(conditions, e.column+" = ?")
+			args = append(args, e.value)
+		}
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+	if filter.MinSize > 0 {
+		conditions = append(conditions, "size >= ?")
+		args = append(args, filter.MinSize)
+	}
+	if filter.MaxSize > 0 {
+		conditions = append(conditions, "size <= ?")
+		args = append(args, filter.MaxSize)
+	}
+
+	query := `SELECT ` + sessionRecordingColumns + ` FROM session_recordings`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	} else if filter.Offset > 0 {
+		query += " LIMIT -1 OFFSET ?"
+		args = append(args, filter.Offset)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recordings := make([]*domain.SessionRecording, 0)
+	for rows.Next() {
+		recording, err := scanSessionRecording(rows)
+		if err != nil {
+			return nil, err
+		}
+		recordings = append(recordings, recording)
+	}
+	return recordings, rows.Err()
+}
+
+func scanSessionRecording(row rowScanner) (*domain.SessionRecording, error) {
+	recording := &domain.SessionRecording{}
+	var manifestPath, verification sql.NullString
+
+	err := row.Scan(
+		&recording.ID,
+		&recording.SessionID,
+		&recording.UserID,
+		&recording.ResourceID,
+		&recording.RecordingPath,
+		&recording.StorageKey,
+		&recording.Format,
+		&recording.Size,
+		&recording.Duration,
+		&recording.CommandCount,
+		&manifestPath,
+		&verification,
+		&recording.Compressed,
+		&recording.Tier,
+		&recording.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	recording.ManifestPath = manifestPath.String
+	if verification.String != "" {
+		recording.Verification = &domain.RecordingVerification{}
+		if err := json.Unmarshal([]byte(verification.String), recording.Verification); err != nil {
+			return nil, err
+		}
+	}
+	return recording, nil
+}
+
+// marshalVerification stores the latest verification of a recording as JSON
+func marshalVerification(verification *domain.RecordingVerification) (interface{}, error) {
+	if verification == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(verification)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"secretary/alpha/internal/domain"
+)
+
+func TestSessionRecordingRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewSessionRecordingRepository(db)
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	recordings := []*domain.SessionRecording{
+		{SessionID: "session-1", UserID: "alice", ResourceID: "db-1", Size: 100, CreatedAt: base},
+		{SessionID: "session-2", UserID: "alice", ResourceID: "db-2", Size: 5000, CreatedAt: base.Add(24 * time.Hour)},
+		{SessionID: "session-3", UserID: "bob", ResourceID: "db-1", Size: 20000, CreatedAt: base.Add(48 * time.Hour)},
+	}
+	for _, recording := range recordings {
+		recording.RecordingPath = "/recordings/" + recording.SessionID + ".cast"
+		recording.StorageKey = recording.SessionID + ".cast"
+		recording.Format = "asciinema"
+		recording.Tier = domain.RecordingTierHot
+		if err := repo.Create(recording); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if recording.ID == "" {
+			t.Fatal("Create() should set ID")
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter domain.RecordingFilter
+		want   []string
+	}{
+		{"all, newest first", domain.RecordingFilter{}, []string{"session-3", "session-2", "session-1"}},
+		{"by user", domain.RecordingFilter{UserID: "alice"}, []string{"session-2", "session-1"}},
+		{"by resource", domain.RecordingFilter{ResourceID: "db-1"}, []string{"session-3", "session-1"}},
+		{"by session", domain.RecordingFilter{SessionID: "session-2"}, []string{"session-2"}},
+		{"from", domain.RecordingFilter{From: base.Add(24 * time.Hour)}, []string{"session-3", "session-2"}},
+		{"to", domain.RecordingFilter{To: base.Add(24 * time.Hour)}, []string{"session-1"}},
+		{"min size", domain.RecordingFilter{MinSize: 5000}, []string{"session-3", "session-2"}},
+		{"max size", domain.RecordingFilter{MaxSize: 5000}, []string{"session-2", "session-1"}},
+		{"paged", domain.RecordingFilter{Limit: 1, Offset: 1}, []string{"session-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := repo.List(tt.filter)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			var sessions []string
+			for _, recording := range found {
+				sessions = append(sessions, recording.SessionID)
+			}
+			if len(sessions) != len(tt.want) {
+				t.Fatalf("List() = %v, want %v", sessions, tt.want)
+			}
+			for i := range sessions {
+				if sessions[i] != tt.want[i] {
+					t.Fatalf("List() = %v, want %v", sessions, tt.want)
+				}
+			}
+		})
+	}
+
+	recording := recordings[0]
+	recording.Size = 250
+	recording.Compressed = true
+	recording.ManifestPath = "/recordings/session-1.cast.manifest.json"
+	recording.Verification = &domain.RecordingVerification{RecordingID: recording.ID, Status: domain.RecordingVerified, Valid: true}
+	if err := repo.Update(recording); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	found, err := repo.FindByID(recording.ID)
+	if err != nil {
+		t.Fatalf("FindByID() error = %v", err)
+	}
+	if found.Size != 250 || !found.Compressed || found.ManifestPath != recording.ManifestPath {
+		t.Errorf("FindByID() = %+v, want the updated recording", found)
+	}
+	if found.Verification == nil || !found.Verification.Valid {
+		t.Errorf("FindByID() verification = %+v, want the stored verification", found.Verification)
+	}
+
+	if err := repo.Create(&domain.SessionRecording{
+		SessionID: "session-1", RecordingPath: "/recordings/again.cast", StorageKey: "again.cast",
+		Format: "asciinema", Tier: domain.RecordingTierHot, CreatedAt: base.Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	latest, err := repo.FindLatestBySessionID("session-1")
+	if err != nil {
+		t.Fatalf("FindLatestBySessionID() error = %v", err)
+	}
+	if latest.StorageKey != "again.cast" {
+		t.Errorf("FindLatestBySessionID() = %+v, want the latest recording", latest)
+	}
+
+	if err := repo.Delete(recording.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.FindByID(recording.ID); err == nil {
+		t.Error("FindByID() should fail for a deleted recording")
+	}
+	if err := repo.Delete(recording.ID); err == nil {
+		t.Error("Delete() should fail for a missing recording")
+	}
+}
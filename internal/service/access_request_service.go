@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -11,12 +12,19 @@ import (
 	"secretary/alpha/internal/domain"
 )
 
+// Audit log actions written for access request decisions
+const (
+	auditAccessRequestApproved = "access_request_approved"
+	auditAccessRequestDenied   = "access_request_denied"
+)
+
 type accessRequestService struct {
-	repo domain.AccessRequestRepository
+	repo            domain.AccessRequestRepository
+	auditLogService domain.AuditLogService
 }
 
-func NewAccessRequestService(repo domain.AccessRequestRepository) domain.AccessRequestService {
-	return &accessRequestService{repo: repo}
+func NewAccessRequestService(repo domain.AccessRequestRepository, auditLogService domain.AuditLogService) domain.AccessRequestService {
+	return &accessRequestService{repo: repo, auditLogService: auditLogService}
 }
 
 func (s *accessRequestService) Create(ctx context.Context, request *domain.AccessRequest) error {
@@ -70,7 +78,11 @@ func (s *accessRequestService) Approve(ctx context.Context, id string, reviewerI
 	request.ExpiresAt = expiresAt
 	request.UpdatedAt = time.Now()
 
-	return s.repo.Update(request)
+	if err := s.repo.Update(request); err != nil {
+		return err
+	}
+	s.audit(ctx, auditAccessRequestApproved, request)
+	return nil
 }
 
 func (s *accessRequestService) Deny(ctx context.Context, id string, reviewerID string, notes string) error {
@@ -92,7 +104,27 @@ func (s *accessRequestService) Deny(ctx context.Context, id string, reviewerID s
 	request.ReviewedAt = time.Now()
 	request.UpdatedAt = time.Now()
 
-	return s.repo.Update(request)
+	if err := s.repo.Update(request); err != nil {
+		return err
+	}
+	s.audit(ctx, auditAccessRequestDenied, request)
+	return nil
+}
+
+// audit records a decision on an access request, attributed to the reviewer
+func (s *accessRequestService) audit(ctx context.Context, action string, request *domain.AccessRequest) {
+	details, _ := json.Marshal(map[string]interface{}{
+		"access_request_id": request.ID,
+		"requester":         request.UserID,
+		"notes":             request.ReviewNotes,
+		"expires_at":        request.ExpiresAt,
+	})
+	recordAudit(ctx, s.auditLogService, &domain.AuditLog{
+		UserID:     request.ReviewerID,
+		ResourceID: request.ResourceID,
+		Action:     action,
+		Details:    string(details),
+	})
 }
 
 func (s *accessRequestService) CreateAccessRequest(ctx context.Context, request *domain.AccessRequest) error {
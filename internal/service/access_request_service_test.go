@@ -97,7 +97,7 @@ func TestAccessRequestService_CreateAccessRequest(t *testing.T) {
 			mockRepo := new(MockAccessRequestRepository)
 			tt.mockSetup(mockRepo)
 
-			service := NewAccessRequestService(mockRepo)
+			service := NewAccessRequestService(mockRepo, nil)
 			err := service.CreateAccessRequest(context.Background(), tt.request)
 
 			if tt.wantErr {
@@ -160,7 +160,7 @@ func TestAccessRequestService_GetAccessRequest(t *testing.T) {
 			mockRepo := new(MockAccessRequestRepository)
 			tt.mockSetup(mockRepo)
 
-			service := NewAccessRequestService(mockRepo)
+			service := NewAccessRequestService(mockRepo, nil)
 			request, err := service.GetAccessRequest(context.Background(), tt.requestID)
 
 			if tt.wantErr {
@@ -223,7 +223,7 @@ func TestAccessRequestService_Approve(t *testing.T) {
 			mockRepo := new(MockAccessRequestRepository)
 			tt.mockSetup(mockRepo)
 
-			service := NewAccessRequestService(mockRepo)
+			service := NewAccessRequestService(mockRepo, nil)
 			err := service.Approve(context.Background(), tt.requestID, tt.reviewerID, tt.notes, tt.expiresAt)
 
 			if tt.wantErr {
@@ -278,7 +278,7 @@ func TestAccessRequestService_Deny(t *testing.T) {
 			mockRepo := new(MockAccessRequestRepository)
 			tt.mockSetup(mockRepo)
 
-			service := NewAccessRequestService(mockRepo)
+			service := NewAccessRequestService(mockRepo, nil)
 			err := service.Deny(context.Background(), tt.requestID, tt.reviewerID, tt.notes)
 
 			if tt.wantErr {
@@ -329,7 +329,7 @@ func TestAccessRequestService_GetPendingAccessRequests(t *testing.T) {
 			mockRepo := new(MockAccessRequestRepository)
 			tt.mockSetup(mockRepo)
 
-			service := NewAccessRequestService(mockRepo)
+			service := NewAccessRequestService(mockRepo, nil)
 			requests, err := service.GetPendingAccessRequests(context.Background())
 
 			if tt.wantErr {
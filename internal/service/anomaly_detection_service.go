@@ -0,0 +1,582 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// AnomalyPolicy configures behavioural anomaly detection. Baselines are
+// learned from the commands of the last Window and refreshed every
+// RefreshInterval. Users with fewer than MinSessions sessions in the window
+// are not judged. Session length and command rate are anomalous once they
+// exceed the baseline mean by Deviations standard deviations. Every anomaly
+// adds Weight to the session's risk score.
+type AnomalyPolicy struct {
+	Window          time.Duration
+	RefreshInterval time.Duration
+	MinSessions     int
+	Deviations      float64
+	Weight          float64
+}
+
+// DefaultAnomalyPolicy returns the policy used when none is configured.
+func DefaultAnomalyPolicy() AnomalyPolicy {
+	return AnomalyPolicy{
+		Window:          30 * 24 * time.Hour,
+		RefreshInterval: time.Hour,
+		MinSessions:     5,
+		Deviations:      3,
+		Weight:          15,
+	}
+}
+
+// Kinds of anomalies, each reported at most once per session
+const (
+	anomalyUnusualHour        = "unusual_hour"
+	anomalyNewResource        = "new_resource"
+	anomalyUnusualCommandType = "unusual_command_type"
+	anomalyLongSession        = "long_session"
+	anomalyCommandRate        = "command_rate"
+)
+
+// minAnomalousRate keeps bursts of a handful of commands from counting as
+// anomalous, however quiet the user usually is
+const minAnomalousRate = 10
+
+// anomalyQueueSize bounds the commands waiting for analysis; commands
+// arriving while it is full are not analyzed
+const anomalyQueueSize = 1024
+
+type baselineKey struct {
+	userID     string
+	resourceID string
+}
+
+// anomalySessionState tracks a live session between commands.
+type anomalySessionState struct {
+	start    time.Time
+	recent   []time.Time
+	reported map[string]bool
+	lastSeen time.Time
+}
+
+type anomalyDetectionService struct {
+	mu                    sync.Mutex
+	policy                AnomalyPolicy
+	sessionService        domain.SessionService
+	sessionCommandService domain.SessionCommandService
+	securityAlertService  domain.SecurityAlertService
+	sessionRiskService    domain.SessionRiskService
+	baselines             map[baselineKey]*domain.BehaviorBaseline
+	sessions              map[string]*anomalySessionState
+	queue                 chan *domain.SessionCommand
+	now                   func() time.Time
+}
+
+func NewAnomalyDetectionService(
+	sessionService domain.SessionService,
+	sessionCommandService domain.SessionCommandService,
+	securityAlertService domain.SecurityAlertService,
+	sessionRiskService domain.SessionRiskService,
+	policy AnomalyPolicy,
+) domain.AnomalyDetectionService {
+	defaults := DefaultAnomalyPolicy()
+	if policy.Window <= 0 {
+		policy.Window = defaults.Window
+	}
+	if policy.RefreshInterval <= 0 {
+		policy.RefreshInterval = defaults.RefreshInterval
+	}
+	if policy.MinSessions <= 0 {
+		policy.MinSessions = defaults.MinSessions
+	}
+	if policy.Deviations <= 0 {
+		policy.Deviations = defaults.Deviations
+	}
+	return &anomalyDetectionService{
+		policy:                policy,
+		sessionService:        sessionService,
+		sessionCommandService: sessionCommandService,
+		securityAlertService:  securityAlertService,
+		sessionRiskService:    sessionRiskService,
+		baselines:             make(map[baselineKey]*domain.BehaviorBaseline),
+		sessions:              make(map[string]*anomalySessionState),
+		queue:                 make(chan *domain.SessionCommand, anomalyQueueSize),
+		now:                   time.Now,
+	}
+}
+
+// ObserveCommand queues a command for analysis by Run. It never blocks the
+// session that sent the command.
+func (s *anomalyDetectionService) ObserveCommand(ctx context.Context, command *domain.SessionCommand) {
+	// The proxy keeps updating its command with the server's reply
+	observed := *command
+	select {
+	case s.queue <- &observed:
+	default:
+		utils.Warnf("Anomaly detection queue full; command %s in session %s not analyzed", command.ID, command.SessionID)
+	}
+}
+
+// Run analyzes queued commands and refreshes the baselines periodically
+// until ctx is done.
+func (s *anomalyDetectionService) Run(ctx context.Context) {
+	if err := s.RefreshBaselines(ctx); err != nil {
+		utils.Errorf("Failed to build behaviour baselines: %v", err)
+	}
+
+	ticker := time.NewTicker(s.policy.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RefreshBaselines(ctx); err != nil {
+				utils.Errorf("Failed to refresh behaviour baselines: %v", err)
+			}
+			s.forgetIdleSessions()
+		case command := <-s.queue:
+			if _, err := s.Analyze(ctx, command); err != nil {
+				utils.Errorf("Failed to analyze command %s for anomalies: %v", command.ID, err)
+			}
+		}
+	}
+}
+
+// historySession collects what the baselines need to know about one past
+// session.
+type historySession struct {
+	userID     string
+	resourceID string
+	start      time.Time
+	end        time.Time
+	finished   bool
+	// stored is set for sessions found in the sessions table
+	stored bool
+	// Commands arrive oldest first; only those sent within the last minute
+	// are kept, to track the peak command rate
+	commands int
+	first    time.Time
+	last     time.Time
+	recent   []time.Time
+	peakRate int
+	types    map[string]int
+	hours    [24]int
+}
+
+// addCommand counts a command sent at t, which must not be before the
+// commands added so far.
+func (h *historySession) addCommand(t time.Time) {
+	if h.commands == 0 {
+		h.first = t
+	}
+	h.commands++
+	h.last = t
+
+	h.recent = append(h.recent, t)
+	drop := 0
+	for t.Sub(h.recent[drop]) >= time.Minute {
+		drop++
+	}
+	h.recent = append(h.recent[:0], h.recent[drop:]...)
+	h.peakRate = max(h.peakRate, len(h.recent))
+}
+
+// RefreshBaselines rebuilds every baseline from the sessions and commands
+// stored within the baseline window. Commands are read a page at a time and
+// folded into per-session totals.
+func (s *anomalyDetectionService) RefreshBaselines(ctx context.Context) error {
+	to := s.now()
+	from := to.Add(-s.policy.Window)
+
+	history := make(map[string]*historySession)
+	get := func(sessionID, userID, resourceID string) *historySession {
+		h, ok := history[sessionID]
+		if !ok {
+			h = &historySession{userID: userID, resourceID: resourceID, types: make(map[string]int)}
+			history[sessionID] = h
+		}
+		return h
+	}
+
+	if s.sessionService != nil {
+		sessions, err := s.sessionService.GetActive(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+		for _, session := range sessions {
+			if session.StartTime.Before(from) || session.UserID == "" {
+				continue
+			}
+			h := get(session.ID, session.UserID, session.ResourceID)
+			h.start = session.StartTime
+			h.end = session.EndTime
+			h.finished = session.Status != "active"
+			h.stored = true
+		}
+	}
+
+	total := 0
+	filter := domain.CommandFilter{From: from, To: to, Limit: MaxCommandPageSize}
+	for {
+		page, err := s.sessionCommandService.SearchCommands(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("failed to load command history: %w", err)
+		}
+		for _, command := range page.Commands {
+			if command.UserID == "" || command.SessionID == "" {
+				continue
+			}
+			h := get(command.SessionID, command.UserID, command.ResourceID)
+			if h.resourceID == "" {
+				h.resourceID = command.ResourceID
+			}
+			h.addCommand(command.Timestamp)
+			h.types[command.CommandType]++
+			h.hours[command.Timestamp.UTC().Hour()]++
+		}
+		total += len(page.Commands)
+		if len(page.Commands) < filter.Limit {
+			break
+		}
+		filter.Offset += len(page.Commands)
+	}
+
+	baselines := make(map[baselineKey]*domain.BehaviorBaseline)
+	lengths := make(map[baselineKey][]float64)
+	rates := make(map[baselineKey][]float64)
+	for _, h := range history {
+		// Sessions missing from the sessions table are taken to have ended
+		// with their last command
+		if h.commands > 0 {
+			if h.start.IsZero() || h.first.Before(h.start) {
+				h.start = h.first
+			}
+			if h.last.After(h.end) {
+				h.end = h.last
+			}
+			if !h.stored {
+				h.finished = true
+			}
+		}
+
+		keys := []baselineKey{{userID: h.userID}}
+		if h.resourceID != "" {
+			keys = append(keys, baselineKey{userID: h.userID, resourceID: h.resourceID})
+		}
+		for _, key := range keys {
+			baseline, ok := baselines[key]
+			if !ok {
+				baseline = &domain.BehaviorBaseline{
+					UserID:       key.userID,
+					ResourceID:   key.resourceID,
+					Resources:    make(map[string]int),
+					CommandTypes: make(map[string]int),
+					From:         from,
+					To:           to,
+				}
+				baselines[key] = baseline
+			}
+			baseline.SessionCount++
+			baseline.CommandCount += h.commands
+			if h.resourceID != "" {
+				baseline.Resources[h.resourceID]++
+			}
+			for commandType, count := range h.types {
+				baseline.CommandTypes[commandType] += count
+			}
+			for hour, count := range h.hours {
+				baseline.Hours[hour] += count
+			}
+			if h.finished && !h.start.IsZero() && h.end.After(h.start) {
+				lengths[key] = append(lengths[key], h.end.Sub(h.start).Minutes())
+			}
+			if h.commands > 0 {
+				rates[key] = append(rates[key], float64(h.peakRate))
+			}
+		}
+	}
+	for key, baseline := range baselines {
+		baseline.SessionMinutes = newBaselineStat(lengths[key])
+		baseline.CommandRate = newBaselineStat(rates[key])
+	}
+
+	s.mu.Lock()
+	s.baselines = baselines
+	s.mu.Unlock()
+
+	utils.Infof("Built %d behaviour baselines from %d sessions and %d commands", len(baselines), len(history), total)
+	return nil
+}
+
+func newBaselineStat(samples []float64) domain.BaselineStat {
+	stat := domain.BaselineStat{Samples: len(samples)}
+	if len(samples) == 0 {
+		return stat
+	}
+	for _, sample := range samples {
+		stat.Mean += sample
+	}
+	stat.Mean /= float64(len(samples))
+	for _, sample := range samples {
+		stat.StdDev += (sample - stat.Mean) * (sample - stat.Mean)
+	}
+	stat.StdDev = math.Sqrt(stat.StdDev / float64(len(samples)))
+	return stat
+}
+
+func (s *anomalyDetectionService) GetBaseline(ctx context.Context, userID, resourceID string) (*domain.BehaviorBaseline, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	baseline, exists := s.baselines[baselineKey{userID: userID, resourceID: resourceID}]
+	if !exists {
+		return nil, errors.New("baseline not found")
+	}
+	return baseline, nil
+}
+
+// ListBaselines returns the baselines of one user, or of every user when
+// userID is empty, ordered by user and resource.
+func (s *anomalyDetectionService) ListBaselines(ctx context.Context, userID string) ([]*domain.BehaviorBaseline, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	baselines := make([]*domain.BehaviorBaseline, 0)
+	for key, baseline := range s.baselines {
+		if userID == "" || key.userID == userID {
+			baselines = append(baselines, baseline)
+		}
+	}
+	sort.Slice(baselines, func(i, j int) bool {
+		if baselines[i].UserID != baselines[j].UserID {
+			return baselines[i].UserID < baselines[j].UserID
+		}
+		return baselines[i].ResourceID < baselines[j].ResourceID
+	})
+	return baselines, nil
+}
+
+// anomaly is a deviation from a baseline with its explanation
+type anomaly struct {
+	kind        string
+	title       string
+	explanation string
+}
+
+// Analyze compares a command and the session it belongs to against the
+// user's baselines, raising an anomaly alert for every new kind of
+// deviation in the session. It returns the alerts raised.
+func (s *anomalyDetectionService) Analyze(ctx context.Context, command *domain.SessionCommand) ([]*domain.SecurityAlert, error) {
+	if command.SessionID == "" {
+		return nil, errors.New("session ID is required")
+	}
+	if command.UserID == "" {
+		return nil, nil
+	}
+
+	at := command.Timestamp
+	if at.IsZero() {
+		at = s.now()
+	}
+
+	s.mu.Lock()
+	state, exists := s.sessions[command.SessionID]
+	if !exists {
+		state = &anomalySessionState{start: at, reported: make(map[string]bool)}
+		s.sessions[command.SessionID] = state
+	}
+	s.mu.Unlock()
+
+	// The session's own start time is looked up outside the lock
+	if !exists && s.sessionService != nil {
+		if session, err := s.sessionService.GetByID(ctx, command.SessionID); err == nil && !session.StartTime.IsZero() {
+			s.mu.Lock()
+			if session.StartTime.Before(state.start) {
+				state.start = session.StartTime
+			}
+			s.mu.Unlock()
+		}
+	}
+
+	s.mu.Lock()
+	state.lastSeen = s.now()
+	state.recent = append(state.recent, at)
+	for len(state.recent) > 0 && at.Sub(state.recent[0]) >= time.Minute {
+		state.recent = state.recent[1:]
+	}
+
+	user := s.baselines[baselineKey{userID: command.UserID}]
+	var found []anomaly
+	if user != nil && user.SessionCount >= s.policy.MinSessions {
+		onResource := s.baselines[baselineKey{userID: command.UserID, resourceID: command.ResourceID}]
+		found = s.deviations(command, at, state, user, onResource)
+	}
+	var fresh []anomaly
+	for _, a := range found {
+		if !state.reported[a.kind] {
+			state.reported[a.kind] = true
+			fresh = append(fresh, a)
+		}
+	}
+	s.mu.Unlock()
+
+	alerts := make([]*domain.SecurityAlert, 0, len(fresh))
+	for _, a := range fresh {
+		alerts = append(alerts, s.raise(ctx, command, a))
+	}
+	return alerts, nil
+}
+
+// deviations lists how a command departs from the user's baselines. Callers
+// hold s.mu.
+func (s *anomalyDetectionService) deviations(command *domain.SessionCommand, at time.Time, state *anomalySessionState, user, onResource *domain.BehaviorBaseline) []anomaly {
+	var found []anomaly
+	days := int(s.policy.Window.Hours() / 24)
+
+	// An hour counts as usual when the user was active within an hour of it
+	hour := at.UTC().Hour()
+	if user.Hours[(hour+23)%24]+user.Hours[hour]+user.Hours[(hour+1)%24] == 0 {
+		found = append(found, anomaly{anomalyUnusualHour, "Activity At Unusual Hour",
+			fmt.Sprintf("Command sent at %02d:%02d UTC; in the last %d days %s was only active at %s",
+				hour, at.UTC().Minute(), days, command.UserID, formatHours(user.Hours))})
+	}
+
+	if command.ResourceID != "" && user.Resources[command.ResourceID] == 0 {
+		found = append(found, anomaly{anomalyNewResource, "Access To Unfamiliar Resource",
+			fmt.Sprintf("First session on resource %s in the last %d days; %s usually works on %s",
+				command.ResourceID, days, command.UserID, formatCounts(user.Resources))})
+	}
+
+	baseline, scope := user, "anywhere"
+	if onResource != nil {
+		baseline, scope = onResource, "on "+command.ResourceID
+	}
+	if command.CommandType != "" && baseline.CommandTypes[command.CommandType] == 0 {
+		found = append(found, anomaly{anomalyUnusualCommandType, "Unusual Command Type",
+			fmt.Sprintf("First %s command by %s %s in the last %d days; usually %s",
+				command.CommandType, command.UserID, scope, days, formatCounts(baseline.CommandTypes))})
+	}
+
+	if limit, ok := s.limit(user.SessionMinutes); ok {
+		if minutes := at.Sub(state.start).Minutes(); minutes > limit {
+			found = append(found, anomaly{anomalyLongSession, "Unusually Long Session",
+				fmt.Sprintf("Session running for %.0f minutes; %s's sessions usually last %.0f ± %.0f minutes",
+					minutes, command.UserID, user.SessionMinutes.Mean, user.SessionMinutes.StdDev)})
+		}
+	}
+
+	if limit, ok := s.limit(user.CommandRate); ok {
+		if rate := len(state.recent); rate >= minAnomalousRate && float64(rate) > limit {
+			found = append(found, anomaly{anomalyCommandRate, "Unusual Command Rate",
+				fmt.Sprintf("%d commands within a minute; %s usually peaks at %.0f ± %.0f per minute",
+					rate, command.UserID, user.CommandRate.Mean, user.CommandRate.StdDev)})
+		}
+	}
+
+	return found
+}
+
+// limit returns the value above which a measurement is anomalous. The
+// deviation is taken to be at least a quarter of the mean, so that very
+// regular histories do not flag small changes.
+func (s *anomalyDetectionService) limit(stat domain.BaselineStat) (float64, bool) {
+	if stat.Samples < s.policy.MinSessions {
+		return 0, false
+	}
+	return stat.Mean + s.policy.Deviations*math.Max(stat.StdDev, stat.Mean/4), true
+}
+
+func (s *anomalyDetectionService) raise(ctx context.Context, command *domain.SessionCommand, a anomaly) *domain.SecurityAlert {
+	alert := &domain.SecurityAlert{
+		ID:          uuid.New().String(),
+		SessionID:   command.SessionID,
+		CommandID:   command.ID,
+		UserID:      command.UserID,
+		ResourceID:  command.ResourceID,
+		AlertType:   "anomaly",
+		Severity:    "medium",
+		Title:       a.title,
+		Description: a.explanation,
+		RawData:     command.Command,
+		Action:      "logged",
+		CreatedAt:   time.Now(),
+	}
+
+	utils.Warnf("Anomaly in session %s (%s): %s", command.SessionID, a.kind, a.explanation)
+
+	if err := s.securityAlertService.CreateAlert(ctx, alert); err != nil {
+		utils.Errorf("Failed to create anomaly alert: %v", err)
+	}
+	if s.sessionRiskService != nil && s.policy.Weight > 0 {
+		if _, err := s.sessionRiskService.RecordAnomaly(ctx, command.SessionID, s.policy.Weight, a.kind+" anomaly"); err != nil {
+			utils.Errorf("Failed to update session risk score: %v", err)
+		}
+	}
+	return alert
+}
+
+// forgetIdleSessions drops the state of sessions without commands for a day.
+func (s *anomalyDetectionService) forgetIdleSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.now().Add(-24 * time.Hour)
+	for sessionID, state := range s.sessions {
+		if state.lastSeen.Before(cutoff) {
+			delete(s.sessions, sessionID)
+		}
+	}
+}
+
+// formatHours lists the active hours of the day as ranges, e.g.
+// "08:00-11:59, 14:00-14:59 UTC".
+func formatHours(hours [24]int) string {
+	var ranges []string
+	for start := 0; start < 24; start++ {
+		if hours[start] == 0 {
+			continue
+		}
+		end := start
+		for end+1 < 24 && hours[end+1] > 0 {
+			end++
+		}
+		ranges = append(ranges, fmt.Sprintf("%02d:00-%02d:59", start, end))
+		start = end
+	}
+	if len(ranges) == 0 {
+		return "no recorded hours"
+	}
+	return strings.Join(ranges, ", ") + " UTC"
+}
+
+// formatCounts lists the keys of counts, most frequent first.
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) == 0 {
+		return "nothing"
+	}
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+)
+
+// newTestAnomalyService returns an anomaly detector whose baselines were
+// built from ten days of ten-minute morning sessions on prod-db, with one
+// query a minute.
+func newTestAnomalyService(t *testing.T, now time.Time) (*anomalyDetectionService, domain.SecurityAlertService) {
+	commands := newTestSessionCommandService(t)
+	alerts := newTestSecurityAlertService(t)
+	ctx := context.Background()
+
+	for day := 1; day <= 10; day++ {
+		start := time.Date(now.Year(), now.Month(), now.Day()-day, 9, 0, 0, 0, time.UTC)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, commands.RecordCommand(ctx, &domain.SessionCommand{
+				SessionID:   fmt.Sprintf("history-%d", day),
+				UserID:      "alice",
+				ResourceID:  "prod-db",
+				Command:     "SELECT * FROM orders",
+				CommandType: "postgresql",
+				Risk:        "low",
+				Timestamp:   start.Add(time.Duration(i) * time.Minute),
+			}))
+		}
+	}
+
+	svc := NewAnomalyDetectionService(nil, commands, alerts, nil, AnomalyPolicy{}).(*anomalyDetectionService)
+	svc.now = func() time.Time { return now }
+	require.NoError(t, svc.RefreshBaselines(ctx))
+	return svc, alerts
+}
+
+func TestAnomalyDetectionService_Baselines(t *testing.T) {
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	svc, _ := newTestAnomalyService(t, now)
+	ctx := context.Background()
+
+	baseline, err := svc.GetBaseline(ctx, "alice", "")
+	require.NoError(t, err)
+	assert.Equal(t, 10, baseline.SessionCount)
+	assert.Equal(t, 100, baseline.CommandCount)
+	assert.Equal(t, 100, baseline.Hours[9])
+	assert.Equal(t, map[string]int{"prod-db": 10}, baseline.Resources)
+	assert.Equal(t, map[string]int{"postgresql": 100}, baseline.CommandTypes)
+	assert.InDelta(t, 9, baseline.SessionMinutes.Mean, 0.001)
+	assert.InDelta(t, 1, baseline.CommandRate.Mean, 0.001)
+	assert.Equal(t, 10, baseline.CommandRate.Samples)
+
+	_, err = svc.GetBaseline(ctx, "alice", "prod-db")
+	assert.NoError(t, err)
+	_, err = svc.GetBaseline(ctx, "bob", "")
+	assert.Error(t, err)
+
+	baselines, err := svc.ListBaselines(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, baselines, 2)
+	assert.Equal(t, "", baselines[0].ResourceID)
+	assert.Equal(t, "prod-db", baselines[1].ResourceID)
+}
+
+func TestAnomalyDetectionService_BaselinesAcrossPages(t *testing.T) {
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	commands := newTestSessionCommandService(t).(*sessionCommandService)
+	ctx := context.Background()
+
+	// One command every two seconds spans more than one page, with 30
+	// commands in any one minute
+	start := now.Add(-2 * time.Hour)
+	for i := 0; i < MaxCommandPageSize+5; i++ {
+		require.NoError(t, commands.sessionCommandRepo.Create(&domain.SessionCommand{
+			ID:          fmt.Sprintf("command-%d", i),
+			SessionID:   "session-1",
+			UserID:      "alice",
+			ResourceID:  "prod-db",
+			Command:     "SELECT 1",
+			CommandType: "postgresql",
+			Timestamp:   start.Add(time.Duration(i) * 2 * time.Second),
+			CreatedAt:   start,
+		}))
+	}
+
+	svc := NewAnomalyDetectionService(nil, commands, newTestSecurityAlertService(t), nil, AnomalyPolicy{}).(*anomalyDetectionService)
+	svc.now = func() time.Time { return now }
+	require.NoError(t, svc.RefreshBaselines(ctx))
+
+	baseline, err := svc.GetBaseline(ctx, "alice", "prod-db")
+	require.NoError(t, err)
+	assert.Equal(t, 1, baseline.SessionCount)
+	assert.Equal(t, MaxCommandPageSize+5, baseline.CommandCount)
+	assert.InDelta(t, 30, baseline.CommandRate.Mean, 0.001)
+	assert.InDelta(t, float64(MaxCommandPageSize+4)*2/60, baseline.SessionMinutes.Mean, 0.001)
+}
+
+func TestAnomalyDetectionService_Analyze(t *testing.T) {
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	svc, alerts := newTestAnomalyService(t, now)
+	ctx := context.Background()
+
+	kinds := func(raised []*domain.SecurityAlert) []string {
+		var titles []string
+		for _, alert := range raised {
+			assert.Equal(t, "anomaly", alert.AlertType)
+			assert.NotEmpty(t, alert.Description)
+			titles = append(titles, alert.Title)
+		}
+		return titles
+	}
+	analyze := func(sessionID, resourceID, commandType string, at time.Time) []string {
+		raised, err := svc.Analyze(ctx, &domain.SessionCommand{
+			SessionID:   sessionID,
+			UserID:      "alice",
+			ResourceID:  resourceID,
+			Command:     "whoami",
+			CommandType: commandType,
+			Timestamp:   at,
+		})
+		require.NoError(t, err)
+		return kinds(raised)
+	}
+
+	morning := time.Date(2024, 5, 20, 9, 30, 0, 0, time.UTC)
+	assert.Empty(t, analyze("usual", "prod-db", "postgresql", morning))
+	assert.Empty(t, analyze("usual", "prod-db", "postgresql", morning.Add(5*time.Minute)))
+
+	night := time.Date(2024, 5, 20, 3, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{"Activity At Unusual Hour", "Access To Unfamiliar Resource", "Unusual Command Type"},
+		analyze("night", "bastion", "ssh", night))
+	// Each kind of anomaly is reported once per session
+	assert.Empty(t, analyze("night", "bastion", "ssh", night.Add(time.Minute)))
+
+	var burst []string
+	for i := 0; i < 15; i++ {
+		burst = append(burst, analyze("burst", "prod-db", "postgresql", morning.Add(time.Duration(i)*time.Second))...)
+	}
+	assert.Equal(t, []string{"Unusual Command Rate"}, burst)
+
+	assert.Equal(t, []string{"Unusually Long Session"}, analyze("usual", "prod-db", "postgresql", morning.Add(30*time.Minute)))
+
+	raised, err := alerts.GetAlertsByUser(ctx, "alice")
+	require.NoError(t, err)
+	assert.Len(t, raised, 5)
+
+	// Users without enough history are not judged
+	raised, err = svc.Analyze(ctx, &domain.SessionCommand{SessionID: "new", UserID: "bob", ResourceID: "bastion", Timestamp: night})
+	require.NoError(t, err)
+	assert.Empty(t, raised)
+}
+
+func TestAnomalyDetectionService_ObserveCommand(t *testing.T) {
+	now := time.Now().UTC()
+	svc, alerts := newTestAnomalyService(t, now)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go svc.Run(ctx)
+
+	svc.ObserveCommand(ctx, &domain.SessionCommand{
+		SessionID:   "observed",
+		UserID:      "alice",
+		ResourceID:  "unknown-host",
+		CommandType: "postgresql",
+		Timestamp:   time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, time.UTC),
+	})
+
+	assert.Eventually(t, func() bool {
+		raised, _ := alerts.GetAlerts(ctx, "observed")
+		return len(raised) == 1 && raised[0].Title == "Access To Unfamiliar Resource"
+	}, 5*time.Second, 10*time.Millisecond)
+}
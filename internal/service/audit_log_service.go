@@ -0,0 +1,434 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// Audit log actions written for proxies
+const (
+	auditProxyStarted = "proxy_started"
+	auditProxyStopped = "proxy_stopped"
+)
+
+// auditChainBatch is how many entries are read at a time while the chain is
+// verified
+const auditChainBatch = 1000
+
+// AuditLogOptions configures how the audit log chain is anchored. The head
+// of the chain is signed with SigningKey every CheckpointInterval, normally
+// with the key recording manifests are signed with. Checkpoints signed with
+// a key in TrustedKeys, such as one used before the key changed, verify too.
+type AuditLogOptions struct {
+	SigningKey         ed25519.PrivateKey
+	TrustedKeys        []ed25519.PublicKey
+	CheckpointInterval time.Duration
+}
+
+// DefaultAuditLogOptions returns the options used when none are configured.
+func DefaultAuditLogOptions() AuditLogOptions {
+	return AuditLogOptions{
+		CheckpointInterval: time.Hour,
+	}
+}
+
+type auditLogService struct {
+	repo           domain.AuditLogRepository
+	checkpointRepo domain.AuditCheckpointRepository
+	options        AuditLogOptions
+	// chainMu keeps entries and checkpoints from being added to the chain
+	// at the same time
+	chainMu   sync.Mutex
+	mu        sync.RWMutex
+	observers []domain.AuditLogObserver
+	now       func() time.Time
+}
+
+func NewAuditLogService(repo domain.AuditLogRepository, checkpointRepo domain.AuditCheckpointRepository, options AuditLogOptions) domain.AuditLogService {
+	if options.CheckpointInterval <= 0 {
+		options.CheckpointInterval = DefaultAuditLogOptions().CheckpointInterval
+	}
+	if len(options.SigningKey) != ed25519.PrivateKeySize {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			utils.Fatalf("Failed to generate audit checkpoint signing key: %v", err)
+		}
+		options.SigningKey = key
+		utils.Warn("WARNING: No audit checkpoint signing key configured. Generated temporary key; checkpoints signed with it cannot be verified after a restart!")
+	}
+	return &auditLogService{
+		repo:           repo,
+		checkpointRepo: checkpointRepo,
+		options:        options,
+		now:            time.Now,
+	}
+}
+
+// Create stores an audit entry. The user, IP and user agent default to those
+// of the API request being served, if any.
+func (s *auditLogService) Create(ctx context.Context, log *domain.AuditLog) error {
+	audit := domain.AuditContextFrom(ctx)
+	if log.UserID == "" {
+		log.UserID = audit.UserID
+	}
+	if log.IP == "" {
+		log.IP = audit.IP
+	}
+	if log.UserAgent == "" {
+		log.UserAgent = audit.UserAgent
+	}
+
+	if err := s.append(log); err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	observers := s.observers
+	s.mu.RUnlock()
+	for _, observer := range observers {
+		observer.ObserveAuditLog(ctx, log)
+	}
+	return nil
+}
+
+// append stores an entry at the head of the chain
+func (s *auditLogService) append(log *domain.AuditLog) error {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+
+	head, err := s.repo.FindLast()
+	if err != nil {
+		return fmt.Errorf("failed to read the head of the audit log chain: %w", err)
+	}
+	if log.ID == "" {
+		log.ID = uuid.New().String()
+	}
+	if log.CreatedAt.IsZero() {
+		log.CreatedAt = s.now()
+	}
+	log.Sequence, log.PrevHash = 1, ""
+	if head != nil {
+		log.Sequence, log.PrevHash = head.Sequence+1, head.Hash
+	}
+	log.Hash = auditLogHash(log)
+	return s.repo.Create(log)
+}
+
+// auditLogHash returns the SHA-256 of an entry together with the hash of
+// the entry before it, hex encoded
+func auditLogHash(log *domain.AuditLog) string {
+	data, _ := json.Marshal(struct {
+		Sequence   int64  `json:"sequence"`
+		PrevHash   string `json:"prev_hash"`
+		ID         string `json:"id"`
+		UserID     string `json:"user_id"`
+		ResourceID string `json:"resource_id"`
+		Action     string `json:"action"`
+		Details    string `json:"details"`
+		IP         string `json:"ip"`
+		UserAgent  string `json:"user_agent"`
+		CreatedAt  string `json:"created_at"`
+	}{
+		Sequence:   log.Sequence,
+		PrevHash:   log.PrevHash,
+		ID:         log.ID,
+		UserID:     log.UserID,
+		ResourceID: log.ResourceID,
+		Action:     log.Action,
+		Details:    log.Details,
+		IP:         log.IP,
+		UserAgent:  log.UserAgent,
+		CreatedAt:  log.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Run signs a checkpoint of the chain every CheckpointInterval until ctx is
+// done
+func (s *auditLogService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.options.CheckpointInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Checkpoint(ctx); err != nil {
+			utils.Errorf("Failed to checkpoint the audit log: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Checkpoint signs the head of the chain. When the chain has not grown since
+// the last checkpoint, that checkpoint is returned instead; when it is
+// empty, there is nothing to sign and nil is returned.
+func (s *auditLogService) Checkpoint(ctx context.Context) (*domain.AuditCheckpoint, error) {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+
+	head, err := s.repo.FindLast()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the head of the audit log chain: %w", err)
+	}
+	if head == nil {
+		return nil, nil
+	}
+	last, err := s.checkpointRepo.FindLast()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the last audit checkpoint: %w", err)
+	}
+	if last != nil && last.Sequence == head.Sequence {
+		return last, nil
+	}
+
+	checkpoint := &domain.AuditCheckpoint{
+		ID:        uuid.New().String(),
+		Sequence:  head.Sequence,
+		Hash:      head.Hash,
+		CreatedAt: s.now().UTC(),
+	}
+	if err := signAuditCheckpoint(checkpoint, s.options.SigningKey); err != nil {
+		return nil, err
+	}
+	if err := s.checkpointRepo.Create(checkpoint); err != nil {
+		return nil, err
+	}
+	utils.Infof("Signed audit checkpoint at entry %d", checkpoint.Sequence)
+	return checkpoint, nil
+}
+
+func (s *auditLogService) ListCheckpoints(ctx context.Context) ([]*domain.AuditCheckpoint, error) {
+	return s.checkpointRepo.FindAll()
+}
+
+// Verify walks the chain and checks it against the checkpoints signed with
+// this server's key or one it trusts
+func (s *auditLogService) Verify(ctx context.Context) (*domain.AuditChainVerification, error) {
+	return VerifyAuditChain(s.repo, s.checkpointRepo, signingKeys(s.options.SigningKey, s.options.TrustedKeys)...)
+}
+
+func (s *auditLogService) SigningPublicKey() []byte {
+	return s.options.SigningKey.Public().(ed25519.PublicKey)
+}
+
+// auditCheckpointSigningBytes returns what a checkpoint's signature covers:
+// the checkpoint as JSON, without the signature
+func auditCheckpointSigningBytes(checkpoint *domain.AuditCheckpoint) ([]byte, error) {
+	unsigned := *checkpoint
+	unsigned.Signature = ""
+	unsigned.CreatedAt = unsigned.CreatedAt.UTC()
+	return json.Marshal(&unsigned)
+}
+
+// signAuditCheckpoint fills in the checkpoint's public key and signature
+func signAuditCheckpoint(checkpoint *domain.AuditCheckpoint, key ed25519.PrivateKey) error {
+	checkpoint.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	data, err := auditCheckpointSigningBytes(checkpoint)
+	if err != nil {
+		return err
+	}
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	return nil
+}
+
+// VerifyAuditChain walks the audit log chain from its first entry, checking
+// that no entry is missing, every entry links to the one before it and
+// matches its hash, and every checkpoint is signed with one of trustedKeys
+// and matches the entry it signed. It stops at the first break. Entries
+// removed from the end of the chain after the last checkpoint cannot be
+// detected.
+func VerifyAuditChain(repo domain.AuditLogRepository, checkpointRepo domain.AuditCheckpointRepository, trustedKeys ...ed25519.PublicKey) (*domain.AuditChainVerification, error) {
+	verification := &domain.AuditChainVerification{VerifiedAt: time.Now()}
+
+	unchained, err := repo.CountUnchained()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unchained audit log entries: %w", err)
+	}
+	verification.Unchained = unchained
+	checkpoints, err := checkpointRepo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit checkpoints: %w", err)
+	}
+	verification.Checkpoints = len(checkpoints)
+
+	var sequence int64
+	prevHash := ""
+	next := 0
+	for {
+		batch, err := repo.FindChain(sequence, auditChainBatch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the audit log chain: %w", err)
+		}
+		for _, log := range batch {
+			if chainBreak := verifyAuditLog(log, sequence+1, prevHash); chainBreak != nil {
+				verification.Break = chainBreak
+				return verification, nil
+			}
+			for ; next < len(checkpoints) && checkpoints[next].Sequence <= log.Sequence; next++ {
+				checkpoint := checkpoints[next]
+				reason := verifyAuditCheckpointSignature(checkpoint, trustedKeys)
+				switch {
+				case reason != "":
+				case checkpoint.Sequence != log.Sequence:
+					reason = fmt.Sprintf("checkpoint signed entry %d, which is not in the chain", checkpoint.Sequence)
+				case checkpoint.Hash != log.Hash:
+					reason = "entry differs from the one the checkpoint signed"
+				}
+				if reason != "" {
+					verification.Break = &domain.AuditChainBreak{
+						Sequence:     log.Sequence,
+						EntryID:      log.ID,
+						CheckpointID: checkpoint.ID,
+						Reason:       reason,
+					}
+					return verification, nil
+				}
+				verification.LastCheckpoint = checkpoint
+			}
+			sequence, prevHash = log.Sequence, log.Hash
+			verification.Entries++
+		}
+		if len(batch) < auditChainBatch {
+			break
+		}
+	}
+
+	// A checkpoint beyond the end of the chain means entries were removed
+	// from the end
+	if next < len(checkpoints) {
+		checkpoint := checkpoints[next]
+		reason := verifyAuditCheckpointSignature(checkpoint, trustedKeys)
+		if reason == "" {
+			reason = fmt.Sprintf("checkpoint signed entry %d, but the chain ends at entry %d", checkpoint.Sequence, sequence)
+		}
+		verification.Break = &domain.AuditChainBreak{
+			Sequence:     sequence + 1,
+			CheckpointID: checkpoint.ID,
+			Reason:       reason,
+		}
+		return verification, nil
+	}
+
+	verification.Valid = true
+	return verification, nil
+}
+
+// verifyAuditLog checks that an entry is the one expected at sequence,
+// links to prevHash and matches its hash
+func verifyAuditLog(log *domain.AuditLog, sequence int64, prevHash string) *domain.AuditChainBreak {
+	switch {
+	case log.Sequence > sequence+1:
+		return &domain.AuditChainBreak{Sequence: sequence, Reason: fmt.Sprintf("entries %d to %d are missing", sequence, log.Sequence-1)}
+	case log.Sequence != sequence:
+		return &domain.AuditChainBreak{Sequence: sequence, Reason: fmt.Sprintf("entry %d is missing", sequence)}
+	case log.PrevHash != prevHash:
+		return &domain.AuditChainBreak{Sequence: sequence, EntryID: log.ID, Reason: "previous hash does not match the entry before it"}
+	case auditLogHash(log) != log.Hash:
+		return &domain.AuditChainBreak{Sequence: sequence, EntryID: log.ID, Reason: "entry does not match its hash"}
+	}
+	return nil
+}
+
+// verifyAuditCheckpointSignature returns why a checkpoint's signature is
+// not to be trusted, or "" if it is
+func verifyAuditCheckpointSignature(checkpoint *domain.AuditCheckpoint, trustedKeys []ed25519.PublicKey) string {
+	trustedKey := trustedSigningKey(checkpoint.PublicKey, trustedKeys)
+	if trustedKey == nil {
+		return "checkpoint is signed with a different key"
+	}
+	signature, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	if err != nil {
+		return "checkpoint signature does not verify"
+	}
+	data, err := auditCheckpointSigningBytes(checkpoint)
+	if err != nil || !ed25519.Verify(trustedKey, data, signature) {
+		return "checkpoint signature does not verify"
+	}
+	return ""
+}
+
+// AddObserver registers an observer to be notified of every audit log entry
+// once it is stored. Observers are called synchronously and must not block.
+func (s *auditLogService) AddObserver(observer domain.AuditLogObserver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+// ObserveProxy records proxies starting and stopping, attributed to the
+// caller or, without one, to the session's user
+func (s *auditLogService) ObserveProxy(ctx context.Context, event string, proxy *domain.ProxyConnection) {
+	action := auditProxyStarted
+	if event == domain.EventProxyStopped {
+		action = auditProxyStopped
+	}
+	details, _ := json.Marshal(map[string]interface{}{
+		"proxy_id":     proxy.ID,
+		"session_id":   proxy.SessionID,
+		"session_user": proxy.UserID,
+		"protocol":     proxy.Protocol,
+		"local_port":   proxy.LocalPort,
+		"remote_host":  proxy.RemoteHost,
+		"remote_port":  proxy.RemotePort,
+	})
+	entry := &domain.AuditLog{ResourceID: proxy.ResourceID, Action: action, Details: string(details)}
+	if domain.AuditContextFrom(ctx).UserID == "" {
+		entry.UserID = proxy.UserID
+	}
+	recordAudit(ctx, s, entry)
+}
+
+// recordAudit writes an audit entry on behalf of another service. A failure
+// is logged rather than failing the operation being audited.
+func recordAudit(ctx context.Context, auditLogService domain.AuditLogService, entry *domain.AuditLog) {
+	if auditLogService == nil {
+		return
+	}
+	if err := auditLogService.Create(ctx, entry); err != nil {
+		utils.Errorf("Failed to write %s audit entry: %v", entry.Action, err)
+	}
+}
+
+func (s *auditLogService) List(ctx context.Context) ([]*domain.AuditLog, error) {
+	return s.repo.FindAll()
+}
+
+func (s *auditLogService) GetByID(ctx context.Context, id string) (*domain.AuditLog, error) {
+	return s.repo.FindByID(id)
+}
+
+func (s *auditLogService) GetByUserID(ctx context.Context, userID string) ([]*domain.AuditLog, error) {
+	return s.repo.FindByUserID(userID)
+}
+
+func (s *auditLogService) GetByResourceID(ctx context.Context, resourceID string) ([]*domain.AuditLog, error) {
+	return s.repo.FindByResourceID(resourceID)
+}
+
+func (s *auditLogService) GetByAction(ctx context.Context, action string) ([]*domain.AuditLog, error) {
+	return s.repo.FindByAction(action)
+}
+
+func (s *auditLogService) GetByDateRange(ctx context.Context, startDate, endDate time.Time) ([]*domain.AuditLog, error) {
+	return s.repo.FindByDateRange(startDate, endDate)
+}
+
+func (s *auditLogService) GetBySession(ctx context.Context, sessionID, userID string, start, end time.Time) ([]*domain.AuditLog, error) {
+	return s.repo.FindBySession(sessionID, userID, start, end)
+}
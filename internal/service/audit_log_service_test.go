@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+func newTestAuditLogService(t *testing.T) (domain.AuditLogService, *sql.DB) {
+	db := newTestDB(t)
+	return NewAuditLogService(repository.NewAuditLogRepository(db), repository.NewAuditCheckpointRepository(db), AuditLogOptions{}), db
+}
+
+// onlyAuditLog returns the single audit entry written for action
+func onlyAuditLog(t *testing.T, auditLogs domain.AuditLogService, action string) *domain.AuditLog {
+	logs, err := auditLogs.GetByAction(context.Background(), action)
+	require.NoError(t, err)
+	require.Len(t, logs, 1, action)
+	return logs[0]
+}
+
+func TestAuditLogService_AttributesToCaller(t *testing.T) {
+	auditLogs, _ := newTestAuditLogService(t)
+	ctx := domain.WithAuditContext(context.Background(), domain.AuditContext{UserID: "alice", IP: "10.0.0.1", UserAgent: "curl/8.0"})
+
+	require.NoError(t, auditLogs.Create(ctx, &domain.AuditLog{Action: "caller"}))
+	require.NoError(t, auditLogs.Create(ctx, &domain.AuditLog{UserID: "system", Action: "explicit"}))
+
+	caller := onlyAuditLog(t, auditLogs, "caller")
+	assert.Equal(t, "alice", caller.UserID)
+	assert.Equal(t, "10.0.0.1", caller.IP)
+	assert.Equal(t, "curl/8.0", caller.UserAgent)
+	assert.Equal(t, "system", onlyAuditLog(t, auditLogs, "explicit").UserID)
+}
+
+func TestAuditLogService_GetBySession(t *testing.T) {
+	auditLogs, _ := newTestAuditLogService(t)
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour)
+
+	for _, entry := range []*domain.AuditLog{
+		{UserID: "alice", Action: "before", CreatedAt: start.Add(-time.Minute)},
+		{UserID: "alice", Action: "during", CreatedAt: start.Add(time.Minute)},
+		{UserID: "bob", Action: "other_user", CreatedAt: start.Add(time.Minute)},
+		{UserID: "bob", Action: "legal_hold_placed", Details: `{"session_id": "s-1"}`, CreatedAt: start.Add(2 * time.Hour)},
+		{UserID: "bob", Action: "wildcard", Details: `{"session_id": "s%1"}`, CreatedAt: start.Add(time.Minute)},
+	} {
+		require.NoError(t, auditLogs.Create(ctx, entry))
+	}
+
+	logs, err := auditLogs.GetBySession(ctx, "s-1", "alice", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	var actions []string
+	for _, log := range logs {
+		actions = append(actions, log.Action)
+	}
+	assert.Equal(t, []string{"during", "legal_hold_placed"}, actions)
+}
+
+func TestAuditLogService_Logins(t *testing.T) {
+	auditLogs, db := newTestAuditLogService(t)
+	users := NewUserService(repository.NewUserRepository(db), auditLogs)
+	ctx := domain.WithAuditContext(context.Background(), domain.AuditContext{IP: "10.0.0.1"})
+
+	user := &domain.User{Username: "alice", Email: "alice@example.com", Password: "correct-horse", Role: "user"}
+	require.NoError(t, users.CreateUser(ctx, user))
+	_, err := users.Authenticate(ctx, "alice", "wrong")
+	assert.Error(t, err)
+	_, err = users.Authenticate(ctx, "alice", "correct-horse")
+	require.NoError(t, err)
+
+	failed := onlyAuditLog(t, auditLogs, auditLoginFailed)
+	assert.Equal(t, user.ID, failed.UserID)
+	assert.Equal(t, "10.0.0.1", failed.IP)
+	assert.JSONEq(t, `{"username": "alice"}`, failed.Details)
+	assert.Equal(t, user.ID, onlyAuditLog(t, auditLogs, auditLoginSucceeded).UserID)
+}
+
+func TestAuditLogService_Approvals(t *testing.T) {
+	auditLogs, db := newTestAuditLogService(t)
+	ctx := context.Background()
+
+	accessRequests := NewAccessRequestService(repository.NewAccessRequestRepository(db), auditLogs)
+	request := &domain.AccessRequest{UserID: "alice", ResourceID: "prod-db", Reason: "incident"}
+	require.NoError(t, accessRequests.CreateAccessRequest(ctx, request))
+	require.NoError(t, accessRequests.Approve(ctx, request.ID, "carol", "ok", time.Now().Add(time.Hour)))
+	approved := onlyAuditLog(t, auditLogs, auditAccessRequestApproved)
+	assert.Equal(t, "carol", approved.UserID)
+	assert.Equal(t, "prod-db", approved.ResourceID)
+	assert.Contains(t, approved.Details, request.ID)
+
+	approvals := newTestCommandApprovalService(t, auditLogs)
+	approval := newTestApproval()
+	require.NoError(t, approvals.RequestApproval(ctx, approval, time.Minute))
+	require.NoError(t, approvals.Deny(ctx, approval.ID, "carol", "not during business hours"))
+	denied := onlyAuditLog(t, auditLogs, auditCommandDenied)
+	assert.Equal(t, "carol", denied.UserID)
+	assert.Contains(t, denied.Details, "DELETE FROM accounts")
+}
+
+func TestAuditLogService_CredentialReads(t *testing.T) {
+	auditLogs, db := newTestAuditLogService(t)
+	credentials := NewCredentialService(repository.NewCredentialRepository(db), auditLogs)
+	ctx := domain.WithAuditContext(context.Background(), domain.AuditContext{UserID: "alice"})
+
+	credential := &domain.Credential{ResourceID: "prod-db", Type: "password", Secret: "hunter2"}
+	require.NoError(t, credentials.CreateCredential(ctx, credential))
+	_, err := credentials.GetCredential(ctx, credential.ID)
+	require.NoError(t, err)
+
+	read := onlyAuditLog(t, auditLogs, auditCredentialRead)
+	assert.Equal(t, "alice", read.UserID)
+	assert.Equal(t, "prod-db", read.ResourceID)
+	assert.Contains(t, read.Details, credential.ID)
+	assert.NotContains(t, read.Details, "hunter2")
+}
+
+func TestAuditLogService_ProxyStarts(t *testing.T) {
+	auditLogs, _ := newTestAuditLogService(t)
+	proxies, _ := newTestProxyService(t, CommandHoldPolicy{})
+	proxies.AddObserver(auditLogs)
+	ctx := context.Background()
+
+	proxy, err := proxies.CreateProxy(ctx, "s-1", "ssh", "127.0.0.1", 22)
+	require.NoError(t, err)
+	_, err = proxies.StartProxy(ctx, proxy.ID)
+	require.NoError(t, err)
+	require.NoError(t, proxies.StopProxy(ctx, proxy.ID))
+
+	assert.Contains(t, onlyAuditLog(t, auditLogs, auditProxyStarted).Details, proxy.ID)
+	onlyAuditLog(t, auditLogs, auditProxyStopped)
+}
+
+func TestAuditLogService_Chain(t *testing.T) {
+	auditLogs, _ := newTestAuditLogService(t)
+	ctx := context.Background()
+
+	checkpoint, err := auditLogs.Checkpoint(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, checkpoint, "nothing to sign")
+
+	var logs []*domain.AuditLog
+	for i := 0; i < 3; i++ {
+		log := &domain.AuditLog{Action: fmt.Sprintf("action_%d", i)}
+		require.NoError(t, auditLogs.Create(ctx, log))
+		logs = append(logs, log)
+	}
+	assert.Equal(t, int64(1), logs[0].Sequence)
+	assert.Empty(t, logs[0].PrevHash)
+	assert.Equal(t, int64(3), logs[2].Sequence)
+	assert.Equal(t, logs[1].Hash, logs[2].PrevHash)
+
+	checkpoint, err = auditLogs.Checkpoint(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), checkpoint.Sequence)
+	assert.Equal(t, logs[2].Hash, checkpoint.Hash)
+	again, err := auditLogs.Checkpoint(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, checkpoint.ID, again.ID, "the chain has not grown")
+
+	verification, err := auditLogs.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+	assert.Nil(t, verification.Break)
+	assert.Equal(t, int64(3), verification.Entries)
+	assert.Equal(t, 1, verification.Checkpoints)
+	assert.Equal(t, checkpoint.ID, verification.LastCheckpoint.ID)
+}
+
+func TestVerifyAuditChain_Breaks(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(t *testing.T, db *sql.DB)
+		sequence int64
+		reason   string
+	}{
+		{
+			name: "altered entry",
+			tamper: func(t *testing.T, db *sql.DB) {
+				_, err := db.Exec(`UPDATE audit_logs SET user_id = 'mallory' WHERE sequence = 2`)
+				require.NoError(t, err)
+			},
+			sequence: 2,
+			reason:   "entry does not match its hash",
+		},
+		{
+			name: "deleted entry",
+			tamper: func(t *testing.T, db *sql.DB) {
+				_, err := db.Exec(`DELETE FROM audit_logs WHERE sequence = 2`)
+				require.NoError(t, err)
+			},
+			sequence: 2,
+			reason:   "entry 2 is missing",
+		},
+		{
+			name: "rehashed entry",
+			tamper: func(t *testing.T, db *sql.DB) {
+				log := &domain.AuditLog{}
+				row := db.QueryRow(`SELECT id, action, created_at, prev_hash FROM audit_logs WHERE sequence = 2`)
+				require.NoError(t, row.Scan(&log.ID, &log.Action, &log.CreatedAt, &log.PrevHash))
+				log.Sequence, log.UserID = 2, "mallory"
+				_, err := db.Exec(`UPDATE audit_logs SET user_id = ?, hash = ? WHERE sequence = 2`, log.UserID, auditLogHash(log))
+				require.NoError(t, err)
+			},
+			sequence: 3,
+			reason:   "previous hash does not match the entry before it",
+		},
+		{
+			name: "truncated chain",
+			tamper: func(t *testing.T, db *sql.DB) {
+				_, err := db.Exec(`DELETE FROM audit_logs WHERE sequence >= 3`)
+				require.NoError(t, err)
+			},
+			sequence: 3,
+			reason:   "checkpoint signed entry 4, but the chain ends at entry 2",
+		},
+		{
+			name: "forged checkpoint",
+			tamper: func(t *testing.T, db *sql.DB) {
+				_, key, err := ed25519.GenerateKey(nil)
+				require.NoError(t, err)
+				checkpoint := &domain.AuditCheckpoint{ID: "forged", Sequence: 2, Hash: "x", CreatedAt: time.Now()}
+				require.NoError(t, signAuditCheckpoint(checkpoint, key))
+				require.NoError(t, repository.NewAuditCheckpointRepository(db).Create(checkpoint))
+			},
+			sequence: 2,
+			reason:   "checkpoint is signed with a different key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditLogs, db := newTestAuditLogService(t)
+			ctx := context.Background()
+			for i := 0; i < 4; i++ {
+				require.NoError(t, auditLogs.Create(ctx, &domain.AuditLog{Action: fmt.Sprintf("action_%d", i)}))
+			}
+			_, err := auditLogs.Checkpoint(ctx)
+			require.NoError(t, err)
+
+			tt.tamper(t, db)
+
+			verification, err := auditLogs.Verify(ctx)
+			require.NoError(t, err)
+			assert.False(t, verification.Valid)
+			require.NotNil(t, verification.Break)
+			assert.Equal(t, tt.sequence, verification.Break.Sequence)
+			assert.Equal(t, tt.reason, verification.Break.Reason)
+		})
+	}
+}
+
+func TestVerifyAuditChain_Unchained(t *testing.T) {
+	auditLogs, db := newTestAuditLogService(t)
+	ctx := context.Background()
+
+	// Written before the chain was introduced
+	require.NoError(t, repository.NewAuditLogRepository(db).Create(&domain.AuditLog{Action: "legacy"}))
+	require.NoError(t, auditLogs.Create(ctx, &domain.AuditLog{Action: "chained"}))
+	assert.Equal(t, int64(1), onlyAuditLog(t, auditLogs, "chained").Sequence)
+
+	verification, err := auditLogs.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+	assert.Equal(t, int64(1), verification.Entries)
+	assert.Equal(t, int64(1), verification.Unchained)
+}
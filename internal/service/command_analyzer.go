@@ -0,0 +1,149 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"secretary/alpha/internal/domain"
+)
+
+// commandAnalyzer scores commands against the rule engines of one policy.
+type commandAnalyzer struct {
+	sqlRules     *ruleEngine
+	shellRules   *ruleEngine
+	genericRules *ruleEngine
+}
+
+// defaultAnalyzer applies the built-in rule sets, compiled once per process.
+var defaultAnalyzer = &commandAnalyzer{
+	sqlRules:     mustNewRuleEngine(defaultSQLRules),
+	shellRules:   mustNewRuleEngine(defaultShellRules),
+	genericRules: mustNewRuleEngine(defaultGenericRules),
+}
+
+// sqlUnboundedSelectRule stands for the built-in heuristic flagging
+// SELECT * without LIMIT as possible data exfiltration. It is not a pattern
+// rule and applies whatever the policy.
+var sqlUnboundedSelectRule = commandRule{ID: "sql-select-unbounded", Risk: "medium"}
+
+// newCommandAnalyzer compiles the rules of a policy.
+func newCommandAnalyzer(policy *domain.Policy) (*commandAnalyzer, error) {
+	sqlRules, err := newRuleEngine(commandRulesFromPolicy(policy.SQL))
+	if err != nil {
+		return nil, fmt.Errorf("sql rules: %w", err)
+	}
+	shellRules, err := newRuleEngine(commandRulesFromPolicy(policy.Shell))
+	if err != nil {
+		return nil, fmt.Errorf("shell rules: %w", err)
+	}
+	genericRules, err := newRuleEngine(commandRulesFromPolicy(policy.Generic))
+	if err != nil {
+		return nil, fmt.Errorf("generic rules: %w", err)
+	}
+
+	return &commandAnalyzer{sqlRules: sqlRules, shellRules: shellRules, genericRules: genericRules}, nil
+}
+
+// analyze returns the risk of command and whether it must be blocked. With
+// collect set, the result also lists every rule the command matched.
+func (a *commandAnalyzer) analyze(command string, commandType string, collect bool) ruleResult {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return ruleResult{Risk: "low"}
+	}
+
+	switch strings.ToLower(commandType) {
+	case "sql", "mysql", "postgresql", "postgres":
+		return a.analyzeSQLCommand(command, collect)
+	case "ssh", "shell", "bash":
+		return a.analyzeShellCommand(command, collect)
+	default:
+		// Generic analysis for unknown command types
+		return a.genericRules.evaluate(command, collect)
+	}
+}
+
+func (a *commandAnalyzer) analyzeSQLCommand(command string, collect bool) ruleResult {
+	if result := a.sqlRules.evaluate(command, collect); result.Risk != "low" {
+		return result
+	}
+
+	upperCommand := strings.ToUpper(command)
+
+	// SELECT statements are generally low risk
+	if strings.HasPrefix(upperCommand, "SELECT") {
+		// But check for potential data exfiltration
+		if strings.Contains(upperCommand, "LIMIT") || strings.Contains(upperCommand, "ORDER BY") {
+			return ruleResult{Risk: "low"}
+		}
+		// Large result sets might indicate data exfiltration
+		if !strings.Contains(upperCommand, "LIMIT") && strings.Contains(upperCommand, "*") {
+			result := ruleResult{Risk: sqlUnboundedSelectRule.Risk}
+			if collect {
+				result.Matched = []*commandRule{&sqlUnboundedSelectRule}
+			}
+			return result
+		}
+	}
+
+	return ruleResult{Risk: "low"}
+}
+
+// analyzeShellCommand scores the raw line as well as every simple command and
+// pipeline the shell parser extracts from it, so that quoting tricks, chained
+// commands, substitutions and encoded payloads cannot hide a dangerous
+// command. The highest risk wins.
+func (a *commandAnalyzer) analyzeShellCommand(command string, collect bool) ruleResult {
+	result := a.shellRules.evaluate(command, collect)
+	for _, segment := range parseShellCommands(command) {
+		segmentResult := a.shellRules.evaluate(segment, collect)
+		result.Risk = higherRisk(result.Risk, segmentResult.Risk)
+		result.Block = result.Block || segmentResult.Block
+		result.Matched = appendNewRules(result.Matched, segmentResult.Matched)
+	}
+	return result
+}
+
+func appendNewRules(rules []*commandRule, more []*commandRule) []*commandRule {
+	for _, rule := range more {
+		seen := false
+		for _, existing := range rules {
+			if existing.ID == rule.ID {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func commandRulesFromPolicy(rules []domain.PolicyRule) []commandRule {
+	converted := make([]commandRule, len(rules))
+	for i, rule := range rules {
+		converted[i] = commandRule{
+			ID:         rule.ID,
+			Pattern:    rule.Pattern,
+			IgnoreCase: rule.IgnoreCase,
+			Risk:       rule.Risk,
+			Block:      rule.Block,
+		}
+	}
+	return converted
+}
+
+func policyRulesFromCommandRules(rules []commandRule) []domain.PolicyRule {
+	converted := make([]domain.PolicyRule, len(rules))
+	for i, rule := range rules {
+		converted[i] = domain.PolicyRule{
+			ID:         rule.ID,
+			Pattern:    rule.Pattern,
+			IgnoreCase: rule.IgnoreCase,
+			Risk:       rule.Risk,
+			Block:      rule.Block,
+		}
+	}
+	return converted
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+)
+
+// DefaultApprovalTimeout is how long a held command waits for a reviewer
+// when no timeout is configured.
+const DefaultApprovalTimeout = 2 * time.Minute
+
+// Audit log actions written for held command decisions
+const (
+	auditCommandApproved = "command_approved"
+	auditCommandDenied   = "command_denied"
+)
+
+// errApprovalNotPending is returned when an approval has already been
+// decided, has expired or was cancelled
+var errApprovalNotPending = errors.New("only pending command approvals can be decided")
+
+type commandApprovalService struct {
+	// mu serialises decisions, so an approval is decided only once
+	mu   sync.Mutex
+	repo domain.CommandApprovalRepository
+	// waiting wakes up the proxy holding each pending approval; entries are
+	// removed once the approval is decided, expires or is cancelled
+	waiting         map[string]chan struct{}
+	auditLogService domain.AuditLogService
+}
+
+func NewCommandApprovalService(repo domain.CommandApprovalRepository, auditLogService domain.AuditLogService) domain.CommandApprovalService {
+	return &commandApprovalService{
+		repo:            repo,
+		waiting:         make(map[string]chan struct{}),
+		auditLogService: auditLogService,
+	}
+}
+
+func (s *commandApprovalService) RequestApproval(ctx context.Context, approval *domain.CommandApproval, timeout time.Duration) error {
+	if approval.SessionID == "" || approval.Command == "" {
+		return errors.New("session ID and command are required")
+	}
+	if timeout <= 0 {
+		timeout = DefaultApprovalTimeout
+	}
+	if approval.ID == "" {
+		approval.ID = uuid.New().String()
+	}
+
+	approval.Status = "pending"
+	approval.RequestedAt = time.Now()
+	approval.ExpiresAt = approval.RequestedAt.Add(timeout)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *approval
+	if err := s.repo.Create(&stored); err != nil {
+		return fmt.Errorf("failed to store command approval: %w", err)
+	}
+	s.waiting[approval.ID] = make(chan struct{})
+	return nil
+}
+
+// WaitForDecision blocks until the approval is decided, expires or ctx is
+// cancelled, and returns the approval in its final state.
+func (s *commandApprovalService) WaitForDecision(ctx context.Context, id string) (*domain.CommandApproval, error) {
+	s.mu.Lock()
+	decided := s.waiting[id]
+	s.mu.Unlock()
+
+	approval, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if approval.Status != "pending" {
+		return approval, nil
+	}
+
+	timer := time.NewTimer(time.Until(approval.ExpiresAt))
+	defer timer.Stop()
+
+	var finishErr error
+	select {
+	case <-decided:
+	case <-timer.C:
+		finishErr = s.finish(id, "expired", "", "approval timed out")
+	case <-ctx.Done():
+		finishErr = s.finish(id, "cancelled", "", "session ended before a decision was made")
+	}
+	// A reviewer may have decided in the meantime
+	if finishErr != nil && finishErr != errApprovalNotPending {
+		return nil, finishErr
+	}
+
+	return s.repo.FindByID(id)
+}
+
+func (s *commandApprovalService) Approve(ctx context.Context, id string, reviewerID string, notes string) error {
+	return s.decide(ctx, id, "approved", reviewerID, notes)
+}
+
+func (s *commandApprovalService) Deny(ctx context.Context, id string, reviewerID string, notes string) error {
+	return s.decide(ctx, id, "denied", reviewerID, notes)
+}
+
+func (s *commandApprovalService) decide(ctx context.Context, id, status, reviewerID, notes string) error {
+	if reviewerID == "" {
+		return errors.New("reviewer ID is required")
+	}
+
+	approval, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if approval.UserID != "" && approval.UserID == reviewerID {
+		return errors.New("reviewers cannot decide on their own commands")
+	}
+
+	if err := s.finish(id, status, reviewerID, notes); err != nil {
+		return err
+	}
+
+	action := auditCommandApproved
+	if status == "denied" {
+		action = auditCommandDenied
+	}
+	details, _ := json.Marshal(map[string]string{
+		"approval_id": approval.ID,
+		"session_id":  approval.SessionID,
+		"command_id":  approval.CommandID,
+		"requester":   approval.UserID,
+		"command":     approval.Command,
+		"notes":       notes,
+	})
+	recordAudit(ctx, s.auditLogService, &domain.AuditLog{
+		UserID:     reviewerID,
+		ResourceID: approval.ResourceID,
+		Action:     action,
+		Details:    string(details),
+	})
+	return nil
+}
+
+// finish moves a pending approval to its final status and wakes up the
+// waiting proxy. It returns errApprovalNotPending if the approval was no
+// longer pending.
+func (s *commandApprovalService) finish(id, status, reviewerID, notes string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	approval, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if approval.Status != "pending" {
+		return errApprovalNotPending
+	}
+	if status == "approved" || status == "denied" {
+		if time.Now().After(approval.ExpiresAt) {
+			return errApprovalNotPending
+		}
+	}
+
+	approval.Status = status
+	approval.ReviewerID = reviewerID
+	approval.ReviewNotes = notes
+	approval.ReviewedAt = time.Now()
+	if err := s.repo.Update(approval); err != nil {
+		return fmt.Errorf("failed to store command approval: %w", err)
+	}
+	if decided, ok := s.waiting[id]; ok {
+		close(decided)
+		delete(s.waiting, id)
+	}
+	return nil
+}
+
+func (s *commandApprovalService) GetApproval(ctx context.Context, id string) (*domain.CommandApproval, error) {
+	return s.repo.FindByID(id)
+}
+
+func (s *commandApprovalService) ListPending(ctx context.Context) ([]*domain.CommandApproval, error) {
+	return s.repo.FindPending(time.Now())
+}
+
+func (s *commandApprovalService) ListBySession(ctx context.Context, sessionID string) ([]*domain.CommandApproval, error) {
+	return s.repo.FindBySessionID(sessionID)
+}
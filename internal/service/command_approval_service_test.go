@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+func newTestCommandApprovalService(t testing.TB, auditLogService domain.AuditLogService) *commandApprovalService {
+	repo := repository.NewCommandApprovalRepository(newTestDB(t))
+	return NewCommandApprovalService(repo, auditLogService).(*commandApprovalService)
+}
+
+func newTestApproval() *domain.CommandApproval {
+	return &domain.CommandApproval{
+		SessionID:   "session-1",
+		CommandID:   "command-1",
+		UserID:      "user-1",
+		ResourceID:  "prod-db",
+		Command:     "DELETE FROM accounts",
+		CommandType: "postgresql",
+		Risk:        "high",
+	}
+}
+
+func TestCommandApprovalService_Approve(t *testing.T) {
+	svc := newTestCommandApprovalService(t, nil)
+	ctx := context.Background()
+
+	approval := newTestApproval()
+	require.NoError(t, svc.RequestApproval(ctx, approval, time.Minute))
+	assert.NotEmpty(t, approval.ID)
+	assert.Equal(t, "pending", approval.Status)
+
+	pending, err := svc.ListPending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, svc.Approve(ctx, approval.ID, "reviewer-1", "ticket OPS-1"))
+	}()
+
+	decision, err := svc.WaitForDecision(ctx, approval.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "approved", decision.Status)
+	assert.Equal(t, "reviewer-1", decision.ReviewerID)
+	assert.Equal(t, "ticket OPS-1", decision.ReviewNotes)
+	assert.False(t, decision.ReviewedAt.IsZero())
+
+	// Decisions are final
+	assert.Error(t, svc.Deny(ctx, approval.ID, "reviewer-2", "too late"))
+
+	pending, err = svc.ListPending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	assert.Empty(t, svc.waiting, "decided approvals are not kept in memory")
+}
+
+func TestCommandApprovalService_Deny(t *testing.T) {
+	svc := newTestCommandApprovalService(t, nil)
+	ctx := context.Background()
+
+	approval := newTestApproval()
+	require.NoError(t, svc.RequestApproval(ctx, approval, time.Minute))
+	require.NoError(t, svc.Deny(ctx, approval.ID, "reviewer-1", "not during business hours"))
+
+	decision, err := svc.WaitForDecision(ctx, approval.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "denied", decision.Status)
+}
+
+func TestCommandApprovalService_SelfApproval(t *testing.T) {
+	svc := newTestCommandApprovalService(t, nil)
+	ctx := context.Background()
+
+	approval := newTestApproval()
+	require.NoError(t, svc.RequestApproval(ctx, approval, time.Minute))
+	assert.Error(t, svc.Approve(ctx, approval.ID, "user-1", ""))
+	assert.Error(t, svc.Approve(ctx, approval.ID, "", ""))
+	assert.Error(t, svc.Approve(ctx, "missing", "reviewer-1", ""))
+}
+
+func TestCommandApprovalService_Expires(t *testing.T) {
+	svc := newTestCommandApprovalService(t, nil)
+	ctx := context.Background()
+
+	approval := newTestApproval()
+	require.NoError(t, svc.RequestApproval(ctx, approval, 20*time.Millisecond))
+
+	decision, err := svc.WaitForDecision(ctx, approval.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "expired", decision.Status)
+	assert.Error(t, svc.Approve(ctx, approval.ID, "reviewer-1", ""))
+	assert.Empty(t, svc.waiting, "expired approvals are not kept in memory")
+}
+
+func TestCommandApprovalService_Cancelled(t *testing.T) {
+	svc := newTestCommandApprovalService(t, nil)
+
+	approval := newTestApproval()
+	require.NoError(t, svc.RequestApproval(context.Background(), approval, time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	decision, err := svc.WaitForDecision(ctx, approval.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", decision.Status)
+	assert.Empty(t, svc.waiting, "cancelled approvals are not kept in memory")
+
+	approvals, err := svc.ListBySession(context.Background(), "session-1")
+	require.NoError(t, err)
+	assert.Len(t, approvals, 1)
+}
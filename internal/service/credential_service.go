@@ -2,15 +2,22 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+
 	"secretary/alpha/internal/domain"
 )
 
+// auditCredentialRead is the audit log action written whenever secrets are
+// handed out
+const auditCredentialRead = "credential_read"
+
 type credentialService struct {
-	repo domain.CredentialRepository
+	repo            domain.CredentialRepository
+	auditLogService domain.AuditLogService
 }
 
-func NewCredentialService(repo domain.CredentialRepository) domain.CredentialService {
-	return &credentialService{repo: repo}
+func NewCredentialService(repo domain.CredentialRepository, auditLogService domain.AuditLogService) domain.CredentialService {
+	return &credentialService{repo: repo, auditLogService: auditLogService}
 }
 
 func (s *credentialService) Create(ctx context.Context, credential *domain.Credential) error {
@@ -18,11 +25,21 @@ func (s *credentialService) Create(ctx context.Context, credential *domain.Crede
 }
 
 func (s *credentialService) GetByID(ctx context.Context, id string) (*domain.Credential, error) {
-	return s.repo.FindByID(id)
+	credential, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, credential)
+	return credential, nil
 }
 
 func (s *credentialService) GetByResourceID(ctx context.Context, resourceID string) ([]*domain.Credential, error) {
-	return s.repo.FindByResourceID(resourceID)
+	credentials, err := s.repo.FindByResourceID(resourceID)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, credentials...)
+	return credentials, nil
 }
 
 func (s *credentialService) Update(ctx context.Context, credential *domain.Credential) error {
@@ -38,11 +55,11 @@ func (s *credentialService) CreateCredential(ctx context.Context, credential *do
 }
 
 func (s *credentialService) ListCredentials(ctx context.Context) ([]*domain.Credential, error) {
-	return s.repo.FindByResourceID("") // TODO: Implement proper listing
+	return s.GetByResourceID(ctx, "") // TODO: Implement proper listing
 }
 
 func (s *credentialService) GetCredential(ctx context.Context, id string) (*domain.Credential, error) {
-	return s.repo.FindByID(id)
+	return s.GetByID(ctx, id)
 }
 
 func (s *credentialService) UpdateCredential(ctx context.Context, credential *domain.Credential) error {
@@ -59,5 +76,17 @@ func (s *credentialService) RotateCredential(ctx context.Context, id string) err
 }
 
 func (s *credentialService) GetCredentialByResourceID(ctx context.Context, resourceID string) ([]*domain.Credential, error) {
-	return s.repo.FindByResourceID(resourceID)
+	return s.GetByResourceID(ctx, resourceID)
+}
+
+// audit records each credential read, without its secret
+func (s *credentialService) audit(ctx context.Context, credentials ...*domain.Credential) {
+	for _, credential := range credentials {
+		details, _ := json.Marshal(map[string]string{"credential_id": credential.ID, "type": credential.Type})
+		recordAudit(ctx, s.auditLogService, &domain.AuditLog{
+			ResourceID: credential.ResourceID,
+			Action:     auditCredentialRead,
+			Details:    string(details),
+		})
+	}
 }
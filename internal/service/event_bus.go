@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+type eventBus struct {
+	mu          sync.RWMutex
+	subscribers map[int]chan *domain.Event
+	nextID      int
+	// dropped counts events each subscriber missed since it last received one
+	dropped map[int]int
+	now     func() time.Time
+}
+
+func NewEventBus() domain.EventBus {
+	return &eventBus{
+		subscribers: make(map[int]chan *domain.Event),
+		dropped:     make(map[int]int),
+		now:         time.Now,
+	}
+}
+
+// Publish hands the event to every subscriber without waiting; subscribers
+// whose buffer is full miss it
+func (b *eventBus) Publish(ctx context.Context, event *domain.Event) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Time.IsZero() {
+		event.Time = b.now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, events := range b.subscribers {
+		select {
+		case events <- event:
+			if b.dropped[id] > 0 {
+				utils.Warnf("Event subscriber %d missed %d events", id, b.dropped[id])
+				b.dropped[id] = 0
+			}
+		default:
+			b.dropped[id]++
+		}
+	}
+}
+
+func (b *eventBus) Subscribe(buffer int) (<-chan *domain.Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	events := make(chan *domain.Event, buffer)
+	b.subscribers[id] = events
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers, id)
+			delete(b.dropped, id)
+			close(events)
+		})
+	}
+}
+
+func (b *eventBus) ObserveSession(ctx context.Context, event string, session *domain.Session) {
+	b.Publish(ctx, &domain.Event{
+		Type:       event,
+		UserID:     session.UserID,
+		ResourceID: session.ResourceID,
+		SessionID:  session.ID,
+		Session:    session,
+	})
+}
+
+func (b *eventBus) ObserveProxy(ctx context.Context, event string, proxy *domain.ProxyConnection) {
+	b.Publish(ctx, &domain.Event{
+		Type:       event,
+		UserID:     proxy.UserID,
+		ResourceID: proxy.ResourceID,
+		SessionID:  proxy.SessionID,
+		Proxy:      proxy,
+	})
+}
+
+func (b *eventBus) ObserveCommand(ctx context.Context, command *domain.SessionCommand) {
+	b.Publish(ctx, &domain.Event{
+		Type:       domain.EventCommand,
+		UserID:     command.UserID,
+		ResourceID: command.ResourceID,
+		SessionID:  command.SessionID,
+		Severity:   command.Risk,
+		Command:    command,
+	})
+}
+
+func (b *eventBus) ObserveAlert(ctx context.Context, alert *domain.SecurityAlert) {
+	b.Publish(ctx, &domain.Event{
+		Type:       domain.EventAlert,
+		UserID:     alert.UserID,
+		ResourceID: alert.ResourceID,
+		SessionID:  alert.SessionID,
+		Severity:   alert.Severity,
+		Alert:      alert,
+	})
+}
+
+func (b *eventBus) ObserveAuditLog(ctx context.Context, log *domain.AuditLog) {
+	b.Publish(ctx, &domain.Event{
+		Type:       domain.EventAuditLog,
+		UserID:     log.UserID,
+		ResourceID: log.ResourceID,
+		AuditLog:   log,
+	})
+}
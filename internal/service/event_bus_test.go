@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+func TestEventBus_SessionLifecycle(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	bus := NewEventBus()
+	sessions := NewSessionService(repository.NewSessionRepository(db))
+	sessions.AddObserver(bus)
+	events, unsubscribe := bus.Subscribe(10)
+
+	session := &domain.Session{UserID: "alice", ResourceID: "db-1"}
+	require.NoError(t, sessions.Create(ctx, session))
+	require.NoError(t, sessions.Terminate(ctx, session.ID))
+
+	started := <-events
+	assert.Equal(t, domain.EventSessionStarted, started.Type)
+	assert.Equal(t, session.ID, started.SessionID)
+	assert.Equal(t, "alice", started.UserID)
+	assert.NotEmpty(t, started.ID)
+	ended := <-events
+	assert.Equal(t, domain.EventSessionEnded, ended.Type)
+	assert.Equal(t, "terminated", ended.Session.Status)
+
+	unsubscribe()
+	unsubscribe()
+	_, open := <-events
+	assert.False(t, open, "unsubscribing closes the channel")
+}
+
+func TestEventBus_SlowSubscriber(t *testing.T) {
+	bus := NewEventBus()
+	slow, unsubscribeSlow := bus.Subscribe(1)
+	defer unsubscribeSlow()
+	fast, unsubscribeFast := bus.Subscribe(10)
+	defer unsubscribeFast()
+
+	ctx := context.Background()
+	for _, severity := range []string{"low", "high", "critical"} {
+		bus.ObserveAlert(ctx, &domain.SecurityAlert{Severity: severity})
+	}
+
+	// A full subscriber misses events without holding up the others
+	assert.Len(t, fast, 3)
+	require.Len(t, slow, 1)
+	assert.Equal(t, "low", (<-slow).Severity)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"secretary/alpha/internal/domain"
+)
+
+// StreamEventTypes are the event types live streams carry
+var StreamEventTypes = []string{
+	domain.EventSessionStarted,
+	domain.EventSessionEnded,
+	domain.EventProxyStarted,
+	domain.EventProxyStopped,
+	domain.EventCommand,
+	domain.EventAlert,
+}
+
+// EventStreamOptions configures live event streams
+type EventStreamOptions struct {
+	// MinCommandRisk is the lowest risk of the commands streamed
+	MinCommandRisk string
+	// Buffer is how many events a stream holds for a slow client before
+	// it misses some
+	Buffer int
+}
+
+// DefaultEventStreamOptions streams high and critical risk commands
+func DefaultEventStreamOptions() EventStreamOptions {
+	return EventStreamOptions{
+		MinCommandRisk: "high",
+		Buffer:         256,
+	}
+}
+
+type eventStreamService struct {
+	bus     domain.EventBus
+	options EventStreamOptions
+}
+
+func NewEventStreamService(bus domain.EventBus, options EventStreamOptions) domain.EventStreamService {
+	defaults := DefaultEventStreamOptions()
+	if _, ok := riskLevels[options.MinCommandRisk]; !ok {
+		options.MinCommandRisk = defaults.MinCommandRisk
+	}
+	if options.Buffer <= 0 {
+		options.Buffer = defaults.Buffer
+	}
+	return &eventStreamService{
+		bus:     bus,
+		options: options,
+	}
+}
+
+func (s *eventStreamService) Subscribe(ctx context.Context, filter domain.EventFilter) (<-chan *domain.Event, error) {
+	for _, eventType := range filter.Types {
+		if !slices.Contains(StreamEventTypes, eventType) {
+			return nil, fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+	for _, severity := range filter.Severities {
+		if _, ok := riskLevels[severity]; !ok {
+			return nil, fmt.Errorf("unknown severity %q", severity)
+		}
+	}
+
+	events, unsubscribe := s.bus.Subscribe(s.options.Buffer)
+	matched := make(chan *domain.Event)
+	go func() {
+		defer close(matched)
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if !s.matches(filter, event) {
+					continue
+				}
+				select {
+				case matched <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return matched, nil
+}
+
+func (s *eventStreamService) matches(filter domain.EventFilter, event *domain.Event) bool {
+	if !slices.Contains(StreamEventTypes, event.Type) {
+		return false
+	}
+	if event.Type == domain.EventCommand && riskLevels[event.Severity] < riskLevels[s.options.MinCommandRisk] {
+		return false
+	}
+	if len(filter.Types) > 0 && !slices.Contains(filter.Types, event.Type) {
+		return false
+	}
+	if filter.UserID != "" && event.UserID != filter.UserID {
+		return false
+	}
+	if filter.ResourceID != "" && event.ResourceID != filter.ResourceID {
+		return false
+	}
+	if len(filter.Severities) > 0 && event.Severity != "" && !slices.Contains(filter.Severities, event.Severity) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+)
+
+// nextEvent waits for the next event of a stream
+func nextEvent(t *testing.T, events <-chan *domain.Event) *domain.Event {
+	select {
+	case event := <-events:
+		require.NotNil(t, event, "stream closed")
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event streamed")
+		return nil
+	}
+}
+
+func TestEventStreamService_Filters(t *testing.T) {
+	bus := NewEventBus()
+	streams := NewEventStreamService(bus, DefaultEventStreamOptions())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	all, err := streams.Subscribe(ctx, domain.EventFilter{})
+	require.NoError(t, err)
+	filtered, err := streams.Subscribe(ctx, domain.EventFilter{ResourceID: "prod-db", Severities: []string{"critical"}})
+	require.NoError(t, err)
+
+	bus.ObserveCommand(ctx, &domain.SessionCommand{ID: "low", ResourceID: "prod-db", Risk: "low"})
+	bus.ObserveAuditLog(ctx, &domain.AuditLog{ID: "log-1", ResourceID: "prod-db"})
+	bus.ObserveAlert(ctx, &domain.SecurityAlert{ID: "high", ResourceID: "prod-db", Severity: "high"})
+	bus.ObserveAlert(ctx, &domain.SecurityAlert{ID: "other", ResourceID: "dev-db", Severity: "critical"})
+	bus.ObserveCommand(ctx, &domain.SessionCommand{ID: "critical", ResourceID: "prod-db", Risk: "critical"})
+	bus.ObserveSession(ctx, domain.EventSessionStarted, &domain.Session{ID: "s-1", ResourceID: "prod-db"})
+
+	// Low-risk commands and audit events are not streamed
+	assert.Equal(t, "high", nextEvent(t, all).Alert.ID)
+	assert.Equal(t, "other", nextEvent(t, all).Alert.ID)
+	assert.Equal(t, "critical", nextEvent(t, all).Command.ID)
+	assert.Equal(t, domain.EventSessionStarted, nextEvent(t, all).Type)
+
+	// Events without a severity pass the severity filter
+	assert.Equal(t, "critical", nextEvent(t, filtered).Command.ID)
+	assert.Equal(t, "s-1", nextEvent(t, filtered).SessionID)
+
+	cancel()
+	require.Eventually(t, func() bool {
+		_, open := <-filtered
+		return !open
+	}, 5*time.Second, time.Millisecond)
+	b := bus.(*eventBus)
+	require.Eventually(t, func() bool {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		return len(b.subscribers) <= 1
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestEventStreamService_InvalidFilter(t *testing.T) {
+	streams := NewEventStreamService(NewEventBus(), DefaultEventStreamOptions())
+	ctx := context.Background()
+
+	_, err := streams.Subscribe(ctx, domain.EventFilter{Types: []string{domain.EventAuditLog}})
+	assert.Error(t, err)
+	_, err = streams.Subscribe(ctx, domain.EventFilter{Severities: []string{"urgent"}})
+	assert.Error(t, err)
+}
+
+func TestEventStreamService_ProxyLifecycle(t *testing.T) {
+	bus := NewEventBus()
+	proxies, _ := newTestProxyService(t, CommandHoldPolicy{})
+	proxies.AddObserver(bus)
+	streams := NewEventStreamService(bus, DefaultEventStreamOptions())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := streams.Subscribe(ctx, domain.EventFilter{Types: []string{domain.EventProxyStarted, domain.EventProxyStopped}})
+	require.NoError(t, err)
+
+	proxy, err := proxies.CreateProxy(ctx, "s-1", "ssh", "127.0.0.1", 22)
+	require.NoError(t, err)
+	_, err = proxies.StartProxy(ctx, proxy.ID)
+	require.NoError(t, err)
+	require.NoError(t, proxies.StopProxy(ctx, proxy.ID))
+
+	started := nextEvent(t, events)
+	assert.Equal(t, domain.EventProxyStarted, started.Type)
+	assert.Equal(t, proxy.ID, started.Proxy.ID)
+	assert.Equal(t, "s-1", started.SessionID)
+	assert.Equal(t, "active", started.Proxy.Status)
+	stopped := nextEvent(t, events)
+	assert.Equal(t, domain.EventProxyStopped, stopped.Type)
+	assert.Equal(t, "closed", stopped.Proxy.Status)
+}
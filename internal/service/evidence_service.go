@@ -0,0 +1,566 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// evidenceManifestVersion is the version of evidence manifests written now
+const evidenceManifestVersion = 1
+
+// Files every evidence bundle has at its root
+const (
+	EvidenceManifestName = "manifest.json"
+	EvidenceSummaryName  = "SUMMARY.txt"
+)
+
+// auditEvidenceExported is the audit log action written for every export
+const auditEvidenceExported = "evidence_exported"
+
+// EvidenceOptions configures evidence bundles. Their manifests are signed
+// with SigningKey, normally the key recording manifests are signed with.
+// Without a key, one is generated that lasts until the server restarts.
+type EvidenceOptions struct {
+	SigningKey ed25519.PrivateKey
+}
+
+type evidenceService struct {
+	options                 EvidenceOptions
+	sessionService          domain.SessionService
+	sessionCommandService   domain.SessionCommandService
+	securityAlertService    domain.SecurityAlertService
+	commandApprovalService  domain.CommandApprovalService
+	accessRequestService    domain.AccessRequestService
+	sessionRecordingService domain.SessionRecordingService
+	auditLogService         domain.AuditLogService
+	now                     func() time.Time
+}
+
+func NewEvidenceService(
+	sessionService domain.SessionService,
+	sessionCommandService domain.SessionCommandService,
+	securityAlertService domain.SecurityAlertService,
+	commandApprovalService domain.CommandApprovalService,
+	accessRequestService domain.AccessRequestService,
+	sessionRecordingService domain.SessionRecordingService,
+	auditLogService domain.AuditLogService,
+	options EvidenceOptions,
+) domain.EvidenceService {
+	if len(options.SigningKey) != ed25519.PrivateKeySize {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			utils.Fatalf("Failed to generate evidence signing key: %v", err)
+		}
+		options.SigningKey = key
+		utils.Warn("WARNING: No evidence signing key configured. Generated temporary key; bundles signed with it cannot be verified after a restart!")
+	}
+	return &evidenceService{
+		options:                 options,
+		sessionService:          sessionService,
+		sessionCommandService:   sessionCommandService,
+		securityAlertService:    securityAlertService,
+		commandApprovalService:  commandApprovalService,
+		accessRequestService:    accessRequestService,
+		sessionRecordingService: sessionRecordingService,
+		auditLogService:         auditLogService,
+		now:                     time.Now,
+	}
+}
+
+// sessionEvidence is everything collected about one session
+type sessionEvidence struct {
+	session        *domain.Session
+	commands       []*domain.SessionCommand
+	alerts         []*domain.SecurityAlert
+	approvals      []*domain.CommandApproval
+	accessRequests []*domain.AccessRequest
+	auditLogs      []*domain.AuditLog
+	recordings     []*recordingEvidence
+}
+
+// recordingEvidence is a recording with its signed manifest. Its file, as
+// played back, is streamed into the bundle; Unavailable tells why the file
+// could not be read.
+type recordingEvidence struct {
+	Recording    *domain.SessionRecording      `json:"recording"`
+	Manifest     *domain.RecordingManifest     `json:"manifest,omitempty"`
+	Verification *domain.RecordingVerification `json:"verification,omitempty"`
+	Unavailable  string                        `json:"unavailable,omitempty"`
+}
+
+// ExportBundle collects the session rows, commands, alerts, command
+// approvals, access requests, audit entries and recordings of the sessions
+// and writes them to w as a zip archive, with a human-readable summary and a
+// signed manifest of every file's hash. Recordings are decrypted and hashed
+// as they are copied into the archive. Nothing is written unless every
+// session exists.
+func (s *evidenceService) ExportBundle(ctx context.Context, sessionIDs []string, exportedBy string, w io.Writer) (*domain.EvidenceManifest, error) {
+	if len(sessionIDs) == 0 {
+		return nil, fmt.Errorf("at least one session is required")
+	}
+
+	var sessions []*sessionEvidence
+	seen := make(map[string]bool, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		if seen[sessionID] {
+			continue
+		}
+		seen[sessionID] = true
+		evidence, err := s.collect(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, evidence)
+	}
+
+	manifest := &domain.EvidenceManifest{
+		Version:    evidenceManifestVersion,
+		BundleID:   uuid.New().String(),
+		ExportedBy: exportedBy,
+		CreatedAt:  s.now().UTC(),
+		Files:      []domain.EvidenceFile{},
+	}
+	for _, evidence := range sessions {
+		manifest.SessionIDs = append(manifest.SessionIDs, evidence.session.ID)
+	}
+
+	archive := zip.NewWriter(w)
+	addFrom := func(name string, r io.Reader) error {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: manifest.CreatedAt})
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		size, err := io.Copy(io.MultiWriter(file, hash), r)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, domain.EvidenceFile{Name: name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))})
+		return nil
+	}
+	add := func(name string, data []byte) error {
+		return addFrom(name, bytes.NewReader(data))
+	}
+	addJSON := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return add(name, append(data, '\n'))
+	}
+
+	for _, evidence := range sessions {
+		dir := path.Join("sessions", evidence.session.ID)
+		// Recordings go first, so that recordings.json tells which could
+		// not be read
+		for _, recording := range evidence.recordings {
+			if err := s.addRecording(ctx, dir, recording, addFrom); err != nil {
+				return nil, fmt.Errorf("failed to write recording %s: %w", recording.Recording.ID, err)
+			}
+		}
+		artefacts := []struct {
+			name string
+			v    interface{}
+		}{
+			{"session.json", evidence.session},
+			{"commands.json", evidence.commands},
+			{"alerts.json", evidence.alerts},
+			{"approvals.json", evidence.approvals},
+			{"access_requests.json", evidence.accessRequests},
+			{"audit_log.json", evidence.auditLogs},
+			{"recordings.json", evidence.recordings},
+		}
+		for _, artefact := range artefacts {
+			if err := addJSON(path.Join(dir, artefact.name), artefact.v); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", artefact.name, err)
+			}
+		}
+	}
+
+	if err := add(EvidenceSummaryName, evidenceSummary(manifest, sessions)); err != nil {
+		return nil, fmt.Errorf("failed to write summary: %w", err)
+	}
+	if err := signEvidenceManifest(manifest, s.options.SigningKey); err != nil {
+		return nil, fmt.Errorf("failed to sign manifest: %w", err)
+	}
+	manifestFile, err := archive.CreateHeader(&zip.FileHeader{Name: EvidenceManifestName, Method: zip.Deflate, Modified: manifest.CreatedAt})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(manifestFile).Encode(manifest); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, manifest)
+	utils.Infof("Evidence bundle %s exported by %s for sessions %s", manifest.BundleID, exportedBy, strings.Join(manifest.SessionIDs, ", "))
+	return manifest, nil
+}
+
+// collect gathers the evidence of one session
+func (s *evidenceService) collect(ctx context.Context, sessionID string) (*sessionEvidence, error) {
+	session, err := s.sessionService.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("session %s not found", sessionID)
+	}
+	evidence := &sessionEvidence{session: session}
+
+	if evidence.commands, err = s.sessionCommandService.GetSessionCommands(ctx, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to get commands of session %s: %w", sessionID, err)
+	}
+	if evidence.alerts, err = s.securityAlertService.GetAlerts(ctx, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to get alerts of session %s: %w", sessionID, err)
+	}
+	sort.Slice(evidence.alerts, func(i, j int) bool {
+		return evidence.alerts[i].CreatedAt.Before(evidence.alerts[j].CreatedAt)
+	})
+	if evidence.approvals, err = s.commandApprovalService.ListBySession(ctx, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to get approvals of session %s: %w", sessionID, err)
+	}
+	if evidence.accessRequests, err = s.accessRequestsFor(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to get access requests of session %s: %w", sessionID, err)
+	}
+	if evidence.auditLogs, err = s.auditLogsFor(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to get audit log of session %s: %w", sessionID, err)
+	}
+
+	recordings, err := s.sessionRecordingService.ListRecordings(ctx, domain.RecordingFilter{SessionID: sessionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recordings of session %s: %w", sessionID, err)
+	}
+	// Oldest first, like everything else in the bundle
+	slices.Reverse(recordings)
+	for _, recording := range recordings {
+		evidence.recordings = append(evidence.recordings, s.recordingEvidence(ctx, recording))
+	}
+
+	// Keep the bundle's JSON files arrays even when empty
+	if evidence.commands == nil {
+		evidence.commands = []*domain.SessionCommand{}
+	}
+	if evidence.alerts == nil {
+		evidence.alerts = []*domain.SecurityAlert{}
+	}
+	if evidence.approvals == nil {
+		evidence.approvals = []*domain.CommandApproval{}
+	}
+	if evidence.recordings == nil {
+		evidence.recordings = []*recordingEvidence{}
+	}
+	return evidence, nil
+}
+
+// recordingEvidence looks up the manifest of a recording and verifies it
+func (s *evidenceService) recordingEvidence(ctx context.Context, recording *domain.SessionRecording) *recordingEvidence {
+	evidence := &recordingEvidence{Recording: recording}
+	if recording.ManifestPath == "" {
+		return evidence
+	}
+	var err error
+	if evidence.Manifest, err = s.sessionRecordingService.GetRecordingManifest(ctx, recording.ID); err != nil {
+		utils.Warnf("Exporting recording %s without its manifest: %v", recording.ID, err)
+	}
+	if evidence.Verification, err = s.sessionRecordingService.VerifyRecording(ctx, recording.ID); err != nil {
+		utils.Warnf("Exporting recording %s without verifying it: %v", recording.ID, err)
+	}
+	return evidence
+}
+
+// addRecording copies a recording's file into the bundle under dir. A file
+// that cannot be opened is left out and marked unavailable; one that fails
+// while it is copied fails the export, as the archive already holds part of
+// it.
+func (s *evidenceService) addRecording(ctx context.Context, dir string, recording *recordingEvidence, addFrom func(string, io.Reader) error) error {
+	file, err := s.sessionRecordingService.OpenRecordingFile(ctx, recording.Recording.ID)
+	if err != nil {
+		recording.Unavailable = err.Error()
+		return nil
+	}
+	defer file.Close()
+
+	name := path.Join(dir, "recordings", recording.Recording.ID+recordingExtensions[recording.Recording.Format])
+	return addFrom(name, file)
+}
+
+// accessRequestsFor returns the requests the session's user made for its
+// resource that were still open when the session started
+func (s *evidenceService) accessRequestsFor(ctx context.Context, session *domain.Session) ([]*domain.AccessRequest, error) {
+	requests, err := s.accessRequestService.GetAccessRequestByUserID(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	matched := []*domain.AccessRequest{}
+	for _, request := range requests {
+		if request.ResourceID != session.ResourceID || request.RequestedAt.After(session.StartTime) {
+			continue
+		}
+		if !request.ExpiresAt.IsZero() && request.ExpiresAt.Before(session.StartTime) {
+			continue
+		}
+		matched = append(matched, request)
+	}
+	return matched, nil
+}
+
+// auditLogsFor returns the audit entries of the session's user while it ran
+// and every entry that names the session, such as legal holds
+func (s *evidenceService) auditLogsFor(ctx context.Context, session *domain.Session) ([]*domain.AuditLog, error) {
+	end := session.EndTime
+	if end.IsZero() {
+		end = s.now()
+	}
+	logs, err := s.auditLogService.GetBySession(ctx, session.ID, session.UserID, session.StartTime, end)
+	if err != nil {
+		return nil, err
+	}
+	if logs == nil {
+		logs = []*domain.AuditLog{}
+	}
+	return logs, nil
+}
+
+// audit records that a bundle was exported
+func (s *evidenceService) audit(ctx context.Context, manifest *domain.EvidenceManifest) {
+	details, _ := json.Marshal(map[string]interface{}{
+		"bundle_id":   manifest.BundleID,
+		"session_ids": manifest.SessionIDs,
+		"files":       len(manifest.Files),
+	})
+	entry := &domain.AuditLog{UserID: manifest.ExportedBy, Action: auditEvidenceExported, Details: string(details)}
+	if err := s.auditLogService.Create(ctx, entry); err != nil {
+		utils.Errorf("Failed to write audit entry for evidence bundle %s: %v", manifest.BundleID, err)
+	}
+}
+
+func (s *evidenceService) SigningPublicKey() []byte {
+	return s.options.SigningKey.Public().(ed25519.PublicKey)
+}
+
+// evidenceSummary describes the bundle for the people reading it
+func evidenceSummary(manifest *domain.EvidenceManifest, sessions []*sessionEvidence) []byte {
+	var b bytes.Buffer
+	timestamp := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	fmt.Fprintf(&b, "Evidence bundle %s\n", manifest.BundleID)
+	fmt.Fprintf(&b, "Exported %s by %s\n", timestamp(manifest.CreatedAt), manifest.ExportedBy)
+	fmt.Fprintf(&b, "Sessions: %d\n", len(sessions))
+
+	for _, evidence := range sessions {
+		session := evidence.session
+		fmt.Fprintf(&b, "\nSession %s\n", session.ID)
+		fmt.Fprintf(&b, "  User:      %s (%s)\n", session.Username, session.UserID)
+		fmt.Fprintf(&b, "  Resource:  %s\n", session.ResourceID)
+		fmt.Fprintf(&b, "  Status:    %s\n", session.Status)
+		fmt.Fprintf(&b, "  Client IP: %s\n", session.ClientIP)
+		fmt.Fprintf(&b, "  Started:   %s\n", timestamp(session.StartTime))
+		fmt.Fprintf(&b, "  Ended:     %s\n", timestamp(session.EndTime))
+
+		fmt.Fprintf(&b, "\n  Access requests: %d\n", len(evidence.accessRequests))
+		for _, request := range evidence.accessRequests {
+			fmt.Fprintf(&b, "    - %s %s, reviewed by %s at %s: %s\n",
+				request.ID, request.Status, orDash(request.ReviewerID), timestamp(request.ReviewedAt), request.Reason)
+		}
+
+		var blocked, held, highRisk int
+		for _, command := range evidence.commands {
+			if command.Status == "blocked" {
+				blocked++
+			}
+			if command.ApprovalID != "" {
+				held++
+			}
+			if riskLevels[command.Risk] >= riskLevels["high"] {
+				highRisk++
+			}
+		}
+		fmt.Fprintf(&b, "\n  Commands: %d (%d blocked, %d held for approval, %d high or critical risk)\n",
+			len(evidence.commands), blocked, held, highRisk)
+
+		fmt.Fprintf(&b, "\n  Alerts: %d\n", len(evidence.alerts))
+		for _, alert := range evidence.alerts {
+			fmt.Fprintf(&b, "    - %s [%s] %s\n", timestamp(alert.CreatedAt), alert.Severity, alert.Title)
+		}
+
+		fmt.Fprintf(&b, "\n  Command approvals: %d\n", len(evidence.approvals))
+		for _, approval := range evidence.approvals {
+			fmt.Fprintf(&b, "    - %s %s by %s: %s\n",
+				timestamp(approval.RequestedAt), approval.Status, orDash(approval.ReviewerID), approval.Command)
+		}
+
+		fmt.Fprintf(&b, "\n  Recordings: %d\n", len(evidence.recordings))
+		for _, recording := range evidence.recordings {
+			status := "not verified"
+			switch {
+			case recording.Unavailable != "":
+				status = "unavailable: " + recording.Unavailable
+			case recording.Verification != nil:
+				status = recording.Verification.Status
+			}
+			fmt.Fprintf(&b, "    - %s %s, %d bytes, %s\n",
+				recording.Recording.ID, recording.Recording.Format, recording.Recording.Size, status)
+		}
+
+		fmt.Fprintf(&b, "\n  Audit entries: %d\n", len(evidence.auditLogs))
+	}
+
+	fmt.Fprintf(&b, "\nEvery file in this bundle is listed in %s with its SHA-256 hash.\n", EvidenceManifestName)
+	fmt.Fprintf(&b, "The manifest is signed with the Ed25519 key %s.\n", manifest.PublicKey)
+	fmt.Fprintf(&b, "Check it with: secretary evidence verify -file BUNDLE.zip\n")
+	return b.Bytes()
+}
+
+// orDash stands in for values that are not set in the summary
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// evidenceSigningBytes is what an evidence manifest's signature covers: the
+// manifest as JSON, without the signature
+func evidenceSigningBytes(manifest *domain.EvidenceManifest) ([]byte, error) {
+	unsigned := *manifest
+	unsigned.Signature = ""
+	return json.Marshal(&unsigned)
+}
+
+// signEvidenceManifest fills in the manifest's public key and signature
+func signEvidenceManifest(manifest *domain.EvidenceManifest, key ed25519.PrivateKey) error {
+	manifest.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	data, err := evidenceSigningBytes(manifest)
+	if err != nil {
+		return err
+	}
+	manifest.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	return nil
+}
+
+// VerifyEvidenceBundle checks an evidence bundle against its manifest. The
+// manifest must be signed by one of trustedKeys, and the bundle must hold exactly
+// the files it lists, unchanged.
+func VerifyEvidenceBundle(bundle io.ReaderAt, size int64, trustedKeys ...ed25519.PublicKey) (*domain.EvidenceVerification, error) {
+	archive, err := zip.NewReader(bundle, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid evidence bundle: %w", err)
+	}
+	result := &domain.EvidenceVerification{VerifiedAt: time.Now()}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	manifestFile, ok := files[EvidenceManifestName]
+	if !ok {
+		return nil, fmt.Errorf("invalid evidence bundle: %s is missing", EvidenceManifestName)
+	}
+	data, err := readZipFile(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var manifest domain.EvidenceManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid evidence manifest: %w", err)
+	}
+	result.BundleID = manifest.BundleID
+
+	signature, sigErr := base64.StdEncoding.DecodeString(manifest.Signature)
+	trustedKey := trustedSigningKey(manifest.PublicKey, trustedKeys)
+	signed, err := evidenceSigningBytes(&manifest)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case manifest.Signature == "":
+		result.Details = "manifest is not signed"
+		return result, nil
+	case trustedKey == nil:
+		result.Details = "manifest was signed by an unknown key"
+		return result, nil
+	case sigErr != nil || !ed25519.Verify(trustedKey, signed, signature):
+		result.Details = "manifest signature does not match its content"
+		return result, nil
+	}
+
+	listed := make(map[string]bool, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		listed[entry.Name] = true
+		file, ok := files[entry.Name]
+		if !ok {
+			result.Problems = append(result.Problems, fmt.Sprintf("%s is missing", entry.Name))
+			continue
+		}
+		size, sum, err := hashZipFile(file)
+		if err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("%s cannot be read: %v", entry.Name, err))
+			continue
+		}
+		if size != entry.Size || sum != entry.SHA256 {
+			result.Problems = append(result.Problems, fmt.Sprintf("%s does not match its signed hash", entry.Name))
+		}
+	}
+	for _, file := range archive.File {
+		if file.Name != EvidenceManifestName && !listed[file.Name] {
+			result.Problems = append(result.Problems, fmt.Sprintf("%s is not in the manifest", file.Name))
+		}
+	}
+
+	if len(result.Problems) > 0 {
+		result.Details = fmt.Sprintf("%d of %d files failed verification", len(result.Problems), len(manifest.Files))
+		return result, nil
+	}
+	result.Valid = true
+	result.Details = fmt.Sprintf("%d files match the signed manifest", len(manifest.Files))
+	return result, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// hashZipFile returns the size and the hex SHA-256 of a file in an archive,
+// without holding it in memory
+func hashZipFile(file *zip.File) (int64, string, error) {
+	r, err := file.Open()
+	if err != nil {
+		return 0, "", err
+	}
+	defer r.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
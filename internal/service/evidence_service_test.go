@@ -0,0 +1,146 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+func TestEvidenceService_ExportBundle(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	sessions := NewSessionService(repository.NewSessionRepository(db))
+	commands := NewSessionCommandService(repository.NewSessionCommandRepository(db))
+	alerts := NewSecurityAlertService(repository.NewSecurityAlertRepository(db))
+	approvals := newTestCommandApprovalService(t, nil)
+	accessRequests := NewAccessRequestService(repository.NewAccessRequestRepository(db), nil)
+	auditLogs := NewAuditLogService(repository.NewAuditLogRepository(db), repository.NewAuditCheckpointRepository(db), AuditLogOptions{})
+	recordings := NewSessionRecordingService(repository.NewSessionRecordingRepository(db), sessions, RecordingOptions{BasePath: t.TempDir(), MasterKey: testMasterKey(t)})
+
+	request := &domain.AccessRequest{UserID: "alice", ResourceID: "db-1", Reason: "incident 42"}
+	require.NoError(t, accessRequests.CreateAccessRequest(ctx, request))
+	// Requests for other resources are not evidence of this session
+	require.NoError(t, accessRequests.CreateAccessRequest(ctx, &domain.AccessRequest{UserID: "alice", ResourceID: "db-2", Reason: "other"}))
+
+	session := &domain.Session{ID: "s-1", UserID: "alice", Username: "alice", ResourceID: "db-1", StartTime: time.Now(), Status: "active", ClientIP: "10.0.0.1"}
+	require.NoError(t, sessions.Create(ctx, session))
+	recording, err := recordings.StartRecording(ctx, "s-1", "")
+	require.NoError(t, err)
+	require.NoError(t, recordings.WriteOutput(ctx, "s-1", []byte("$ whoami\r\nalice\r\n")))
+	require.NoError(t, recordings.StopRecording(ctx, "s-1"))
+
+	require.NoError(t, commands.RecordCommand(ctx, &domain.SessionCommand{SessionID: "s-1", UserID: "alice", ResourceID: "db-1", Command: "DROP TABLE users", Risk: "critical", Status: "blocked"}))
+	require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{SessionID: "s-1", UserID: "alice", Severity: "critical", Title: "Destructive command"}))
+	require.NoError(t, approvals.RequestApproval(ctx, &domain.CommandApproval{SessionID: "s-1", Command: "DELETE FROM orders"}, time.Minute))
+
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	svc := NewEvidenceService(sessions, commands, alerts, approvals, accessRequests, recordings, auditLogs, EvidenceOptions{SigningKey: key})
+
+	var bundle bytes.Buffer
+	manifest, err := svc.ExportBundle(ctx, []string{"s-1", "s-1"}, "bob", &bundle)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s-1"}, manifest.SessionIDs)
+
+	archive, err := zip.NewReader(bytes.NewReader(bundle.Bytes()), int64(bundle.Len()))
+	require.NoError(t, err)
+	read := func(name string) []byte {
+		for _, file := range archive.File {
+			if file.Name == name {
+				data, err := readZipFile(file)
+				require.NoError(t, err)
+				return data
+			}
+		}
+		t.Fatalf("bundle has no %s", name)
+		return nil
+	}
+
+	var exported []*domain.AccessRequest
+	require.NoError(t, json.Unmarshal(read("sessions/s-1/access_requests.json"), &exported))
+	require.Len(t, exported, 1)
+	assert.Equal(t, request.ID, exported[0].ID)
+
+	var exportedCommands []*domain.SessionCommand
+	require.NoError(t, json.Unmarshal(read("sessions/s-1/commands.json"), &exportedCommands))
+	require.Len(t, exportedCommands, 1)
+	assert.Equal(t, "DROP TABLE users", exportedCommands[0].Command)
+
+	assert.Contains(t, string(read("sessions/s-1/recordings/"+recording.ID+".cast")), "alice")
+	assert.Contains(t, string(read("sessions/s-1/recordings.json")), domain.RecordingVerified)
+	assert.Contains(t, string(read("sessions/s-1/alerts.json")), "Destructive command")
+	assert.Contains(t, string(read("sessions/s-1/approvals.json")), "DELETE FROM orders")
+	summary := string(read(EvidenceSummaryName))
+	assert.Contains(t, summary, "Commands: 1 (1 blocked")
+	assert.Contains(t, summary, "[critical] Destructive command")
+
+	// The export itself is audited
+	logs, err := auditLogs.GetByAction(ctx, auditEvidenceExported)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "bob", logs[0].UserID)
+
+	publicKey := key.Public().(ed25519.PublicKey)
+	verification, err := VerifyEvidenceBundle(bytes.NewReader(bundle.Bytes()), int64(bundle.Len()), publicKey)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid, verification.Details)
+	assert.Equal(t, manifest.BundleID, verification.BundleID)
+
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	verification, err = VerifyEvidenceBundle(bytes.NewReader(bundle.Bytes()), int64(bundle.Len()), otherKey)
+	require.NoError(t, err)
+	assert.False(t, verification.Valid)
+
+	// Unknown sessions fail the export before anything is written
+	var empty bytes.Buffer
+	_, err = svc.ExportBundle(ctx, []string{"s-1", "missing"}, "bob", &empty)
+	assert.Error(t, err)
+	assert.Zero(t, empty.Len())
+}
+
+func TestVerifyEvidenceBundle_Tampered(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	// A bundle whose summary was edited after it was signed
+	manifest := &domain.EvidenceManifest{Version: evidenceManifestVersion, BundleID: "bundle-1", Files: []domain.EvidenceFile{
+		{Name: EvidenceSummaryName, Size: 8, SHA256: "0000000000000000000000000000000000000000000000000000000000000000"},
+	}}
+	require.NoError(t, signEvidenceManifest(manifest, key))
+
+	var bundle bytes.Buffer
+	archive := zip.NewWriter(&bundle)
+	for name, write := range map[string]func(io.Writer) error{
+		EvidenceSummaryName: func(w io.Writer) error { _, err := w.Write([]byte("tampered")); return err },
+		"extra.txt":         func(w io.Writer) error { _, err := w.Write([]byte("added")); return err },
+		EvidenceManifestName: func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(manifest)
+		},
+	} {
+		w, err := archive.Create(name)
+		require.NoError(t, err)
+		require.NoError(t, write(w))
+	}
+	require.NoError(t, archive.Close())
+
+	verification, err := VerifyEvidenceBundle(bytes.NewReader(bundle.Bytes()), int64(bundle.Len()), key.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	assert.False(t, verification.Valid)
+	assert.ElementsMatch(t, []string{
+		EvidenceSummaryName + " does not match its signed hash",
+		"extra.txt is not in the manifest",
+	}, verification.Problems)
+}
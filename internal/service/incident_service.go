@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// Page sizes of incident searches
+const (
+	DefaultIncidentPageSize = 100
+	MaxIncidentPageSize     = 1000
+)
+
+// DefaultCorrelationPolicy groups alerts of the same type raised for the
+// same user on the same resource less than 15 minutes apart.
+func DefaultCorrelationPolicy() domain.CorrelationPolicy {
+	return domain.CorrelationPolicy{
+		Rules: []domain.CorrelationRule{
+			{
+				Name:          "default",
+				GroupBy:       []string{domain.CorrelateUser, domain.CorrelateResource, domain.CorrelateType},
+				WindowMinutes: 15,
+			},
+		},
+	}
+}
+
+// LoadCorrelationPolicy reads and validates a JSON correlation policy
+func LoadCorrelationPolicy(path string) (*domain.CorrelationPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy domain.CorrelationPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid correlation policy: %w", err)
+	}
+	if err := ValidateCorrelationPolicy(&policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ValidateCorrelationPolicy checks that every rule is named once, groups by
+// known fields over a positive window and names known severities.
+func ValidateCorrelationPolicy(policy *domain.CorrelationPolicy) error {
+	names := make(map[string]bool)
+	fields := []string{domain.CorrelateUser, domain.CorrelateResource, domain.CorrelateType, domain.CorrelateSession}
+	for i, rule := range policy.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("correlation rule %d has no name", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("correlation rule %q is defined twice", rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.WindowMinutes <= 0 {
+			return fmt.Errorf("correlation rule %q needs a positive window", rule.Name)
+		}
+		for _, field := range rule.GroupBy {
+			if !slices.Contains(fields, field) {
+				return fmt.Errorf("correlation rule %q groups by unknown field %q", rule.Name, field)
+			}
+		}
+		for _, severity := range rule.Severities {
+			if _, ok := riskLevels[severity]; !ok {
+				return fmt.Errorf("correlation rule %q has unknown severity %q", rule.Name, severity)
+			}
+		}
+	}
+	return nil
+}
+
+type incidentService struct {
+	// mu keeps two alerts from opening the same incident at once
+	mu           sync.Mutex
+	policy       domain.CorrelationPolicy
+	incidentRepo domain.IncidentRepository
+	now          func() time.Time
+}
+
+func NewIncidentService(incidentRepo domain.IncidentRepository, policy domain.CorrelationPolicy) domain.IncidentService {
+	if policy.Rules == nil {
+		policy = DefaultCorrelationPolicy()
+	}
+	return &incidentService{
+		policy:       policy,
+		incidentRepo: incidentRepo,
+		now:          time.Now,
+	}
+}
+
+// Correlate adds the alert to the open incident of the first rule it
+// matches, if that incident saw an alert within the rule's window, and opens
+// a new incident otherwise. Alerts no rule matches open one of their own.
+func (s *incidentService) Correlate(ctx context.Context, alert *domain.SecurityAlert) (*domain.Incident, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at := alert.CreatedAt
+	if at.IsZero() {
+		at = s.now()
+	}
+
+	rule := s.ruleFor(alert)
+	if rule != nil {
+		incident, err := s.incidentRepo.FindOpenByKey(correlationKey(rule, alert))
+		window := time.Duration(rule.WindowMinutes) * time.Minute
+		if err == nil && !incident.LastSeen.Before(at.Add(-window)) {
+			incident.AlertCount++
+			if at.After(incident.LastSeen) {
+				incident.LastSeen = at
+			}
+			incident.Severity = higherRisk(incident.Severity, alert.Severity)
+			incident.UpdatedAt = s.now()
+			if err := s.incidentRepo.Update(incident); err != nil {
+				return nil, false, fmt.Errorf("failed to update incident: %w", err)
+			}
+			return incident, false, nil
+		}
+	}
+
+	incident := &domain.Incident{
+		UserID:     alert.UserID,
+		ResourceID: alert.ResourceID,
+		SessionID:  alert.SessionID,
+		AlertType:  alert.AlertType,
+		Severity:   alert.Severity,
+		Title:      alert.Title,
+		Status:     domain.IncidentOpen,
+		AlertCount: 1,
+		FirstSeen:  at,
+		LastSeen:   at,
+		CreatedAt:  s.now(),
+	}
+	if rule != nil {
+		incident.Rule = rule.Name
+		incident.Key = correlationKey(rule, alert)
+	} else {
+		// A key no other alert shares
+		incident.Key = "alert\x00" + alert.ID
+	}
+	if err := s.incidentRepo.Create(incident); err != nil {
+		return nil, false, fmt.Errorf("failed to open incident: %w", err)
+	}
+	utils.Infof("Opened incident %s: %s", incident.ID, incident.Title)
+	return incident, true, nil
+}
+
+func (s *incidentService) ruleFor(alert *domain.SecurityAlert) *domain.CorrelationRule {
+	matches := func(values []string, value string) bool {
+		return len(values) == 0 || slices.Contains(values, value)
+	}
+	for i, rule := range s.policy.Rules {
+		if matches(rule.Severities, alert.Severity) && matches(rule.AlertTypes, alert.AlertType) {
+			return &s.policy.Rules[i]
+		}
+	}
+	return nil
+}
+
+// correlationKey is the rule and the alert's values of the fields the rule
+// groups by
+func correlationKey(rule *domain.CorrelationRule, alert *domain.SecurityAlert) string {
+	parts := []string{rule.Name}
+	for _, field := range rule.GroupBy {
+		switch field {
+		case domain.CorrelateUser:
+			parts = append(parts, alert.UserID)
+		case domain.CorrelateResource:
+			parts = append(parts, alert.ResourceID)
+		case domain.CorrelateType:
+			parts = append(parts, alert.AlertType)
+		case domain.CorrelateSession:
+			parts = append(parts, alert.SessionID)
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func (s *incidentService) GetIncident(ctx context.Context, id string) (*domain.Incident, error) {
+	return s.incidentRepo.FindByID(id)
+}
+
+// SearchIncidents returns one page of the incidents matching filter, most
+// recently active first. The page size defaults to DefaultIncidentPageSize
+// and is capped at MaxIncidentPageSize.
+func (s *incidentService) SearchIncidents(ctx context.Context, filter domain.IncidentFilter) (*domain.IncidentPage, error) {
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return nil, errors.New("limit and offset cannot be negative")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, errors.New("from must be before to")
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultIncidentPageSize
+	}
+	if filter.Limit > MaxIncidentPageSize {
+		filter.Limit = MaxIncidentPageSize
+	}
+	for _, status := range filter.Statuses {
+		if status != domain.IncidentOpen && status != domain.IncidentResolved {
+			return nil, fmt.Errorf("unknown incident status %q", status)
+		}
+	}
+
+	incidents, total, err := s.incidentRepo.Search(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search incidents: %w", err)
+	}
+	return &domain.IncidentPage{
+		Incidents: incidents,
+		Total:     total,
+		Limit:     filter.Limit,
+		Offset:    filter.Offset,
+	}, nil
+}
+
+// UpdateIncidentStatus resolves or reopens an incident. Alerts arriving
+// after an incident is resolved open a new one.
+func (s *incidentService) UpdateIncidentStatus(ctx context.Context, id string, status string, userID string) (*domain.Incident, error) {
+	if status != domain.IncidentOpen && status != domain.IncidentResolved {
+		return nil, fmt.Errorf("unknown incident status %q", status)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	incident, err := s.incidentRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if incident.UserID != "" && incident.UserID == userID {
+		return nil, errors.New("reviewers cannot resolve or reopen incidents about themselves")
+	}
+	if incident.Status == status {
+		return nil, fmt.Errorf("incident %s is already %s", id, status)
+	}
+
+	now := s.now()
+	incident.Status = status
+	if status == domain.IncidentResolved {
+		incident.ResolvedBy = userID
+		incident.ResolvedAt = now
+	} else {
+		incident.ResolvedBy = ""
+		incident.ResolvedAt = time.Time{}
+	}
+	incident.UpdatedAt = now
+	if err := s.incidentRepo.Update(incident); err != nil {
+		return nil, err
+	}
+	utils.Infof("Incident %s moved to %s by %s", id, status, userID)
+	return incident, nil
+}
+
+func (s *incidentService) GetPolicy(ctx context.Context) *domain.CorrelationPolicy {
+	return &s.policy
+}
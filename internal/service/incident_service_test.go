@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+func newTestIncidentService(t *testing.T, policy domain.CorrelationPolicy) (*incidentService, domain.SecurityAlertService) {
+	db := newTestDB(t)
+
+	svc := NewIncidentService(repository.NewIncidentRepository(db), policy).(*incidentService)
+	alerts := NewSecurityAlertService(repository.NewSecurityAlertRepository(db))
+	alerts.SetCorrelator(svc)
+	return svc, alerts
+}
+
+func TestValidateCorrelationPolicy(t *testing.T) {
+	rule := domain.CorrelationRule{Name: "default", GroupBy: []string{domain.CorrelateUser}, WindowMinutes: 15}
+	tests := []struct {
+		name   string
+		policy domain.CorrelationPolicy
+	}{
+		{"unnamed rule", domain.CorrelationPolicy{Rules: []domain.CorrelationRule{{WindowMinutes: 15}}}},
+		{"duplicate rule", domain.CorrelationPolicy{Rules: []domain.CorrelationRule{rule, rule}}},
+		{"no window", domain.CorrelationPolicy{Rules: []domain.CorrelationRule{{Name: "default"}}}},
+		{"unknown field", domain.CorrelationPolicy{Rules: []domain.CorrelationRule{{Name: "default", GroupBy: []string{"ip"}, WindowMinutes: 15}}}},
+		{"unknown severity", domain.CorrelationPolicy{Rules: []domain.CorrelationRule{{Name: "default", Severities: []string{"urgent"}, WindowMinutes: 15}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, ValidateCorrelationPolicy(&tt.policy))
+		})
+	}
+
+	policy := DefaultCorrelationPolicy()
+	assert.NoError(t, ValidateCorrelationPolicy(&policy))
+}
+
+func TestIncidentService_GroupsAlerts(t *testing.T) {
+	svc, alerts := newTestIncidentService(t, DefaultCorrelationPolicy())
+	ctx := context.Background()
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// A user hammering the same blocked command opens one incident
+	for i := 0; i < 40; i++ {
+		severity := "medium"
+		if i == 20 {
+			severity = "critical"
+		}
+		require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{
+			UserID: "alice", ResourceID: "db-1", AlertType: "blocked_command", Severity: severity,
+			Title: "Blocked command", CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	page, err := svc.SearchIncidents(ctx, domain.IncidentFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 1, page.Total)
+	incident := page.Incidents[0]
+	assert.Equal(t, 40, incident.AlertCount)
+	assert.Equal(t, "critical", incident.Severity)
+	assert.Equal(t, "default", incident.Rule)
+	assert.True(t, incident.FirstSeen.Equal(base), "first seen %v", incident.FirstSeen)
+	assert.True(t, incident.LastSeen.Equal(base.Add(39*time.Minute)), "last seen %v", incident.LastSeen)
+
+	grouped, err := alerts.SearchAlerts(ctx, domain.AlertFilter{IncidentID: incident.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 40, grouped.Total)
+
+	// Another user, or the same user after a quiet window, opens a new one
+	require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{
+		UserID: "bob", ResourceID: "db-1", AlertType: "blocked_command", Severity: "medium", CreatedAt: base.Add(40 * time.Minute),
+	}))
+	require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{
+		UserID: "alice", ResourceID: "db-1", AlertType: "blocked_command", Severity: "medium", CreatedAt: base.Add(time.Hour),
+	}))
+	page, err = svc.SearchIncidents(ctx, domain.IncidentFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+}
+
+func TestIncidentService_ResolvedIncidentsStayClosed(t *testing.T) {
+	svc, alerts := newTestIncidentService(t, DefaultCorrelationPolicy())
+	ctx := context.Background()
+
+	alert := &domain.SecurityAlert{UserID: "alice", ResourceID: "db-1", AlertType: "blocked_command", Severity: "high"}
+	require.NoError(t, alerts.CreateAlert(ctx, alert))
+	require.NotEmpty(t, alert.IncidentID)
+
+	_, err := svc.UpdateIncidentStatus(ctx, alert.IncidentID, domain.IncidentResolved, "alice")
+	assert.Error(t, err, "users cannot resolve incidents about themselves")
+
+	resolved, err := svc.UpdateIncidentStatus(ctx, alert.IncidentID, domain.IncidentResolved, "carol")
+	require.NoError(t, err)
+	assert.Equal(t, "carol", resolved.ResolvedBy)
+	assert.False(t, resolved.ResolvedAt.IsZero())
+	_, err = svc.UpdateIncidentStatus(ctx, alert.IncidentID, domain.IncidentResolved, "carol")
+	assert.Error(t, err, "resolving twice should fail")
+	_, err = svc.UpdateIncidentStatus(ctx, alert.IncidentID, "closed", "carol")
+	assert.Error(t, err)
+
+	next := &domain.SecurityAlert{UserID: "alice", ResourceID: "db-1", AlertType: "blocked_command", Severity: "high"}
+	require.NoError(t, alerts.CreateAlert(ctx, next))
+	assert.NotEqual(t, alert.IncidentID, next.IncidentID)
+
+	reopened, err := svc.UpdateIncidentStatus(ctx, alert.IncidentID, domain.IncidentOpen, "carol")
+	require.NoError(t, err)
+	assert.Empty(t, reopened.ResolvedBy)
+	assert.True(t, reopened.ResolvedAt.IsZero())
+}
+
+func TestIncidentService_UnmatchedAlerts(t *testing.T) {
+	svc, alerts := newTestIncidentService(t, domain.CorrelationPolicy{Rules: []domain.CorrelationRule{
+		{Name: "escalations", AlertTypes: []string{"privilege_escalation"}, GroupBy: []string{domain.CorrelateUser}, WindowMinutes: 60},
+	}})
+	ctx := context.Background()
+
+	for _, resource := range []string{"db-1", "db-2"} {
+		require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{UserID: "alice", ResourceID: resource, AlertType: "blocked_command", Severity: "low"}))
+		require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{UserID: "alice", ResourceID: resource, AlertType: "privilege_escalation", Severity: "high"}))
+	}
+
+	page, err := svc.SearchIncidents(ctx, domain.IncidentFilter{})
+	require.NoError(t, err)
+	// Each unmatched alert stands alone; the escalations group by user only
+	assert.Equal(t, 3, page.Total)
+	for _, incident := range page.Incidents {
+		if incident.AlertType == "privilege_escalation" {
+			assert.Equal(t, 2, incident.AlertCount)
+			assert.Equal(t, "escalations", incident.Rule)
+		} else {
+			assert.Equal(t, 1, incident.AlertCount)
+			assert.Empty(t, incident.Rule)
+		}
+	}
+}
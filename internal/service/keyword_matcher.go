@@ -0,0 +1,155 @@
+package service
+
+import (
+	"sort"
+)
+
+// keywordMatcher is an Aho-Corasick automaton that reports every keyword
+// occurring in a text in a single pass over it, so the cost of a scan does not
+// depend on how many keywords are registered. Matching is ASCII
+// case-insensitive: keywords must be added in lower case.
+type keywordMatcher struct {
+	nodes []acNode
+	root  [256]int32
+	built bool
+}
+
+type acNode struct {
+	edges []acEdge
+	fail  int32
+	// output is the closest node, this one included, reachable through
+	// failure links that terminates at least one keyword, or -1.
+	output int32
+	values []int32
+}
+
+type acEdge struct {
+	label byte
+	next  int32
+}
+
+func newKeywordMatcher() *keywordMatcher {
+	return &keywordMatcher{nodes: []acNode{{output: -1}}}
+}
+
+// add registers keyword and the value reported when it is found.
+func (m *keywordMatcher) add(keyword string, value int32) {
+	state := int32(0)
+	for i := 0; i < len(keyword); i++ {
+		next := m.edge(state, keyword[i])
+		if next < 0 {
+			next = int32(len(m.nodes))
+			m.nodes = append(m.nodes, acNode{output: -1})
+			m.nodes[state].edges = append(m.nodes[state].edges, acEdge{label: keyword[i], next: next})
+		}
+		state = next
+	}
+	m.nodes[state].values = append(m.nodes[state].values, value)
+	m.built = false
+}
+
+// build computes failure and output links. It must be called after the last
+// add and before scan.
+func (m *keywordMatcher) build() {
+	for i := range m.nodes {
+		edges := m.nodes[i].edges
+		sort.Slice(edges, func(a, b int) bool { return edges[a].label < edges[b].label })
+	}
+
+	queue := make([]int32, 0, len(m.nodes))
+	for _, e := range m.nodes[0].edges {
+		m.nodes[e.next].fail = 0
+		queue = append(queue, e.next)
+	}
+	if len(m.nodes[0].values) > 0 {
+		m.nodes[0].output = 0
+	}
+
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+
+		node := &m.nodes[u]
+		if len(node.values) > 0 {
+			node.output = u
+		} else {
+			node.output = m.nodes[node.fail].output
+		}
+
+		for _, e := range node.edges {
+			f := node.fail
+			next := m.edge(f, e.label)
+			for next < 0 && f != 0 {
+				f = m.nodes[f].fail
+				next = m.edge(f, e.label)
+			}
+			if next < 0 || next == e.next {
+				next = 0
+			}
+			m.nodes[e.next].fail = next
+			queue = append(queue, e.next)
+		}
+	}
+
+	for c := 0; c < 256; c++ {
+		m.root[c] = 0
+		if next := m.edge(0, byte(c)); next >= 0 {
+			m.root[c] = next
+		}
+	}
+	m.built = true
+}
+
+// scan calls fn with the value of every keyword occurrence in text. Values
+// may be reported more than once.
+func (m *keywordMatcher) scan(text string, fn func(value int32)) {
+	if !m.built {
+		m.build()
+	}
+
+	state := int32(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+
+		for {
+			if state == 0 {
+				state = m.root[c]
+				break
+			}
+			if next := m.edge(state, c); next >= 0 {
+				state = next
+				break
+			}
+			state = m.nodes[state].fail
+		}
+
+		for out := m.nodes[state].output; out >= 0; out = m.nodes[m.nodes[out].fail].output {
+			for _, v := range m.nodes[out].values {
+				fn(v)
+			}
+			if out == 0 {
+				break
+			}
+		}
+	}
+}
+
+func (m *keywordMatcher) edge(state int32, label byte) int32 {
+	edges := m.nodes[state].edges
+	if !m.built || len(edges) < 8 {
+		for _, e := range edges {
+			if e.label == label {
+				return e.next
+			}
+		}
+		return -1
+	}
+	i := sort.Search(len(edges), func(i int) bool { return edges[i].label >= label })
+	if i < len(edges) && edges[i].label == label {
+		return edges[i].next
+	}
+	return -1
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"secretary/alpha/internal/domain"
+)
+
+// Headers of generic webhook requests. The signature is the hex HMAC-SHA256,
+// keyed with the channel secret, of the timestamp, a dot and the body.
+const (
+	NotificationSignatureHeader = "X-Secretary-Signature"
+	NotificationTimestampHeader = "X-Secretary-Timestamp"
+)
+
+// notificationSender delivers one alert to one channel
+type notificationSender func(ctx context.Context, channel domain.NotificationChannel, alert *domain.SecurityAlert, now time.Time) error
+
+func newNotificationSenders(timeout time.Duration) map[string]notificationSender {
+	client := &http.Client{Timeout: timeout}
+	return map[string]notificationSender{
+		domain.NotificationWebhook: func(ctx context.Context, channel domain.NotificationChannel, alert *domain.SecurityAlert, now time.Time) error {
+			return sendWebhook(ctx, client, channel, alert, now)
+		},
+		domain.NotificationSlack: func(ctx context.Context, channel domain.NotificationChannel, alert *domain.SecurityAlert, now time.Time) error {
+			return postJSON(ctx, client, channel.URL, map[string]string{"text": alertSummary(alert)}, nil)
+		},
+		domain.NotificationTeams: func(ctx context.Context, channel domain.NotificationChannel, alert *domain.SecurityAlert, now time.Time) error {
+			return postJSON(ctx, client, channel.URL, teamsCard(alert), nil)
+		},
+		domain.NotificationEmail: sendEmail,
+	}
+}
+
+// SignNotification returns the signature header value of a webhook body sent
+// at timestamp, for receivers to compare against
+func SignNotification(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendWebhook(ctx context.Context, client *http.Client, channel domain.NotificationChannel, alert *domain.SecurityAlert, now time.Time) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"event": "security_alert",
+		"alert": alert,
+	})
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{}
+	if channel.Secret != "" {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		headers[NotificationTimestampHeader] = timestamp
+		headers[NotificationSignatureHeader] = SignNotification(channel.Secret, timestamp, body)
+	}
+	return post(ctx, client, channel.URL, body, headers)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}, headers map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return post(ctx, client, url, body, headers)
+}
+
+func post(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}
+
+// alertSummary is the one-line text of chat notifications and email subjects
+func alertSummary(alert *domain.SecurityAlert) string {
+	summary := fmt.Sprintf("[%s] %s", strings.ToUpper(alert.Severity), alert.Title)
+	var details []string
+	if alert.UserID != "" {
+		details = append(details, "user "+alert.UserID)
+	}
+	if alert.ResourceID != "" {
+		details = append(details, "resource "+alert.ResourceID)
+	}
+	if alert.SessionID != "" {
+		details = append(details, "session "+alert.SessionID)
+	}
+	if len(details) > 0 {
+		summary += " (" + strings.Join(details, ", ") + ")"
+	}
+	return summary
+}
+
+// alertBody is the plain text of email notifications
+func alertBody(alert *domain.SecurityAlert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\r\n\r\n", alertSummary(alert))
+	if alert.Description != "" {
+		fmt.Fprintf(&b, "%s\r\n\r\n", alert.Description)
+	}
+	fmt.Fprintf(&b, "Alert:    %s\r\n", alert.ID)
+	fmt.Fprintf(&b, "Type:     %s\r\n", orDash(alert.AlertType))
+	fmt.Fprintf(&b, "Severity: %s\r\n", alert.Severity)
+	fmt.Fprintf(&b, "User:     %s\r\n", orDash(alert.UserID))
+	fmt.Fprintf(&b, "Resource: %s\r\n", orDash(alert.ResourceID))
+	fmt.Fprintf(&b, "Session:  %s\r\n", orDash(alert.SessionID))
+	fmt.Fprintf(&b, "Raised:   %s\r\n", alert.CreatedAt.UTC().Format(time.RFC3339))
+	return b.String()
+}
+
+// teamsCard is an alert as an Office 365 connector message card
+func teamsCard(alert *domain.SecurityAlert) map[string]interface{} {
+	colors := map[string]string{"low": "2E7D32", "medium": "F9A825", "high": "EF6C00", "critical": "C62828"}
+	return map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    alertSummary(alert),
+		"themeColor": colors[alert.Severity],
+		"title":      alertSummary(alert),
+		"text":       alert.Description,
+		"sections": []map[string]interface{}{{
+			"facts": []map[string]string{
+				{"name": "Alert", "value": alert.ID},
+				{"name": "Type", "value": orDash(alert.AlertType)},
+				{"name": "User", "value": orDash(alert.UserID)},
+				{"name": "Resource", "value": orDash(alert.ResourceID)},
+				{"name": "Session", "value": orDash(alert.SessionID)},
+			},
+		}},
+	}
+}
+
+// sendEmail mails the alert through the channel's SMTP server, upgrading to
+// TLS when the server offers STARTTLS
+func sendEmail(ctx context.Context, channel domain.NotificationChannel, alert *domain.SecurityAlert, now time.Time) error {
+	settings := channel.SMTP
+	host, _, err := net.SplitHostPort(settings.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid smtp addr: %w", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", settings.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if settings.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", settings.Username, settings.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(settings.From); err != nil {
+		return err
+	}
+	for _, to := range settings.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	message := "From: " + settings.From + "\r\n" +
+		"To: " + strings.Join(settings.To, ", ") + "\r\n" +
+		"Subject: " + alertSummary(alert) + "\r\n" +
+		"Date: " + now.Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + alertBody(alert)
+	if _, err := w.Write([]byte(message)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
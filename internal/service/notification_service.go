@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// NotificationOptions configures alert delivery. Failed deliveries are
+// retried up to MaxAttempts times in all, waiting InitialBackoff after the
+// first failure and twice as long after each one after that, up to
+// MaxBackoff. Each attempt is given Timeout.
+type NotificationOptions struct {
+	Policy         domain.NotificationPolicy
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration
+	// Workers is the number of deliveries made at once, QueueSize the
+	// number waiting before further alerts go straight to the dead-letter log
+	Workers   int
+	QueueSize int
+}
+
+// DefaultNotificationOptions returns the options used when none are
+// configured. There are no channels, so nothing is sent.
+func DefaultNotificationOptions() NotificationOptions {
+	return NotificationOptions{
+		MaxAttempts:    5,
+		InitialBackoff: 2 * time.Second,
+		MaxBackoff:     time.Minute,
+		Timeout:        10 * time.Second,
+		Workers:        4,
+		QueueSize:      1000,
+	}
+}
+
+// redacted replaces secrets in the policy returned by GetPolicy
+const redacted = "********"
+
+// LoadNotificationPolicy reads and validates a JSON notification policy
+func LoadNotificationPolicy(path string) (*domain.NotificationPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy domain.NotificationPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid notification policy: %w", err)
+	}
+	if err := ValidateNotificationPolicy(&policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ValidateNotificationPolicy checks that every channel is named, of a known
+// type and has somewhere to send to, and that routes only name channels
+// that exist.
+func ValidateNotificationPolicy(policy *domain.NotificationPolicy) error {
+	channels := make(map[string]bool)
+	for i, channel := range policy.Channels {
+		if channel.Name == "" {
+			return fmt.Errorf("notification channel %d has no name", i)
+		}
+		if channels[channel.Name] {
+			return fmt.Errorf("notification channel %q is defined twice", channel.Name)
+		}
+		channels[channel.Name] = true
+
+		switch channel.Type {
+		case domain.NotificationWebhook, domain.NotificationSlack, domain.NotificationTeams:
+			u, err := url.Parse(channel.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("notification channel %q needs an http or https url", channel.Name)
+			}
+		case domain.NotificationEmail:
+			if channel.SMTP == nil || channel.SMTP.Addr == "" || channel.SMTP.From == "" || len(channel.SMTP.To) == 0 {
+				return fmt.Errorf("notification channel %q needs an smtp addr, from and to", channel.Name)
+			}
+		default:
+			return fmt.Errorf("notification channel %q has unknown type %q", channel.Name, channel.Type)
+		}
+	}
+
+	for i, route := range policy.Routes {
+		if route.Name == "" {
+			return fmt.Errorf("notification route %d has no name", i)
+		}
+		if len(route.Channels) == 0 {
+			return fmt.Errorf("notification route %q has no channels", route.Name)
+		}
+		for _, channel := range route.Channels {
+			if !channels[channel] {
+				return fmt.Errorf("notification route %q names unknown channel %q", route.Name, channel)
+			}
+		}
+		for _, severity := range route.Severities {
+			if _, ok := riskLevels[severity]; !ok {
+				return fmt.Errorf("notification route %q has unknown severity %q", route.Name, severity)
+			}
+		}
+	}
+	return nil
+}
+
+// delivery is one alert on its way to one channel
+type delivery struct {
+	alert   *domain.SecurityAlert
+	channel domain.NotificationChannel
+}
+
+type notificationService struct {
+	options        NotificationOptions
+	channels       map[string]domain.NotificationChannel
+	deadLetterRepo domain.NotificationDeadLetterRepository
+	senders        map[string]notificationSender
+	queue          chan delivery
+	now            func() time.Time
+	sleep          func(ctx context.Context, d time.Duration) error
+}
+
+func NewNotificationService(deadLetterRepo domain.NotificationDeadLetterRepository, options NotificationOptions) domain.NotificationService {
+	defaults := DefaultNotificationOptions()
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = defaults.MaxAttempts
+	}
+	if options.InitialBackoff <= 0 {
+		options.InitialBackoff = defaults.InitialBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaults.MaxBackoff
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaults.Timeout
+	}
+	if options.Workers <= 0 {
+		options.Workers = defaults.Workers
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = defaults.QueueSize
+	}
+
+	channels := make(map[string]domain.NotificationChannel)
+	for _, channel := range options.Policy.Channels {
+		channels[channel.Name] = channel
+	}
+	return &notificationService{
+		options:        options,
+		channels:       channels,
+		deadLetterRepo: deadLetterRepo,
+		senders:        newNotificationSenders(options.Timeout),
+		queue:          make(chan delivery, options.QueueSize),
+		now:            time.Now,
+		sleep:          sleepContext,
+	}
+}
+
+// ObserveIncident queues the alert that opened an incident for every
+// channel of every route it matches, so each incident is notified once. It
+// does not wait for delivery. Alerts already sent by NotifyAlert are not
+// sent again.
+func (s *notificationService) ObserveIncident(ctx context.Context, incident *domain.Incident, alert *domain.SecurityAlert) {
+	if alert.Action == "notified" {
+		return
+	}
+	s.enqueue(alert, s.channelsFor(alert))
+}
+
+// NotifyAlert queues a copy of alert for every channel of every route it
+// matches, whether or not it opens an incident, so the caller may go on to
+// store it. It fails if no route matches, and does not wait for delivery.
+func (s *notificationService) NotifyAlert(ctx context.Context, alert *domain.SecurityAlert) error {
+	channels := s.channelsFor(alert)
+	if len(channels) == 0 {
+		return errors.New("no notification route matches the alert")
+	}
+	sent := *alert
+	s.enqueue(&sent, channels)
+	return nil
+}
+
+func (s *notificationService) enqueue(alert *domain.SecurityAlert, channels []string) {
+	for _, name := range channels {
+		d := delivery{alert: alert, channel: s.channels[name]}
+		select {
+		case s.queue <- d:
+		default:
+			s.deadLetter(d, 0, errors.New("notification queue is full"))
+		}
+	}
+}
+
+// channelsFor returns the channels of the routes alert matches, each once
+func (s *notificationService) channelsFor(alert *domain.SecurityAlert) []string {
+	matches := func(values []string, value string) bool {
+		return len(values) == 0 || slices.Contains(values, value)
+	}
+
+	var channels []string
+	for _, route := range s.options.Policy.Routes {
+		if !matches(route.Severities, alert.Severity) ||
+			!matches(route.AlertTypes, alert.AlertType) ||
+			!matches(route.ResourceIDs, alert.ResourceID) {
+			continue
+		}
+		for _, channel := range route.Channels {
+			if !slices.Contains(channels, channel) {
+				channels = append(channels, channel)
+			}
+		}
+	}
+	return channels
+}
+
+// Run delivers queued alerts until ctx is done. Deliveries still being
+// retried when it stops are dead-lettered so they can be retried later.
+func (s *notificationService) Run(ctx context.Context) {
+	done := make(chan struct{})
+	for i := 0; i < s.options.Workers; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case d := <-s.queue:
+					s.deliver(ctx, d)
+				}
+			}
+		}()
+	}
+	for i := 0; i < s.options.Workers; i++ {
+		<-done
+	}
+}
+
+// deliver sends d, backing off between failed attempts, and dead-letters it
+// once the attempts run out
+func (s *notificationService) deliver(ctx context.Context, d delivery) {
+	backoff := s.options.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = s.send(ctx, d); err == nil {
+			return
+		}
+		utils.Warnf("Failed to send alert %s to %s (attempt %d of %d): %v",
+			d.alert.ID, d.channel.Name, attempt, s.options.MaxAttempts, err)
+		if attempt == s.options.MaxAttempts {
+			s.deadLetter(d, attempt, err)
+			return
+		}
+		if s.sleep(ctx, backoff) != nil {
+			s.deadLetter(d, attempt, fmt.Errorf("server stopped while retrying: %w", err))
+			return
+		}
+		if backoff *= 2; backoff > s.options.MaxBackoff {
+			backoff = s.options.MaxBackoff
+		}
+	}
+}
+
+func (s *notificationService) send(ctx context.Context, d delivery) error {
+	sender, ok := s.senders[d.channel.Type]
+	if !ok {
+		return fmt.Errorf("unknown channel type %q", d.channel.Type)
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+	return sender(ctx, d.channel, d.alert, s.now())
+}
+
+func (s *notificationService) deadLetter(d delivery, attempts int, err error) {
+	letter := &domain.NotificationDeadLetter{
+		AlertID:   d.alert.ID,
+		Channel:   d.channel.Name,
+		Attempts:  attempts,
+		LastError: err.Error(),
+		Alert:     d.alert,
+		CreatedAt: s.now(),
+	}
+	if err := s.deadLetterRepo.Create(letter); err != nil {
+		utils.Errorf("Failed to dead-letter alert %s for %s: %v", d.alert.ID, d.channel.Name, err)
+		return
+	}
+	utils.Errorf("Gave up sending alert %s to %s: %s", d.alert.ID, d.channel.Name, letter.LastError)
+}
+
+// GetPolicy returns the notification policy with its secrets, passwords and
+// incoming-webhook paths redacted
+func (s *notificationService) GetPolicy(ctx context.Context) *domain.NotificationPolicy {
+	policy := &domain.NotificationPolicy{
+		Channels: make([]domain.NotificationChannel, 0, len(s.options.Policy.Channels)),
+		Routes:   s.options.Policy.Routes,
+	}
+	if policy.Routes == nil {
+		policy.Routes = []domain.NotificationRoute{}
+	}
+	for _, channel := range s.options.Policy.Channels {
+		if channel.Secret != "" {
+			channel.Secret = redacted
+		}
+		if channel.Type == domain.NotificationSlack || channel.Type == domain.NotificationTeams {
+			if u, err := url.Parse(channel.URL); err == nil {
+				channel.URL = u.Scheme + "://" + u.Host + "/" + redacted
+			}
+		}
+		if channel.SMTP != nil {
+			smtp := *channel.SMTP
+			if smtp.Password != "" {
+				smtp.Password = redacted
+			}
+			channel.SMTP = &smtp
+		}
+		policy.Channels = append(policy.Channels, channel)
+	}
+	return policy
+}
+
+// SendTest sends a made-up alert to a channel once, so its configuration
+// can be checked
+func (s *notificationService) SendTest(ctx context.Context, name string) error {
+	channel, ok := s.channels[name]
+	if !ok {
+		return fmt.Errorf("notification channel %q not found", name)
+	}
+	alert := &domain.SecurityAlert{
+		ID:          "test",
+		AlertType:   "test",
+		Severity:    "low",
+		Title:       "Test notification",
+		Description: "This is a test of the " + name + " notification channel.",
+		Status:      domain.AlertOpen,
+		CreatedAt:   s.now(),
+	}
+	return s.send(ctx, delivery{alert: alert, channel: channel})
+}
+
+func (s *notificationService) ListDeadLetters(ctx context.Context) ([]*domain.NotificationDeadLetter, error) {
+	return s.deadLetterRepo.FindAll()
+}
+
+func (s *notificationService) GetDeadLetter(ctx context.Context, id string) (*domain.NotificationDeadLetter, error) {
+	return s.deadLetterRepo.FindByID(id)
+}
+
+// RetryDeadLetter sends a dead-lettered alert again, once, and removes it
+// from the dead-letter log if it is delivered
+func (s *notificationService) RetryDeadLetter(ctx context.Context, id string) error {
+	letter, err := s.deadLetterRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	channel, ok := s.channels[letter.Channel]
+	if !ok {
+		return fmt.Errorf("notification channel %q is no longer configured", letter.Channel)
+	}
+
+	if err := s.send(ctx, delivery{alert: letter.Alert, channel: channel}); err != nil {
+		return fmt.Errorf("failed to send alert %s to %s: %w", letter.AlertID, letter.Channel, err)
+	}
+	return s.deadLetterRepo.Delete(id)
+}
+
+func (s *notificationService) DeleteDeadLetter(ctx context.Context, id string) error {
+	return s.deadLetterRepo.Delete(id)
+}
@@ -0,0 +1,380 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+func newTestNotificationService(t *testing.T, options NotificationOptions) (*notificationService, domain.SecurityAlertService) {
+	db := newTestDB(t)
+
+	require.NoError(t, ValidateNotificationPolicy(&options.Policy))
+	svc := NewNotificationService(repository.NewNotificationDeadLetterRepository(db), options).(*notificationService)
+	alerts := NewSecurityAlertService(repository.NewSecurityAlertRepository(db))
+	alerts.SetCorrelator(NewIncidentService(repository.NewIncidentRepository(db), DefaultCorrelationPolicy()))
+	alerts.AddIncidentObserver(svc)
+	return svc, alerts
+}
+
+// runNotifications starts delivering notifications until the test ends
+func runNotifications(t *testing.T, svc *notificationService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		svc.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestValidateNotificationPolicy(t *testing.T) {
+	hook := domain.NotificationChannel{Name: "hook", Type: domain.NotificationWebhook, URL: "https://example.com/hook"}
+	tests := []struct {
+		name   string
+		policy domain.NotificationPolicy
+	}{
+		{"unnamed channel", domain.NotificationPolicy{Channels: []domain.NotificationChannel{{Type: domain.NotificationWebhook, URL: "https://example.com"}}}},
+		{"duplicate channel", domain.NotificationPolicy{Channels: []domain.NotificationChannel{hook, hook}}},
+		{"unknown type", domain.NotificationPolicy{Channels: []domain.NotificationChannel{{Name: "pager", Type: "pager"}}}},
+		{"webhook without url", domain.NotificationPolicy{Channels: []domain.NotificationChannel{{Name: "hook", Type: domain.NotificationSlack, URL: "hooks.slack.com"}}}},
+		{"email without recipients", domain.NotificationPolicy{Channels: []domain.NotificationChannel{{Name: "mail", Type: domain.NotificationEmail, SMTP: &domain.SMTPSettings{Addr: "localhost:25", From: "secretary@example.com"}}}}},
+		{"route without channels", domain.NotificationPolicy{Channels: []domain.NotificationChannel{hook}, Routes: []domain.NotificationRoute{{Name: "all"}}}},
+		{"route to unknown channel", domain.NotificationPolicy{Channels: []domain.NotificationChannel{hook}, Routes: []domain.NotificationRoute{{Name: "all", Channels: []string{"pager"}}}}},
+		{"route with unknown severity", domain.NotificationPolicy{Channels: []domain.NotificationChannel{hook}, Routes: []domain.NotificationRoute{{Name: "all", Severities: []string{"urgent"}, Channels: []string{"hook"}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, ValidateNotificationPolicy(&tt.policy))
+		})
+	}
+
+	assert.NoError(t, ValidateNotificationPolicy(&domain.NotificationPolicy{
+		Channels: []domain.NotificationChannel{hook},
+		Routes:   []domain.NotificationRoute{{Name: "all", Severities: []string{"critical"}, Channels: []string{"hook"}}},
+	}))
+}
+
+func TestNotificationService_Routing(t *testing.T) {
+	type received struct {
+		path    string
+		headers http.Header
+		body    []byte
+	}
+	requests := make(chan received, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{path: r.URL.Path, headers: r.Header, body: body}
+	}))
+	defer server.Close()
+
+	svc, alerts := newTestNotificationService(t, NotificationOptions{Policy: domain.NotificationPolicy{
+		Channels: []domain.NotificationChannel{
+			{Name: "siem", Type: domain.NotificationWebhook, URL: server.URL + "/siem", Secret: "s3cret"},
+			{Name: "chat", Type: domain.NotificationSlack, URL: server.URL + "/slack"},
+			{Name: "teams", Type: domain.NotificationTeams, URL: server.URL + "/teams"},
+		},
+		Routes: []domain.NotificationRoute{
+			{Name: "critical", Severities: []string{"critical"}, Channels: []string{"siem", "chat"}},
+			{Name: "production", ResourceIDs: []string{"prod-db"}, AlertTypes: []string{"blocked_command"}, Channels: []string{"chat", "teams"}},
+		},
+	}})
+	runNotifications(t, svc)
+	ctx := context.Background()
+
+	// Matches neither route
+	require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{Severity: "high", AlertType: "blocked_command", ResourceID: "dev-db", Title: "Ignored"}))
+	// Matches both routes, but is sent to chat only once
+	alert := &domain.SecurityAlert{Severity: "critical", AlertType: "blocked_command", ResourceID: "prod-db", UserID: "alice", Title: "DROP TABLE users"}
+	require.NoError(t, alerts.CreateAlert(ctx, alert))
+
+	byPath := make(map[string]received)
+	for i := 0; i < 3; i++ {
+		select {
+		case r := <-requests:
+			_, seen := byPath[r.path]
+			assert.False(t, seen, "%s was notified twice", r.path)
+			byPath[r.path] = r
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d notifications, want 3", len(byPath))
+		}
+	}
+	select {
+	case r := <-requests:
+		t.Fatalf("unexpected notification to %s", r.path)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	siem := byPath["/siem"]
+	timestamp := siem.headers.Get(NotificationTimestampHeader)
+	assert.NotEmpty(t, timestamp)
+	assert.Equal(t, SignNotification("s3cret", timestamp, siem.body), siem.headers.Get(NotificationSignatureHeader))
+	var payload struct {
+		Event string                `json:"event"`
+		Alert *domain.SecurityAlert `json:"alert"`
+	}
+	require.NoError(t, json.Unmarshal(siem.body, &payload))
+	assert.Equal(t, "security_alert", payload.Event)
+	assert.Equal(t, alert.ID, payload.Alert.ID)
+
+	assert.Contains(t, string(byPath["/slack"].body), `"text":"[CRITICAL] DROP TABLE users (user alice, resource prod-db)"`)
+	assert.Contains(t, string(byPath["/teams"].body), `"@type":"MessageCard"`)
+
+	// Secrets and incoming-webhook paths are not shown
+	policy := svc.GetPolicy(ctx)
+	assert.Equal(t, redacted, policy.Channels[0].Secret)
+	assert.NotContains(t, policy.Channels[1].URL, "/slack")
+	assert.Equal(t, "s3cret", svc.channels["siem"].Secret)
+}
+
+func TestNotificationService_RetryAndDeadLetter(t *testing.T) {
+	var failures atomic.Int32
+	failures.Store(2)
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer flaky.Close()
+
+	var down atomic.Bool
+	down.Store(true)
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer broken.Close()
+
+	svc, _ := newTestNotificationService(t, NotificationOptions{
+		MaxAttempts:    4,
+		InitialBackoff: time.Second,
+		MaxBackoff:     3 * time.Second,
+		Policy: domain.NotificationPolicy{
+			Channels: []domain.NotificationChannel{
+				{Name: "flaky", Type: domain.NotificationWebhook, URL: flaky.URL},
+				{Name: "broken", Type: domain.NotificationWebhook, URL: broken.URL},
+			},
+			Routes: []domain.NotificationRoute{{Name: "all", Channels: []string{"flaky", "broken"}}},
+		},
+	})
+	var backoffs []time.Duration
+	svc.sleep = func(ctx context.Context, d time.Duration) error {
+		backoffs = append(backoffs, d)
+		return nil
+	}
+	ctx := context.Background()
+
+	// Deliver one channel at a time so the backoffs are in order
+	alert := &domain.SecurityAlert{ID: "alert-1", Severity: "high", Title: "Blocked command"}
+	svc.deliver(ctx, delivery{alert: alert, channel: svc.channels["flaky"]})
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, backoffs)
+	letters, err := svc.ListDeadLetters(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+
+	backoffs = nil
+	svc.deliver(ctx, delivery{alert: alert, channel: svc.channels["broken"]})
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, backoffs)
+	letters, err = svc.ListDeadLetters(ctx)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "broken", letters[0].Channel)
+	assert.Equal(t, 4, letters[0].Attempts)
+	assert.Contains(t, letters[0].LastError, "500")
+	assert.Equal(t, "Blocked command", letters[0].Alert.Title)
+
+	// A failed retry keeps the dead letter, a successful one removes it
+	assert.Error(t, svc.RetryDeadLetter(ctx, letters[0].ID))
+	down.Store(false)
+	require.NoError(t, svc.RetryDeadLetter(ctx, letters[0].ID))
+	letters, err = svc.ListDeadLetters(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+
+	// Stopping mid-retry dead-letters the delivery rather than dropping it
+	down.Store(true)
+	svc.sleep = sleepContext
+	stopped, cancel := context.WithCancel(ctx)
+	cancel()
+	svc.deliver(stopped, delivery{alert: alert, channel: svc.channels["broken"]})
+	letters, err = svc.ListDeadLetters(ctx)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Contains(t, letters[0].LastError, "server stopped")
+	require.NoError(t, svc.DeleteDeadLetter(ctx, letters[0].ID))
+}
+
+func TestNotificationService_QueueFull(t *testing.T) {
+	svc, alerts := newTestNotificationService(t, NotificationOptions{
+		QueueSize: 1,
+		Policy: domain.NotificationPolicy{
+			Channels: []domain.NotificationChannel{{Name: "hook", Type: domain.NotificationWebhook, URL: "http://127.0.0.1:1"}},
+			Routes:   []domain.NotificationRoute{{Name: "all", Channels: []string{"hook"}}},
+		},
+	})
+	ctx := context.Background()
+
+	// Nothing is delivering, so the second alert has nowhere to wait
+	require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{Severity: "low", UserID: "alice", Title: "First"}))
+	require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{Severity: "low", UserID: "bob", Title: "Second"}))
+
+	letters, err := svc.ListDeadLetters(ctx)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "Second", letters[0].Alert.Title)
+	assert.Equal(t, 0, letters[0].Attempts)
+}
+
+// smtpStub is a minimal SMTP server that accepts every message
+type smtpStub struct {
+	listener net.Listener
+	messages chan string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	stub := &smtpStub{listener: listener, messages: make(chan string, 10)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(conn)
+		}
+	}()
+	return stub
+}
+
+func (s *smtpStub) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 stub ESMTP")
+	var envelope []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 stub")
+		case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+			envelope = append(envelope, strings.TrimSpace(line))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.messages <- strings.Join(envelope, "\n") + "\n" + data.String()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestNotificationService_Email(t *testing.T) {
+	stub := newSMTPStub(t)
+	svc, alerts := newTestNotificationService(t, NotificationOptions{Policy: domain.NotificationPolicy{
+		Channels: []domain.NotificationChannel{{
+			Name: "security-team",
+			Type: domain.NotificationEmail,
+			SMTP: &domain.SMTPSettings{
+				Addr: stub.listener.Addr().String(),
+				From: "secretary@example.com",
+				To:   []string{"soc@example.com", "oncall@example.com"},
+			},
+		}},
+		Routes: []domain.NotificationRoute{{Name: "high", Severities: []string{"high", "critical"}, Channels: []string{"security-team"}}},
+	}})
+	runNotifications(t, svc)
+	ctx := context.Background()
+
+	require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{
+		Severity:    "high",
+		SessionID:   "s-1",
+		Title:       "Privilege escalation",
+		Description: "sudo su - on a production host",
+	}))
+
+	select {
+	case message := <-stub.messages:
+		assert.Contains(t, message, "MAIL FROM:<secretary@example.com>")
+		assert.Contains(t, message, "RCPT TO:<oncall@example.com>")
+		assert.Contains(t, message, "Subject: [HIGH] Privilege escalation (session s-1)")
+		assert.Contains(t, message, "sudo su - on a production host")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email was sent")
+	}
+
+	require.NoError(t, svc.SendTest(ctx, "security-team"))
+	assert.Contains(t, <-stub.messages, "Test notification")
+	assert.Error(t, svc.SendTest(ctx, "missing"))
+}
+
+func TestNotificationService_OncePerIncident(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Alert domain.SecurityAlert `json:"alert"`
+		}
+		if json.NewDecoder(r.Body).Decode(&payload) == nil && payload.Alert.IncidentID != "" {
+			calls.Add(1)
+		}
+	}))
+	defer server.Close()
+
+	svc, alerts := newTestNotificationService(t, NotificationOptions{Policy: domain.NotificationPolicy{
+		Channels: []domain.NotificationChannel{{Name: "hook", Type: domain.NotificationWebhook, URL: server.URL}},
+		Routes:   []domain.NotificationRoute{{Name: "all", Channels: []string{"hook"}}},
+	}})
+	runNotifications(t, svc)
+
+	// Only the first alert of each incident is sent
+	ctx := context.Background()
+	for i := 0; i < 40; i++ {
+		require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{UserID: "alice", ResourceID: "db-1", AlertType: "blocked_command", Severity: "high"}))
+	}
+	require.NoError(t, alerts.CreateAlert(ctx, &domain.SecurityAlert{UserID: "bob", ResourceID: "db-1", AlertType: "blocked_command", Severity: "high"}))
+
+	require.Eventually(t, func() bool { return calls.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load())
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"secretary/alpha/internal/domain"
+)
+
+// MaxSimulatedCommands bounds the size of a single simulation batch.
+const MaxSimulatedCommands = 1000
+
+// Bounds of a single replay of recorded commands
+const (
+	MaxReplayWindow     = 31 * 24 * time.Hour
+	MaxReplayedCommands = 100000
+)
+
+type policySimulationService struct {
+	sessionCommandService domain.SessionCommandService
+	holdPolicy            CommandHoldPolicy
+	current               *commandAnalyzer
+}
+
+// NewPolicySimulationService evaluates candidate policies against submitted
+// and recorded commands. holdPolicy is applied to both the current and the
+// candidate policy, so that held commands show up as such.
+func NewPolicySimulationService(sessionCommandService domain.SessionCommandService, holdPolicy CommandHoldPolicy) domain.PolicySimulationService {
+	return &policySimulationService{
+		sessionCommandService: sessionCommandService,
+		holdPolicy:            holdPolicy,
+		current:               defaultAnalyzer,
+	}
+}
+
+// CurrentPolicy returns the rules enforced today, as a starting point for
+// candidate policies.
+func (s *policySimulationService) CurrentPolicy(ctx context.Context) *domain.Policy {
+	return &domain.Policy{
+		Name:    "current",
+		SQL:     policyRulesFromCommandRules(defaultSQLRules),
+		Shell:   policyRulesFromCommandRules(defaultShellRules),
+		Generic: policyRulesFromCommandRules(defaultGenericRules),
+	}
+}
+
+func (s *policySimulationService) Simulate(ctx context.Context, policy *domain.Policy, commands []domain.PolicyCommand) ([]*domain.PolicyEvaluation, error) {
+	if len(commands) == 0 {
+		return nil, errors.New("at least one command is required")
+	}
+	if len(commands) > MaxSimulatedCommands {
+		return nil, fmt.Errorf("at most %d commands can be simulated at once", MaxSimulatedCommands)
+	}
+
+	analyzer, err := s.analyzer(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluations := make([]*domain.PolicyEvaluation, 0, len(commands))
+	for _, command := range commands {
+		evaluation := s.evaluate(analyzer, command.Command, command.CommandType, command.ResourceID)
+		evaluations = append(evaluations, &evaluation)
+	}
+	return evaluations, nil
+}
+
+// Replay compares the commands recorded between from and to, reading them
+// a page at a time. The range is capped at MaxReplayWindow and at
+// MaxReplayedCommands commands.
+func (s *policySimulationService) Replay(ctx context.Context, policy *domain.Policy, from, to time.Time, includeUnchanged bool) (*domain.PolicyReplayReport, error) {
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+	if to.Sub(from) > MaxReplayWindow {
+		return nil, fmt.Errorf("at most %d days of commands can be replayed at once", MaxReplayWindow/(24*time.Hour))
+	}
+
+	candidate, err := s.analyzer(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newPolicyReplayReport()
+	report.From = from
+	report.To = to
+	filter := domain.CommandFilter{From: from, To: to, Limit: MaxCommandPageSize}
+	for {
+		page, err := s.sessionCommandService.SearchCommands(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get recorded commands: %w", err)
+		}
+		if page.Total > MaxReplayedCommands {
+			return nil, fmt.Errorf("%d commands were recorded in the range, more than the %d replayed at once", page.Total, MaxReplayedCommands)
+		}
+		s.compare(report, candidate, page.Commands, includeUnchanged)
+		if len(page.Commands) < filter.Limit {
+			return report, nil
+		}
+		filter.Offset += len(page.Commands)
+	}
+}
+
+// Compare evaluates recorded commands under the current and the candidate
+// policy and reports where the outcomes differ.
+func (s *policySimulationService) Compare(ctx context.Context, policy *domain.Policy, commands []*domain.SessionCommand, includeUnchanged bool) (*domain.PolicyReplayReport, error) {
+	candidate, err := s.analyzer(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newPolicyReplayReport()
+	s.compare(report, candidate, commands, includeUnchanged)
+	return report, nil
+}
+
+func newPolicyReplayReport() *domain.PolicyReplayReport {
+	return &domain.PolicyReplayReport{Entries: make([]*domain.PolicyReplayEntry, 0)}
+}
+
+// compare adds commands to report
+func (s *policySimulationService) compare(report *domain.PolicyReplayReport, candidate *commandAnalyzer, commands []*domain.SessionCommand, includeUnchanged bool) {
+	for _, command := range commands {
+		entry := &domain.PolicyReplayEntry{
+			CommandID:      command.ID,
+			SessionID:      command.SessionID,
+			UserID:         command.UserID,
+			ResourceID:     command.ResourceID,
+			Timestamp:      command.Timestamp,
+			RecordedRisk:   command.Risk,
+			RecordedStatus: command.Status,
+			Current:        s.evaluate(s.current, command.Command, command.CommandType, command.ResourceID),
+			Candidate:      s.evaluate(candidate, command.Command, command.CommandType, command.ResourceID),
+		}
+		entry.Changed = entry.Current.Risk != entry.Candidate.Risk || entry.Current.Action != entry.Candidate.Action
+
+		report.Total++
+		if entry.Changed {
+			report.Changed++
+			switch {
+			case entry.Candidate.Action == "blocked" && entry.Current.Action != "blocked":
+				report.NewlyBlocked++
+			case entry.Current.Action == "blocked" && entry.Candidate.Action != "blocked":
+				report.NewlyAllowed++
+			}
+			switch {
+			case riskLevels[entry.Candidate.Risk] > riskLevels[entry.Current.Risk]:
+				report.RiskRaised++
+			case riskLevels[entry.Candidate.Risk] < riskLevels[entry.Current.Risk]:
+				report.RiskLowered++
+			}
+		}
+		if entry.Changed || includeUnchanged {
+			report.Entries = append(report.Entries, entry)
+		}
+	}
+}
+
+func (s *policySimulationService) analyzer(policy *domain.Policy) (*commandAnalyzer, error) {
+	if policy == nil {
+		return s.current, nil
+	}
+	analyzer, err := newCommandAnalyzer(policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	return analyzer, nil
+}
+
+func (s *policySimulationService) evaluate(analyzer *commandAnalyzer, command, commandType, resourceID string) domain.PolicyEvaluation {
+	result := analyzer.analyze(command, commandType, true)
+
+	evaluation := domain.PolicyEvaluation{
+		Command:      command,
+		CommandType:  commandType,
+		Risk:         result.Risk,
+		Action:       "allowed",
+		MatchedRules: make([]string, 0, len(result.Matched)),
+	}
+	for _, rule := range result.Matched {
+		evaluation.MatchedRules = append(evaluation.MatchedRules, rule.ID)
+	}
+
+	switch {
+	case result.Block:
+		evaluation.Action = "blocked"
+	case s.holdPolicy.requiresApproval(resourceID, result.Risk):
+		evaluation.Action = "held"
+	}
+	return evaluation
+}
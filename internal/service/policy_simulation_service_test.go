@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+)
+
+func TestPolicySimulationService_Simulate(t *testing.T) {
+	svc := NewPolicySimulationService(newTestSessionCommandService(t), CommandHoldPolicy{ResourceIDs: []string{"prod-db"}})
+	ctx := context.Background()
+
+	candidate := svc.CurrentPolicy(ctx)
+	candidate.SQL = append(candidate.SQL, domain.PolicyRule{
+		ID: "sql-no-pg-sleep", Pattern: `pg_sleep`, IgnoreCase: true, Risk: "critical", Block: true,
+	})
+
+	evaluations, err := svc.Simulate(ctx, candidate, []domain.PolicyCommand{
+		{Command: "SELECT pg_sleep(100)", CommandType: "postgresql"},
+		{Command: "DELETE FROM accounts", CommandType: "postgresql", ResourceID: "prod-db"},
+		{Command: "echo cm0gLXJmIC8= | base64 -d | sh", CommandType: "shell"},
+		{Command: "ls", CommandType: "shell"},
+	})
+	require.NoError(t, err)
+	require.Len(t, evaluations, 4)
+
+	assert.Equal(t, "critical", evaluations[0].Risk)
+	assert.Equal(t, "blocked", evaluations[0].Action)
+	assert.Equal(t, []string{"sql-no-pg-sleep"}, evaluations[0].MatchedRules)
+
+	assert.Equal(t, "high", evaluations[1].Risk)
+	assert.Equal(t, "held", evaluations[1].Action)
+
+	assert.Equal(t, "blocked", evaluations[2].Action)
+	assert.Contains(t, evaluations[2].MatchedRules, "shell-rm-root")
+
+	assert.Equal(t, "low", evaluations[3].Risk)
+	assert.Equal(t, "allowed", evaluations[3].Action)
+	assert.Empty(t, evaluations[3].MatchedRules)
+}
+
+func TestPolicySimulationService_InvalidInput(t *testing.T) {
+	svc := NewPolicySimulationService(newTestSessionCommandService(t), CommandHoldPolicy{})
+	ctx := context.Background()
+	commands := []domain.PolicyCommand{{Command: "ls", CommandType: "shell"}}
+
+	_, err := svc.Simulate(ctx, &domain.Policy{Shell: []domain.PolicyRule{{ID: "bad", Pattern: `(`, Risk: "high"}}}, commands)
+	assert.Error(t, err)
+
+	_, err = svc.Simulate(ctx, nil, nil)
+	assert.Error(t, err)
+
+	_, err = svc.Replay(ctx, nil, time.Now(), time.Now().Add(-time.Hour), false)
+	assert.Error(t, err)
+
+	_, err = svc.Replay(ctx, nil, time.Now().Add(-MaxReplayWindow-time.Hour), time.Now(), false)
+	assert.Error(t, err)
+}
+
+func TestPolicySimulationService_Replay(t *testing.T) {
+	commands := newTestSessionCommandService(t)
+	svc := NewPolicySimulationService(commands, CommandHoldPolicy{})
+	ctx := context.Background()
+
+	start := time.Now().Add(-time.Hour)
+	for i, text := range []string{"DELETE FROM users WHERE id = 1", "SELECT 1", "TRUNCATE audit"} {
+		require.NoError(t, commands.RecordCommand(ctx, &domain.SessionCommand{
+			SessionID:   "session-1",
+			Command:     text,
+			CommandType: "postgresql",
+			Timestamp:   start.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	// The candidate drops the TRUNCATE rule and blocks every DELETE
+	candidate := svc.CurrentPolicy(ctx)
+	var sqlRules []domain.PolicyRule
+	for _, rule := range candidate.SQL {
+		if rule.ID != "sql-truncate" {
+			sqlRules = append(sqlRules, rule)
+		}
+	}
+	candidate.SQL = append(sqlRules, domain.PolicyRule{
+		ID: "sql-block-delete", Pattern: `DELETE FROM`, IgnoreCase: true, Risk: "critical", Block: true,
+	})
+
+	report, err := svc.Replay(ctx, candidate, start, time.Now(), false)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 2, report.Changed)
+	assert.Equal(t, 1, report.NewlyBlocked)
+	assert.Equal(t, 1, report.NewlyAllowed)
+	assert.Equal(t, 1, report.RiskRaised)
+	assert.Equal(t, 1, report.RiskLowered)
+	require.Len(t, report.Entries, 2)
+	assert.Equal(t, "DELETE FROM users WHERE id = 1", report.Entries[0].Current.Command)
+	assert.Equal(t, "allowed", report.Entries[0].Current.Action)
+	assert.Equal(t, "blocked", report.Entries[0].Candidate.Action)
+
+	report, err = svc.Replay(ctx, candidate, start, start.Add(90*time.Second), true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Total)
+	assert.Len(t, report.Entries, 2)
+}
+
+func TestPolicySimulationService_ReplaysPageByPage(t *testing.T) {
+	commands := newTestSessionCommandService(t).(*sessionCommandService)
+	svc := NewPolicySimulationService(commands, CommandHoldPolicy{})
+	ctx := context.Background()
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < MaxCommandPageSize+5; i++ {
+		require.NoError(t, commands.sessionCommandRepo.Create(&domain.SessionCommand{
+			ID:          fmt.Sprintf("command-%d", i),
+			SessionID:   "session-1",
+			Command:     "TRUNCATE audit",
+			CommandType: "postgresql",
+			Timestamp:   start.Add(time.Duration(i) * time.Millisecond),
+			CreatedAt:   start,
+		}))
+	}
+
+	report, err := svc.Replay(ctx, svc.CurrentPolicy(ctx), start, time.Now(), true)
+	require.NoError(t, err)
+	assert.Equal(t, MaxCommandPageSize+5, report.Total)
+	assert.Len(t, report.Entries, MaxCommandPageSize+5)
+	assert.Equal(t, "command-0", report.Entries[0].CommandID)
+	assert.Equal(t, fmt.Sprintf("command-%d", MaxCommandPageSize+4), report.Entries[len(report.Entries)-1].CommandID)
+}
@@ -0,0 +1,461 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+const (
+	mysqlClientSSL             = 0x00000800
+	mysqlClientQueryAttributes = 0x08000000
+	mysqlClientDeprecateEOF    = 0x01000000
+
+	mysqlServerMoreResultsExist = 0x0008
+
+	mysqlComQuery       = 0x03
+	mysqlComStmtPrepare = 0x16
+
+	mysqlMaxPacketPayload = 0xffffff
+
+	// ER_SPECIFIC_ACCESS_DENIED_ERROR
+	mysqlErrAccessDenied = 1227
+)
+
+// mysqlPacket is a single wire packet: 3-byte little-endian payload length,
+// sequence id and payload.
+type mysqlPacket struct {
+	seq     byte
+	payload []byte
+	raw     []byte
+}
+
+func (s *proxyService) handleMySQLConnection(ctx context.Context, proxy *ProxyConnection, clientConn, targetConn net.Conn) {
+	clientReader := bufio.NewReader(clientConn)
+	targetReader := bufio.NewReader(targetConn)
+
+	// The server speaks first with its greeting
+	greeting, err := readMySQLPacket(targetReader)
+	if err != nil {
+		return
+	}
+	if _, err := clientConn.Write(greeting.raw); err != nil {
+		return
+	}
+
+	response, err := readMySQLPacket(clientReader)
+	if err != nil {
+		return
+	}
+	if _, err := targetConn.Write(response.raw); err != nil {
+		return
+	}
+
+	var capabilities uint32
+	if len(response.payload) >= 4 {
+		capabilities = binary.LittleEndian.Uint32(response.payload)
+	}
+	if capabilities&mysqlClientSSL != 0 && len(response.payload) == 32 {
+		// SSL request: the rest of the connection is encrypted end to end
+		relayRaw(clientReader, targetReader, clientConn, targetConn)
+		return
+	}
+
+	session := &mysqlSession{
+		client:       &lockedWriter{w: clientConn},
+		capabilities: capabilities,
+		complete:     func(reply *commandReply) { s.completeCommand(ctx, reply) },
+	}
+	done := make(chan struct{}, 2)
+
+	// Client to Server (SQL commands)
+	go func() {
+		defer func() { done <- struct{}{} }()
+		s.monitorMySQLTraffic(ctx, proxy, session, clientReader, targetConn)
+	}()
+
+	// Server to Client (results)
+	go func() {
+		defer func() { done <- struct{}{} }()
+		session.relayServer(targetReader)
+	}()
+
+	<-done
+}
+
+func (s *proxyService) monitorMySQLTraffic(ctx context.Context, proxy *ProxyConnection, session *mysqlSession, src io.Reader, dst io.Writer) {
+	for {
+		packets, err := readMySQLCommand(src)
+		if err != nil {
+			return
+		}
+
+		// Every command starts a new sequence; authentication packets
+		// continue the handshake sequence and pass through untouched
+		first := packets[0]
+		if first.seq == 0 && len(first.payload) > 0 {
+			if query, ok := mysqlCommandText(packets, session.capabilities); ok {
+				sessionCommand, allowed, reason := s.inspectCommand(ctx, proxy, query, "mysql")
+				if !allowed {
+					if _, err := session.client.Write(mysqlErrPacket(1, mysqlErrAccessDenied, "secretary: "+reason)); err != nil {
+						return
+					}
+					continue
+				}
+				last := packets[len(packets)-1]
+				session.track(newCommandReply(sessionCommand), first.payload[0], last.seq+1)
+			}
+		}
+
+		for _, packet := range packets {
+			if _, err := dst.Write(packet.raw); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// mysqlSession follows the server's reply to the command in flight. The
+// classic protocol has one command in flight at a time.
+type mysqlSession struct {
+	mu           sync.Mutex
+	client       io.Writer
+	capabilities uint32
+	pending      *mysqlReply
+	complete     func(*commandReply)
+}
+
+// mysqlReply is where the server is in its reply to a command.
+type mysqlReply struct {
+	*commandReply
+	command byte
+	// seq is the sequence id of the reply's first packet
+	seq   byte
+	state int
+	// columns counts the column definitions still to come
+	columns uint64
+}
+
+// States of a reply
+const (
+	mysqlReplyStart = iota
+	mysqlReplyNext
+	mysqlReplyColumns
+	mysqlReplyColumnsEOF
+	mysqlReplyRows
+)
+
+func (m *mysqlSession) track(reply *commandReply, command byte, seq byte) {
+	m.mu.Lock()
+	previous := m.pending
+	m.pending = &mysqlReply{commandReply: reply, command: command, seq: seq}
+	m.mu.Unlock()
+
+	m.finish(previous)
+}
+
+// relayServer copies whole packets so that error packets written by the
+// proxy never land in the middle of one, and collects the reply to the
+// command in flight.
+func (m *mysqlSession) relayServer(src io.Reader) {
+	defer func() {
+		m.mu.Lock()
+		pending := m.pending
+		m.pending = nil
+		m.mu.Unlock()
+		m.finish(pending)
+	}()
+
+	for {
+		packet, err := readMySQLPacket(src)
+		if err != nil {
+			return
+		}
+
+		m.mu.Lock()
+		var completed *mysqlReply
+		if m.pending != nil && m.observe(m.pending, packet) {
+			completed = m.pending
+			m.pending = nil
+		}
+		m.mu.Unlock()
+
+		if _, err := m.client.Write(packet.raw); err != nil {
+			return
+		}
+		m.finish(completed)
+	}
+}
+
+// observe adds a server packet to a reply and reports whether the reply is
+// complete. Callers hold m.mu.
+func (m *mysqlSession) observe(reply *mysqlReply, packet mysqlPacket) bool {
+	payload := packet.payload
+	if len(payload) == 0 {
+		return false
+	}
+
+	switch reply.state {
+	case mysqlReplyStart, mysqlReplyNext:
+		// Packets before the reply answer an earlier, untracked command
+		if reply.state == mysqlReplyStart && packet.seq != reply.seq {
+			return false
+		}
+		switch payload[0] {
+		case 0x00:
+			if reply.command == mysqlComStmtPrepare {
+				reply.writeString("statement prepared\n")
+				return true
+			}
+			affected, status := mysqlOKFields(payload)
+			reply.rows += int64(affected)
+			reply.writeString(fmt.Sprintf("OK, %d rows affected\n", affected))
+			return !m.moreResults(reply, status)
+		case 0xff:
+			reply.fail(mysqlErrText(payload))
+			reply.writeString(mysqlErrText(payload) + "\n")
+			return true
+		case 0xfb:
+			// LOCAL INFILE request: the rest of the exchange is not followed
+			reply.writeString("LOCAL INFILE requested\n")
+			return true
+		case 0xfe:
+			return true
+		}
+		count, n := mysqlLengthEncodedInt(payload)
+		if n == 0 || count == 0 {
+			return true
+		}
+		reply.columns = count
+		reply.state = mysqlReplyColumns
+		return false
+	case mysqlReplyColumns:
+		reply.writeString(mysqlColumnName(payload))
+		reply.columns--
+		if reply.columns > 0 {
+			reply.writeString("\t")
+			return false
+		}
+		reply.writeString("\n")
+		reply.state = mysqlReplyRows
+		if m.capabilities&mysqlClientDeprecateEOF == 0 {
+			reply.state = mysqlReplyColumnsEOF
+		}
+		return false
+	case mysqlReplyColumnsEOF:
+		reply.state = mysqlReplyRows
+		return false
+	case mysqlReplyRows:
+		switch {
+		case payload[0] == 0xfe && len(payload) < mysqlMaxPacketPayload:
+			// EOF, or an OK packet in its place
+			var status uint16
+			if m.capabilities&mysqlClientDeprecateEOF != 0 {
+				_, status = mysqlOKFields(payload)
+			} else if len(payload) >= 5 {
+				status = binary.LittleEndian.Uint16(payload[3:])
+			}
+			return !m.moreResults(reply, status)
+		case payload[0] == 0xff:
+			reply.fail(mysqlErrText(payload))
+			reply.writeString(mysqlErrText(payload) + "\n")
+			return true
+		}
+		reply.rows++
+		if !reply.truncated {
+			reply.writeString(mysqlRowText(payload))
+		}
+	}
+	return false
+}
+
+// moreResults reports whether another result follows, as it does for
+// multi-statement queries, and readies the reply for it.
+func (m *mysqlSession) moreResults(reply *mysqlReply, status uint16) bool {
+	if status&mysqlServerMoreResultsExist == 0 {
+		return false
+	}
+	reply.state = mysqlReplyNext
+	return true
+}
+
+func (m *mysqlSession) finish(reply *mysqlReply) {
+	if reply != nil && m.complete != nil {
+		m.complete(reply.commandReply)
+	}
+}
+
+// mysqlOKFields returns the affected rows and status flags of an OK packet.
+func mysqlOKFields(payload []byte) (uint64, uint16) {
+	body := payload[1:]
+	affected, n := mysqlLengthEncodedInt(body)
+	if n == 0 {
+		return 0, 0
+	}
+	body = body[n:]
+	// Last insert ID
+	_, n = mysqlLengthEncodedInt(body)
+	if n == 0 || len(body) < n+2 {
+		return affected, 0
+	}
+	return affected, binary.LittleEndian.Uint16(body[n:])
+}
+
+// mysqlErrText renders an ERR packet as "ERROR code (state): message".
+func mysqlErrText(payload []byte) string {
+	if len(payload) < 3 {
+		return "ERROR"
+	}
+	code := binary.LittleEndian.Uint16(payload[1:])
+	message := payload[3:]
+	state := ""
+	if len(message) >= 6 && message[0] == '#' {
+		state = fmt.Sprintf(" (%s)", message[1:6])
+		message = message[6:]
+	}
+	return fmt.Sprintf("ERROR %d%s: %s", code, state, message)
+}
+
+// mysqlColumnName returns the name of a column definition: the fifth of its
+// length-encoded strings, after catalog, schema, table and original table.
+func mysqlColumnName(payload []byte) string {
+	for i := 0; i < 4; i++ {
+		length, n := mysqlLengthEncodedInt(payload)
+		if n == 0 || uint64(len(payload)) < uint64(n)+length {
+			return ""
+		}
+		payload = payload[uint64(n)+length:]
+	}
+	name, _ := mysqlLengthEncodedString(payload)
+	return name
+}
+
+// mysqlRowText renders a text-protocol row as a tab-separated line.
+func mysqlRowText(payload []byte) string {
+	var values []string
+	for len(payload) > 0 {
+		if payload[0] == 0xfb {
+			values = append(values, "NULL")
+			payload = payload[1:]
+			continue
+		}
+		value, n := mysqlLengthEncodedString(payload)
+		if n == 0 {
+			break
+		}
+		values = append(values, value)
+		payload = payload[n:]
+	}
+	return strings.Join(values, "\t") + "\n"
+}
+
+// mysqlLengthEncodedString decodes a length-encoded string and returns it
+// with the number of bytes read, or 0 bytes when b is too short.
+func mysqlLengthEncodedString(b []byte) (string, int) {
+	length, n := mysqlLengthEncodedInt(b)
+	if n == 0 || uint64(len(b)-n) < length {
+		return "", 0
+	}
+	end := n + int(length)
+	return string(b[n:end]), end
+}
+
+// mysqlCommandText returns the SQL text of a COM_QUERY or COM_STMT_PREPARE
+// command.
+func mysqlCommandText(packets []mysqlPacket, capabilities uint32) (string, bool) {
+	var payload []byte
+	for _, packet := range packets {
+		payload = append(payload, packet.payload...)
+	}
+
+	switch payload[0] {
+	case mysqlComStmtPrepare:
+		return string(payload[1:]), true
+	case mysqlComQuery:
+		body := payload[1:]
+		if capabilities&mysqlClientQueryAttributes != 0 {
+			// Parameter count and parameter set count precede the query;
+			// with attributes bound, inspect the packet as a whole
+			count, n := mysqlLengthEncodedInt(body)
+			if n > 0 && count == 0 {
+				if _, m := mysqlLengthEncodedInt(body[n:]); m > 0 {
+					body = body[n+m:]
+				}
+			}
+		}
+		return string(body), true
+	}
+	return "", false
+}
+
+// mysqlLengthEncodedInt decodes a length-encoded integer and returns it with
+// the number of bytes read, or 0 bytes when b is too short.
+func mysqlLengthEncodedInt(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	switch b[0] {
+	case 0xfc:
+		if len(b) < 3 {
+			return 0, 0
+		}
+		return uint64(binary.LittleEndian.Uint16(b[1:])), 3
+	case 0xfd:
+		if len(b) < 4 {
+			return 0, 0
+		}
+		return uint64(b[1]) | uint64(b[2])<<8 | uint64(b[3])<<16, 4
+	case 0xfe:
+		if len(b) < 9 {
+			return 0, 0
+		}
+		return binary.LittleEndian.Uint64(b[1:]), 9
+	}
+	return uint64(b[0]), 1
+}
+
+// readMySQLCommand reads a packet and, when its payload fills a whole
+// packet, the continuation packets that complete it.
+func readMySQLCommand(r io.Reader) ([]mysqlPacket, error) {
+	var packets []mysqlPacket
+	for {
+		packet, err := readMySQLPacket(r)
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, packet)
+		if len(packet.payload) < mysqlMaxPacketPayload {
+			return packets, nil
+		}
+	}
+}
+
+func readMySQLPacket(r io.Reader) (mysqlPacket, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return mysqlPacket{}, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+
+	raw := make([]byte, 4+length)
+	copy(raw, header)
+	if _, err := io.ReadFull(r, raw[4:]); err != nil {
+		return mysqlPacket{}, err
+	}
+	return mysqlPacket{seq: header[3], payload: raw[4:], raw: raw}, nil
+}
+
+// mysqlErrPacket builds an ERR packet with SQL state 42000.
+func mysqlErrPacket(seq byte, code uint16, message string) []byte {
+	payload := []byte{0xff, byte(code), byte(code >> 8), '#'}
+	payload = append(payload, "42000"...)
+	payload = append(payload, message...)
+
+	packet := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}
+	return append(packet, payload...)
+}
@@ -0,0 +1,601 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// PostgreSQL startup request codes sent in place of a protocol version.
+const (
+	pgCancelRequest = 80877102
+	pgSSLRequest    = 80877103
+	pgGSSENCRequest = 80877104
+
+	pgMaxMessageSize = 1 << 30
+)
+
+// pgSession tracks what the server has answered so the proxy can slot its
+// own error responses between complete server messages, in the order the
+// client expects them.
+type pgSession struct {
+	mu       sync.Mutex
+	client   io.Writer
+	txStatus byte
+	// expected counts the ReadyForQuery messages the server owes the
+	// client for forwarded queries and syncs; seen counts those relayed.
+	expected   int
+	seen       int
+	injections []pgInjection
+	// replies holds the forwarded commands whose replies are still coming
+	// in, oldest first
+	replies  []*pgReply
+	complete func(*commandReply)
+}
+
+// pgReply is a forwarded command that completes with the at-th
+// ReadyForQuery. at is zero for an extended-protocol command until its batch
+// is synced.
+type pgReply struct {
+	*commandReply
+	at       int
+	extended bool
+	// answered is set once an extended-protocol command has its
+	// CommandComplete or ErrorResponse; later ones belong to the next
+	// command of the batch
+	answered bool
+}
+
+// pgInjection is a response written to the client around the at-th
+// ReadyForQuery relayed from the server.
+type pgInjection struct {
+	at     int
+	before bool
+	data   []byte
+}
+
+func (s *proxyService) handlePostgreSQLConnection(ctx context.Context, proxy *ProxyConnection, clientConn, targetConn net.Conn) {
+	clientReader := bufio.NewReader(clientConn)
+	targetReader := bufio.NewReader(targetConn)
+
+	// Startup phase: the first messages carry no type byte
+	for {
+		startup, err := readPGStartup(clientReader)
+		if err != nil {
+			return
+		}
+		if _, err := targetConn.Write(startup); err != nil {
+			return
+		}
+
+		code := binary.BigEndian.Uint32(startup[4:8])
+		if code == pgSSLRequest || code == pgGSSENCRequest {
+			answer, err := targetReader.ReadByte()
+			if err != nil {
+				return
+			}
+			if _, err := clientConn.Write([]byte{answer}); err != nil {
+				return
+			}
+			if answer == 'S' || answer == 'G' {
+				// The rest of the connection is encrypted end to end
+				relayRaw(clientReader, targetReader, clientConn, targetConn)
+				return
+			}
+			continue
+		}
+		if code == pgCancelRequest {
+			relayRaw(clientReader, targetReader, clientConn, targetConn)
+			return
+		}
+		break
+	}
+
+	session := &pgSession{
+		client:   clientConn,
+		txStatus: 'I',
+		complete: func(reply *commandReply) { s.completeCommand(ctx, reply) },
+	}
+	done := make(chan struct{}, 2)
+
+	// Client to Server (queries)
+	go func() {
+		defer func() { done <- struct{}{} }()
+		s.monitorPostgreSQLTraffic(ctx, proxy, session, clientReader, targetConn)
+	}()
+
+	// Server to Client (results)
+	go func() {
+		defer func() { done <- struct{}{} }()
+		session.relayServer(targetReader)
+	}()
+
+	<-done
+}
+
+func (s *proxyService) monitorPostgreSQLTraffic(ctx context.Context, proxy *ProxyConnection, session *pgSession, src io.Reader, dst io.Writer) {
+	// Set while a blocked extended-protocol batch is discarded up to its Sync
+	var rejected string
+	// Set when messages of the current extended-protocol batch reached the
+	// server and it therefore needs the batch's Sync
+	unsynced := false
+
+	for {
+		msgType, msg, err := readPGMessage(src)
+		if err != nil {
+			return
+		}
+
+		if rejected != "" {
+			if msgType != 'S' {
+				continue
+			}
+			session.rejectBatch(dst, rejected, unsynced)
+			rejected = ""
+			unsynced = false
+			continue
+		}
+
+		switch msgType {
+		case 'Q':
+			query := pgCString(msg[5:])
+			sessionCommand, allowed, reason := s.inspectCommand(ctx, proxy, query, "postgresql")
+			if !allowed {
+				session.reject(reason)
+				continue
+			}
+			session.expect()
+			session.track(newCommandReply(sessionCommand), false)
+		case 'P':
+			// Parse: statement name followed by the query text
+			name := pgCString(msg[5:])
+			query := ""
+			if offset := 5 + len(name) + 1; offset < len(msg) {
+				query = pgCString(msg[offset:])
+			}
+			sessionCommand, allowed, reason := s.inspectCommand(ctx, proxy, query, "postgresql")
+			if !allowed {
+				rejected = reason
+				continue
+			}
+			session.track(newCommandReply(sessionCommand), true)
+			unsynced = true
+		case 'S':
+			session.expect()
+			unsynced = false
+		case 'B':
+			session.bind(pgBindParameters(msg))
+			unsynced = true
+		case 'E', 'D', 'C', 'H':
+			unsynced = true
+		}
+
+		if _, err := dst.Write(msg); err != nil {
+			return
+		}
+	}
+}
+
+// relayServer copies complete server messages to the client, writing any
+// pending proxy responses at their place in the stream, and collects the
+// replies to forwarded commands.
+func (p *pgSession) relayServer(src io.Reader) {
+	defer p.completeAll()
+
+	for {
+		msgType, msg, err := readPGMessage(src)
+		if err != nil {
+			return
+		}
+
+		var completed []*commandReply
+		p.mu.Lock()
+		p.observe(msgType, msg)
+		if msgType == 'Z' {
+			p.flush(p.seen+1, true)
+		}
+		_, err = p.client.Write(msg)
+		if msgType == 'Z' {
+			p.seen++
+			if len(msg) > 5 {
+				p.txStatus = msg[5]
+			}
+			p.flush(p.seen, false)
+			completed = p.completed()
+		}
+		p.mu.Unlock()
+
+		for _, reply := range completed {
+			p.finish(reply)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// track registers a forwarded command. A simple query completes with the
+// ReadyForQuery just expected for it, an extended-protocol command with the
+// one answering its batch's Sync.
+func (p *pgSession) track(reply *commandReply, extended bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	r := &pgReply{commandReply: reply, extended: extended}
+	if !extended {
+		r.at = p.expected
+	}
+	p.replies = append(p.replies, r)
+}
+
+// bind attaches the parameters of a Bind to the extended-protocol command
+// parsed last, unless it has some already
+func (p *pgSession) bind(parameters []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := len(p.replies) - 1; i >= 0; i-- {
+		if r := p.replies[i]; r.extended {
+			if r.parameters == nil {
+				r.parameters = parameters
+			}
+			return
+		}
+	}
+}
+
+// observe adds a server message to the reply it belongs to. Callers hold
+// p.mu.
+func (p *pgSession) observe(msgType byte, msg []byte) {
+	var reply *pgReply
+	for _, r := range p.replies {
+		if !r.answered {
+			reply = r
+			break
+		}
+	}
+	if reply == nil {
+		return
+	}
+
+	switch msgType {
+	case 'T':
+		reply.writeString(pgRowDescriptionText(msg))
+	case 'D':
+		if !reply.truncated {
+			reply.writeString(pgDataRowText(msg))
+		}
+	case 'C':
+		tag := pgCString(msg[5:])
+		reply.rows += pgCommandTagRows(tag)
+		reply.writeString(tag + "\n")
+		reply.answered = reply.extended
+	case 'E':
+		code, message := pgErrorFields(msg)
+		reply.fail(code + ": " + message)
+		reply.writeString("ERROR " + code + ": " + message + "\n")
+		reply.answered = reply.extended
+	}
+}
+
+// completed removes and returns the replies that ended with the last
+// ReadyForQuery. Callers hold p.mu.
+func (p *pgSession) completed() []*commandReply {
+	var completed []*commandReply
+	remaining := p.replies[:0]
+	for _, r := range p.replies {
+		if r.at != 0 && r.at <= p.seen {
+			completed = append(completed, r.commandReply)
+			continue
+		}
+		remaining = append(remaining, r)
+	}
+	p.replies = remaining
+	return completed
+}
+
+// completeAll completes the replies cut short by the connection closing.
+func (p *pgSession) completeAll() {
+	p.mu.Lock()
+	replies := p.replies
+	p.replies = nil
+	p.mu.Unlock()
+
+	for _, r := range replies {
+		p.finish(r.commandReply)
+	}
+}
+
+func (p *pgSession) finish(reply *commandReply) {
+	if p.complete != nil {
+		p.complete(reply)
+	}
+}
+
+// flush writes the injections due at the given ReadyForQuery. Callers hold
+// p.mu.
+func (p *pgSession) flush(at int, before bool) {
+	remaining := p.injections[:0]
+	for _, injection := range p.injections {
+		if injection.before != before || injection.at > at {
+			remaining = append(remaining, injection)
+			continue
+		}
+		data := injection.data
+		if !before {
+			data = append(data, pgReadyForQuery(p.txStatus)...)
+		}
+		p.client.Write(data)
+	}
+	p.injections = remaining
+}
+
+func (p *pgSession) expect() {
+	p.mu.Lock()
+	p.sync()
+	p.mu.Unlock()
+}
+
+// sync counts a ReadyForQuery owed by the server, which also completes the
+// extended-protocol commands sent since the last Sync. Callers hold p.mu.
+func (p *pgSession) sync() {
+	p.expected++
+	for _, r := range p.replies {
+		if r.extended && r.at == 0 {
+			r.at = p.expected
+		}
+	}
+}
+
+// reject answers a blocked simple query with an error and ReadyForQuery once
+// the server has answered everything sent before it.
+func (p *pgSession) reject(reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.injections = append(p.injections, pgInjection{at: p.expected, data: pgErrorResponse(reason)})
+	p.flush(p.seen, false)
+}
+
+// rejectBatch answers a blocked extended-protocol batch. When part of the
+// batch already reached the server its Sync is forwarded and the error goes
+// out ahead of the server's ReadyForQuery.
+func (p *pgSession) rejectBatch(server io.Writer, reason string, unsynced bool) {
+	if !unsynced {
+		p.reject(reason)
+		return
+	}
+
+	p.mu.Lock()
+	p.sync()
+	p.injections = append(p.injections, pgInjection{at: p.expected, before: true, data: pgErrorResponse(reason)})
+	p.mu.Unlock()
+
+	server.Write(pgSync())
+}
+
+// readPGStartup reads an untyped startup-phase message.
+func readPGStartup(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length < 8 || length > 10000 {
+		return nil, fmt.Errorf("invalid startup message length %d", length)
+	}
+
+	msg := make([]byte, length)
+	copy(msg, header)
+	if _, err := io.ReadFull(r, msg[4:]); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// readPGMessage reads a typed message and returns its type and raw bytes,
+// header included.
+func readPGMessage(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < 4 || length > pgMaxMessageSize {
+		return 0, nil, fmt.Errorf("invalid message length %d", length)
+	}
+
+	msg := make([]byte, 1+length)
+	copy(msg, header)
+	if _, err := io.ReadFull(r, msg[5:]); err != nil {
+		return 0, nil, err
+	}
+	return header[0], msg, nil
+}
+
+// pgCString returns the NUL-terminated string at the start of b.
+func pgCString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// pgCommandTagRows returns the row count at the end of a CommandComplete tag
+// such as "INSERT 0 5" or "UPDATE 3", or zero for tags without one.
+func pgCommandTagRows(tag string) int64 {
+	fields := strings.Fields(tag)
+	if len(fields) < 2 {
+		return 0
+	}
+	rows, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return rows
+}
+
+// pgErrorFields returns the SQLSTATE code and message of an ErrorResponse.
+func pgErrorFields(msg []byte) (code, message string) {
+	body := msg[5:]
+	for len(body) > 1 && body[0] != 0 {
+		value := pgCString(body[1:])
+		switch body[0] {
+		case 'C':
+			code = value
+		case 'M':
+			message = value
+		}
+		body = body[min(len(body), 1+len(value)+1):]
+	}
+	return code, message
+}
+
+// pgRowDescriptionText renders the column names of a RowDescription as a
+// tab-separated line.
+func pgRowDescriptionText(msg []byte) string {
+	body := msg[5:]
+	if len(body) < 2 {
+		return ""
+	}
+	count := int(binary.BigEndian.Uint16(body))
+	body = body[2:]
+
+	names := make([]string, 0, count)
+	for i := 0; i < count && len(body) > 0; i++ {
+		name := pgCString(body)
+		names = append(names, name)
+		// Table OID, column number, type OID, size, modifier and format
+		body = body[min(len(body), len(name)+1+18):]
+	}
+	return strings.Join(names, "\t") + "\n"
+}
+
+// pgDataRowText renders a DataRow as a tab-separated line. Values that are
+// not text, such as those sent in binary format, are hex encoded.
+func pgDataRowText(msg []byte) string {
+	body := msg[5:]
+	if len(body) < 2 {
+		return ""
+	}
+	count := int(binary.BigEndian.Uint16(body))
+	body = body[2:]
+
+	values := make([]string, 0, count)
+	for i := 0; i < count && len(body) >= 4; i++ {
+		length := int32(binary.BigEndian.Uint32(body))
+		body = body[4:]
+		if length < 0 {
+			values = append(values, "NULL")
+			continue
+		}
+		value := body[:min(len(body), int(length))]
+		body = body[len(value):]
+		if utf8.Valid(value) {
+			values = append(values, string(value))
+		} else {
+			values = append(values, "\\x"+hex.EncodeToString(value))
+		}
+	}
+	return strings.Join(values, "\t") + "\n"
+}
+
+// pgBindParameters returns the parameter values of a Bind message, as text.
+// Values sent in binary format are hex encoded and nulls are NULL, as in
+// pgDataRowText.
+func pgBindParameters(msg []byte) []string {
+	body := msg[5:]
+	// Portal and statement names
+	for i := 0; i < 2; i++ {
+		name := pgCString(body)
+		body = body[min(len(body), len(name)+1):]
+	}
+	if len(body) < 2 {
+		return nil
+	}
+	formats := make([]uint16, binary.BigEndian.Uint16(body))
+	body = body[2:]
+	for i := range formats {
+		if len(body) < 2 {
+			return nil
+		}
+		formats[i] = binary.BigEndian.Uint16(body)
+		body = body[2:]
+	}
+	if len(body) < 2 {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint16(body))
+	body = body[2:]
+
+	parameters := make([]string, 0, count)
+	for i := 0; i < count && len(body) >= 4; i++ {
+		length := int32(binary.BigEndian.Uint32(body))
+		body = body[4:]
+		if length < 0 {
+			parameters = append(parameters, "NULL")
+			continue
+		}
+		value := body[:min(len(body), int(length))]
+		body = body[len(value):]
+
+		// One format code applies to every parameter; none means text
+		var format uint16
+		switch {
+		case len(formats) == 1:
+			format = formats[0]
+		case i < len(formats):
+			format = formats[i]
+		}
+		if format == 0 && utf8.Valid(value) {
+			parameters = append(parameters, string(value))
+		} else {
+			parameters = append(parameters, "\\x"+hex.EncodeToString(value))
+		}
+	}
+	return parameters
+}
+
+func pgMessage(msgType byte, body []byte) []byte {
+	msg := make([]byte, 5, 5+len(body))
+	msg[0] = msgType
+	binary.BigEndian.PutUint32(msg[1:], uint32(4+len(body)))
+	return append(msg, body...)
+}
+
+// pgErrorResponse builds an insufficient_privilege error carrying message.
+func pgErrorResponse(message string) []byte {
+	var body []byte
+	for _, field := range []struct {
+		code  byte
+		value string
+	}{
+		{'S', "ERROR"},
+		{'V', "ERROR"},
+		{'C', "42501"},
+		{'M', "secretary: " + message},
+	} {
+		body = append(body, field.code)
+		body = append(body, field.value...)
+		body = append(body, 0)
+	}
+	return pgMessage('E', append(body, 0))
+}
+
+func pgReadyForQuery(txStatus byte) []byte {
+	return pgMessage('Z', []byte{txStatus})
+}
+
+func pgSync() []byte {
+	return pgMessage('S', nil)
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// MaxCommandResponseSize bounds the part of a server reply stored with a
+// command.
+const MaxCommandResponseSize = 4096
+
+// commandReply collects the server's reply to a forwarded command until the
+// protocol handler sees it end.
+type commandReply struct {
+	command *domain.SessionCommand
+	// echo is the command line as the client sent it, before redaction;
+	// shells print it back ahead of the reply
+	echo      string
+	sentAt    time.Time
+	response  []byte
+	truncated bool
+	rows      int64
+	failed    bool
+	// err is the first error the server answered with
+	err string
+	// parameters are the values bound to a prepared statement
+	parameters []string
+	// finishedAt is when the reply ended, if that was before it was
+	// completed; the completion time is used otherwise
+	finishedAt time.Time
+}
+
+func newCommandReply(command *domain.SessionCommand) *commandReply {
+	return &commandReply{command: command, sentAt: time.Now()}
+}
+
+func (r *commandReply) write(p []byte) {
+	if room := MaxCommandResponseSize - len(r.response); len(p) > room {
+		p = p[:room]
+		r.truncated = true
+	}
+	r.response = append(r.response, p...)
+}
+
+func (r *commandReply) writeString(s string) {
+	r.write([]byte(s))
+}
+
+// fail marks the reply as an error, keeping the first error message
+func (r *commandReply) fail(message string) {
+	r.failed = true
+	if r.err == "" {
+		r.err = message
+	}
+}
+
+// completeCommand stores the outcome of a forwarded command once the server
+// has answered it.
+func (s *proxyService) completeCommand(ctx context.Context, reply *commandReply) {
+	finishedAt := reply.finishedAt
+	if finishedAt.IsZero() {
+		finishedAt = time.Now()
+	}
+
+	command := reply.command
+	command.Duration = finishedAt.Sub(reply.sentAt).Milliseconds()
+	response, secrets := RedactSecrets(s.secretDetector, strings.ToValidUTF8(string(reply.response), "\uFFFD"))
+	command.Response = strings.TrimRight(response, "\n")
+	command.ResponseTruncated = reply.truncated
+	command.RowsAffected = reply.rows
+	if reply.failed {
+		command.Status = "failed"
+	}
+
+	if err := s.sessionCommandService.UpdateCommand(ctx, command); err != nil {
+		utils.Errorf("Failed to store reply to command %s: %v", command.ID, err)
+	}
+	if len(secrets) > 0 {
+		s.reportSecrets(ctx, command, "reply", secrets)
+	}
+	s.recordQuery(ctx, command, reply.parameters, reply.err)
+}
+
+// Terminal escape sequences: CSI sequences, OSC sequences such as window
+// titles, and two-byte escapes
+var ansiEscape = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[@-Z\\-_])`)
+
+// shellPrompt matches the end of a typical shell prompt: a short last line
+// ending in $, #, % or >
+var shellPrompt = regexp.MustCompile(`(^|\n)[^\n]{0,200}[$#%>] ?$`)
+
+// shellSession correlates the lines sent to a shell with its output. The
+// reply to a command is everything the server prints until the next prompt.
+type shellSession struct {
+	mu      sync.Mutex
+	pending *commandReply
+	tail    []byte
+	// newline is set once the reply has a line break; a prompt before it
+	// is the command echoed back
+	newline  bool
+	complete func(*commandReply)
+}
+
+// track starts collecting the reply to a command that is about to be
+// forwarded. A command still waiting for a prompt is completed first.
+func (sh *shellSession) track(reply *commandReply) {
+	sh.mu.Lock()
+	previous := sh.pending
+	sh.pending = reply
+	sh.tail = sh.tail[:0]
+	sh.newline = false
+	sh.mu.Unlock()
+
+	sh.finish(previous)
+}
+
+// Write observes server output on its way to the client.
+func (sh *shellSession) Write(p []byte) (int, error) {
+	sh.mu.Lock()
+	reply := sh.pending
+	if reply == nil {
+		sh.mu.Unlock()
+		return len(p), nil
+	}
+
+	reply.write(p)
+	reply.finishedAt = time.Now()
+	sh.tail = append(sh.tail, p...)
+	if len(sh.tail) > 512 {
+		sh.tail = append(sh.tail[:0], sh.tail[len(sh.tail)-512:]...)
+	}
+	sh.newline = sh.newline || bytes.IndexByte(p, '\n') >= 0
+	if !sh.newline || !atShellPrompt(sh.tail) {
+		sh.mu.Unlock()
+		return len(p), nil
+	}
+	sh.pending = nil
+	sh.mu.Unlock()
+
+	sh.finish(reply)
+	return len(p), nil
+}
+
+// close completes the command still waiting for a prompt, if any.
+func (sh *shellSession) close() {
+	sh.mu.Lock()
+	reply := sh.pending
+	sh.pending = nil
+	sh.mu.Unlock()
+
+	sh.finish(reply)
+}
+
+// finish strips the echoed command line, the closing prompt and terminal
+// escapes from a reply before completing it.
+func (sh *shellSession) finish(reply *commandReply) {
+	if reply == nil || sh.complete == nil {
+		return
+	}
+
+	output := ansiEscape.ReplaceAll(reply.response, nil)
+	output = bytes.ReplaceAll(output, []byte("\r\n"), []byte("\n"))
+	echo := reply.echo
+	if echo == "" {
+		echo = reply.command.Command
+	}
+	if rest, ok := bytes.CutPrefix(output, []byte(echo)); ok && (len(rest) == 0 || rest[0] == '\n') {
+		output = bytes.TrimPrefix(rest, []byte("\n"))
+	}
+	if !reply.truncated {
+		if i := bytes.LastIndexByte(output, '\n'); i >= 0 && shellPrompt.Match(output[i:]) {
+			output = output[:i]
+		} else if shellPrompt.Match(output) {
+			output = nil
+		}
+	}
+	reply.response = bytes.TrimRight(output, "\n")
+
+	sh.complete(reply)
+}
+
+func atShellPrompt(output []byte) bool {
+	return shellPrompt.Match(ansiEscape.ReplaceAll(output, nil))
+}
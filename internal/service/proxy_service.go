@@ -20,10 +20,43 @@ type proxyService struct {
 	sessionCommandService   domain.SessionCommandService
 	sessionRecordingService domain.SessionRecordingService
 	securityAlertService    domain.SecurityAlertService
+	sessionService          domain.SessionService
+	commandApprovalService  domain.CommandApprovalService
+	sessionRiskService      domain.SessionRiskService
+	holdPolicy              CommandHoldPolicy
+	secretDetector          SecretDetector
 	activeConnections       map[string]*ProxyConnection
+	observers               []domain.ProxyObserver
 	mu                      sync.RWMutex
 }
 
+// CommandHoldPolicy selects the commands the proxy holds until a reviewer
+// approves them. Commands sent to one of ResourceIDs ("*" matches every
+// resource) are held when their risk is at least MinRisk. An empty resource
+// list disables holding.
+type CommandHoldPolicy struct {
+	ResourceIDs []string
+	MinRisk     string
+	Timeout     time.Duration
+}
+
+func (p CommandHoldPolicy) requiresApproval(resourceID, risk string) bool {
+	minLevel, ok := riskLevels[p.MinRisk]
+	if !ok {
+		minLevel = riskLevels["high"]
+	}
+	if level, ok := riskLevels[risk]; !ok || level < minLevel {
+		return false
+	}
+
+	for _, id := range p.ResourceIDs {
+		if id == "*" || (id == resourceID && resourceID != "") {
+			return true
+		}
+	}
+	return false
+}
+
 type ProxyConnection struct {
 	ID          string
 	SessionID   string
@@ -37,17 +70,32 @@ type ProxyConnection struct {
 	listener    net.Listener
 	connections []net.Conn
 	cancel      context.CancelFunc
+	// stopped is closed when the proxy is stopped
+	stopped chan struct{}
 }
 
 func NewProxyService(
 	sessionCommandService domain.SessionCommandService,
 	sessionRecordingService domain.SessionRecordingService,
 	securityAlertService domain.SecurityAlertService,
+	sessionService domain.SessionService,
+	commandApprovalService domain.CommandApprovalService,
+	sessionRiskService domain.SessionRiskService,
+	holdPolicy CommandHoldPolicy,
+	secretDetector SecretDetector,
 ) domain.ProxyService {
+	if secretDetector == nil {
+		secretDetector = DefaultSecretDetector()
+	}
 	return &proxyService{
 		sessionCommandService:   sessionCommandService,
 		sessionRecordingService: sessionRecordingService,
 		securityAlertService:    securityAlertService,
+		sessionService:          sessionService,
+		commandApprovalService:  commandApprovalService,
+		sessionRiskService:      sessionRiskService,
+		holdPolicy:              holdPolicy,
+		secretDetector:          secretDetector,
 		activeConnections:       make(map[string]*ProxyConnection),
 	}
 }
@@ -61,9 +109,24 @@ func (s *proxyService) CreateProxy(ctx context.Context, sessionID, protocol, rem
 		return nil, fmt.Errorf("failed to find available port: %w", err)
 	}
 
+	// Resolve who is connecting to what, so commands and alerts can be
+	// attributed and resource policies applied
+	var userID, resourceID string
+	if s.sessionService != nil {
+		session, err := s.sessionService.GetByID(ctx, sessionID)
+		if err != nil {
+			utils.Warnf("Failed to look up session %s for proxy: %v", sessionID, err)
+		} else {
+			userID = session.UserID
+			resourceID = session.ResourceID
+		}
+	}
+
 	proxy := &domain.ProxyConnection{
 		ID:           proxyID,
 		SessionID:    sessionID,
+		UserID:       userID,
+		ResourceID:   resourceID,
 		Protocol:     protocol,
 		LocalPort:    localPort,
 		RemoteHost:   remoteHost,
@@ -80,11 +143,14 @@ func (s *proxyService) CreateProxy(ctx context.Context, sessionID, protocol, rem
 	internalProxy := &ProxyConnection{
 		ID:         proxyID,
 		SessionID:  sessionID,
+		UserID:     userID,
+		ResourceID: resourceID,
 		Protocol:   protocol,
 		LocalPort:  localPort,
 		RemoteHost: remoteHost,
 		RemotePort: remotePort,
 		Status:     "created",
+		stopped:    make(chan struct{}),
 	}
 	s.activeConnections[proxyID] = internalProxy
 
@@ -112,12 +178,13 @@ func (s *proxyService) StartProxy(ctx context.Context, proxyID string) (int, err
 	proxy.listener = listener
 	proxy.Status = "active"
 
-	// Create context for this proxy
-	proxyCtx, cancel := context.WithCancel(ctx)
+	// Create context for this proxy. The proxy outlives the request that
+	// started it, so only StopProxy ends it.
+	proxyCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
 	proxy.cancel = cancel
 
-	// Start session recording
-	recording, err := s.sessionRecordingService.StartRecording(proxyCtx, proxy.SessionID)
+	// Start session recording; database sessions are recorded as query logs
+	recording, err := s.sessionRecordingService.StartRecording(proxyCtx, proxy.SessionID, proxy.Protocol)
 	if err != nil {
 		utils.Warnf("Failed to start recording for session %s: %v", proxy.SessionID, err)
 	} else {
@@ -128,6 +195,7 @@ func (s *proxyService) StartProxy(ctx context.Context, proxyID string) (int, err
 	go s.handleConnections(proxyCtx, proxy)
 
 	utils.Infof("Started proxy %s on port %d", proxyID, proxy.LocalPort)
+	s.notify(ctx, domain.EventProxyStarted, proxy)
 	return proxy.LocalPort, nil
 }
 
@@ -140,6 +208,7 @@ func (s *proxyService) StopProxy(ctx context.Context, proxyID string) error {
 	}
 	delete(s.activeConnections, proxyID)
 	s.mu.Unlock()
+	close(proxy.stopped)
 
 	// Cancel context and close listener
 	if proxy.cancel != nil {
@@ -159,10 +228,45 @@ func (s *proxyService) StopProxy(ctx context.Context, proxyID string) error {
 		utils.Warnf("Failed to stop recording for session %s: %v", proxy.SessionID, err)
 	}
 
+	proxy.Status = "closed"
 	utils.Infof("Stopped proxy %s", proxyID)
+	s.notify(ctx, domain.EventProxyStopped, proxy)
 	return nil
 }
 
+// AddObserver registers an observer to be notified when proxies start and
+// stop. Observers are called synchronously and must not block.
+func (s *proxyService) AddObserver(observer domain.ProxyObserver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+func (s *proxyService) notify(ctx context.Context, event string, proxy *ProxyConnection) {
+	s.mu.RLock()
+	observers := s.observers
+	s.mu.RUnlock()
+	if len(observers) == 0 {
+		return
+	}
+
+	connection := &domain.ProxyConnection{
+		ID:           proxy.ID,
+		SessionID:    proxy.SessionID,
+		UserID:       proxy.UserID,
+		ResourceID:   proxy.ResourceID,
+		Protocol:     proxy.Protocol,
+		LocalPort:    proxy.LocalPort,
+		RemoteHost:   proxy.RemoteHost,
+		RemotePort:   proxy.RemotePort,
+		Status:       proxy.Status,
+		LastActivity: time.Now(),
+	}
+	for _, observer := range observers {
+		observer.ObserveProxy(ctx, event, connection)
+	}
+}
+
 func (s *proxyService) GetActiveProxies(ctx context.Context) ([]*domain.ProxyConnection, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -172,6 +276,8 @@ func (s *proxyService) GetActiveProxies(ctx context.Context) ([]*domain.ProxyCon
 		proxies = append(proxies, &domain.ProxyConnection{
 			ID:           proxy.ID,
 			SessionID:    proxy.SessionID,
+			UserID:       proxy.UserID,
+			ResourceID:   proxy.ResourceID,
 			Protocol:     proxy.Protocol,
 			LocalPort:    proxy.LocalPort,
 			RemoteHost:   proxy.RemoteHost,
@@ -194,6 +300,8 @@ func (s *proxyService) GetProxyBySession(ctx context.Context, sessionID string)
 			return &domain.ProxyConnection{
 				ID:           proxy.ID,
 				SessionID:    proxy.SessionID,
+				UserID:       proxy.UserID,
+				ResourceID:   proxy.ResourceID,
 				Protocol:     proxy.Protocol,
 				LocalPort:    proxy.LocalPort,
 				RemoteHost:   proxy.RemoteHost,
@@ -270,142 +378,96 @@ func (s *proxyService) handleConnection(ctx context.Context, proxy *ProxyConnect
 func (s *proxyService) handleSSHConnection(ctx context.Context, proxy *ProxyConnection, clientConn, targetConn net.Conn) {
 	// Create channels for data flow
 	done := make(chan struct{}, 2)
+	clientWriter := &lockedWriter{w: clientConn}
+	shell := &shellSession{complete: func(reply *commandReply) { s.completeCommand(ctx, reply) }}
+	recorder := &sessionRecorder{ctx: ctx, recordings: s.sessionRecordingService, sessionID: proxy.SessionID}
 
 	// Client to Server (commands)
 	go func() {
 		defer func() { done <- struct{}{} }()
-		s.monitorSSHTraffic(ctx, proxy, clientConn, targetConn, "client_to_server")
+		s.monitorSSHTraffic(ctx, proxy, shell, recorder, clientConn, targetConn, clientWriter)
 	}()
 
 	// Server to Client (responses)
 	go func() {
 		defer func() { done <- struct{}{} }()
-		s.monitorSSHTraffic(ctx, proxy, targetConn, clientConn, "server_to_client")
-	}()
-
-	// Wait for either direction to close
-	<-done
-}
-
-func (s *proxyService) handleMySQLConnection(ctx context.Context, proxy *ProxyConnection, clientConn, targetConn net.Conn) {
-	// Create channels for data flow
-	done := make(chan struct{}, 2)
-
-	// Client to Server (SQL commands)
-	go func() {
-		defer func() { done <- struct{}{} }()
-		s.monitorMySQLTraffic(ctx, proxy, clientConn, targetConn, "client_to_server")
-	}()
-
-	// Server to Client (results)
-	go func() {
-		defer func() { done <- struct{}{} }()
-		s.monitorMySQLTraffic(ctx, proxy, targetConn, clientConn, "server_to_client")
+		io.Copy(io.MultiWriter(clientWriter, shell, recorder), targetConn)
 	}()
 
 	// Wait for either direction to close
 	<-done
+	shell.close()
 }
 
-func (s *proxyService) handlePostgreSQLConnection(ctx context.Context, proxy *ProxyConnection, clientConn, targetConn net.Conn) {
-	// Similar to MySQL but for PostgreSQL protocol
+func (s *proxyService) handleGenericConnection(ctx context.Context, proxy *ProxyConnection, clientConn, targetConn net.Conn) {
+	// Simple bidirectional proxy with basic monitoring
 	done := make(chan struct{}, 2)
 
 	go func() {
 		defer func() { done <- struct{}{} }()
-		s.monitorPostgreSQLTraffic(ctx, proxy, clientConn, targetConn, "client_to_server")
+		io.Copy(targetConn, clientConn)
 	}()
 
 	go func() {
 		defer func() { done <- struct{}{} }()
-		s.monitorPostgreSQLTraffic(ctx, proxy, targetConn, clientConn, "server_to_client")
+		io.Copy(clientConn, targetConn)
 	}()
 
 	<-done
 }
 
-func (s *proxyService) handleGenericConnection(ctx context.Context, proxy *ProxyConnection, clientConn, targetConn net.Conn) {
-	// Simple bidirectional proxy with basic monitoring
+// relayRaw copies both directions unmodified. It is used once a protocol
+// switches to end-to-end encryption and can no longer be inspected.
+func relayRaw(clientReader, targetReader io.Reader, clientConn, targetConn net.Conn) {
 	done := make(chan struct{}, 2)
 
 	go func() {
 		defer func() { done <- struct{}{} }()
-		io.Copy(targetConn, clientConn)
+		io.Copy(targetConn, clientReader)
 	}()
 
 	go func() {
 		defer func() { done <- struct{}{} }()
-		io.Copy(clientConn, targetConn)
+		io.Copy(clientConn, targetReader)
 	}()
 
 	<-done
 }
 
-func (s *proxyService) monitorSSHTraffic(ctx context.Context, proxy *ProxyConnection, src, dst net.Conn, direction string) {
+// monitorSSHTraffic inspects each line typed by the client before forwarding
+// it, so blocked or held commands never reach the server.
+func (s *proxyService) monitorSSHTraffic(ctx context.Context, proxy *ProxyConnection, shell *shellSession, recorder *sessionRecorder, src io.Reader, dst io.Writer, clientWriter io.Writer) {
 	scanner := bufio.NewScanner(src)
 	writer := bufio.NewWriter(dst)
+	// Notices from the proxy are part of what the client saw
+	notices := io.MultiWriter(clientWriter, recorder)
 
 	for scanner.Scan() {
 		line := scanner.Text()
+		recorder.input([]byte(line + "\n"))
 
-		// Write to destination
-		writer.WriteString(line + "\n")
-		writer.Flush()
-
-		// Only analyze client-to-server traffic (commands)
-		if direction == "client_to_server" && strings.TrimSpace(line) != "" {
-			s.analyzeAndRecordCommand(ctx, proxy, line, "ssh")
-		}
-	}
-}
-
-func (s *proxyService) monitorMySQLTraffic(ctx context.Context, proxy *ProxyConnection, src, dst net.Conn, direction string) {
-	// MySQL protocol parsing is complex, but we can intercept text-based queries
-	buffer := make([]byte, 4096)
-
-	for {
-		n, err := src.Read(buffer)
-		if err != nil {
-			break
-		}
-
-		// Write to destination
-		dst.Write(buffer[:n])
-
-		// Analyze SQL commands (simplified)
-		if direction == "client_to_server" {
-			data := string(buffer[:n])
-			if s.containsSQLCommand(data) {
-				s.analyzeAndRecordCommand(ctx, proxy, data, "mysql")
+		if strings.TrimSpace(line) != "" {
+			sessionCommand, allowed, reason := s.inspectCommand(ctx, proxy, line, "ssh")
+			if !allowed {
+				fmt.Fprintf(notices, "\r\nsecretary: %s\r\n", reason)
+				continue
 			}
-		}
-	}
-}
-
-func (s *proxyService) monitorPostgreSQLTraffic(ctx context.Context, proxy *ProxyConnection, src, dst net.Conn, direction string) {
-	// PostgreSQL protocol parsing
-	buffer := make([]byte, 4096)
-
-	for {
-		n, err := src.Read(buffer)
-		if err != nil {
-			break
+			reply := newCommandReply(sessionCommand)
+			reply.echo = line
+			shell.track(reply)
 		}
 
 		// Write to destination
-		dst.Write(buffer[:n])
-
-		// Analyze SQL commands (simplified)
-		if direction == "client_to_server" {
-			data := string(buffer[:n])
-			if s.containsSQLCommand(data) {
-				s.analyzeAndRecordCommand(ctx, proxy, data, "postgresql")
-			}
-		}
+		writer.WriteString(line + "\n")
+		writer.Flush()
 	}
 }
 
-func (s *proxyService) analyzeAndRecordCommand(ctx context.Context, proxy *ProxyConnection, command, commandType string) {
+// inspectCommand analyzes a client command before it is forwarded and
+// records the decision. It returns the recorded command, whether it may be
+// forwarded and, when it may not, the reason to report back to the client.
+// Protocol handlers complete the record with the server's reply.
+func (s *proxyService) inspectCommand(ctx context.Context, proxy *ProxyConnection, command, commandType string) (*domain.SessionCommand, bool, string) {
 	startTime := time.Now()
 
 	// Analyze command for risk
@@ -415,65 +477,282 @@ func (s *proxyService) analyzeAndRecordCommand(ctx context.Context, proxy *Proxy
 		risk = "unknown"
 	}
 
+	// The command is analyzed as sent, but only its redacted form is kept
+	redacted, secrets := RedactSecrets(s.secretDetector, command)
+
 	// Create session command record
 	sessionCommand := &domain.SessionCommand{
 		ID:          uuid.New().String(),
 		SessionID:   proxy.SessionID,
 		UserID:      proxy.UserID,
 		ResourceID:  proxy.ResourceID,
-		Command:     command,
+		Command:     redacted,
 		CommandType: commandType,
 		Status:      "executed",
+		Action:      "allowed",
 		Risk:        risk,
 		Timestamp:   startTime,
-		Duration:    time.Since(startTime).Milliseconds(),
 		CreatedAt:   time.Now(),
 	}
+	if len(secrets) > 0 {
+		s.reportSecrets(ctx, sessionCommand, "command", secrets)
+	}
 
-	if shouldBlock {
+	// Nothing in flight when the proxy was stopped is forwarded. This
+	// covers sessions interrupted for risk, whose score is dropped once
+	// they end.
+	select {
+	case <-proxy.stopped:
 		sessionCommand.Status = "blocked"
+		sessionCommand.Action = "blocked"
+		sessionCommand.DecisionReason = "proxy stopped"
+		sessionCommand.DecidedAt = time.Now()
+		s.recordCommand(ctx, sessionCommand)
+		return sessionCommand, false, "proxy stopped"
+	default:
+	}
 
-		// Create security alert
-		alert := &domain.SecurityAlert{
-			ID:          uuid.New().String(),
-			SessionID:   proxy.SessionID,
-			CommandID:   sessionCommand.ID,
-			UserID:      proxy.UserID,
-			ResourceID:  proxy.ResourceID,
-			AlertType:   "blocked_command",
-			Severity:    risk,
-			Title:       "Blocked High-Risk Command",
-			Description: fmt.Sprintf("Command blocked due to %s risk level", risk),
-			RawData:     command,
-			Action:      "blocked",
-			CreatedAt:   time.Now(),
+	// A session whose cumulative risk crosses the interrupt threshold is
+	// torn down; the command that tipped it over is not forwarded
+	if s.sessionRiskService != nil {
+		score, err := s.sessionRiskService.RecordCommand(ctx, sessionCommand)
+		if err != nil {
+			utils.Errorf("Failed to update session risk score: %v", err)
+		} else if score.Interrupted {
+			sessionCommand.Status = "blocked"
+			sessionCommand.Action = "blocked"
+			sessionCommand.DecisionReason = fmt.Sprintf("session interrupted at risk score %.1f", score.Score)
+			sessionCommand.DecidedAt = time.Now()
+			s.recordCommand(ctx, sessionCommand)
+			return sessionCommand, false, "session interrupted due to cumulative risk"
 		}
+	}
 
-		if err := s.securityAlertService.CreateAlert(ctx, alert); err != nil {
-			utils.Errorf("Failed to create security alert: %v", err)
-		}
+	if shouldBlock {
+		sessionCommand.Status = "blocked"
+		sessionCommand.Action = "blocked"
+		sessionCommand.DecisionReason = fmt.Sprintf("blocked by policy due to %s risk", risk)
+		sessionCommand.DecidedAt = time.Now()
+
+		s.raiseAlert(ctx, proxy, sessionCommand, "blocked_command", "Blocked High-Risk Command",
+			fmt.Sprintf("Command blocked due to %s risk level", risk), "blocked")
+		s.recordCommand(ctx, sessionCommand)
+		return sessionCommand, false, "command blocked by security policy"
+	}
+
+	if s.holdPolicy.requiresApproval(proxy.ResourceID, risk) {
+		allowed, reason := s.holdCommand(ctx, proxy, sessionCommand)
+		return sessionCommand, allowed, reason
+	}
+
+	s.recordCommand(ctx, sessionCommand)
+	return sessionCommand, true, ""
+}
+
+// holdCommand pauses a command until a reviewer approves or denies it, or
+// the approval times out. Anything but an explicit approval keeps the command
+// from reaching the server.
+func (s *proxyService) holdCommand(ctx context.Context, proxy *ProxyConnection, sessionCommand *domain.SessionCommand) (bool, string) {
+	sessionCommand.Status = "pending_approval"
+	sessionCommand.Action = "held"
+
+	approval := &domain.CommandApproval{
+		SessionID:   proxy.SessionID,
+		CommandID:   sessionCommand.ID,
+		UserID:      proxy.UserID,
+		ResourceID:  proxy.ResourceID,
+		Command:     sessionCommand.Command,
+		CommandType: sessionCommand.CommandType,
+		Risk:        sessionCommand.Risk,
+	}
+	if s.commandApprovalService == nil {
+		sessionCommand.Status = "blocked"
+		sessionCommand.DecisionReason = "approval required but no approval service is configured"
+		sessionCommand.DecidedAt = time.Now()
+		s.recordCommand(ctx, sessionCommand)
+		return false, "command requires approval"
+	}
+	if err := s.commandApprovalService.RequestApproval(ctx, approval, s.holdPolicy.Timeout); err != nil {
+		utils.Errorf("Failed to request approval for command %s: %v", sessionCommand.ID, err)
+		sessionCommand.Status = "blocked"
+		sessionCommand.DecisionReason = "approval required but could not be requested"
+		sessionCommand.DecidedAt = time.Now()
+		s.recordCommand(ctx, sessionCommand)
+		return false, "command requires approval"
 	}
 
-	// Record the command
+	sessionCommand.ApprovalID = approval.ID
+	sessionCommand.ApprovalStatus = approval.Status
+	s.recordCommand(ctx, sessionCommand)
+	s.raiseAlert(ctx, proxy, sessionCommand, "command_held", "Command Held For Approval",
+		fmt.Sprintf("%s risk command held for reviewer approval until %s (approval %s)",
+			sessionCommand.Risk, approval.ExpiresAt.Format(time.RFC3339), approval.ID), "held")
+
+	utils.Infof("Holding %s command in session %s for approval %s",
+		sessionCommand.CommandType, proxy.SessionID, approval.ID)
+
+	decision, err := s.commandApprovalService.WaitForDecision(ctx, approval.ID)
+	if err != nil {
+		utils.Errorf("Failed to wait for approval %s: %v", approval.ID, err)
+		decision = &domain.CommandApproval{Status: "expired", ReviewedAt: time.Now()}
+	}
+
+	sessionCommand.ApprovalStatus = decision.Status
+	sessionCommand.ReviewerID = decision.ReviewerID
+	sessionCommand.DecisionReason = decision.ReviewNotes
+	sessionCommand.DecidedAt = decision.ReviewedAt
+
+	allowed := false
+	var reason string
+	switch decision.Status {
+	case "approved":
+		allowed = true
+		sessionCommand.Status = "executed"
+		s.raiseAlert(ctx, proxy, sessionCommand, "command_approved", "Held Command Approved",
+			fmt.Sprintf("Reviewer %s approved the held command: %s", decision.ReviewerID, decision.ReviewNotes), "approved")
+	case "denied":
+		sessionCommand.Status = "denied"
+		reason = "command denied by reviewer"
+		s.raiseAlert(ctx, proxy, sessionCommand, "command_denied", "Held Command Denied",
+			fmt.Sprintf("Reviewer %s denied the held command: %s", decision.ReviewerID, decision.ReviewNotes), "blocked")
+	default:
+		sessionCommand.Status = "expired"
+		reason = "command approval timed out"
+		s.raiseAlert(ctx, proxy, sessionCommand, "command_approval_expired", "Held Command Not Approved",
+			fmt.Sprintf("No reviewer approved the held command (%s)", decision.Status), "blocked")
+	}
+
+	if err := s.sessionCommandService.UpdateCommand(ctx, sessionCommand); err != nil {
+		utils.Errorf("Failed to update command %s: %v", sessionCommand.ID, err)
+	}
+	if !allowed {
+		s.recordQuery(ctx, sessionCommand, nil, "")
+	}
+	return allowed, reason
+}
+
+func (s *proxyService) recordCommand(ctx context.Context, sessionCommand *domain.SessionCommand) {
 	if err := s.sessionCommandService.RecordCommand(ctx, sessionCommand); err != nil {
 		utils.Errorf("Failed to record command: %v", err)
 	}
 
-	utils.Infof("Recorded %s command in session %s: %s (risk: %s)",
-		commandType, proxy.SessionID, command[:min(50, len(command))], risk)
+	utils.Infof("Recorded %s command in session %s: %s (risk: %s, action: %s)",
+		sessionCommand.CommandType, sessionCommand.SessionID,
+		sessionCommand.Command[:min(50, len(sessionCommand.Command))],
+		sessionCommand.Risk, sessionCommand.Action)
+
+	if err := s.sessionRecordingService.RecordCommand(ctx, sessionCommand); err != nil {
+		utils.Warnf("Failed to add command to recording for session %s: %v", sessionCommand.SessionID, err)
+	}
+	// Blocked statements never get a reply to complete them
+	if sessionCommand.Status == "blocked" {
+		s.recordQuery(ctx, sessionCommand, nil, "")
+	}
 }
 
-func (s *proxyService) containsSQLCommand(data string) bool {
-	// Simple heuristic to detect SQL commands
-	sqlKeywords := []string{"SELECT", "INSERT", "UPDATE", "DELETE", "DROP", "CREATE", "ALTER"}
-	upperData := strings.ToUpper(data)
+// recordQuery adds the outcome of a database command to its session's
+// query log. queryErr is the error the server answered with, if any.
+func (s *proxyService) recordQuery(ctx context.Context, sessionCommand *domain.SessionCommand, parameters []string, queryErr string) {
+	if !isDatabaseProtocol(sessionCommand.CommandType) {
+		return
+	}
+	entry := &domain.QueryLogEntry{
+		CommandID:  sessionCommand.ID,
+		Time:       sessionCommand.Timestamp,
+		Statement:  sessionCommand.Command,
+		Parameters: parameters,
+		DurationMs: sessionCommand.Duration,
+		Rows:       sessionCommand.RowsAffected,
+		Error:      queryErr,
+		Risk:       sessionCommand.Risk,
+		Status:     sessionCommand.Status,
+		Action:     sessionCommand.Action,
+		Reason:     sessionCommand.DecisionReason,
+	}
+	if err := s.sessionRecordingService.RecordQuery(ctx, sessionCommand.SessionID, entry); err != nil {
+		utils.Warnf("Failed to add query to recording for session %s: %v", sessionCommand.SessionID, err)
+	}
+}
 
-	for _, keyword := range sqlKeywords {
-		if strings.Contains(upperData, keyword) {
-			return true
+func (s *proxyService) raiseAlert(ctx context.Context, proxy *ProxyConnection, sessionCommand *domain.SessionCommand, alertType, title, description, action string) {
+	alert := &domain.SecurityAlert{
+		ID:          uuid.New().String(),
+		SessionID:   proxy.SessionID,
+		CommandID:   sessionCommand.ID,
+		UserID:      proxy.UserID,
+		ResourceID:  proxy.ResourceID,
+		AlertType:   alertType,
+		Severity:    sessionCommand.Risk,
+		Title:       title,
+		Description: description,
+		RawData:     sessionCommand.Command,
+		Action:      action,
+		CreatedAt:   time.Now(),
+	}
+	s.createAlert(ctx, alert)
+}
+
+// reportSecrets raises a credential_exposure alert for secrets found, and
+// already redacted, in a command or in the server's reply to it.
+func (s *proxyService) reportSecrets(ctx context.Context, sessionCommand *domain.SessionCommand, where string, secrets []SecretMatch) {
+	s.createAlert(ctx, &domain.SecurityAlert{
+		ID:          uuid.New().String(),
+		SessionID:   sessionCommand.SessionID,
+		CommandID:   sessionCommand.ID,
+		UserID:      sessionCommand.UserID,
+		ResourceID:  sessionCommand.ResourceID,
+		AlertType:   "credential_exposure",
+		Severity:    "high",
+		Title:       "Credential Exposed In Session",
+		Description: fmt.Sprintf("%d secret(s) redacted from the %s: %s", len(secrets), where, strings.Join(secretTypes(secrets), ", ")),
+		RawData:     sessionCommand.Command,
+		Action:      "redacted",
+		CreatedAt:   time.Now(),
+	})
+}
+
+func (s *proxyService) createAlert(ctx context.Context, alert *domain.SecurityAlert) {
+	if err := s.securityAlertService.CreateAlert(ctx, alert); err != nil {
+		utils.Errorf("Failed to create security alert: %v", err)
+	}
+
+	if s.sessionRiskService != nil {
+		if _, err := s.sessionRiskService.RecordAlert(ctx, alert); err != nil {
+			utils.Errorf("Failed to update session risk score: %v", err)
 		}
 	}
-	return false
+}
+
+// sessionRecorder feeds a session's terminal traffic to its recording. A
+// session need not be recorded, so failed writes are not reported.
+type sessionRecorder struct {
+	ctx        context.Context
+	recordings domain.SessionRecordingService
+	sessionID  string
+}
+
+// Write records output sent to the client.
+func (r *sessionRecorder) Write(p []byte) (int, error) {
+	r.recordings.WriteOutput(r.ctx, r.sessionID, p)
+	return len(p), nil
+}
+
+// input records input typed by the client.
+func (r *sessionRecorder) input(p []byte) {
+	r.recordings.WriteInput(r.ctx, r.sessionID, p)
+}
+
+// lockedWriter serializes writes to a connection shared by the goroutine
+// relaying server output and the interceptor answering for the server.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
 }
 
 func (s *proxyService) findAvailablePort() (int, error) {
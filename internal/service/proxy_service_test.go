@@ -0,0 +1,535 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+)
+
+func newTestProxyService(t *testing.T, policy CommandHoldPolicy) (*proxyService, *commandApprovalService) {
+	approvals := newTestCommandApprovalService(t, nil)
+	svc := NewProxyService(
+		newTestSessionCommandService(t),
+		newTestSessionRecordingService(t, RecordingOptions{BasePath: t.TempDir()}),
+		newTestSecurityAlertService(t),
+		nil,
+		approvals,
+		nil,
+		policy,
+		nil,
+	).(*proxyService)
+	return svc, approvals
+}
+
+func TestCommandHoldPolicy_RequiresApproval(t *testing.T) {
+	policy := CommandHoldPolicy{ResourceIDs: []string{"prod-db"}, MinRisk: "high"}
+
+	assert.True(t, policy.requiresApproval("prod-db", "high"))
+	assert.True(t, policy.requiresApproval("prod-db", "critical"))
+	assert.False(t, policy.requiresApproval("prod-db", "medium"))
+	assert.False(t, policy.requiresApproval("staging-db", "critical"))
+	assert.False(t, policy.requiresApproval("", "critical"))
+	assert.False(t, CommandHoldPolicy{}.requiresApproval("prod-db", "critical"))
+	assert.True(t, CommandHoldPolicy{ResourceIDs: []string{"*"}}.requiresApproval("any", "high"))
+}
+
+func TestProxyService_HoldCommand(t *testing.T) {
+	svc, approvals := newTestProxyService(t, CommandHoldPolicy{
+		ResourceIDs: []string{"prod-db"},
+		MinRisk:     "high",
+		Timeout:     time.Minute,
+	})
+	ctx := context.Background()
+	proxy := &ProxyConnection{SessionID: "session-1", UserID: "user-1", ResourceID: "prod-db"}
+
+	tests := []struct {
+		name    string
+		decide  func(id string) error
+		allowed bool
+		status  string
+	}{
+		{"approved", func(id string) error { return approvals.Approve(ctx, id, "reviewer-1", "ok") }, true, "executed"},
+		{"denied", func(id string) error { return approvals.Deny(ctx, id, "reviewer-1", "no") }, false, "denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			go func() {
+				for {
+					pending, _ := approvals.ListPending(ctx)
+					if len(pending) > 0 {
+						assert.NoError(t, tt.decide(pending[0].ID))
+						return
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}()
+
+			_, allowed, reason := svc.inspectCommand(ctx, proxy, "DELETE FROM accounts", "postgresql")
+			assert.Equal(t, tt.allowed, allowed)
+			if !allowed {
+				assert.NotEmpty(t, reason)
+			}
+
+			commands, err := svc.sessionCommandService.GetSessionCommands(ctx, "session-1")
+			require.NoError(t, err)
+			command := commands[len(commands)-1]
+			assert.Equal(t, "held", command.Action)
+			assert.Equal(t, tt.status, command.Status)
+			assert.Equal(t, tt.name, command.ApprovalStatus)
+			assert.Equal(t, "reviewer-1", command.ReviewerID)
+			assert.NotEmpty(t, command.ApprovalID)
+		})
+	}
+
+	// Low-risk commands are never held
+	_, allowed, _ := svc.inspectCommand(ctx, proxy, "SELECT 1", "postgresql")
+	assert.True(t, allowed)
+}
+
+func TestProxyService_HeldCommandOutlivesStartRequest(t *testing.T) {
+	svc, approvals := newTestProxyService(t, CommandHoldPolicy{
+		ResourceIDs: []string{"prod-db"},
+		MinRisk:     "high",
+		Timeout:     time.Minute,
+	})
+
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer target.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	proxy, err := svc.CreateProxy(context.Background(), "session-1", "ssh", "127.0.0.1", target.Addr().(*net.TCPAddr).Port)
+	require.NoError(t, err)
+	svc.activeConnections[proxy.ID].ResourceID = "prod-db"
+	defer svc.StopProxy(context.Background(), proxy.ID)
+
+	// The request that started the proxy is over once it is started
+	startCtx, cancel := context.WithCancel(context.Background())
+	port, err := svc.StartProxy(startCtx, proxy.ID)
+	require.NoError(t, err)
+	cancel()
+
+	client, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Write([]byte("sudo systemctl stop nginx\n"))
+	require.NoError(t, err)
+
+	var pending []*domain.CommandApproval
+	require.Eventually(t, func() bool {
+		pending, _ = approvals.ListPending(context.Background())
+		return len(pending) == 1
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(t, approvals.Approve(context.Background(), pending[0].ID, "reviewer-1", "ok"))
+
+	select {
+	case line := <-received:
+		assert.Equal(t, "sudo systemctl stop nginx\n", line)
+	case <-time.After(5 * time.Second):
+		t.Fatal("approved command was not forwarded")
+	}
+}
+
+func TestProxyService_PostgreSQLRejectsBlockedQuery(t *testing.T) {
+	svc, _ := newTestProxyService(t, CommandHoldPolicy{})
+	proxy := &ProxyConnection{SessionID: "session-1"}
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+	var forwarded bytes.Buffer
+	session := &pgSession{client: proxySide, txStatus: 'I'}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.monitorPostgreSQLTraffic(context.Background(), proxy, session, proxySide, &forwarded)
+		proxySide.Close()
+	}()
+
+	_, err := clientSide.Write(pgMessage('Q', append([]byte("DROP DATABASE production"), 0)))
+	require.NoError(t, err)
+
+	msgType, msg, err := readPGMessage(clientSide)
+	require.NoError(t, err)
+	assert.Equal(t, byte('E'), msgType)
+	assert.Contains(t, string(msg), "42501")
+
+	msgType, msg, err = readPGMessage(clientSide)
+	require.NoError(t, err)
+	assert.Equal(t, byte('Z'), msgType)
+	assert.Equal(t, byte('I'), msg[5])
+
+	clientSide.Close()
+	<-done
+	assert.Zero(t, forwarded.Len())
+}
+
+func TestProxyService_MySQLRejectsBlockedQuery(t *testing.T) {
+	svc, _ := newTestProxyService(t, CommandHoldPolicy{})
+	proxy := &ProxyConnection{SessionID: "session-1"}
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+	var forwarded bytes.Buffer
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.monitorMySQLTraffic(context.Background(), proxy, &mysqlSession{client: proxySide}, proxySide, &forwarded)
+		proxySide.Close()
+	}()
+
+	query := append([]byte{mysqlComQuery}, "DROP DATABASE production"...)
+	_, err := clientSide.Write(append([]byte{byte(len(query)), 0, 0, 0}, query...))
+	require.NoError(t, err)
+
+	packet, err := readMySQLPacket(clientSide)
+	require.NoError(t, err)
+	assert.Equal(t, byte(1), packet.seq)
+	assert.Equal(t, byte(0xff), packet.payload[0])
+	assert.Equal(t, "#42000", string(packet.payload[3:9]))
+
+	clientSide.Close()
+	<-done
+	assert.Zero(t, forwarded.Len())
+}
+
+// replyRecorder completes replies through the proxy service and hands them
+// to the test.
+func replyRecorder(svc *proxyService) (func(*commandReply), chan *domain.SessionCommand) {
+	completed := make(chan *domain.SessionCommand, 10)
+	return func(reply *commandReply) {
+		svc.completeCommand(context.Background(), reply)
+		completed <- reply.command
+	}, completed
+}
+
+func TestProxyService_PostgreSQLRecordsReply(t *testing.T) {
+	svc, _ := newTestProxyService(t, CommandHoldPolicy{})
+	proxy := &ProxyConnection{SessionID: "session-1"}
+	complete, completed := replyRecorder(svc)
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+	serverSide, proxyServerSide := net.Pipe()
+	defer serverSide.Close()
+	go io.Copy(io.Discard, clientSide)
+
+	session := &pgSession{client: proxySide, txStatus: 'I', complete: complete}
+	go svc.monitorPostgreSQLTraffic(context.Background(), proxy, session, proxySide, proxyServerSide)
+	go session.relayServer(proxyServerSide)
+
+	query := func(text string, replies ...[]byte) *domain.SessionCommand {
+		_, err := clientSide.Write(pgMessage('Q', append([]byte(text), 0)))
+		require.NoError(t, err)
+		msgType, _, err := readPGMessage(serverSide)
+		require.NoError(t, err)
+		require.Equal(t, byte('Q'), msgType)
+
+		for _, reply := range replies {
+			_, err := serverSide.Write(reply)
+			require.NoError(t, err)
+		}
+		_, err = serverSide.Write(pgReadyForQuery('I'))
+		require.NoError(t, err)
+
+		select {
+		case command := <-completed:
+			return command
+		case <-time.After(5 * time.Second):
+			t.Fatal("reply not completed")
+			return nil
+		}
+	}
+
+	command := query("SELECT id, name FROM users",
+		pgMessage('T', pgTestRowDescription("id", "name")),
+		pgMessage('D', pgTestDataRow("1", "alice")),
+		pgMessage('D', pgTestDataRow("2", "")),
+		pgMessage('C', append([]byte("SELECT 2"), 0)))
+	assert.Equal(t, "executed", command.Status)
+	assert.Equal(t, int64(2), command.RowsAffected)
+	assert.Equal(t, "id\tname\n1\talice\n2\tNULL\nSELECT 2", command.Response)
+
+	command = query("UPDATE accounts SET active = false WHERE id < 4",
+		pgMessage('C', append([]byte("UPDATE 3"), 0)))
+	assert.Equal(t, int64(3), command.RowsAffected)
+
+	command = query("SELECT * FROM missing", pgTestError("42P01", `relation "missing" does not exist`))
+	assert.Equal(t, "failed", command.Status)
+	assert.Equal(t, `ERROR 42P01: relation "missing" does not exist`, command.Response)
+
+	stored, err := svc.sessionCommandService.GetSessionCommands(context.Background(), "session-1")
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	assert.Equal(t, int64(2), stored[0].RowsAffected)
+	assert.Equal(t, "failed", stored[2].Status)
+}
+
+func TestProxyService_PostgreSQLQueryLog(t *testing.T) {
+	svc, _ := newTestProxyService(t, CommandHoldPolicy{})
+	ctx := context.Background()
+	proxy := &ProxyConnection{SessionID: "session-1", Protocol: "postgresql"}
+	complete, completed := replyRecorder(svc)
+
+	recording, err := svc.sessionRecordingService.StartRecording(ctx, proxy.SessionID, proxy.Protocol)
+	require.NoError(t, err)
+	assert.Equal(t, RecordingFormatQueryLog, recording.Format)
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+	serverSide, proxyServerSide := net.Pipe()
+	defer serverSide.Close()
+	go io.Copy(io.Discard, clientSide)
+
+	session := &pgSession{client: proxySide, txStatus: 'I', complete: complete}
+	go svc.monitorPostgreSQLTraffic(ctx, proxy, session, proxySide, proxyServerSide)
+	go session.relayServer(proxyServerSide)
+
+	send := func(messages [][]byte, replies ...[]byte) {
+		for _, msg := range messages {
+			_, err := clientSide.Write(msg)
+			require.NoError(t, err)
+			_, _, err = readPGMessage(serverSide)
+			require.NoError(t, err)
+		}
+		for _, reply := range replies {
+			_, err := serverSide.Write(reply)
+			require.NoError(t, err)
+		}
+		_, err := serverSide.Write(pgReadyForQuery('I'))
+		require.NoError(t, err)
+		select {
+		case <-completed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("reply not completed")
+		}
+	}
+
+	// An extended-protocol statement with a text and a null parameter
+	bind := []byte{0, 0, 0, 0, 0, 2, 0, 0, 0, 2, '4', '2', 0xff, 0xff, 0xff, 0xff, 0, 0}
+	send([][]byte{
+		pgMessage('P', append([]byte("\x00SELECT name FROM users WHERE id = $1 OR manager = $2\x00"), 0, 0)),
+		pgMessage('B', bind),
+		pgMessage('E', []byte{0, 0, 0, 0, 0}),
+		pgSync(),
+	},
+		pgMessage('1', nil),
+		pgMessage('2', nil),
+		pgMessage('D', pgTestDataRow("alice")),
+		pgMessage('C', append([]byte("SELECT 1"), 0)))
+	send([][]byte{pgMessage('Q', append([]byte("SELECT * FROM missing"), 0))},
+		pgTestError("42P01", `relation "missing" does not exist`))
+	_, err = clientSide.Write(pgMessage('Q', append([]byte("DROP DATABASE production"), 0)))
+	require.NoError(t, err)
+
+	var entries []*domain.QueryLogEntry
+	require.Eventually(t, func() bool {
+		entries, err = svc.sessionRecordingService.GetQueryLog(ctx, recording.ID)
+		return err == nil && len(entries) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "SELECT name FROM users WHERE id = $1 OR manager = $2", entries[0].Statement)
+	assert.Equal(t, []string{"42", "NULL"}, entries[0].Parameters)
+	assert.Equal(t, int64(1), entries[0].Rows)
+	assert.Equal(t, "executed", entries[0].Status)
+	assert.Empty(t, entries[0].Error)
+
+	assert.Equal(t, "failed", entries[1].Status)
+	assert.Equal(t, `42P01: relation "missing" does not exist`, entries[1].Error)
+	assert.Empty(t, entries[1].Parameters)
+
+	assert.Equal(t, "DROP DATABASE production", entries[2].Statement)
+	assert.Equal(t, "blocked", entries[2].Status)
+	assert.NotEmpty(t, entries[2].Reason)
+	assert.NotEmpty(t, entries[2].Risk)
+	for i := 1; i < len(entries); i++ {
+		assert.False(t, entries[i].Time.Before(entries[i-1].Time))
+		assert.GreaterOrEqual(t, entries[i].Offset, entries[i-1].Offset)
+	}
+}
+
+func TestProxyService_MySQLRecordsReply(t *testing.T) {
+	svc, _ := newTestProxyService(t, CommandHoldPolicy{})
+	proxy := &ProxyConnection{SessionID: "session-1"}
+	complete, completed := replyRecorder(svc)
+
+	clientSide, proxySide := net.Pipe()
+	defer clientSide.Close()
+	serverSide, proxyServerSide := net.Pipe()
+	defer serverSide.Close()
+	go io.Copy(io.Discard, clientSide)
+
+	session := &mysqlSession{client: proxySide, complete: complete}
+	go svc.monitorMySQLTraffic(context.Background(), proxy, session, proxySide, proxyServerSide)
+	go session.relayServer(proxyServerSide)
+
+	query := func(text string, replies ...[]byte) *domain.SessionCommand {
+		_, err := clientSide.Write(mysqlTestPacket(0, append([]byte{mysqlComQuery}, text...)))
+		require.NoError(t, err)
+		_, err = readMySQLPacket(serverSide)
+		require.NoError(t, err)
+
+		for i, reply := range replies {
+			_, err := serverSide.Write(mysqlTestPacket(byte(i+1), reply))
+			require.NoError(t, err)
+		}
+
+		select {
+		case command := <-completed:
+			return command
+		case <-time.After(5 * time.Second):
+			t.Fatal("reply not completed")
+			return nil
+		}
+	}
+
+	command := query("UPDATE accounts SET active = 0", []byte{0x00, 3, 0, 0x02, 0, 0, 0})
+	assert.Equal(t, "executed", command.Status)
+	assert.Equal(t, int64(3), command.RowsAffected)
+	assert.Equal(t, "OK, 3 rows affected", command.Response)
+
+	eof := []byte{0xfe, 0, 0, 0x02, 0}
+	command = query("SELECT name FROM users",
+		[]byte{1},
+		mysqlTestColumn("name"),
+		eof,
+		[]byte{5, 'a', 'l', 'i', 'c', 'e'},
+		[]byte{0xfb},
+		eof)
+	assert.Equal(t, int64(2), command.RowsAffected)
+	assert.Equal(t, "name\nalice\nNULL", command.Response)
+
+	command = query("SELECT * FROM missing", append([]byte{0xff, 0x7a, 0x04, '#', '4', '2', 'S', '0', '2'}, "Table 'db.missing' doesn't exist"...))
+	assert.Equal(t, "failed", command.Status)
+	assert.Equal(t, "ERROR 1146 (42S02): Table 'db.missing' doesn't exist", command.Response)
+}
+
+func TestShellSession_CompletesAtPrompt(t *testing.T) {
+	completed := make(chan *commandReply, 1)
+	shell := &shellSession{complete: func(reply *commandReply) { completed <- reply }}
+
+	shell.track(newCommandReply(&domain.SessionCommand{Command: "echo $"}))
+	// The echoed command ends like a prompt but is not one
+	shell.Write([]byte("echo $"))
+	shell.Write([]byte("\r\n$\r\n"))
+	assert.Empty(t, completed)
+
+	shell.Write([]byte("\x1b[32muser@host\x1b[0m:~$ "))
+	require.Len(t, completed, 1)
+	assert.Equal(t, "$", string((<-completed).response))
+
+	// A command without a prompt completes when the next one is sent
+	first := newCommandReply(&domain.SessionCommand{Command: "top"})
+	shell.track(first)
+	shell.Write([]byte("top\r\nload average: 0.01\r\n"))
+	shell.track(newCommandReply(&domain.SessionCommand{Command: "q"}))
+	require.Len(t, completed, 1)
+	reply := <-completed
+	assert.Equal(t, "load average: 0.01", string(reply.response))
+	assert.False(t, reply.finishedAt.IsZero())
+}
+
+func pgTestRowDescription(names ...string) []byte {
+	body := []byte{0, byte(len(names))}
+	for _, name := range names {
+		body = append(body, name...)
+		body = append(body, 0)
+		body = append(body, make([]byte, 18)...)
+	}
+	return body
+}
+
+// pgTestDataRow builds a DataRow body; empty values are sent as NULL.
+func pgTestDataRow(values ...string) []byte {
+	body := []byte{0, byte(len(values))}
+	for _, value := range values {
+		if value == "" {
+			body = append(body, 0xff, 0xff, 0xff, 0xff)
+			continue
+		}
+		body = append(body, 0, 0, 0, byte(len(value)))
+		body = append(body, value...)
+	}
+	return body
+}
+
+func pgTestError(code, message string) []byte {
+	body := append([]byte{'S'}, "ERROR\x00"...)
+	body = append(body, 'C')
+	body = append(body, code+"\x00"...)
+	body = append(body, 'M')
+	body = append(body, message+"\x00"...)
+	return pgMessage('E', append(body, 0))
+}
+
+func mysqlTestPacket(seq byte, payload []byte) []byte {
+	return append([]byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}, payload...)
+}
+
+func mysqlTestColumn(name string) []byte {
+	var payload []byte
+	for _, field := range []string{"def", "db", "users", "users", name, name} {
+		payload = append(payload, byte(len(field)))
+		payload = append(payload, field...)
+	}
+	return append(payload, make([]byte, 13)...)
+}
+
+func TestProxyService_RedactsSecrets(t *testing.T) {
+	svc, _ := newTestProxyService(t, CommandHoldPolicy{})
+	ctx := context.Background()
+	proxy := &ProxyConnection{SessionID: "session-secrets", UserID: "user-1", ResourceID: "prod-db"}
+
+	recording, err := svc.sessionRecordingService.StartRecording(ctx, proxy.SessionID, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { svc.sessionRecordingService.DeleteRecording(ctx, recording.ID) })
+
+	command, allowed, _ := svc.inspectCommand(ctx, proxy, "ALTER USER app WITH PASSWORD 'hunter2'", "postgresql")
+	require.True(t, allowed)
+	assert.Equal(t, "ALTER USER app WITH PASSWORD '[REDACTED:sql_password]'", command.Command)
+
+	reply := newCommandReply(command)
+	reply.writeString("aws_secret_access_key = wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY")
+	svc.completeCommand(ctx, reply)
+
+	stored, err := svc.sessionCommandService.GetSessionCommands(ctx, proxy.SessionID)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.NotContains(t, stored[0].Command, "hunter2")
+	assert.Equal(t, "aws_secret_access_key = [REDACTED:secret_assignment]", stored[0].Response)
+
+	alerts, err := svc.securityAlertService.GetAlerts(ctx, proxy.SessionID)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	for _, alert := range alerts {
+		assert.Equal(t, "credential_exposure", alert.AlertType)
+		assert.Equal(t, "high", alert.Severity)
+		assert.NotContains(t, alert.RawData, "hunter2")
+	}
+
+	data, err := svc.sessionRecordingService.GetRecordingFile(ctx, recording.ID)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "[postgresql] [allowed] ALTER USER app WITH PASSWORD '[REDACTED:sql_password]'")
+	assert.NotContains(t, string(data), "hunter2")
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// CompressRecording rewrites a finished recording compressed with codec,
+// RecordingCodecGzip when empty, or RecordingCodecZstd. The file
+// is checked against its manifest first and is not touched if it fails;
+// the compressed file gets a new manifest that names the one it replaces.
+// Encrypted recordings are compressed before they are encrypted again.
+func (s *sessionRecordingService) CompressRecording(ctx context.Context, recordingID string, codec string) error {
+	if codec == "" {
+		codec = RecordingCodecGzip
+	}
+	extension, ok := recordingCodecExtensions[codec]
+	if !ok {
+		return fmt.Errorf("unknown compression codec %q", codec)
+	}
+
+	s.storageMu.Lock()
+	defer s.storageMu.Unlock()
+
+	recording, err := s.finishedRecording(recordingID)
+	if err != nil {
+		return err
+	}
+	if recording.Compressed {
+		return fmt.Errorf("recording %s is already compressed", recordingID)
+	}
+
+	store := s.storeFor(recording)
+	manifest, previous, err := readStoredManifest(ctx, store, manifestKey(recording.StorageKey))
+	if err != nil {
+		return fmt.Errorf("failed to read recording manifest: %w", err)
+	}
+	if err := s.checkRecordingFile(ctx, store, recording.StorageKey, manifest); err != nil {
+		return err
+	}
+
+	compressedKey := recording.StorageKey + extension
+	chain, err := s.compressRecordingFile(ctx, store, recording.StorageKey, compressedKey, codec)
+	if err != nil {
+		store.Delete(ctx, compressedKey)
+		return fmt.Errorf("failed to compress recording: %w", err)
+	}
+
+	sum := sha256.Sum256(previous)
+	manifest.Encrypted = len(s.options.MasterKey) > 0
+	manifest.Compressed = true
+	manifest.Codec = codec
+	manifest.Size = chain.size
+	manifest.Chunks = chain.digests
+	manifest.ChainHash = chainHash(recording.ID, chain.digests)
+	manifest.Previous = hex.EncodeToString(sum[:])
+	if err := signManifest(manifest, s.options.SigningKey); err != nil {
+		store.Delete(ctx, compressedKey)
+		return fmt.Errorf("failed to sign recording manifest: %w", err)
+	}
+	if err := writeManifest(ctx, store, manifestKey(compressedKey), manifest); err != nil {
+		store.Delete(ctx, compressedKey)
+		return fmt.Errorf("failed to write recording manifest: %w", err)
+	}
+
+	err = s.update(recordingID, func(stored *domain.SessionRecording) {
+		stored.RecordingPath = store.Location(compressedKey)
+		stored.ManifestPath = store.Location(manifestKey(compressedKey))
+		stored.StorageKey = compressedKey
+		stored.Size = chain.size
+		stored.Compressed = true
+		stored.Verification = nil
+	})
+	if err != nil {
+		s.removeObjects(ctx, store, compressedKey)
+		return fmt.Errorf("failed to store compressed recording: %w", err)
+	}
+	s.removeObjects(ctx, store, recording.StorageKey)
+
+	utils.Infof("Compressed recording %s with %s: %d -> %d bytes", recordingID, codec, recording.Size, chain.size)
+	return nil
+}
+
+// ArchiveRecording moves a finished recording and its manifest to the
+// archive store. The manifest stays valid, as the file is unchanged.
+func (s *sessionRecordingService) ArchiveRecording(ctx context.Context, recordingID string) error {
+	s.storageMu.Lock()
+	defer s.storageMu.Unlock()
+
+	recording, err := s.finishedRecording(recordingID)
+	if err != nil {
+		return err
+	}
+	if recording.Tier == domain.RecordingTierArchive {
+		return fmt.Errorf("recording %s is already archived", recordingID)
+	}
+
+	key := recording.StorageKey
+	hot, archive := s.options.Store, s.options.ArchiveStore
+	if err := copyRecordingObject(ctx, hot, key, archive, key); err != nil {
+		return fmt.Errorf("failed to archive recording: %w", err)
+	}
+	if err := copyRecordingObject(ctx, hot, manifestKey(key), archive, manifestKey(key)); err != nil {
+		archive.Delete(ctx, key)
+		return fmt.Errorf("failed to archive recording manifest: %w", err)
+	}
+
+	err = s.update(recordingID, func(stored *domain.SessionRecording) {
+		stored.RecordingPath = archive.Location(key)
+		stored.ManifestPath = archive.Location(manifestKey(key))
+		stored.Tier = domain.RecordingTierArchive
+	})
+	if err != nil {
+		s.removeObjects(ctx, archive, key)
+		return fmt.Errorf("failed to store archived recording: %w", err)
+	}
+	s.removeObjects(ctx, hot, key)
+
+	utils.Infof("Archived recording %s to %s", recordingID, archive.Location(key))
+	return nil
+}
+
+// finishedRecording returns a copy of a recording that has stopped and has
+// a manifest
+func (s *sessionRecordingService) finishedRecording(recordingID string) (*domain.SessionRecording, error) {
+	recording, active, err := s.snapshot(recordingID)
+	if err != nil {
+		return nil, err
+	}
+	if active {
+		return nil, fmt.Errorf("recording %s is still being written", recordingID)
+	}
+	if recording.ManifestPath == "" {
+		return nil, fmt.Errorf("recording %s has no manifest", recordingID)
+	}
+	return recording, nil
+}
+
+// update changes the stored record of a recording
+func (s *sessionRecordingService) update(recordingID string, change func(*domain.SessionRecording)) error {
+	stored, err := s.sessionRecordingRepo.FindByID(recordingID)
+	if err != nil {
+		return err
+	}
+	change(stored)
+	return s.sessionRecordingRepo.Update(stored)
+}
+
+// removeObjects deletes a recording file and its manifest that have been
+// replaced
+func (s *sessionRecordingService) removeObjects(ctx context.Context, store domain.RecordingStore, key string) {
+	for _, object := range []string{key, manifestKey(key)} {
+		if err := store.Delete(ctx, object); err != nil {
+			utils.Warnf("Failed to remove replaced recording object %s: %v", store.Location(object), err)
+		}
+	}
+}
+
+// checkRecordingFile fails unless a recording file matches its manifest
+func (s *sessionRecordingService) checkRecordingFile(ctx context.Context, store domain.RecordingStore, key string, manifest *domain.RecordingManifest) error {
+	file, err := store.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	verification, err := VerifyRecordingFile(file, manifest, signingKeys(s.options.SigningKey, s.options.TrustedKeys)...)
+	if err != nil {
+		return fmt.Errorf("failed to verify recording: %w", err)
+	}
+	if !verification.Valid {
+		return fmt.Errorf("recording failed verification: %s", verification.Details)
+	}
+	return nil
+}
+
+// compressRecordingFile writes the content of a recording file to dst
+// compressed with codec, and encrypted with a new data key if a master key
+// is configured. It returns the hashes of dst for its manifest.
+func (s *sessionRecordingService) compressRecordingFile(ctx context.Context, store domain.RecordingStore, src, dst, codec string) (*recordingChainWriter, error) {
+	in, err := store.Open(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	buffered := bufio.NewReader(in)
+	var reader io.Reader = buffered
+	if magic, _ := buffered.Peek(len(recordingMagic)); isEncryptedRecording(magic) {
+		if reader, err = newRecordingCipherReader(buffered, s.options.MasterKey, false); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := store.Create(ctx, dst)
+	if err != nil {
+		return nil, err
+	}
+	chain := newRecordingChainWriter(file)
+	var out io.WriteCloser = chain
+	if len(s.options.MasterKey) > 0 {
+		if out, err = newRecordingCipherWriter(chain, s.options.MasterKey); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	compressor, err := newRecordingCompressor(out, codec)
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+	if _, err := io.Copy(compressor, reader); err != nil {
+		compressor.Close()
+		out.Close()
+		return nil, err
+	}
+	if err := compressor.Close(); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codecs recordings are compressed with
+const (
+	RecordingCodecGzip = "gzip"
+	RecordingCodecZstd = "zstd"
+)
+
+// recordingCodecExtensions are the file extensions compressed recordings
+// get, by codec
+var recordingCodecExtensions = map[string]string{
+	RecordingCodecGzip: ".gz",
+	RecordingCodecZstd: ".zst",
+}
+
+// zstdMagic starts every zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// newRecordingCompressor returns a writer compressing into w with codec.
+// Closing it finishes the stream but leaves w open.
+func newRecordingCompressor(w io.Writer, codec string) (io.WriteCloser, error) {
+	switch codec {
+	case RecordingCodecGzip:
+		return gzip.NewWriter(w), nil
+	case RecordingCodecZstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("unknown compression codec %q", codec)
+}
+
+// newRecordingDecompressor returns a reader decompressing r, telling the
+// codec from the start of the stream. Recordings compressed before zstd was
+// supported are gzip.
+func newRecordingDecompressor(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	if magic, _ := buffered.Peek(len(zstdMagic)); bytes.Equal(magic, zstdMagic) {
+		decoder, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return gzip.NewReader(buffered)
+}
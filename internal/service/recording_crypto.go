@@ -0,0 +1,265 @@
+package service
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Encrypted recordings start with a header holding the recording's data key,
+// wrapped by the master key, followed by chunks sealed with AES-GCM:
+//
+//	magic (8) | master key ID (8) | wrapped data key (12 + 32 + 16) | nonce prefix (7)
+//	{ ciphertext length (4) | ciphertext }...
+//
+// A chunk's nonce is the prefix, the chunk number and a flag set on the last
+// chunk only, so chunks cannot be reordered and a cut-off file is noticed.
+// Every chunk is authenticated together with the header.
+const (
+	recordingKeySize    = 32
+	recordingPrefixSize = 7
+	// recordingChunkSize is the most plaintext sealed in one chunk
+	recordingChunkSize = 64 * 1024
+	// recordingSealInterval is the longest buffered data waits to be sealed
+	// while a session keeps writing
+	recordingSealInterval = time.Second
+)
+
+var recordingMagic = []byte("SECREC\x00\x01")
+
+var recordingHeaderSize = len(recordingMagic) + 8 + 12 + recordingKeySize + 16 + recordingPrefixSize
+
+// errRecordingTruncated reports an encrypted recording without its last chunk
+var errRecordingTruncated = errors.New("recording is truncated")
+
+// isEncryptedRecording reports whether data starts like an encrypted recording
+func isEncryptedRecording(data []byte) bool {
+	return bytes.HasPrefix(data, recordingMagic)
+}
+
+// recordingKeyID identifies a master key without revealing it
+func recordingKeyID(masterKey []byte) []byte {
+	sum := sha256.Sum256(masterKey)
+	return sum[:8]
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce builds the nonce of chunk number counter
+func chunkNonce(prefix []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[recordingPrefixSize:], counter)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// recordingCipherWriter encrypts a recording as it is written. Data is
+// sealed a chunk at a time, at the latest a second after it was written if
+// more follows, and when the writer is closed.
+type recordingCipherWriter struct {
+	w        io.WriteCloser
+	aead     cipher.AEAD
+	header   []byte
+	prefix   []byte
+	counter  uint32
+	buf      []byte
+	sealedAt time.Time
+}
+
+// newRecordingCipherWriter writes the header of a recording encrypted with a
+// fresh data key to w and returns a writer for its content.
+func newRecordingCipherWriter(w io.WriteCloser, masterKey []byte) (*recordingCipherWriter, error) {
+	wrap, err := newGCM(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+
+	dataKey := make([]byte, recordingKeySize)
+	wrapNonce := make([]byte, wrap.NonceSize())
+	prefix := make([]byte, recordingPrefixSize)
+	for _, b := range [][]byte{dataKey, wrapNonce, prefix} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	}
+
+	header := append([]byte(nil), recordingMagic...)
+	header = append(header, recordingKeyID(masterKey)...)
+	header = append(header, wrapNonce...)
+	header = wrap.Seal(header, wrapNonce, dataKey, header[:len(recordingMagic)+8])
+	header = append(header, prefix...)
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &recordingCipherWriter{w: w, aead: aead, header: header, prefix: prefix, sealedAt: time.Now()}, nil
+}
+
+func (c *recordingCipherWriter) Write(p []byte) (int, error) {
+	c.buf = append(c.buf, p...)
+	for len(c.buf) >= recordingChunkSize {
+		if err := c.seal(c.buf[:recordingChunkSize], false); err != nil {
+			return len(p), err
+		}
+		c.buf = c.buf[recordingChunkSize:]
+	}
+	if len(c.buf) > 0 && time.Since(c.sealedAt) >= recordingSealInterval {
+		if err := c.seal(c.buf, false); err != nil {
+			return len(p), err
+		}
+		c.buf = nil
+	}
+	return len(p), nil
+}
+
+func (c *recordingCipherWriter) seal(plaintext []byte, final bool) error {
+	sealed := c.aead.Seal(make([]byte, 4, 4+len(plaintext)+c.aead.Overhead()),
+		chunkNonce(c.prefix, c.counter, final), plaintext, c.header)
+	binary.BigEndian.PutUint32(sealed, uint32(len(sealed)-4))
+	c.counter++
+	c.sealedAt = time.Now()
+	_, err := c.w.Write(sealed)
+	return err
+}
+
+// Close seals the last chunk and closes the underlying writer
+func (c *recordingCipherWriter) Close() error {
+	err := c.seal(c.buf, true)
+	c.buf = nil
+	if closeErr := c.w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// recordingCipherReader decrypts a recording a chunk at a time. Unless
+// partial is set, a recording that ends before its last chunk fails with
+// errRecordingTruncated; recordings still being written have no last chunk
+// yet.
+type recordingCipherReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	counter uint32
+	partial bool
+	buf     []byte
+	done    bool
+}
+
+func newRecordingCipherReader(r io.Reader, masterKey []byte, partial bool) (*recordingCipherReader, error) {
+	if len(masterKey) == 0 {
+		return nil, errors.New("recording is encrypted and no master key is configured")
+	}
+
+	header := make([]byte, recordingHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil || !isEncryptedRecording(header) {
+		return nil, errors.New("recording is not encrypted or its header is damaged")
+	}
+	idEnd := len(recordingMagic) + 8
+	if !bytes.Equal(header[len(recordingMagic):idEnd], recordingKeyID(masterKey)) {
+		return nil, errors.New("recording was encrypted with a different master key")
+	}
+
+	wrap, err := newGCM(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	nonceEnd := idEnd + wrap.NonceSize()
+	keyEnd := recordingHeaderSize - recordingPrefixSize
+	dataKey, err := wrap.Open(nil, header[idEnd:nonceEnd], header[nonceEnd:keyEnd], header[:idEnd])
+	if err != nil {
+		return nil, errors.New("failed to unwrap recording key")
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recordingCipherReader{
+		r:       r,
+		aead:    aead,
+		header:  header,
+		prefix:  header[keyEnd:],
+		partial: partial,
+	}, nil
+}
+
+func (c *recordingCipherReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk into buf
+func (c *recordingCipherReader) next() error {
+	var length [4]byte
+	if _, err := io.ReadFull(c.r, length[:]); err != nil {
+		if err == io.EOF && c.partial {
+			c.done = true
+			return nil
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errRecordingTruncated
+		}
+		return err
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size < uint32(c.aead.Overhead()) || size > recordingChunkSize+uint32(c.aead.Overhead()) {
+		return errors.New("recording chunk has an invalid length")
+	}
+
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(c.r, sealed); err != nil {
+		if c.partial {
+			// The chunk is still being written
+			c.done = true
+			return nil
+		}
+		return errRecordingTruncated
+	}
+
+	plaintext, err := c.aead.Open(nil, chunkNonce(c.prefix, c.counter, false), sealed, c.header)
+	if err != nil {
+		plaintext, err = c.aead.Open(nil, chunkNonce(c.prefix, c.counter, true), sealed, c.header)
+		if err != nil {
+			return fmt.Errorf("recording chunk %d failed authentication", c.counter)
+		}
+		c.done = true
+		// Nothing may follow the last chunk
+		if n, _ := c.r.Read(length[:1]); n > 0 {
+			return errors.New("recording has data after its last chunk")
+		}
+	}
+	c.counter++
+	c.buf = plaintext
+	return nil
+}
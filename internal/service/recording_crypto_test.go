@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+)
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func testMasterKey(t *testing.T) []byte {
+	key := make([]byte, recordingKeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func TestRecordingCipher_RoundTrip(t *testing.T) {
+	masterKey := testMasterKey(t)
+	plaintext := bytes.Repeat([]byte("0123456789abcdef"), recordingChunkSize/8+3)
+
+	var file bytes.Buffer
+	w, err := newRecordingCipherWriter(nopWriteCloser{&file}, masterKey)
+	require.NoError(t, err)
+	for chunk := plaintext; len(chunk) > 0; chunk = chunk[min(len(chunk), 1000):] {
+		_, err := w.Write(chunk[:min(len(chunk), 1000)])
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	encrypted := file.Bytes()
+	assert.True(t, isEncryptedRecording(encrypted))
+	assert.NotContains(t, string(encrypted), "0123456789abcdef")
+
+	decrypt := func(data, key []byte, partial bool) ([]byte, error) {
+		r, err := newRecordingCipherReader(bytes.NewReader(data), key, partial)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+
+	decrypted, err := decrypt(encrypted, masterKey, false)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	t.Run("wrong master key", func(t *testing.T) {
+		_, err := decrypt(encrypted, testMasterKey(t), false)
+		assert.Error(t, err)
+	})
+
+	t.Run("tampered chunk", func(t *testing.T) {
+		tampered := append([]byte(nil), encrypted...)
+		tampered[len(tampered)-10] ^= 1
+		_, err := decrypt(tampered, masterKey, false)
+		assert.Error(t, err)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		// Dropping the last chunk leaves whole chunks only
+		last := recordingHeaderSize
+		for offset := last; offset < len(encrypted); {
+			last = offset
+			offset += 4 + int(binary.BigEndian.Uint32(encrypted[offset:]))
+		}
+		_, err := decrypt(encrypted[:last], masterKey, false)
+		assert.ErrorIs(t, err, errRecordingTruncated)
+
+		partial, err := decrypt(encrypted[:last], masterKey, true)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext[:len(partial)], partial)
+	})
+}
+
+func TestSessionRecordingService_Encrypted(t *testing.T) {
+	masterKey := testMasterKey(t)
+	svc := newTestSessionRecordingService(t, RecordingOptions{BasePath: t.TempDir(), MasterKey: masterKey})
+	ctx := context.Background()
+
+	recording, err := svc.StartRecording(ctx, "session-1", "")
+	require.NoError(t, err)
+	require.NoError(t, svc.WriteOutput(ctx, "session-1", []byte("uid=0(root) gid=0(root)\r\n")))
+
+	// A recording still being written can be read
+	_, err = svc.GetRecordingFile(ctx, recording.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, svc.StopRecording(ctx, "session-1"))
+
+	onDisk, err := os.ReadFile(recording.RecordingPath)
+	require.NoError(t, err)
+	assert.True(t, isEncryptedRecording(onDisk))
+	assert.NotContains(t, string(onDisk), "uid=0(root)")
+
+	data, err := svc.GetRecordingFile(ctx, recording.ID)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"version":2`)
+	assert.Contains(t, string(data), "uid=0(root)")
+
+	// Playback decrypts as well
+	var output string
+	require.NoError(t, svc.PlayRecording(ctx, recording.ID, domain.PlaybackOptions{Speed: 64}, func(frame *domain.PlaybackFrame) error {
+		if frame.Type == "o" {
+			output += frame.Data
+		}
+		return nil
+	}))
+	assert.Equal(t, "uid=0(root) gid=0(root)\r\n", output)
+}
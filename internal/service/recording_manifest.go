@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"secretary/alpha/internal/domain"
+)
+
+// recordingManifestVersion is the version of manifests written now
+const recordingManifestVersion = 1
+
+// recordingHashChunkSize is the size of the chunks a recording file is
+// hashed in
+const recordingHashChunkSize = 64 * 1024
+
+// manifestKey returns where the manifest of a recording file is kept
+func manifestKey(recordingKey string) string {
+	return recordingKey + ".manifest.json"
+}
+
+// recordingChainWriter hashes a recording file in chunks as it is written
+type recordingChainWriter struct {
+	w       io.WriteCloser
+	chunk   hash.Hash
+	pending int
+	size    int64
+	digests []string
+}
+
+func newRecordingChainWriter(w io.WriteCloser) *recordingChainWriter {
+	return &recordingChainWriter{w: w, chunk: sha256.New()}
+}
+
+func (c *recordingChainWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.add(p[:n])
+	return n, err
+}
+
+func (c *recordingChainWriter) add(p []byte) {
+	c.size += int64(len(p))
+	for len(p) > 0 {
+		n := min(len(p), recordingHashChunkSize-c.pending)
+		c.chunk.Write(p[:n])
+		c.pending += n
+		p = p[n:]
+		if c.pending == recordingHashChunkSize {
+			c.endChunk()
+		}
+	}
+}
+
+func (c *recordingChainWriter) endChunk() {
+	c.digests = append(c.digests, hex.EncodeToString(c.chunk.Sum(nil)))
+	c.chunk.Reset()
+	c.pending = 0
+}
+
+// Close hashes the last, partial chunk and closes the underlying writer
+func (c *recordingChainWriter) Close() error {
+	if c.pending > 0 {
+		c.endChunk()
+	}
+	return c.w.Close()
+}
+
+// chainHash links the chunk hashes of a recording in order: each link is the
+// SHA-256 of the previous link, the chunk number and the chunk's hash. The
+// first link starts from the recording ID.
+func chainHash(recordingID string, digests []string) string {
+	link := sha256.Sum256([]byte(recordingID))
+	for i, digest := range digests {
+		h := sha256.New()
+		h.Write(link[:])
+		binary.Write(h, binary.BigEndian, uint64(i))
+		h.Write([]byte(digest))
+		h.Sum(link[:0])
+	}
+	return hex.EncodeToString(link[:])
+}
+
+// manifestSigningBytes is what a manifest's signature covers: the manifest
+// as JSON, without the signature
+func manifestSigningBytes(manifest *domain.RecordingManifest) ([]byte, error) {
+	unsigned := *manifest
+	unsigned.Signature = ""
+	return json.Marshal(&unsigned)
+}
+
+// signManifest fills in the manifest's public key and signature
+func signManifest(manifest *domain.RecordingManifest, key ed25519.PrivateKey) error {
+	manifest.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	data, err := manifestSigningBytes(manifest)
+	if err != nil {
+		return err
+	}
+	manifest.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	return nil
+}
+
+// writeManifest stores a manifest next to its recording
+func writeManifest(ctx context.Context, store domain.RecordingStore, key string, manifest *domain.RecordingManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, key, append(data, '\n'))
+}
+
+// ReadRecordingManifest reads the manifest of a recording from a file
+func ReadRecordingManifest(path string) (*domain.RecordingManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseRecordingManifest(data)
+}
+
+func parseRecordingManifest(data []byte) (*domain.RecordingManifest, error) {
+	var manifest domain.RecordingManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid recording manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// VerifyRecordingFile checks a recording file, as stored, against its
+// manifest. The manifest must be signed by one of trustedKeys. The result tells
+// whether the file was modified, cut short, extended or had chunks
+// reordered since the manifest was signed.
+func VerifyRecordingFile(file io.Reader, manifest *domain.RecordingManifest, trustedKeys ...ed25519.PublicKey) (*domain.RecordingVerification, error) {
+	result := &domain.RecordingVerification{RecordingID: manifest.RecordingID, VerifiedAt: time.Now()}
+
+	// The manifest itself first: signed, by the right key, consistent
+	signature, sigErr := base64.StdEncoding.DecodeString(manifest.Signature)
+	trustedKey := trustedSigningKey(manifest.PublicKey, trustedKeys)
+	data, err := manifestSigningBytes(manifest)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case manifest.Signature == "":
+		return failVerification(result, domain.RecordingUnsigned, nil, "manifest is not signed"), nil
+	case trustedKey == nil:
+		return failVerification(result, domain.RecordingUntrustedKey, nil, "manifest was signed by an unknown key"), nil
+	case sigErr != nil || !ed25519.Verify(trustedKey, data, signature):
+		return failVerification(result, domain.RecordingInvalidSignature, nil, "manifest signature does not match its content"), nil
+	case manifest.ChunkSize <= 0 || chainHash(manifest.RecordingID, manifest.Chunks) != manifest.ChainHash:
+		return failVerification(result, domain.RecordingInvalidSignature, nil, "manifest hash chain is inconsistent"), nil
+	}
+
+	expected := make(map[string]bool, len(manifest.Chunks))
+	for _, digest := range manifest.Chunks {
+		expected[digest] = true
+	}
+
+	buf := make([]byte, manifest.ChunkSize)
+	var size int64
+	for i := 0; ; i++ {
+		n, err := io.ReadFull(file, buf)
+		if n == 0 {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		size += int64(n)
+
+		sum := sha256.Sum256(buf[:n])
+		digest := hex.EncodeToString(sum[:])
+		switch {
+		case i >= len(manifest.Chunks):
+			return failVerification(result, domain.RecordingExtended, &i, fmt.Sprintf("file has data after its last signed chunk (%d chunks signed)", len(manifest.Chunks))), nil
+		case digest == manifest.Chunks[i]:
+			continue
+		case expected[digest]:
+			return failVerification(result, domain.RecordingReordered, &i, fmt.Sprintf("chunk %d belongs elsewhere in the recording", i)), nil
+		case n < manifest.ChunkSize && size < manifest.Size:
+			return failVerification(result, domain.RecordingTruncated, &i, fmt.Sprintf("file ends after %d of %d bytes", size, manifest.Size)), nil
+		default:
+			return failVerification(result, domain.RecordingModified, &i, fmt.Sprintf("chunk %d does not match its signed hash", i)), nil
+		}
+	}
+
+	if size != manifest.Size {
+		chunk := int(size / int64(manifest.ChunkSize))
+		return failVerification(result, domain.RecordingTruncated, &chunk, fmt.Sprintf("file ends after %d of %d bytes", size, manifest.Size)), nil
+	}
+
+	result.Status = domain.RecordingVerified
+	result.Valid = true
+	result.Details = fmt.Sprintf("%d bytes in %d chunks match the signed manifest", size, len(manifest.Chunks))
+	return result, nil
+}
+
+// failVerification records why a recording failed verification
+func failVerification(result *domain.RecordingVerification, status string, chunk *int, details string) *domain.RecordingVerification {
+	result.Status = status
+	result.Valid = false
+	result.Chunk = chunk
+	result.Details = details
+	return result
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+)
+
+func TestSessionRecordingService_VerifyRecording(t *testing.T) {
+	for _, encrypted := range []bool{false, true} {
+		t.Run(fmt.Sprintf("encrypted=%v", encrypted), func(t *testing.T) {
+			options := RecordingOptions{BasePath: t.TempDir()}
+			if encrypted {
+				options.MasterKey = testMasterKey(t)
+			}
+			svc := newTestSessionRecordingService(t, options)
+			ctx := context.Background()
+
+			recording, err := svc.StartRecording(ctx, "session-1", "")
+			require.NoError(t, err)
+			for i := 0; i < 10000; i++ {
+				require.NoError(t, svc.WriteOutput(ctx, "session-1", []byte(fmt.Sprintf("line %d of the output\r\n", i))))
+			}
+
+			_, err = svc.VerifyRecording(ctx, recording.ID)
+			assert.Error(t, err, "a recording is verified once it stops")
+
+			require.NoError(t, svc.StopRecording(ctx, "session-1"))
+
+			manifest, err := svc.GetRecordingManifest(ctx, recording.ID)
+			require.NoError(t, err)
+			assert.Equal(t, encrypted, manifest.Encrypted)
+			assert.Greater(t, len(manifest.Chunks), 3)
+
+			verification, err := svc.VerifyRecording(ctx, recording.ID)
+			require.NoError(t, err)
+			assert.True(t, verification.Valid, verification.Details)
+			assert.Equal(t, domain.RecordingVerified, verification.Status)
+
+			stored, err := svc.GetRecordingByID(ctx, recording.ID)
+			require.NoError(t, err)
+			require.NotNil(t, stored.Verification)
+			assert.Equal(t, domain.RecordingVerified, stored.Verification.Status)
+
+			// Editing the file afterwards is noticed
+			data, err := os.ReadFile(recording.RecordingPath)
+			require.NoError(t, err)
+			data[recordingHashChunkSize+100] ^= 1
+			require.NoError(t, os.WriteFile(recording.RecordingPath, data, 0644))
+
+			verification, err = svc.VerifyRecording(ctx, recording.ID)
+			require.NoError(t, err)
+			assert.False(t, verification.Valid)
+			assert.Equal(t, domain.RecordingModified, verification.Status)
+			require.NotNil(t, verification.Chunk)
+			assert.Equal(t, 1, *verification.Chunk)
+
+			stored, err = svc.GetRecordingByID(ctx, recording.ID)
+			require.NoError(t, err)
+			assert.Equal(t, domain.RecordingModified, stored.Verification.Status)
+		})
+	}
+}
+
+func TestVerifyRecordingFile(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	publicKey := key.Public().(ed25519.PublicKey)
+
+	// Four full chunks and a partial one, all different
+	var original []byte
+	for i := 0; len(original) < 4*recordingHashChunkSize+1000; i++ {
+		original = append(original, fmt.Sprintf("event %d\n", i)...)
+	}
+	var file bytes.Buffer
+	chain := newRecordingChainWriter(nopWriteCloser{&file})
+	_, err = chain.Write(original)
+	require.NoError(t, err)
+	require.NoError(t, chain.Close())
+
+	manifest := &domain.RecordingManifest{
+		Version:     recordingManifestVersion,
+		RecordingID: "recording-1",
+		Size:        chain.size,
+		ChunkSize:   recordingHashChunkSize,
+		Chunks:      chain.digests,
+		ChainHash:   chainHash("recording-1", chain.digests),
+	}
+	require.NoError(t, signManifest(manifest, key))
+	require.Len(t, manifest.Chunks, 5)
+
+	chunk := func(i int) []byte {
+		return original[i*recordingHashChunkSize : min(len(original), (i+1)*recordingHashChunkSize)]
+	}
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		manifest func(m domain.RecordingManifest) *domain.RecordingManifest
+		key      ed25519.PublicKey
+		status   string
+		chunk    int
+	}{
+		{name: "unchanged", data: original, status: domain.RecordingVerified, chunk: -1},
+		{name: "modified", data: concat(chunk(0), chunk(1), []byte("x"), chunk(2)[1:], chunk(3), chunk(4)), status: domain.RecordingModified, chunk: 2},
+		{name: "truncated mid-chunk", data: original[:3*recordingHashChunkSize-10], status: domain.RecordingTruncated, chunk: 2},
+		{name: "truncated at chunk boundary", data: original[:3*recordingHashChunkSize], status: domain.RecordingTruncated, chunk: 3},
+		{name: "last chunk extended", data: concat(original, bytes.Repeat([]byte("x"), 100)), status: domain.RecordingModified, chunk: 4},
+		{name: "chunk repeated", data: concat(chunk(0), chunk(1), chunk(2), chunk(3), chunk(3), chunk(4)), status: domain.RecordingReordered, chunk: 4},
+		{
+			name: "chunk appended",
+			data: original,
+			manifest: func(m domain.RecordingManifest) *domain.RecordingManifest {
+				// Signed when the file ended after four chunks
+				m.Size = 4 * recordingHashChunkSize
+				m.Chunks = m.Chunks[:4]
+				m.ChainHash = chainHash(m.RecordingID, m.Chunks)
+				require.NoError(t, signManifest(&m, key))
+				return &m
+			},
+			status: domain.RecordingExtended,
+			chunk:  4,
+		},
+		{name: "reordered", data: concat(chunk(1), chunk(0), chunk(2), chunk(3), chunk(4)), status: domain.RecordingReordered, chunk: 0},
+		{
+			name: "manifest edited",
+			data: original[:recordingHashChunkSize],
+			manifest: func(m domain.RecordingManifest) *domain.RecordingManifest {
+				m.Size = recordingHashChunkSize
+				m.Chunks = m.Chunks[:1]
+				m.ChainHash = chainHash(m.RecordingID, m.Chunks)
+				return &m
+			},
+			status: domain.RecordingInvalidSignature,
+			chunk:  -1,
+		},
+		{
+			name: "unsigned",
+			data: original,
+			manifest: func(m domain.RecordingManifest) *domain.RecordingManifest {
+				m.Signature = ""
+				return &m
+			},
+			status: domain.RecordingUnsigned,
+			chunk:  -1,
+		},
+		{name: "other key", data: original, key: make(ed25519.PublicKey, ed25519.PublicKeySize), status: domain.RecordingUntrustedKey, chunk: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := manifest
+			if tt.manifest != nil {
+				m = tt.manifest(*manifest)
+			}
+			trusted := publicKey
+			if tt.key != nil {
+				trusted = tt.key
+			}
+
+			verification, err := VerifyRecordingFile(bytes.NewReader(tt.data), m, trusted)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, verification.Status, verification.Details)
+			assert.Equal(t, tt.status == domain.RecordingVerified, verification.Valid)
+			if tt.chunk < 0 {
+				assert.Nil(t, verification.Chunk)
+			} else if assert.NotNil(t, verification.Chunk) {
+				assert.Equal(t, tt.chunk, *verification.Chunk)
+			}
+		})
+	}
+}
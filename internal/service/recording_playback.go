@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"secretary/alpha/internal/domain"
+)
+
+// maxPlaybackSpeed bounds the speed multiplier of a playback
+const maxPlaybackSpeed = 64
+
+// PlayRecording plays an asciicast recording back with its original timing,
+// handing each event to emit as its time comes. It returns when the
+// recording ends, when emit fails or when ctx is done.
+func (s *sessionRecordingService) PlayRecording(ctx context.Context, recordingID string, options domain.PlaybackOptions, emit func(*domain.PlaybackFrame) error) error {
+	if options.Speed == 0 {
+		options.Speed = 1
+	}
+	if options.Speed < 0 || options.Speed > maxPlaybackSpeed {
+		return fmt.Errorf("speed must be between 0 and %d", maxPlaybackSpeed)
+	}
+	if options.Offset < 0 || options.IdleLimit < 0 {
+		return fmt.Errorf("offset and idle limit must not be negative")
+	}
+
+	data, err := s.GetRecordingFile(ctx, recordingID)
+	if err != nil {
+		return err
+	}
+	header, frames, err := parseAsciicast(data)
+	if err != nil {
+		return err
+	}
+
+	// Everything before the offset is sent at once: the latest terminal size,
+	// then the output that built up the screen
+	offset := options.Offset.Seconds()
+	size := fmt.Sprintf("%dx%d", header.Width, header.Height)
+	var screen strings.Builder
+	for len(frames) > 0 && frames[0].Time < offset {
+		switch frames[0].Type {
+		case "o":
+			screen.WriteString(frames[0].Data)
+		case "r":
+			size = frames[0].Data
+		}
+		frames = frames[1:]
+	}
+	if err := emit(&domain.PlaybackFrame{Time: offset, Type: "r", Data: size}); err != nil {
+		return err
+	}
+	if screen.Len() > 0 {
+		if err := emit(&domain.PlaybackFrame{Time: offset, Type: "o", Data: screen.String()}); err != nil {
+			return err
+		}
+	}
+
+	previous := offset
+	for _, frame := range frames {
+		wait := time.Duration((frame.Time - previous) * float64(time.Second))
+		if options.IdleLimit > 0 && wait > options.IdleLimit {
+			wait = options.IdleLimit
+		}
+		if err := s.sleep(ctx, time.Duration(float64(wait)/options.Speed)); err != nil {
+			return err
+		}
+		previous = frame.Time
+
+		if err := emit(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseAsciicast reads the header and events of an asciicast v2 file
+func parseAsciicast(data []byte) (*asciicastHeader, []*domain.PlaybackFrame, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		return nil, nil, fmt.Errorf("recording is empty")
+	}
+	var header asciicastHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Version != 2 {
+		return nil, nil, fmt.Errorf("recording is not an asciicast v2 file")
+	}
+
+	var frames []*domain.PlaybackFrame
+	for line := 2; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var event []json.RawMessage
+		frame := &domain.PlaybackFrame{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) != 3 ||
+			json.Unmarshal(event[0], &frame.Time) != nil ||
+			json.Unmarshal(event[1], &frame.Type) != nil ||
+			json.Unmarshal(event[2], &frame.Data) != nil {
+			return nil, nil, fmt.Errorf("invalid event on line %d of recording", line)
+		}
+		frames = append(frames, frame)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return &header, frames, nil
+}
+
+// sleepContext waits for d, or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"secretary/alpha/internal/domain"
+)
+
+// GetQueryLog returns the statements of a query-log recording in the order
+// they were sent. Statements are written as they complete, so pipelined
+// ones may be out of order in the file.
+func (s *sessionRecordingService) GetQueryLog(ctx context.Context, recordingID string) ([]*domain.QueryLogEntry, error) {
+	recording, active, err := s.snapshot(recordingID)
+	if err != nil {
+		return nil, err
+	}
+	if recording.Format != RecordingFormatQueryLog {
+		return nil, fmt.Errorf("recording %s is not a query log", recordingID)
+	}
+
+	data, err := s.GetRecordingFile(ctx, recordingID)
+	if err != nil {
+		return nil, err
+	}
+	// A recording still being written, or cut off by a crash, may end in
+	// part of a line
+	partial := active || recording.ManifestPath == ""
+	return parseQueryLog(data, partial)
+}
+
+// parseQueryLog reads the entries of a query log, ignoring an incomplete
+// last line if partial is set
+func parseQueryLog(data []byte, partial bool) ([]*domain.QueryLogEntry, error) {
+	entries := make([]*domain.QueryLogEntry, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		entry := &domain.QueryLogEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			if partial && !bytes.HasSuffix(data, []byte("\n")) && !scanner.Scan() {
+				break
+			}
+			return nil, fmt.Errorf("invalid query log entry on line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// recordingKeyPattern matches the keys recordings are stored under:
+// session_<session ID>_<recording ID>.<cast|txt|jsonl>, with .gz or .zst once
+// compressed
+var recordingKeyPattern = regexp.MustCompile(`^session_(.+)_([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\.(cast|txt|jsonl)(\.gz|\.zst)?$`)
+
+// ReconcileRecordings brings the stored records of recordings in line with
+// the recording files, such as after a crash or a restore from backup.
+// Files without a record are recorded again, from their manifest when they
+// have one, and records whose file is gone are reported. It returns the
+// number of recordings picked up.
+func (s *sessionRecordingService) ReconcileRecordings(ctx context.Context) (int, error) {
+	s.storageMu.Lock()
+	defer s.storageMu.Unlock()
+
+	records, err := s.sessionRecordingRepo.List(domain.RecordingFilter{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list recordings: %w", err)
+	}
+	known := make(map[string]bool, len(records))
+	for _, recording := range records {
+		known[recording.ID] = true
+	}
+
+	found := make(map[string]bool)
+	picked := 0
+	for _, tier := range []string{domain.RecordingTierHot, domain.RecordingTierArchive} {
+		store := s.storeFor(&domain.SessionRecording{Tier: tier})
+		objects, err := store.List(ctx, "")
+		if err != nil {
+			return picked, fmt.Errorf("failed to list %s recordings: %w", tier, err)
+		}
+		keys := make(map[string]bool, len(objects))
+		for _, object := range objects {
+			keys[object.Key] = true
+			found[store.Location(object.Key)] = true
+		}
+
+		for _, object := range objects {
+			match := recordingKeyPattern.FindStringSubmatch(object.Key)
+			if match == nil || known[match[2]] {
+				continue
+			}
+			recording := &domain.SessionRecording{
+				ID:            match[2],
+				SessionID:     match[1],
+				RecordingPath: store.Location(object.Key),
+				StorageKey:    object.Key,
+				Format:        recordingFormatOf("." + match[3]),
+				Size:          object.Size,
+				CreatedAt:     object.ModifiedAt,
+				Compressed:    match[4] != "",
+				Tier:          tier,
+			}
+			if keys[manifestKey(object.Key)] {
+				manifest, _, err := readStoredManifest(ctx, store, manifestKey(object.Key))
+				switch {
+				case err != nil:
+					utils.Warnf("Ignoring the manifest of orphaned recording %s: %v", recording.RecordingPath, err)
+				case manifest.RecordingID != recording.ID:
+					utils.Warnf("Ignoring the manifest of orphaned recording %s, which is for recording %s", recording.RecordingPath, manifest.RecordingID)
+				default:
+					recording.CreatedAt = manifest.CreatedAt
+					recording.Duration = int64(manifest.StoppedAt.Sub(manifest.CreatedAt).Seconds())
+					recording.ManifestPath = store.Location(manifestKey(object.Key))
+				}
+			}
+			s.attachSession(ctx, recording)
+
+			if err := s.sessionRecordingRepo.Create(recording); err != nil {
+				return picked, fmt.Errorf("failed to store recording %s: %w", recording.ID, err)
+			}
+			known[recording.ID] = true
+			picked++
+			utils.Infof("Picked up orphaned recording %s: %s", recording.ID, recording.RecordingPath)
+		}
+	}
+
+	for _, recording := range records {
+		if !found[s.storeFor(recording).Location(recording.StorageKey)] {
+			utils.Warnf("Recording %s is missing its file %s", recording.ID, recording.RecordingPath)
+		}
+	}
+	return picked, nil
+}
+
+// recordingFormatOf returns the format of recordings with a file extension
+func recordingFormatOf(extension string) string {
+	for format, ext := range recordingExtensions {
+		if ext == extension {
+			return format
+		}
+	}
+	return RecordingFormatText
+}
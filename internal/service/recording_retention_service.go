@@ -0,0 +1,369 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// RetentionOptions configures how long session recordings are kept. Policy
+// decides what happens to each recording; Run enforces it every Interval.
+type RetentionOptions struct {
+	Policy   domain.RetentionPolicy
+	Interval time.Duration
+}
+
+// DefaultRetentionPolicy compresses recordings after a week and keeps them
+// otherwise.
+func DefaultRetentionPolicy() domain.RetentionPolicy {
+	return domain.RetentionPolicy{
+		Rules: []domain.RetentionRule{
+			{Name: "default", CompressAfterDays: 7},
+		},
+	}
+}
+
+// DefaultRetentionOptions returns the options used when none are configured.
+func DefaultRetentionOptions() RetentionOptions {
+	return RetentionOptions{
+		Policy:   DefaultRetentionPolicy(),
+		Interval: time.Hour,
+	}
+}
+
+// Retention actions
+const (
+	retentionCompressed = "compressed"
+	retentionArchived   = "archived"
+	retentionDeleted    = "deleted"
+	retentionHeld       = "held"
+	retentionFailed     = "failed"
+)
+
+// Audit log actions written by retention
+const (
+	auditRecordingDeleted  = "recording_deleted"
+	auditLegalHoldPlaced   = "legal_hold_placed"
+	auditLegalHoldReleased = "legal_hold_released"
+)
+
+// retentionAuditUser is the user retention runs are audited as
+const retentionAuditUser = "system"
+
+// LoadRetentionPolicy reads a retention policy from a JSON file.
+func LoadRetentionPolicy(path string) (*domain.RetentionPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy domain.RetentionPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid retention policy: %w", err)
+	}
+	if err := ValidateRetentionPolicy(&policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ValidateRetentionPolicy checks that every rule is named, names a known
+// codec and does not delete recordings before it compresses or archives
+// them.
+func ValidateRetentionPolicy(policy *domain.RetentionPolicy) error {
+	for i, rule := range policy.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("retention rule %d has no name", i)
+		}
+		if rule.CompressAfterDays < 0 || rule.ArchiveAfterDays < 0 || rule.DeleteAfterDays < 0 {
+			return fmt.Errorf("retention rule %q has a negative number of days", rule.Name)
+		}
+		if _, ok := recordingCodecExtensions[rule.Codec]; rule.Codec != "" && !ok {
+			return fmt.Errorf("retention rule %q has an unknown codec %q", rule.Name, rule.Codec)
+		}
+		if rule.DeleteAfterDays > 0 &&
+			(rule.CompressAfterDays >= rule.DeleteAfterDays || rule.ArchiveAfterDays >= rule.DeleteAfterDays) {
+			return fmt.Errorf("retention rule %q deletes recordings before compressing or archiving them", rule.Name)
+		}
+	}
+	return nil
+}
+
+type recordingRetentionService struct {
+	// mu keeps runs from overlapping
+	mu                      sync.Mutex
+	options                 RetentionOptions
+	sessionRecordingService domain.SessionRecordingService
+	sessionService          domain.SessionService
+	resourceService         domain.ResourceService
+	legalHoldRepo           domain.LegalHoldRepository
+	auditLogService         domain.AuditLogService
+	now                     func() time.Time
+}
+
+func NewRecordingRetentionService(
+	sessionRecordingService domain.SessionRecordingService,
+	sessionService domain.SessionService,
+	resourceService domain.ResourceService,
+	legalHoldRepo domain.LegalHoldRepository,
+	auditLogService domain.AuditLogService,
+	options RetentionOptions,
+) domain.RecordingRetentionService {
+	defaults := DefaultRetentionOptions()
+	if options.Policy.Rules == nil {
+		options.Policy = defaults.Policy
+	}
+	if options.Interval <= 0 {
+		options.Interval = defaults.Interval
+	}
+	return &recordingRetentionService{
+		options:                 options,
+		sessionRecordingService: sessionRecordingService,
+		sessionService:          sessionService,
+		resourceService:         resourceService,
+		legalHoldRepo:           legalHoldRepo,
+		auditLogService:         auditLogService,
+		now:                     time.Now,
+	}
+}
+
+// Run enforces the retention policy now and then every interval until ctx
+// is done.
+func (s *recordingRetentionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Enforce(ctx); err != nil {
+			utils.Errorf("Failed to enforce recording retention: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Enforce applies the retention policy to every finished recording once.
+// Recordings are deleted once they expire unless their session is under a
+// legal hold, and every deletion is written to the audit log first.
+// Otherwise they are compressed and archived as they come due. Recordings
+// that never stopped, such as after a crash, have no manifest: they are
+// not rewritten, but are deleted once they expire.
+func (s *recordingRetentionService) Enforce(ctx context.Context) (*domain.RetentionReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &domain.RetentionReport{StartedAt: s.now(), Actions: []domain.RetentionAction{}}
+	recordings, err := s.sessionRecordingService.ListRecordings(ctx, domain.RecordingFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recordings: %w", err)
+	}
+
+	resources := make(map[string]*domain.Resource)
+	for _, recording := range recordings {
+		if s.sessionRecordingService.IsRecording(ctx, recording.ID) {
+			continue
+		}
+		resource := s.resourceFor(ctx, recording.SessionID, resources)
+		rule := s.ruleFor(resource)
+		if rule == nil {
+			continue
+		}
+
+		age := s.now().Sub(recording.CreatedAt)
+		due := func(days int) bool {
+			return days > 0 && age >= time.Duration(days)*24*time.Hour
+		}
+		action := func(name string, err error) {
+			entry := domain.RetentionAction{
+				RecordingID: recording.ID,
+				SessionID:   recording.SessionID,
+				Rule:        rule.Name,
+				Action:      name,
+			}
+			if err != nil {
+				entry.Action = retentionFailed
+				entry.Details = fmt.Sprintf("%s: %v", name, err)
+				utils.Errorf("Retention failed to %s recording %s: %v", name, recording.ID, err)
+			}
+			report.Actions = append(report.Actions, entry)
+		}
+
+		if due(rule.DeleteAfterDays) {
+			held, err := s.isHeld(recording.SessionID)
+			switch {
+			case err != nil:
+				action("delete", err)
+			case held:
+				action(retentionHeld, nil)
+			default:
+				err := s.deleteRecording(ctx, recording, resource, rule)
+				if err != nil {
+					action("delete", err)
+				} else {
+					action(retentionDeleted, nil)
+				}
+			}
+			continue
+		}
+		// Without a manifest, a recording cannot be checked before it is
+		// rewritten
+		if recording.ManifestPath == "" {
+			continue
+		}
+		if due(rule.CompressAfterDays) && !recording.Compressed {
+			if err := s.sessionRecordingService.CompressRecording(ctx, recording.ID, rule.Codec); err != nil {
+				action("compress", err)
+			} else {
+				action(retentionCompressed, nil)
+			}
+		}
+		if due(rule.ArchiveAfterDays) && recording.Tier != domain.RecordingTierArchive {
+			if err := s.sessionRecordingService.ArchiveRecording(ctx, recording.ID); err != nil {
+				action("archive", err)
+			} else {
+				action(retentionArchived, nil)
+			}
+		}
+	}
+
+	report.FinishedAt = s.now()
+	if len(report.Actions) > 0 {
+		utils.Infof("Recording retention took %d actions", len(report.Actions))
+	}
+	return report, nil
+}
+
+// deleteRecording deletes an expired recording, but only once the deletion
+// is in the audit log
+func (s *recordingRetentionService) deleteRecording(ctx context.Context, recording *domain.SessionRecording, resource *domain.Resource, rule *domain.RetentionRule) error {
+	details, err := json.Marshal(map[string]interface{}{
+		"recording_id": recording.ID,
+		"session_id":   recording.SessionID,
+		"rule":         rule.Name,
+		"created_at":   recording.CreatedAt,
+		"size":         recording.Size,
+	})
+	if err != nil {
+		return err
+	}
+	entry := &domain.AuditLog{
+		UserID:  retentionAuditUser,
+		Action:  auditRecordingDeleted,
+		Details: string(details),
+	}
+	if resource != nil {
+		entry.ResourceID = resource.ID
+	}
+	if err := s.auditLogService.Create(ctx, entry); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return s.sessionRecordingService.DeleteRecording(ctx, recording.ID)
+}
+
+// resourceFor looks up the resource a session connected to, caching
+// resources by ID. It returns nil when the session or resource is unknown.
+func (s *recordingRetentionService) resourceFor(ctx context.Context, sessionID string, cache map[string]*domain.Resource) *domain.Resource {
+	session, err := s.sessionService.GetByID(ctx, sessionID)
+	if err != nil || session.ResourceID == "" {
+		return nil
+	}
+	resource, cached := cache[session.ResourceID]
+	if !cached {
+		resource, err = s.resourceService.GetResource(ctx, session.ResourceID)
+		if err != nil {
+			resource = nil
+		}
+		cache[session.ResourceID] = resource
+	}
+	return resource
+}
+
+// ruleFor returns the first rule matching a resource. Recordings whose
+// resource is unknown only match rules that match any resource.
+func (s *recordingRetentionService) ruleFor(resource *domain.Resource) *domain.RetentionRule {
+	for i := range s.options.Policy.Rules {
+		rule := &s.options.Policy.Rules[i]
+		if resource == nil {
+			if len(rule.ResourceTypes) == 0 && len(rule.Sensitivities) == 0 {
+				return rule
+			}
+			continue
+		}
+		if (len(rule.ResourceTypes) == 0 || slices.Contains(rule.ResourceTypes, resource.Type)) &&
+			(len(rule.Sensitivities) == 0 || slices.Contains(rule.Sensitivities, resource.Sensitivity)) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (s *recordingRetentionService) isHeld(sessionID string) (bool, error) {
+	holds, err := s.legalHoldRepo.FindBySessionID(sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check legal holds: %w", err)
+	}
+	return len(holds) > 0, nil
+}
+
+func (s *recordingRetentionService) GetPolicy(ctx context.Context) *domain.RetentionPolicy {
+	policy := s.options.Policy
+	return &policy
+}
+
+// PlaceLegalHold keeps the recordings of a session from being deleted until
+// the hold is released.
+func (s *recordingRetentionService) PlaceLegalHold(ctx context.Context, hold *domain.LegalHold) error {
+	if hold.SessionID == "" {
+		return errors.New("session_id is required")
+	}
+	if hold.Reason == "" {
+		return errors.New("reason is required")
+	}
+	if err := s.legalHoldRepo.Create(hold); err != nil {
+		return fmt.Errorf("failed to create legal hold: %w", err)
+	}
+	s.audit(ctx, hold.CreatedBy, auditLegalHoldPlaced, hold)
+	utils.Infof("Legal hold %s placed on session %s", hold.ID, hold.SessionID)
+	return nil
+}
+
+// ReleaseLegalHold removes a legal hold; the session's recordings are
+// deleted by the next run if they have expired.
+func (s *recordingRetentionService) ReleaseLegalHold(ctx context.Context, id string, userID string) error {
+	hold, err := s.legalHoldRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.legalHoldRepo.Delete(id); err != nil {
+		return err
+	}
+	s.audit(ctx, userID, auditLegalHoldReleased, hold)
+	utils.Infof("Legal hold %s released on session %s", hold.ID, hold.SessionID)
+	return nil
+}
+
+func (s *recordingRetentionService) ListLegalHolds(ctx context.Context) ([]*domain.LegalHold, error) {
+	return s.legalHoldRepo.FindAll()
+}
+
+// audit records a change to a legal hold
+func (s *recordingRetentionService) audit(ctx context.Context, userID, action string, hold *domain.LegalHold) {
+	details, _ := json.Marshal(hold)
+	entry := &domain.AuditLog{UserID: userID, Action: action, Details: string(details)}
+	if resource := s.resourceFor(ctx, hold.SessionID, map[string]*domain.Resource{}); resource != nil {
+		entry.ResourceID = resource.ID
+	}
+	if err := s.auditLogService.Create(ctx, entry); err != nil {
+		utils.Errorf("Failed to write audit entry for legal hold %s: %v", hold.ID, err)
+	}
+}
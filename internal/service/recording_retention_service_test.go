@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+func TestRecordingRetentionService_Enforce(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	resources := NewResourceService(repository.NewResourceRepository(db))
+	sessions := NewSessionService(repository.NewSessionRepository(db))
+	auditLogs := NewAuditLogService(repository.NewAuditLogRepository(db), repository.NewAuditCheckpointRepository(db), AuditLogOptions{})
+	legalHolds := repository.NewLegalHoldRepository(db)
+
+	prod := &domain.Resource{Name: "prod-db", Type: "postgresql", Sensitivity: "critical"}
+	dev := &domain.Resource{Name: "dev-db", Type: "postgresql", Sensitivity: "low"}
+	require.NoError(t, resources.CreateResource(ctx, prod))
+	require.NoError(t, resources.CreateResource(ctx, dev))
+
+	basePath := t.TempDir()
+	recordings := NewSessionRecordingService(repository.NewSessionRecordingRepository(db), sessions, RecordingOptions{BasePath: basePath, MasterKey: testMasterKey(t)})
+	content := make(map[string]string)
+	recorded := make(map[string]*domain.SessionRecording)
+	for sessionID, resource := range map[string]*domain.Resource{"s-prod": prod, "s-dev": dev, "s-held": dev} {
+		require.NoError(t, sessions.Create(ctx, &domain.Session{
+			ID:         sessionID,
+			UserID:     "alice",
+			ResourceID: resource.ID,
+			StartTime:  time.Now(),
+			Status:     "completed",
+			ClientIP:   "127.0.0.1",
+		}))
+		recording, err := recordings.StartRecording(ctx, sessionID, "")
+		require.NoError(t, err)
+		for i := 0; i < 2000; i++ {
+			require.NoError(t, recordings.WriteOutput(ctx, sessionID, []byte(fmt.Sprintf("%s row %d\r\n", sessionID, i))))
+		}
+		require.NoError(t, recordings.StopRecording(ctx, sessionID))
+		data, err := recordings.GetRecordingFile(ctx, recording.ID)
+		require.NoError(t, err)
+		content[sessionID] = string(data)
+		recorded[sessionID] = recording
+	}
+
+	svc := NewRecordingRetentionService(recordings, sessions, resources, legalHolds, auditLogs, RetentionOptions{
+		Policy: domain.RetentionPolicy{Rules: []domain.RetentionRule{
+			{Name: "critical", Sensitivities: []string{"critical"}, CompressAfterDays: 1, Codec: RecordingCodecZstd, ArchiveAfterDays: 2},
+			{Name: "default", CompressAfterDays: 1, DeleteAfterDays: 3},
+		}},
+	}).(*recordingRetentionService)
+
+	hold := &domain.LegalHold{SessionID: "s-held", Reason: "investigation", CreatedBy: "bob"}
+	require.NoError(t, svc.PlaceLegalHold(ctx, hold))
+
+	actions := func(report *domain.RetentionReport) map[string][]string {
+		result := make(map[string][]string)
+		for _, action := range report.Actions {
+			result[action.SessionID] = append(result[action.SessionID], action.Action)
+		}
+		return result
+	}
+
+	// Nothing is due yet
+	report, err := svc.Enforce(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Actions)
+
+	// After a day, everything is compressed
+	svc.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	report, err = svc.Enforce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"s-prod": {"compressed"},
+		"s-dev":  {"compressed"},
+		"s-held": {"compressed"},
+	}, actions(report))
+
+	compressed, err := recordings.GetRecordingByID(ctx, recorded["s-prod"].ID)
+	require.NoError(t, err)
+	assert.True(t, compressed.Compressed)
+	assert.Less(t, compressed.Size, int64(len(content["s-prod"]))/2)
+	assert.Equal(t, recorded["s-prod"].RecordingPath+".zst", compressed.RecordingPath)
+	_, err = os.Stat(recorded["s-prod"].RecordingPath)
+	assert.True(t, os.IsNotExist(err), "the uncompressed file is removed")
+
+	data, err := recordings.GetRecordingFile(ctx, compressed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, content["s-prod"], string(data))
+
+	manifest, err := recordings.GetRecordingManifest(ctx, compressed.ID)
+	require.NoError(t, err)
+	assert.True(t, manifest.Compressed)
+	assert.Equal(t, RecordingCodecZstd, manifest.Codec)
+	assert.NotEmpty(t, manifest.Previous)
+	verification, err := recordings.VerifyRecording(ctx, compressed.ID)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid, verification.Details)
+
+	gzipped, err := recordings.GetRecordingManifest(ctx, recorded["s-dev"].ID)
+	require.NoError(t, err)
+	assert.Equal(t, RecordingCodecGzip, gzipped.Codec)
+	data, err = recordings.GetRecordingFile(ctx, recorded["s-dev"].ID)
+	require.NoError(t, err)
+	assert.Equal(t, content["s-dev"], string(data))
+
+	// After three days, critical recordings are archived and the others
+	// deleted, except those on hold
+	svc.now = func() time.Time { return time.Now().Add(73 * time.Hour) }
+	report, err = svc.Enforce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"s-prod": {"archived"},
+		"s-dev":  {"deleted"},
+		"s-held": {"held"},
+	}, actions(report))
+
+	archived, err := recordings.GetRecordingByID(ctx, recorded["s-prod"].ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RecordingTierArchive, archived.Tier)
+	assert.Equal(t, filepath.Join(basePath, "archive"), filepath.Dir(archived.RecordingPath))
+	verification, err = recordings.VerifyRecording(ctx, archived.ID)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid, verification.Details)
+
+	_, err = recordings.GetRecordingByID(ctx, recorded["s-dev"].ID)
+	assert.Error(t, err)
+
+	deletions, err := auditLogs.GetByAction(ctx, auditRecordingDeleted)
+	require.NoError(t, err)
+	require.Len(t, deletions, 1)
+	assert.Equal(t, retentionAuditUser, deletions[0].UserID)
+	assert.Equal(t, dev.ID, deletions[0].ResourceID)
+	assert.True(t, strings.Contains(deletions[0].Details, recorded["s-dev"].ID))
+
+	// Once released, the held recording goes too
+	require.NoError(t, svc.ReleaseLegalHold(ctx, hold.ID, "bob"))
+	report, err = svc.Enforce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"s-held": {"deleted"}}, actions(report))
+
+	for _, action := range []string{auditLegalHoldPlaced, auditLegalHoldReleased} {
+		entries, err := auditLogs.GetByAction(ctx, action)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, action)
+		assert.Equal(t, "bob", entries[0].UserID)
+	}
+	deletions, err = auditLogs.GetByAction(ctx, auditRecordingDeleted)
+	require.NoError(t, err)
+	assert.Len(t, deletions, 2)
+}
+
+func TestRecordingRetentionService_ExpiresRecordingsWithoutManifest(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	sessions := NewSessionService(repository.NewSessionRepository(db))
+	auditLogs := NewAuditLogService(repository.NewAuditLogRepository(db), repository.NewAuditCheckpointRepository(db), AuditLogOptions{})
+	repo := repository.NewSessionRecordingRepository(db)
+	basePath := t.TempDir()
+
+	// The server crashes while the session is recorded, so the recording
+	// never gets a manifest
+	crashed, err := NewSessionRecordingService(repo, sessions, RecordingOptions{BasePath: basePath}).StartRecording(ctx, "s-crashed", "")
+	require.NoError(t, err)
+
+	recordings := NewSessionRecordingService(repo, sessions, RecordingOptions{BasePath: basePath})
+	live, err := recordings.StartRecording(ctx, "s-live", "")
+	require.NoError(t, err)
+
+	svc := NewRecordingRetentionService(recordings, sessions, NewResourceService(repository.NewResourceRepository(db)),
+		repository.NewLegalHoldRepository(db), auditLogs, RetentionOptions{
+			Policy: domain.RetentionPolicy{Rules: []domain.RetentionRule{{Name: "default", CompressAfterDays: 1, DeleteAfterDays: 3}}},
+		}).(*recordingRetentionService)
+
+	// Not yet expired, it is not compressed either
+	svc.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	report, err := svc.Enforce(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Actions)
+
+	svc.now = func() time.Time { return time.Now().Add(73 * time.Hour) }
+	report, err = svc.Enforce(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Actions, 1)
+	assert.Equal(t, crashed.ID, report.Actions[0].RecordingID)
+	assert.Equal(t, retentionDeleted, report.Actions[0].Action)
+
+	_, err = recordings.GetRecordingByID(ctx, crashed.ID)
+	assert.Error(t, err)
+	_, err = os.Stat(crashed.RecordingPath)
+	assert.True(t, os.IsNotExist(err))
+
+	// The recording still being written is left alone
+	assert.True(t, recordings.IsRecording(ctx, live.ID))
+	_, err = recordings.GetRecordingByID(ctx, live.ID)
+	assert.NoError(t, err)
+}
+
+func TestRecordingRetentionService_CompressRefusesTamperedRecording(t *testing.T) {
+	recordings := newTestSessionRecordingService(t, RecordingOptions{BasePath: t.TempDir()})
+	ctx := context.Background()
+
+	recording, err := recordings.StartRecording(ctx, "session-1", "")
+	require.NoError(t, err)
+	require.NoError(t, recordings.WriteOutput(ctx, "session-1", []byte("SELECT 1;\r\n")))
+	require.NoError(t, recordings.StopRecording(ctx, "session-1"))
+
+	data, err := os.ReadFile(recording.RecordingPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(recording.RecordingPath, append(data, "forged\n"...), 0644))
+
+	assert.Error(t, recordings.CompressRecording(ctx, recording.ID, RecordingCodecGzip))
+	stored, err := recordings.GetRecordingByID(ctx, recording.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.Compressed)
+	_, err = os.Stat(recording.RecordingPath + ".gz")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestValidateRetentionPolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  domain.RetentionRule
+		valid bool
+	}{
+		{"keeps forever", domain.RetentionRule{Name: "r", CompressAfterDays: 7}, true},
+		{"full lifecycle", domain.RetentionRule{Name: "r", CompressAfterDays: 7, ArchiveAfterDays: 30, DeleteAfterDays: 365}, true},
+		{"unnamed", domain.RetentionRule{DeleteAfterDays: 30}, false},
+		{"negative", domain.RetentionRule{Name: "r", DeleteAfterDays: -1}, false},
+		{"zstd", domain.RetentionRule{Name: "r", CompressAfterDays: 7, Codec: RecordingCodecZstd}, true},
+		{"unknown codec", domain.RetentionRule{Name: "r", CompressAfterDays: 7, Codec: "lz4"}, false},
+		{"deletes before archiving", domain.RetentionRule{Name: "r", ArchiveAfterDays: 30, DeleteAfterDays: 30}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRetentionPolicy(&domain.RetentionPolicy{Rules: []domain.RetentionRule{tt.rule}})
+			assert.Equal(t, tt.valid, err == nil, err)
+		})
+	}
+}
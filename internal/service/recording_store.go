@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"secretary/alpha/internal/domain"
+)
+
+// localRecordingStore keeps recordings as files under a directory
+type localRecordingStore struct {
+	root string
+}
+
+// NewLocalRecordingStore returns a store keeping recordings under root. The
+// directory is created as recordings are written.
+func NewLocalRecordingStore(root string) domain.RecordingStore {
+	return &localRecordingStore{root: root}
+}
+
+// path returns the file of a key, refusing keys that leave the store
+func (s *localRecordingStore) path(key string) (string, error) {
+	name := filepath.FromSlash(key)
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid recording key %q", key)
+	}
+	return filepath.Join(s.root, name), nil
+}
+
+func (s *localRecordingStore) Create(ctx context.Context, key string) (io.WriteCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+}
+
+func (s *localRecordingStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *localRecordingStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func (s *localRecordingStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *localRecordingStore) List(ctx context.Context, prefix string) ([]domain.RecordingObject, error) {
+	var objects []domain.RecordingObject
+	err := filepath.WalkDir(s.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == s.root {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(name)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, domain.RecordingObject{Key: key, Size: info.Size(), ModifiedAt: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
+
+func (s *localRecordingStore) Location(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+// readStoredManifest reads the manifest of a recording from a store
+func readStoredManifest(ctx context.Context, store domain.RecordingStore, key string) (*domain.RecordingManifest, []byte, error) {
+	file, err := store.Open(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := parseRecordingManifest(data)
+	return manifest, data, err
+}
+
+// copyRecordingObject copies an object from one store to another
+func copyRecordingObject(ctx context.Context, from domain.RecordingStore, fromKey string, to domain.RecordingStore, toKey string) error {
+	in, err := from.Open(ctx, fromKey)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := to.Create(ctx, toKey)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		to.Delete(ctx, toKey)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		to.Delete(ctx, toKey)
+		return err
+	}
+	return nil
+}
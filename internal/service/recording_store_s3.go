@@ -0,0 +1,678 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// S3StoreOptions configures a recording store on S3-compatible object
+// storage, such as AWS S3 or MinIO. Objects are addressed path-style, as
+// Endpoint/Bucket/Prefix+key. Recordings are uploaded in parts of PartSize
+// bytes (at least 5 MiB) while they are written. With SpoolPath, every
+// upload is also written to a file there until it completes, so that the
+// bytes not uploaded yet survive a crash or a failed part. Without it they
+// are only buffered in memory, and a crash loses the part being filled and
+// any still queued.
+type S3StoreOptions struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	PartSize  int
+	SpoolPath string
+	Client    *http.Client
+}
+
+// s3MinPartSize is the smallest part S3 accepts, except for the last one
+const s3MinPartSize = 5 * 1024 * 1024
+
+// s3UploadQueue bounds the parts waiting to be uploaded; writers block
+// when it is full
+const s3UploadQueue = 4
+
+// s3PartAttempts is how often a part upload is tried before the upload
+// fails
+const s3PartAttempts = 3
+
+// s3RetryDelay is the wait before retrying a part, growing with every
+// attempt
+var s3RetryDelay = time.Second
+
+type s3RecordingStore struct {
+	options  S3StoreOptions
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3RecordingStore returns a store keeping recordings in an S3 bucket.
+func NewS3RecordingStore(options S3StoreOptions) (domain.RecordingStore, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(options.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", options.Endpoint)
+	}
+	if options.Bucket == "" {
+		return nil, errors.New("S3 bucket is required")
+	}
+	if options.AccessKey == "" || options.SecretKey == "" {
+		return nil, errors.New("S3 access key and secret key are required")
+	}
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+	if options.PartSize < s3MinPartSize {
+		options.PartSize = s3MinPartSize
+	}
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	return &s3RecordingStore{
+		options:  options,
+		endpoint: endpoint,
+		client:   options.Client,
+		now:      time.Now,
+	}, nil
+}
+
+// Create starts a multipart upload. Parts are uploaded in the background
+// as they fill up; closing the writer uploads the rest and completes the
+// object.
+func (s *s3RecordingStore) Create(ctx context.Context, key string) (io.WriteCloser, error) {
+	// Uploads outlive the request that started the recording
+	ctx = context.WithoutCancel(ctx)
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := s.call(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, &result); err != nil {
+		return nil, err
+	}
+
+	w := &s3UploadWriter{
+		store:    s,
+		ctx:      ctx,
+		key:      key,
+		uploadID: result.UploadID,
+		queue:    make(chan s3Part, s3UploadQueue),
+		done:     make(chan struct{}),
+	}
+	if s.options.SpoolPath != "" {
+		spool, err := s.createSpool(key, result.UploadID)
+		if err != nil {
+			s.call(ctx, http.MethodDelete, key, url.Values{"uploadId": {result.UploadID}}, nil, nil)
+			return nil, fmt.Errorf("failed to spool %s: %w", key, err)
+		}
+		w.spool = spool
+	}
+	go w.upload()
+	return w, nil
+}
+
+// spoolPath is the file an upload is spooled to
+func (s *s3RecordingStore) spoolPath(key, uploadID string) string {
+	sum := sha256.Sum256([]byte(s.options.Bucket + "/" + s.options.Prefix + key + "\x00" + uploadID))
+	return filepath.Join(s.options.SpoolPath, hex.EncodeToString(sum[:])+".spool")
+}
+
+func (s *s3RecordingStore) createSpool(key, uploadID string) (*os.File, error) {
+	if err := os.MkdirAll(s.options.SpoolPath, 0700); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(s.spoolPath(key, uploadID), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+}
+
+func (s *s3RecordingStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, s3Error(resp, http.MethodGet, key)
+	}
+	return resp.Body, nil
+}
+
+func (s *s3RecordingStore) Put(ctx context.Context, key string, data []byte) error {
+	return s.call(ctx, http.MethodPut, key, nil, data, nil)
+}
+
+func (s *s3RecordingStore) Delete(ctx context.Context, key string) error {
+	err := s.call(ctx, http.MethodDelete, key, nil, nil, nil)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *s3RecordingStore) List(ctx context.Context, prefix string) ([]domain.RecordingObject, error) {
+	var objects []domain.RecordingObject
+	query := url.Values{"list-type": {"2"}, "prefix": {s.options.Prefix + prefix}}
+	for {
+		var listing struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		if err := s.call(ctx, http.MethodGet, "", query, nil, &listing); err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		for _, object := range listing.Contents {
+			objects = append(objects, domain.RecordingObject{
+				Key:        strings.TrimPrefix(object.Key, s.options.Prefix),
+				Size:       object.Size,
+				ModifiedAt: object.LastModified,
+			})
+		}
+		if !listing.IsTruncated || listing.NextContinuationToken == "" {
+			return objects, nil
+		}
+		query.Set("continuation-token", listing.NextContinuationToken)
+	}
+}
+
+func (s *s3RecordingStore) Location(key string) string {
+	return "s3://" + s.options.Bucket + "/" + s.options.Prefix + key
+}
+
+// RecoverUploads completes the multipart uploads under the store's prefix
+// that were left behind, such as by a crash while recordings were being
+// written, so that what was written becomes a readable object. Uploads
+// with a spool file are finished from it; others keep the parts uploaded
+// so far. It returns the keys of the recovered objects.
+func (s *s3RecordingStore) RecoverUploads(ctx context.Context) ([]string, error) {
+	var uploads struct {
+		Uploads []struct {
+			Key      string `xml:"Key"`
+			UploadID string `xml:"UploadId"`
+		} `xml:"Upload"`
+	}
+	query := url.Values{"uploads": {""}, "prefix": {s.options.Prefix}}
+	if err := s.call(ctx, http.MethodGet, "", query, nil, &uploads); err != nil {
+		return nil, fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	var recovered []string
+	for _, upload := range uploads.Uploads {
+		key := strings.TrimPrefix(upload.Key, s.options.Prefix)
+		if s.options.SpoolPath != "" {
+			spool := s.spoolPath(key, upload.UploadID)
+			if _, err := os.Stat(spool); err == nil {
+				if err := s.resume(ctx, key, upload.UploadID, spool); err != nil {
+					return recovered, err
+				}
+				os.Remove(spool)
+				recovered = append(recovered, key)
+				continue
+			}
+		}
+
+		parts, err := s.listParts(ctx, key, upload.UploadID)
+		if err != nil {
+			return recovered, err
+		}
+		if len(parts) == 0 {
+			s.call(ctx, http.MethodDelete, key, url.Values{"uploadId": {upload.UploadID}}, nil, nil)
+			continue
+		}
+		completed := make([]s3CompletedPart, 0, len(parts))
+		for _, part := range parts {
+			completed = append(completed, s3CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag})
+		}
+		if err := s.complete(ctx, key, upload.UploadID, completed); err != nil {
+			return recovered, err
+		}
+		recovered = append(recovered, key)
+	}
+	return recovered, nil
+}
+
+// resume finishes an upload from its spool file, which holds the whole
+// object. The parts already uploaded in order are kept and the rest of the
+// spool is uploaded after them.
+func (s *s3RecordingStore) resume(ctx context.Context, key, uploadID, spoolPath string) error {
+	listed, err := s.listParts(ctx, key, uploadID)
+	if err != nil {
+		return err
+	}
+	var parts []s3CompletedPart
+	var offset int64
+	for i, part := range listed {
+		if part.PartNumber != i+1 {
+			break
+		}
+		parts = append(parts, s3CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag})
+		offset += part.Size
+	}
+
+	spool, err := os.Open(spoolPath)
+	if err != nil {
+		return err
+	}
+	defer spool.Close()
+	if _, err := spool.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	data := make([]byte, s.options.PartSize)
+	for {
+		n, err := io.ReadFull(spool, data)
+		if n > 0 || len(parts) == 0 {
+			etag, uploadErr := s.uploadPart(ctx, key, uploadID, len(parts)+1, data[:n])
+			if uploadErr != nil {
+				return fmt.Errorf("failed to upload part %d of %s: %w", len(parts)+1, key, uploadErr)
+			}
+			parts = append(parts, s3CompletedPart{PartNumber: len(parts) + 1, ETag: etag})
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read spool of %s: %w", key, err)
+		}
+	}
+	return s.complete(ctx, key, uploadID, parts)
+}
+
+// s3ListedPart is a part of an upload in progress
+type s3ListedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+	Size       int64  `xml:"Size"`
+}
+
+// listParts returns the parts uploaded so far, in part number order
+func (s *s3RecordingStore) listParts(ctx context.Context, key, uploadID string) ([]s3ListedPart, error) {
+	var parts []s3ListedPart
+	query := url.Values{"uploadId": {uploadID}}
+	for {
+		var listing struct {
+			Parts                []s3ListedPart `xml:"Part"`
+			IsTruncated          bool           `xml:"IsTruncated"`
+			NextPartNumberMarker int            `xml:"NextPartNumberMarker"`
+		}
+		if err := s.call(ctx, http.MethodGet, key, query, nil, &listing); err != nil {
+			return nil, fmt.Errorf("failed to list parts of %s: %w", key, err)
+		}
+		parts = append(parts, listing.Parts...)
+		if !listing.IsTruncated || listing.NextPartNumberMarker == 0 {
+			sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+			return parts, nil
+		}
+		query.Set("part-number-marker", strconv.Itoa(listing.NextPartNumberMarker))
+	}
+}
+
+// RecordingUploadRecoverer is implemented by stores whose uploads can be
+// interrupted, to make what was uploaded readable after a restart
+type RecordingUploadRecoverer interface {
+	RecoverUploads(ctx context.Context) ([]string, error)
+}
+
+type s3CompletedPart struct {
+	XMLName    xml.Name `xml:"Part"`
+	PartNumber int      `xml:"PartNumber"`
+	ETag       string   `xml:"ETag"`
+}
+
+func (s *s3RecordingStore) complete(ctx context.Context, key, uploadID string, parts []s3CompletedPart) error {
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	if err := s.call(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body, nil); err != nil {
+		return fmt.Errorf("failed to complete upload of %s: %w", key, err)
+	}
+	return nil
+}
+
+// call sends a request and decodes an XML reply into result, if given
+func (s *s3RecordingStore) call(ctx context.Context, method, key string, query url.Values, body []byte, result interface{}) error {
+	resp, err := s.do(ctx, method, key, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return s3Error(resp, method, key)
+	}
+	// Completing an upload can fail after the reply has started
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(data, []byte("<Error>")) {
+		return s3ErrorBody(data, method, key)
+	}
+	if result != nil {
+		if err := xml.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("invalid S3 reply to %s %s: %w", method, key, err)
+		}
+	}
+	return nil
+}
+
+// do sends a signed request for an object, or for the bucket if key is
+// empty
+func (s *s3RecordingStore) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	path := s.endpoint.EscapedPath() + "/" + s3Escape(s.options.Bucket, true)
+	if key != "" {
+		path += "/" + s3Escape(s.options.Prefix+key, false)
+	}
+	target := s.endpoint.Scheme + "://" + s.endpoint.Host + path
+	if len(query) > 0 {
+		target += "?" + s3CanonicalQuery(query)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	sum := sha256.Sum256(body)
+	signS3Request(req, hex.EncodeToString(sum[:]), s.options.AccessKey, s.options.SecretKey, s.options.Region, s.now())
+	return s.client.Do(req)
+}
+
+// s3Error turns an error reply into an error; a missing object wraps
+// fs.ErrNotExist
+func s3Error(resp *http.Response, method, key string) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("S3 %s %s: %w", method, key, fs.ErrNotExist)
+	}
+	if err := s3ErrorBody(data, method, key); err != nil {
+		return err
+	}
+	return fmt.Errorf("S3 %s %s: %s", method, key, resp.Status)
+}
+
+func s3ErrorBody(data []byte, method, key string) error {
+	var reply struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(data, &reply) != nil || reply.Code == "" {
+		return nil
+	}
+	if reply.Code == "NoSuchKey" {
+		return fmt.Errorf("S3 %s %s: %w", method, key, fs.ErrNotExist)
+	}
+	return fmt.Errorf("S3 %s %s: %s: %s", method, key, reply.Code, reply.Message)
+}
+
+// s3UploadWriter streams an object to S3 as a multipart upload. Bytes not
+// yet uploaded live in buf and queue, and in spool when the store spools.
+type s3UploadWriter struct {
+	store    *s3RecordingStore
+	ctx      context.Context
+	key      string
+	uploadID string
+	spool    *os.File
+	buf      []byte
+	number   int
+	queue    chan s3Part
+	done     chan struct{}
+	closed   bool
+
+	mu    sync.Mutex
+	err   error
+	parts []s3CompletedPart
+}
+
+type s3Part struct {
+	number int
+	data   []byte
+}
+
+// Write spools p and queues the parts it fills. Once a part has failed,
+// writes only go to the spool, which Close finishes the upload from;
+// without a spool they fail.
+func (w *s3UploadWriter) Write(p []byte) (int, error) {
+	if w.spool != nil {
+		if _, err := w.spool.Write(p); err != nil {
+			return 0, fmt.Errorf("failed to spool %s: %w", w.key, err)
+		}
+	}
+	if err := w.failed(); err != nil {
+		if w.spool != nil {
+			return len(p), nil
+		}
+		return 0, err
+	}
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= w.store.options.PartSize {
+		w.send(w.buf[:w.store.options.PartSize])
+		w.buf = w.buf[w.store.options.PartSize:]
+	}
+	return len(p), nil
+}
+
+func (w *s3UploadWriter) send(data []byte) {
+	w.number++
+	w.queue <- s3Part{number: w.number, data: bytes.Clone(data)}
+}
+
+// upload sends queued parts until the queue is closed. Parts after a
+// failed one are dropped, so that the parts uploaded stay in order.
+func (w *s3UploadWriter) upload() {
+	defer close(w.done)
+	for part := range w.queue {
+		if w.failed() != nil {
+			continue
+		}
+		etag, err := w.store.uploadPart(w.ctx, w.key, w.uploadID, part.number, part.data)
+
+		w.mu.Lock()
+		if err != nil {
+			w.err = fmt.Errorf("failed to upload part %d of %s: %w", part.number, w.key, err)
+		} else {
+			w.parts = append(w.parts, s3CompletedPart{PartNumber: part.number, ETag: etag})
+		}
+		w.mu.Unlock()
+	}
+}
+
+// uploadPart uploads one part, retrying a few times
+func (s *s3RecordingStore) uploadPart(ctx context.Context, key, uploadID string, number int, data []byte) (string, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	var err error
+	for attempt := 1; attempt <= s3PartAttempts; attempt++ {
+		var resp *http.Response
+		resp, err = s.do(ctx, http.MethodPut, key, query, data)
+		if err == nil {
+			if resp.StatusCode == http.StatusOK {
+				resp.Body.Close()
+				return resp.Header.Get("ETag"), nil
+			}
+			err = s3Error(resp, http.MethodPut, key)
+			resp.Body.Close()
+		}
+		if attempt < s3PartAttempts {
+			utils.Warnf("Retrying upload of part %d of %s: %v", number, key, err)
+			if sleepErr := sleepContext(ctx, s3RetryDelay*time.Duration(attempt)); sleepErr != nil {
+				return "", sleepErr
+			}
+		}
+	}
+	return "", err
+}
+
+func (w *s3UploadWriter) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close uploads the last part and completes the object. The upload is
+// never aborted: if a part failed, it is finished from the spool, or
+// completed with the parts uploaded so far when there is none. An upload
+// that still cannot be finished is left with its spool for RecoverUploads.
+func (w *s3UploadWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.failed() == nil && (len(w.buf) > 0 || w.number == 0) {
+		w.send(w.buf)
+	}
+	w.buf = nil
+	close(w.queue)
+	<-w.done
+
+	partErr := w.failed()
+	err := partErr
+	if partErr == nil {
+		if err = w.store.complete(w.ctx, w.key, w.uploadID, w.parts); err == nil {
+			w.removeSpool()
+			return nil
+		}
+	}
+
+	if w.spool != nil {
+		w.spool.Close()
+		if resumeErr := w.store.resume(w.ctx, w.key, w.uploadID, w.spool.Name()); resumeErr != nil {
+			return fmt.Errorf("%w; %s is left in %s for recovery: %v", err, w.key, w.spool.Name(), resumeErr)
+		}
+		utils.Warnf("Finished upload of %s from its spool after: %v", w.key, err)
+		os.Remove(w.spool.Name())
+		return nil
+	}
+	if partErr != nil && len(w.parts) > 0 {
+		if completeErr := w.store.complete(w.ctx, w.key, w.uploadID, w.parts); completeErr == nil {
+			return fmt.Errorf("%w; kept the first %d parts of %s", err, len(w.parts), w.key)
+		}
+	}
+	return err
+}
+
+func (w *s3UploadWriter) removeSpool() {
+	if w.spool == nil {
+		return
+	}
+	w.spool.Close()
+	os.Remove(w.spool.Name())
+}
+
+// signS3Request signs a request with AWS Signature Version 4. The host,
+// content hash and date are signed, with any x-amz-*, content-type,
+// content-md5 and range headers the request has.
+func signS3Request(req *http.Request, payloadHash, accessKey, secretKey, region string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" || name == "content-md5" || name == "range" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3CanonicalQuery encodes a query string the way it is signed: sorted by
+// name, with every name and value escaped
+func s3CanonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes everything but unreserved characters, and
+// slashes unless encodeSlash is set
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
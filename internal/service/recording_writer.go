@@ -0,0 +1,230 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"secretary/alpha/internal/domain"
+)
+
+// maxRecordingLine bounds the partial line a recording stream holds back.
+// Full-screen programs may not print a line break for a long time.
+const maxRecordingLine = 4096
+
+// terminalResize matches the xterm sequence that sets the window size in
+// characters: ESC [ 8 ; rows ; columns t
+var terminalResize = regexp.MustCompile(`\x1b\[8;(\d{1,4});(\d{1,4})t`)
+
+// recordingStream holds back one direction of terminal traffic until the end
+// of a line. Echoed input and slow output arrive a few bytes at a time, and
+// a secret can only be redacted once it has arrived in full.
+type recordingStream struct {
+	pending []byte
+}
+
+// write buffers p and returns the complete lines that can be recorded now.
+func (st *recordingStream) write(p []byte) []byte {
+	st.pending = append(st.pending, p...)
+
+	end := bytes.LastIndexAny(st.pending, "\r\n") + 1
+	if end > 0 && end == len(st.pending) && st.pending[end-1] == '\r' {
+		// A trailing carriage return is usually followed by a line feed
+		end = bytes.LastIndexAny(st.pending[:end-1], "\r\n") + 1
+	}
+	if end == 0 && len(st.pending) >= maxRecordingLine {
+		// Do not split a UTF-8 sequence
+		end = len(st.pending)
+		for i := 0; i < utf8.UTFMax && end > 0; i++ {
+			if r, size := utf8.DecodeLastRune(st.pending[:end]); r != utf8.RuneError || size != 1 {
+				break
+			}
+			end--
+		}
+		if end == 0 {
+			end = len(st.pending)
+		}
+	}
+	if end == 0 {
+		return nil
+	}
+
+	ready := append([]byte(nil), st.pending[:end]...)
+	st.pending = append(st.pending[:0], st.pending[end:]...)
+	return ready
+}
+
+// flush returns whatever is still held back.
+func (st *recordingStream) flush() []byte {
+	ready := st.pending
+	st.pending = nil
+	return ready
+}
+
+// activeRecording is a recording still being written. Callers serialize
+// access to it.
+type activeRecording struct {
+	recording *domain.SessionRecording
+	// out is the recording file, or an encrypting writer in front of it
+	out io.WriteCloser
+	// chain hashes the file as it is stored
+	chain    *recordingChainWriter
+	start    time.Time
+	detector SecretDetector
+	output   recordingStream
+	input    recordingStream
+}
+
+// asciicastHeader is the first line of an asciicast v2 file
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+func (a *activeRecording) writeHeader(width, height int) error {
+	if a.recording.Format != RecordingFormatAsciinema {
+		return nil
+	}
+	header, err := json.Marshal(asciicastHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: a.start.Unix(),
+		Title:     "Secretary session " + a.recording.SessionID,
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	})
+	if err != nil {
+		return err
+	}
+	return a.writeLine(header)
+}
+
+// writeStream records the complete lines of one direction of traffic,
+// redacted. Resize sequences in the output become resize events.
+func (a *activeRecording) writeStream(stream *recordingStream, kind string, data []byte) error {
+	return a.writeData(kind, stream.write(data))
+}
+
+func (a *activeRecording) writeData(kind string, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	if kind == "o" {
+		for _, m := range terminalResize.FindAllSubmatch(data, -1) {
+			height, _ := strconv.Atoi(string(m[1]))
+			width, _ := strconv.Atoi(string(m[2]))
+			if width > 0 && height > 0 {
+				if err := a.resize(width, height); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if a.recording.Format == RecordingFormatQueryLog {
+		// Query logs hold statements, not terminal traffic
+		return nil
+	}
+	text, _ := RedactSecrets(a.detector, strings.ToValidUTF8(string(data), "\uFFFD"))
+	if a.recording.Format != RecordingFormatAsciinema {
+		// Transcripts only hold what the client saw, without escapes
+		if kind != "o" {
+			return nil
+		}
+		text = ansiEscape.ReplaceAllString(strings.ReplaceAll(text, "\r\n", "\n"), "")
+		return a.writeRaw([]byte(text))
+	}
+	return a.event(kind, text)
+}
+
+func (a *activeRecording) resize(width, height int) error {
+	if a.recording.Format != RecordingFormatAsciinema {
+		return nil
+	}
+	return a.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+// marker records a command: asciicast files get a marker event labelled
+// with the command, transcripts a line of its own. Query logs get the
+// statement once its outcome is known, from query.
+func (a *activeRecording) marker(command *domain.SessionCommand) error {
+	if a.recording.Format == RecordingFormatQueryLog {
+		return nil
+	}
+	text, _ := RedactSecrets(a.detector, command.Command)
+	if a.recording.Format == RecordingFormatAsciinema {
+		return a.event("m", fmt.Sprintf("[%s] [%s] %s", command.CommandType, command.Action, text))
+	}
+
+	// One line per command; line breaks inside it are escaped
+	return a.writeRaw([]byte(fmt.Sprintf("%s [%s] [%s] %s\n", command.Timestamp.UTC().Format(time.RFC3339),
+		command.CommandType, command.Action, strings.ReplaceAll(text, "\n", `\n`))))
+}
+
+// query writes a statement to a query log, redacted. Recordings in other
+// formats ignore it.
+func (a *activeRecording) query(entry *domain.QueryLogEntry) error {
+	if a.recording.Format != RecordingFormatQueryLog {
+		return nil
+	}
+
+	redacted := *entry
+	redacted.Offset = math.Round(max(entry.Time.Sub(a.start).Seconds(), 0)*1e6) / 1e6
+	redacted.Statement, _ = RedactSecrets(a.detector, entry.Statement)
+	redacted.Error, _ = RedactSecrets(a.detector, entry.Error)
+	if len(entry.Parameters) > 0 {
+		redacted.Parameters = make([]string, len(entry.Parameters))
+		for i, parameter := range entry.Parameters {
+			redacted.Parameters[i], _ = RedactSecrets(a.detector, parameter)
+		}
+	}
+	line, err := json.Marshal(&redacted)
+	if err != nil {
+		return err
+	}
+	return a.writeLine(line)
+}
+
+// event writes one asciicast event, timed relative to the recording start.
+func (a *activeRecording) event(kind, data string) error {
+	elapsed := math.Round(time.Since(a.start).Seconds()*1e6) / 1e6
+	line, err := json.Marshal([]interface{}{elapsed, kind, data})
+	if err != nil {
+		return err
+	}
+	return a.writeLine(line)
+}
+
+func (a *activeRecording) writeLine(line []byte) error {
+	return a.writeRaw(append(line, '\n'))
+}
+
+func (a *activeRecording) writeRaw(p []byte) error {
+	n, err := a.out.Write(p)
+	a.recording.Size += int64(n)
+	a.recording.Duration = int64(time.Since(a.start).Seconds())
+	return err
+}
+
+// flush records what the streams still hold back, before the file is
+// closed.
+func (a *activeRecording) flush() error {
+	err := a.writeData("o", a.output.flush())
+	if inputErr := a.writeData("i", a.input.flush()); err == nil {
+		err = inputErr
+	}
+	a.recording.Duration = int64(time.Since(a.start).Seconds())
+	return err
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// commandRule describes a single command analysis rule.
+type commandRule struct {
+	ID         string
+	Pattern    string
+	IgnoreCase bool
+	Risk       string
+	Block      bool
+	// Keywords are lower-case literals, at least one of which occurs in every
+	// text the pattern matches. They are derived from the pattern when empty.
+	Keywords []string
+}
+
+// compiledRule is a commandRule with its pattern compiled once.
+type compiledRule struct {
+	commandRule
+	re    *regexp.Regexp
+	level int
+}
+
+// ruleResult is the outcome of evaluating a text against a rule engine.
+type ruleResult struct {
+	Risk    string
+	Block   bool
+	Matched []*commandRule
+}
+
+// ruleEngine evaluates precompiled rules against command text. Rule keywords
+// are loaded into an Aho-Corasick automaton that selects candidate rules in a
+// single pass over the text, so only rules that can possibly match run their
+// regular expression and the cost of an analysis stays flat as rule sets grow.
+type ruleEngine struct {
+	rules   []compiledRule
+	matcher *keywordMatcher
+	// always holds rules for which no keyword could be derived; they are
+	// evaluated for every text.
+	always  []int32
+	scratch sync.Pool
+}
+
+type ruleScratch struct {
+	stamps     []uint32
+	generation uint32
+	candidates []int32
+}
+
+// newRuleEngine compiles rules into an engine. Rules are evaluated from the
+// most to the least severe.
+func newRuleEngine(rules []commandRule) (*ruleEngine, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		if _, ok := riskLevels[rule.Risk]; !ok {
+			return nil, fmt.Errorf("rule %s: invalid risk %q", rule.ID, rule.Risk)
+		}
+
+		pattern := rule.Pattern
+		if rule.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+		if len(rule.Keywords) == 0 {
+			rule.Keywords = requiredKeywords(pattern)
+		}
+
+		compiled = append(compiled, compiledRule{commandRule: rule, re: re, level: riskLevels[rule.Risk]})
+	}
+
+	sort.SliceStable(compiled, func(i, j int) bool {
+		return compiled[i].level > compiled[j].level
+	})
+
+	e := &ruleEngine{
+		rules:   compiled,
+		matcher: newKeywordMatcher(),
+	}
+	for i, rule := range compiled {
+		usable := len(rule.Keywords) > 0
+		for _, keyword := range rule.Keywords {
+			if keyword == "" || !isASCII(keyword) {
+				usable = false
+			}
+		}
+		if !usable {
+			e.always = append(e.always, int32(i))
+			continue
+		}
+		for _, keyword := range rule.Keywords {
+			e.matcher.add(strings.ToLower(keyword), int32(i))
+		}
+	}
+	e.matcher.build()
+	e.scratch.New = func() interface{} {
+		return &ruleScratch{stamps: make([]uint32, len(e.rules))}
+	}
+
+	return e, nil
+}
+
+// mustNewRuleEngine is like newRuleEngine but panics on invalid rules. It is
+// meant for the built-in rule sets.
+func mustNewRuleEngine(rules []commandRule) *ruleEngine {
+	e, err := newRuleEngine(rules)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// evaluate returns the highest risk matched by text and whether any rule at
+// that risk blocks it. With collect set, every matching rule is evaluated and
+// returned instead of stopping at the most severe ones.
+func (e *ruleEngine) evaluate(text string, collect bool) ruleResult {
+	result := ruleResult{Risk: "low"}
+
+	s := e.scratch.Get().(*ruleScratch)
+	defer e.scratch.Put(s)
+
+	s.generation++
+	if s.generation == 0 {
+		for i := range s.stamps {
+			s.stamps[i] = 0
+		}
+		s.generation = 1
+	}
+	s.candidates = append(s.candidates[:0], e.always...)
+	for _, idx := range e.always {
+		s.stamps[idx] = s.generation
+	}
+	e.matcher.scan(text, func(idx int32) {
+		if s.stamps[idx] != s.generation {
+			s.stamps[idx] = s.generation
+			s.candidates = append(s.candidates, idx)
+		}
+	})
+	sort.Slice(s.candidates, func(i, j int) bool { return s.candidates[i] < s.candidates[j] })
+
+	matchedLevel := -1
+	for _, idx := range s.candidates {
+		rule := &e.rules[idx]
+		if !collect && matchedLevel >= 0 && rule.level < matchedLevel {
+			break
+		}
+		if !rule.re.MatchString(text) {
+			continue
+		}
+		if matchedLevel < 0 || rule.level > matchedLevel {
+			matchedLevel = rule.level
+			result.Risk = rule.Risk
+		}
+		result.Block = result.Block || (rule.Block && rule.level == matchedLevel)
+		if collect {
+			result.Matched = append(result.Matched, &rule.commandRule)
+		}
+	}
+
+	return result
+}
+
+// requiredKeywords derives a set of literals, one of which must occur in any
+// text matched by pattern. It returns nil when no such set exists.
+func requiredKeywords(pattern string) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return requiredLiterals(re.Simplify())
+}
+
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		lit := strings.ToLower(string(re.Rune))
+		if lit == "" {
+			return nil
+		}
+		return []string{lit}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var best []string
+		for _, sub := range re.Sub {
+			if set := requiredLiterals(sub); set != nil && shortestLen(set) > shortestLen(best) {
+				best = set
+			}
+		}
+		return best
+	case syntax.OpAlternate:
+		var all []string
+		for _, sub := range re.Sub {
+			set := requiredLiterals(sub)
+			if set == nil {
+				return nil
+			}
+			all = append(all, set...)
+		}
+		return all
+	}
+	return nil
+}
+
+func shortestLen(set []string) int {
+	if len(set) == 0 {
+		return 0
+	}
+	n := len(set[0])
+	for _, s := range set[1:] {
+		if len(s) < n {
+			n = len(s)
+		}
+	}
+	return n
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Built-in rule sets, compiled once per process.
+var (
+	defaultSQLRules = []commandRule{
+		{ID: "sql-drop-database", Pattern: `DROP DATABASE`, IgnoreCase: true, Risk: "critical", Block: true},
+		{ID: "sql-drop-schema", Pattern: `DROP SCHEMA`, IgnoreCase: true, Risk: "critical", Block: true},
+		{ID: "sql-truncate", Pattern: `TRUNCATE`, IgnoreCase: true, Risk: "critical", Block: true},
+		{ID: "sql-delete-all", Pattern: `DELETE FROM.*WHERE.*1=1`, IgnoreCase: true, Risk: "critical", Block: true},
+		{ID: "sql-update-all", Pattern: `UPDATE.*SET.*WHERE.*1=1`, IgnoreCase: true, Risk: "critical", Block: true},
+		{ID: "sql-shutdown", Pattern: `SHUTDOWN`, IgnoreCase: true, Risk: "critical", Block: true},
+
+		{ID: "sql-drop-table", Pattern: `DROP TABLE`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-drop-view", Pattern: `DROP VIEW`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-alter-drop", Pattern: `ALTER TABLE.*DROP`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-delete", Pattern: `DELETE FROM`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-update", Pattern: `UPDATE.*SET`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-grant-all", Pattern: `GRANT.*ALL`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-revoke", Pattern: `REVOKE`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-create-user", Pattern: `CREATE USER`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-drop-user", Pattern: `DROP USER`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-password-comment", Pattern: `--.*PASSWORD`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-union-select", Pattern: `UNION.*SELECT`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-load-file", Pattern: `LOAD_FILE`, IgnoreCase: true, Risk: "high"},
+		{ID: "sql-into-outfile", Pattern: `INTO OUTFILE`, IgnoreCase: true, Risk: "high"},
+
+		{ID: "sql-insert", Pattern: `INSERT INTO`, IgnoreCase: true, Risk: "medium"},
+		{ID: "sql-create-table", Pattern: `CREATE TABLE`, IgnoreCase: true, Risk: "medium"},
+		{ID: "sql-create-index", Pattern: `CREATE INDEX`, IgnoreCase: true, Risk: "medium"},
+		{ID: "sql-alter-table", Pattern: `ALTER TABLE`, IgnoreCase: true, Risk: "medium"},
+		{ID: "sql-create-view", Pattern: `CREATE VIEW`, IgnoreCase: true, Risk: "medium"},
+	}
+
+	defaultShellRules = []commandRule{
+		{ID: "shell-rm-root", Pattern: `rm\s+-rf\s+/`, Risk: "critical", Block: true},
+		{ID: "shell-fork-bomb", Pattern: `:\(\)\{\s*:\|\:&\s*\}`, Risk: "critical", Block: true},
+		{ID: "shell-mkfs", Pattern: `mkfs\.`, Risk: "critical", Block: true},
+		{ID: "shell-dd-device", Pattern: `dd\s+if=.*of=/dev/`, Risk: "critical", Block: true},
+		{ID: "shell-chmod-777-root", Pattern: `chmod\s+777\s+/`, Risk: "critical", Block: true},
+
+		{ID: "shell-sudo", Pattern: `sudo\s+`, Risk: "high"},
+		{ID: "shell-su", Pattern: `su\s+`, Risk: "high"},
+		{ID: "shell-rm-rf", Pattern: `rm\s+-rf`, Risk: "high"},
+		{ID: "shell-chmod", Pattern: `chmod\s+[0-9]+`, Risk: "high"},
+		{ID: "shell-chown", Pattern: `chown\s+`, Risk: "high"},
+		{ID: "shell-passwd", Pattern: `passwd\s+`, Risk: "high"},
+		{ID: "shell-useradd", Pattern: `useradd\s+`, Risk: "high"},
+		{ID: "shell-userdel", Pattern: `userdel\s+`, Risk: "high"},
+		{ID: "shell-crontab", Pattern: `crontab\s+`, Risk: "high"},
+		{ID: "shell-systemctl", Pattern: `systemctl\s+`, Risk: "high"},
+		{ID: "shell-service", Pattern: `service\s+`, Risk: "high"},
+		{ID: "shell-iptables", Pattern: `iptables\s+`, Risk: "high"},
+		{ID: "shell-mount", Pattern: `mount\s+`, Risk: "high"},
+		{ID: "shell-umount", Pattern: `umount\s+`, Risk: "high"},
+		{ID: "shell-fdisk", Pattern: `fdisk\s+`, Risk: "high"},
+		{ID: "shell-etc-passwd", Pattern: `/etc/passwd`, Risk: "high"},
+		{ID: "shell-etc-shadow", Pattern: `/etc/shadow`, Risk: "high"},
+		{ID: "shell-wget-pipe-sh", Pattern: `wget\s+.*\|\s*sh`, Risk: "high"},
+		{ID: "shell-curl-pipe-sh", Pattern: `curl\s+.*\|\s*sh`, Risk: "high"},
+		{ID: "shell-netcat-listener", Pattern: `nc\s+-l`, Risk: "high"},
+		{ID: "shell-python-socket", Pattern: `python.*-c.*socket`, Risk: "high"},
+		{ID: "shell-interactive-bash", Pattern: `bash\s+-i\s+`, Risk: "high"},
+
+		{ID: "shell-ls-la", Pattern: `ls\s+-la`, Risk: "medium"},
+		{ID: "shell-find-root", Pattern: `find\s+/`, Risk: "medium"},
+		{ID: "shell-grep-recursive", Pattern: `grep\s+-r`, Risk: "medium"},
+		{ID: "shell-cat-etc", Pattern: `cat\s+/etc/`, Risk: "medium"},
+		{ID: "shell-ps-aux", Pattern: `ps\s+aux`, Risk: "medium"},
+		{ID: "shell-netstat", Pattern: `netstat\s+`, Risk: "medium"},
+		{ID: "shell-ss", Pattern: `ss\s+`, Risk: "medium"},
+		{ID: "shell-lsof", Pattern: `lsof\s+`, Risk: "medium"},
+		{ID: "shell-history", Pattern: `history\s*$`, Risk: "medium"},
+		{ID: "shell-env", Pattern: `env\s*$`, Risk: "medium"},
+		{ID: "shell-id", Pattern: `id\s*$`, Risk: "medium"},
+		{ID: "shell-whoami", Pattern: `whoami\s*$`, Risk: "medium"},
+	}
+
+	defaultGenericRules = []commandRule{
+		{ID: "generic-secret-keyword", Pattern: `password|secret|token`, IgnoreCase: true, Risk: "medium"},
+		{ID: "generic-destructive-keyword", Pattern: `delete|remove|drop`, IgnoreCase: true, Risk: "medium"},
+	}
+)
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeywordMatcher_Scan(t *testing.T) {
+	keywords := []string{"he", "she", "his", "hers", "drop table", "rm", "-rf"}
+	m := newKeywordMatcher()
+	for i, k := range keywords {
+		m.add(k, int32(i))
+	}
+	m.build()
+
+	texts := []string{
+		"ushers",
+		"DROP TABLE users",
+		"sudo rm -rf /tmp/x",
+		"nothing to see",
+		"",
+	}
+	for _, text := range texts {
+		found := map[int32]bool{}
+		m.scan(text, func(v int32) { found[v] = true })
+
+		for i, k := range keywords {
+			want := strings.Contains(strings.ToLower(text), k)
+			assert.Equal(t, want, found[int32(i)], "keyword %q in %q", k, text)
+		}
+	}
+}
+
+func TestKeywordMatcher_RandomAgainstNaive(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	alphabet := "abcAB -"
+	randString := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+
+	for round := 0; round < 50; round++ {
+		m := newKeywordMatcher()
+		var keywords []string
+		for i := 0; i < 20; i++ {
+			k := strings.ToLower(randString(1 + rng.Intn(4)))
+			keywords = append(keywords, k)
+			m.add(k, int32(i))
+		}
+		m.build()
+
+		text := randString(40)
+		found := map[int32]bool{}
+		m.scan(text, func(v int32) { found[v] = true })
+		for i, k := range keywords {
+			assert.Equal(t, strings.Contains(strings.ToLower(text), k), found[int32(i)], "keyword %q in %q", k, text)
+		}
+	}
+}
+
+func TestRequiredKeywords(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{`DROP DATABASE`, []string{"drop database"}},
+		{`(?i)DELETE FROM.*WHERE.*1=1`, []string{"delete from"}},
+		{`rm\s+-rf\s+/`, []string{"-rf"}},
+		{`password|secret|token`, []string{"password", "secret", "token"}},
+		{`history\s*$`, []string{"history"}},
+		{`(foo)+bar?`, []string{"foo"}},
+		{`a*`, nil},
+		{`\s+`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got := requiredKeywords(tt.pattern)
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRuleEngine_Evaluate(t *testing.T) {
+	engine, err := newRuleEngine([]commandRule{
+		{ID: "medium", Pattern: `select`, IgnoreCase: true, Risk: "medium"},
+		{ID: "critical", Pattern: `drop\s+database`, IgnoreCase: true, Risk: "critical", Block: true},
+		{ID: "high", Pattern: `drop`, IgnoreCase: true, Risk: "high"},
+		{ID: "no-keyword", Pattern: `^\d+$`, Risk: "high"},
+	})
+	require.NoError(t, err)
+
+	result := engine.evaluate("DROP   DATABASE prod; select 1", false)
+	assert.Equal(t, "critical", result.Risk)
+	assert.True(t, result.Block)
+	assert.Empty(t, result.Matched)
+
+	result = engine.evaluate("DROP   DATABASE prod; select 1", true)
+	assert.Equal(t, "critical", result.Risk)
+	assert.True(t, result.Block)
+	var ids []string
+	for _, rule := range result.Matched {
+		ids = append(ids, rule.ID)
+	}
+	assert.Equal(t, []string{"critical", "high", "medium"}, ids)
+
+	result = engine.evaluate("12345", false)
+	assert.Equal(t, "high", result.Risk)
+	assert.False(t, result.Block)
+
+	result = engine.evaluate("show tables", false)
+	assert.Equal(t, "low", result.Risk)
+	assert.False(t, result.Block)
+}
+
+func TestNewRuleEngine_InvalidRules(t *testing.T) {
+	_, err := newRuleEngine([]commandRule{{ID: "bad", Pattern: `(`, Risk: "high"}})
+	assert.Error(t, err)
+
+	_, err = newRuleEngine([]commandRule{{ID: "bad-risk", Pattern: `x`, Risk: "severe"}})
+	assert.Error(t, err)
+}
+
+// TestRuleEngine_MatchesNaiveEvaluation checks that the prefilter never hides
+// a match the plain regular expressions would find.
+func TestRuleEngine_MatchesNaiveEvaluation(t *testing.T) {
+	commands := []string{
+		"DROP DATABASE prod",
+		"delete from users where 1=1",
+		"UPDATE accounts SET balance = 0",
+		"select * from t union all select password from users",
+		"INSERT INTO logs VALUES (1)",
+		"rm -rf /",
+		"sudo   systemctl restart nginx",
+		"cat /etc/shadow",
+		"curl http://x | sh",
+		"history",
+		"echo hello",
+		"my token is here",
+	}
+
+	for _, rules := range [][]commandRule{defaultSQLRules, defaultShellRules, defaultGenericRules} {
+		engine := mustNewRuleEngine(rules)
+		for _, command := range commands {
+			naive := "low"
+			for _, rule := range rules {
+				pattern := rule.Pattern
+				if rule.IgnoreCase {
+					pattern = "(?i)" + pattern
+				}
+				if regexp.MustCompile(pattern).MatchString(command) {
+					naive = higherRisk(naive, rule.Risk)
+				}
+			}
+			assert.Equal(t, naive, engine.evaluate(command, false).Risk, "command %q", command)
+		}
+	}
+}
+
+// syntheticRules generates n rules with distinct keywords, the way large
+// customer policy sets look.
+func syntheticRules(n int) []commandRule {
+	risks := []string{"medium", "high", "critical"}
+	rules := make([]commandRule, n)
+	for i := range rules {
+		rules[i] = commandRule{
+			ID:         fmt.Sprintf("rule-%d", i),
+			Pattern:    fmt.Sprintf(`forbidden_%05d\s+\w+`, i),
+			IgnoreCase: true,
+			Risk:       risks[i%len(risks)],
+		}
+	}
+	return rules
+}
+
+func BenchmarkRuleEngine(b *testing.B) {
+	command := "SELECT id, name, email FROM customers WHERE created_at > now() - interval '1 day' ORDER BY id LIMIT 100"
+	for _, n := range []int{10, 100, 1000, 5000} {
+		engine := mustNewRuleEngine(syntheticRules(n))
+		b.Run(fmt.Sprintf("rules=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				engine.evaluate(command, false)
+			}
+		})
+	}
+}
+
+func BenchmarkRuleEngine_Match(b *testing.B) {
+	for _, n := range []int{10, 100, 1000, 5000} {
+		engine := mustNewRuleEngine(syntheticRules(n))
+		command := fmt.Sprintf("run forbidden_%05d now", n/2)
+		b.Run(fmt.Sprintf("rules=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				engine.evaluate(command, false)
+			}
+		})
+	}
+}
+
+func BenchmarkAnalyzeCommand(b *testing.B) {
+	svc := NewSessionCommandService(nil)
+	ctx := context.Background()
+	cases := []struct {
+		name        string
+		command     string
+		commandType string
+	}{
+		{"sql", "SELECT id, name FROM users WHERE id = 42", "postgresql"},
+		{"sql-critical", "DROP DATABASE production", "mysql"},
+		{"shell", "ls -la /var/log && tail -n 100 /var/log/syslog", "shell"},
+		{"shell-obfuscated", "$(echo cm0gLXJmIC8= | base64 -d)", "shell"},
+		{"generic", "GET /healthz", "http"},
+	}
+
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := svc.AnalyzeCommand(ctx, tc.command, tc.commandType); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SecretDetector finds credentials in command text and server replies, so
+// that they can be redacted before anything is logged, stored or recorded.
+// Detectors are combined with NewSecretDetector.
+type SecretDetector interface {
+	Detect(text string) []SecretMatch
+}
+
+// SecretMatch locates a secret in the scanned text: text[Start:End] is the
+// secret itself and Type names the kind of secret found.
+type SecretMatch struct {
+	Type  string
+	Start int
+	End   int
+}
+
+// SecretPattern is a regular expression rule for a known secret format.
+// When the expression has capture groups, the first group that matched is
+// the secret (the value of PASSWORD=..., say); otherwise the whole match is.
+type SecretPattern struct {
+	Type    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultSecretPatterns covers well-known token formats and the usual ways
+// passwords end up on a command line or in SQL.
+var DefaultSecretPatterns = []SecretPattern{
+	{"private_key", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z0-9 ]*PRIVATE KEY-----|$)`)},
+	{"aws_access_key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github_token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"slack_token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"stripe_key", regexp.MustCompile(`\b[rs]k_(?:live|test)_[A-Za-z0-9]{16,}\b`)},
+	{"google_api_key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`)},
+	{"url_credentials", regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://[^\s:/@]+:([^\s@/]+)@`)},
+	{"authorization_header", regexp.MustCompile(`(?i)\bauthorization:\s*(?:bearer|basic|token)\s+([A-Za-z0-9._~+/=-]+)`)},
+	{"password_option", regexp.MustCompile(`(?i)(?:^|\s)--?(?:password|passwd|pass)(?:=|\s+)(?:"([^"]*)"|'([^']*)'|([^\s"'-]\S*))`)},
+	{"password_option", regexp.MustCompile(`\b(?:mysql|mysqladmin|mysqldump|mariadb)\b[^|;&\n]*?\s-p([^\s-]\S*)`)},
+	{"password_option", regexp.MustCompile(`\bsshpass\s+-p\s*(\S+)`)},
+	{"sql_password", regexp.MustCompile(`(?i)\b(?:PASSWORD|IDENTIFIED\s+(?:WITH\s+\S+\s+)?BY)\s*(?:=\s*)?(?:PASSWORD\s*\(\s*)?'((?:[^']|'')*)'`)},
+	{"secret_assignment", regexp.MustCompile(`(?i)\b[A-Z0-9_.-]*(?:SECRET|PASSWORD|PASSWD|PWD|TOKEN|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|CREDENTIALS?)[A-Z0-9_.-]*["']?\s*[=:]\s*(?:"([^"]*)"|'([^']*)'|([^\s"'&;|,]+))`)},
+}
+
+type patternSecretDetector struct {
+	patterns []SecretPattern
+}
+
+// NewPatternSecretDetector returns a detector that reports every match of
+// the given patterns.
+func NewPatternSecretDetector(patterns []SecretPattern) SecretDetector {
+	return &patternSecretDetector{patterns: patterns}
+}
+
+func (d *patternSecretDetector) Detect(text string) []SecretMatch {
+	var matches []SecretMatch
+	for _, p := range d.patterns {
+		for _, loc := range p.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			for g := 2; g+1 < len(loc); g += 2 {
+				if loc[g] >= 0 {
+					start, end = loc[g], loc[g+1]
+					break
+				}
+			}
+			if start < end {
+				matches = append(matches, SecretMatch{Type: p.Type, Start: start, End: end})
+			}
+		}
+	}
+	return matches
+}
+
+// secretCandidate matches the runs of characters random tokens are made of
+var secretCandidate = regexp.MustCompile(`[A-Za-z0-9+/_=-]+`)
+
+var hexString = regexp.MustCompile(`^[0-9a-fA-F-]+$`)
+
+type entropySecretDetector struct {
+	minLength  int
+	minEntropy float64
+}
+
+// NewEntropySecretDetector returns a detector for unrecognised random
+// tokens: words of at least minLength characters mixing letters and digits
+// whose Shannon entropy is at least minEntropy bits per character.
+// Hexadecimal strings are left alone, as they are mostly hashes and IDs.
+func NewEntropySecretDetector(minLength int, minEntropy float64) SecretDetector {
+	return &entropySecretDetector{minLength: minLength, minEntropy: minEntropy}
+}
+
+func (d *entropySecretDetector) Detect(text string) []SecretMatch {
+	var matches []SecretMatch
+	for _, loc := range secretCandidate.FindAllStringIndex(text, -1) {
+		// Leading slashes belong to paths and URLs rather than the token
+		for loc[0] < loc[1] && text[loc[0]] == '/' {
+			loc[0]++
+		}
+		word := text[loc[0]:loc[1]]
+		if len(word) < d.minLength || hexString.MatchString(word) {
+			continue
+		}
+		if !strings.ContainsAny(word, "0123456789") || strings.IndexFunc(word, isASCIILetter) < 0 {
+			continue
+		}
+		if shannonEntropy(word) >= d.minEntropy {
+			matches = append(matches, SecretMatch{Type: "high_entropy", Start: loc[0], End: loc[1]})
+		}
+	}
+	return matches
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// shannonEntropy returns the entropy of s in bits per byte.
+func shannonEntropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(len(s))
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+type multiSecretDetector []SecretDetector
+
+// NewSecretDetector combines detectors; a text's secrets are everything any
+// of them finds.
+func NewSecretDetector(detectors ...SecretDetector) SecretDetector {
+	return multiSecretDetector(detectors)
+}
+
+func (m multiSecretDetector) Detect(text string) []SecretMatch {
+	var matches []SecretMatch
+	for _, d := range m {
+		matches = append(matches, d.Detect(text)...)
+	}
+	return matches
+}
+
+// DefaultSecretDetector looks for the DefaultSecretPatterns and for random
+// tokens of 20 or more characters.
+func DefaultSecretDetector() SecretDetector {
+	return NewSecretDetector(
+		NewPatternSecretDetector(DefaultSecretPatterns),
+		NewEntropySecretDetector(20, 4.0),
+	)
+}
+
+// RedactSecrets replaces every secret the detector finds in text with a
+// [REDACTED:<type>] marker. It returns the redacted text and the secrets
+// found, merged where they overlap, in order. A nil detector finds nothing.
+func RedactSecrets(detector SecretDetector, text string) (string, []SecretMatch) {
+	if detector == nil || text == "" {
+		return text, nil
+	}
+	found := detector.Detect(text)
+	if len(found) == 0 {
+		return text, nil
+	}
+
+	// Overlapping matches are merged into the earliest, widest one; ties go
+	// to the detector listed first
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Start != found[j].Start {
+			return found[i].Start < found[j].Start
+		}
+		return found[i].End > found[j].End
+	})
+	merged := found[:1]
+	for _, match := range found[1:] {
+		last := &merged[len(merged)-1]
+		if match.Start < last.End {
+			last.End = max(last.End, match.End)
+			continue
+		}
+		merged = append(merged, match)
+	}
+
+	var b strings.Builder
+	position := 0
+	for _, match := range merged {
+		b.WriteString(text[position:match.Start])
+		b.WriteString("[REDACTED:" + match.Type + "]")
+		position = match.End
+	}
+	b.WriteString(text[position:])
+	return b.String(), merged
+}
+
+// secretTypes lists the distinct kinds of secrets in matches.
+func secretTypes(matches []SecretMatch) []string {
+	var types []string
+	seen := make(map[string]bool)
+	for _, match := range matches {
+		if !seen[match.Type] {
+			seen[match.Type] = true
+			types = append(types, match.Type)
+		}
+	}
+	return types
+}
@@ -2,72 +2,233 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
 	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// Page sizes of alert searches
+const (
+	DefaultAlertPageSize = 100
+	MaxAlertPageSize     = 1000
 )
 
+// alertTransitions lists the statuses an alert may move to from each
+// status. Resolved and dismissed alerts can only be reopened.
+var alertTransitions = map[string][]string{
+	domain.AlertOpen:          {domain.AlertAcknowledged, domain.AlertInvestigating, domain.AlertResolved, domain.AlertFalsePositive},
+	domain.AlertAcknowledged:  {domain.AlertInvestigating, domain.AlertResolved, domain.AlertFalsePositive},
+	domain.AlertInvestigating: {domain.AlertResolved, domain.AlertFalsePositive},
+	domain.AlertResolved:      {domain.AlertOpen},
+	domain.AlertFalsePositive: {domain.AlertOpen},
+}
+
 type securityAlertService struct {
-	alerts map[string]*domain.SecurityAlert
+	securityAlertRepo domain.SecurityAlertRepository
+	now               func() time.Time
+	mu                sync.RWMutex
+	observers         []domain.AlertObserver
+	correlator        domain.AlertCorrelator
+	incidentObservers []domain.IncidentObserver
 }
 
-func NewSecurityAlertService() domain.SecurityAlertService {
+func NewSecurityAlertService(securityAlertRepo domain.SecurityAlertRepository) domain.SecurityAlertService {
 	return &securityAlertService{
-		alerts: make(map[string]*domain.SecurityAlert),
+		securityAlertRepo: securityAlertRepo,
+		now:               time.Now,
 	}
 }
 
+// CreateAlert stores a new alert, open and unassigned, as part of the
+// incident the correlator puts it in
 func (s *securityAlertService) CreateAlert(ctx context.Context, alert *domain.SecurityAlert) error {
 	if alert.ID == "" {
-		alert.ID = fmt.Sprintf("alert_%d", time.Now().UnixNano())
+		alert.ID = uuid.New().String()
 	}
 	if alert.CreatedAt.IsZero() {
-		alert.CreatedAt = time.Now()
+		alert.CreatedAt = s.now()
 	}
+	alert.Status = domain.AlertOpen
+	alert.UpdatedAt = alert.CreatedAt
+
+	s.mu.RLock()
+	correlator := s.correlator
+	observers := s.observers
+	incidentObservers := s.incidentObservers
+	s.mu.RUnlock()
 
-	s.alerts[alert.ID] = alert
+	var incident *domain.Incident
+	opened := false
+	if correlator != nil {
+		var err error
+		incident, opened, err = correlator.Correlate(ctx, alert)
+		if err != nil {
+			return fmt.Errorf("failed to correlate alert: %w", err)
+		}
+		alert.IncidentID = incident.ID
+	}
+
+	if err := s.securityAlertRepo.Create(alert); err != nil {
+		return fmt.Errorf("failed to store alert: %w", err)
+	}
+
+	for _, observer := range observers {
+		observer.ObserveAlert(ctx, alert)
+	}
+	if opened {
+		for _, observer := range incidentObservers {
+			observer.ObserveIncident(ctx, incident, alert)
+		}
+	}
 	return nil
 }
 
+// SetCorrelator makes every alert created from now on part of an incident
+func (s *securityAlertService) SetCorrelator(correlator domain.AlertCorrelator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.correlator = correlator
+}
+
+// AddIncidentObserver registers an observer to be notified of each alert
+// that opens an incident, once it is stored. Observers are called
+// synchronously and must not block.
+func (s *securityAlertService) AddIncidentObserver(observer domain.IncidentObserver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.incidentObservers = append(s.incidentObservers, observer)
+}
+
+// AddObserver registers an observer to be notified of every alert once it
+// is stored. Observers are called synchronously and must not block.
+func (s *securityAlertService) AddObserver(observer domain.AlertObserver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
 func (s *securityAlertService) GetAlerts(ctx context.Context, sessionID string) ([]*domain.SecurityAlert, error) {
-	var alerts []*domain.SecurityAlert
-	for _, alert := range s.alerts {
-		if alert.SessionID == sessionID {
-			alerts = append(alerts, alert)
-		}
-	}
-	return alerts, nil
+	return s.search(domain.AlertFilter{SessionID: sessionID})
 }
 
 func (s *securityAlertService) GetAlertsByUser(ctx context.Context, userID string) ([]*domain.SecurityAlert, error) {
-	var alerts []*domain.SecurityAlert
-	for _, alert := range s.alerts {
-		if alert.UserID == userID {
-			alerts = append(alerts, alert)
-		}
-	}
-	return alerts, nil
+	return s.search(domain.AlertFilter{UserID: userID})
 }
 
 func (s *securityAlertService) GetAlertsBySeverity(ctx context.Context, severity string) ([]*domain.SecurityAlert, error) {
-	var alerts []*domain.SecurityAlert
-	for _, alert := range s.alerts {
-		if alert.Severity == severity {
-			alerts = append(alerts, alert)
+	return s.search(domain.AlertFilter{Severities: []string{severity}})
+}
+
+func (s *securityAlertService) search(filter domain.AlertFilter) ([]*domain.SecurityAlert, error) {
+	alerts, _, err := s.securityAlertRepo.Search(filter)
+	return alerts, err
+}
+
+func (s *securityAlertService) GetAlert(ctx context.Context, alertID string) (*domain.SecurityAlert, error) {
+	return s.securityAlertRepo.FindByID(alertID)
+}
+
+// SearchAlerts returns one page of the alerts matching filter, newest first.
+// The page size defaults to DefaultAlertPageSize and is capped at
+// MaxAlertPageSize.
+func (s *securityAlertService) SearchAlerts(ctx context.Context, filter domain.AlertFilter) (*domain.AlertPage, error) {
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return nil, errors.New("limit and offset cannot be negative")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, errors.New("from must be before to")
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultAlertPageSize
+	}
+	if filter.Limit > MaxAlertPageSize {
+		filter.Limit = MaxAlertPageSize
+	}
+	for _, status := range filter.Statuses {
+		if _, ok := alertTransitions[status]; !ok {
+			return nil, fmt.Errorf("unknown alert status %q", status)
 		}
 	}
-	return alerts, nil
+
+	alerts, total, err := s.securityAlertRepo.Search(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search alerts: %w", err)
+	}
+	return &domain.AlertPage{
+		Alerts: alerts,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}, nil
 }
 
-func (s *securityAlertService) MarkAlertAsReviewed(ctx context.Context, alertID string) error {
-	alert, exists := s.alerts[alertID]
-	if !exists {
-		return fmt.Errorf("alert %s not found", alertID)
+// UpdateAlertStatus moves an alert to status, if its current status allows
+// it, and records who did so and why. The first move out of the open state
+// sets AcknowledgedAt; resolving or dismissing an alert sets ResolvedAt, and
+// reopening it clears ResolvedAt again.
+func (s *securityAlertService) UpdateAlertStatus(ctx context.Context, alertID string, status string, reviewerID string, notes string) (*domain.SecurityAlert, error) {
+	if _, ok := alertTransitions[status]; !ok {
+		return nil, fmt.Errorf("unknown alert status %q", status)
+	}
+	alert, err := s.securityAlertRepo.FindByID(alertID)
+	if err != nil {
+		return nil, err
+	}
+	if alert.UserID != "" && alert.UserID == reviewerID {
+		return nil, errors.New("reviewers cannot triage alerts about themselves")
+	}
+	if !slices.Contains(alertTransitions[alert.Status], status) {
+		return nil, fmt.Errorf("alert %s cannot move from %s to %s", alertID, alert.Status, status)
 	}
 
-	// In a real implementation, you'd update the alert status
-	// For now, just return success
-	_ = alert
-	return nil
+	now := s.now()
+	alert.Status = status
+	alert.ReviewerID = reviewerID
+	if notes != "" {
+		alert.ReviewNotes = notes
+	}
+	if status != domain.AlertOpen && alert.AcknowledgedAt.IsZero() {
+		alert.AcknowledgedAt = now
+	}
+	switch status {
+	case domain.AlertResolved, domain.AlertFalsePositive:
+		alert.ResolvedAt = now
+	case domain.AlertOpen:
+		alert.ResolvedAt = time.Time{}
+	}
+	alert.UpdatedAt = now
+
+	if err := s.securityAlertRepo.Update(alert); err != nil {
+		return nil, err
+	}
+	utils.Infof("Alert %s moved to %s by %s", alertID, status, reviewerID)
+	return alert, nil
+}
+
+// AssignAlert hands an alert to assigneeID, or unassigns it when assigneeID
+// is empty. Neither the reviewer nor the assignee may be the user the alert
+// is about.
+func (s *securityAlertService) AssignAlert(ctx context.Context, alertID string, assigneeID string, reviewerID string) (*domain.SecurityAlert, error) {
+	alert, err := s.securityAlertRepo.FindByID(alertID)
+	if err != nil {
+		return nil, err
+	}
+	if alert.UserID != "" && (alert.UserID == reviewerID || alert.UserID == assigneeID) {
+		return nil, errors.New("alerts cannot be assigned by or to the user they are about")
+	}
+
+	alert.AssigneeID = assigneeID
+	alert.ReviewerID = reviewerID
+	alert.UpdatedAt = s.now()
+	if err := s.securityAlertRepo.Update(alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
 }
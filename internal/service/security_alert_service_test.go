@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+func newTestSecurityAlertService(t testing.TB) domain.SecurityAlertService {
+	db := newTestDB(t)
+	return NewSecurityAlertService(repository.NewSecurityAlertRepository(db))
+}
+
+func TestSecurityAlertService_Lifecycle(t *testing.T) {
+	svc := newTestSecurityAlertService(t)
+	ctx := context.Background()
+
+	alert := &domain.SecurityAlert{SessionID: "session-1", UserID: "alice", AlertType: "suspicious_command", Severity: "high", Title: "Reads /etc/shadow"}
+	require.NoError(t, svc.CreateAlert(ctx, alert))
+	assert.NotEmpty(t, alert.ID)
+	assert.Equal(t, domain.AlertOpen, alert.Status)
+
+	// The user an alert is about cannot triage it
+	_, err := svc.AssignAlert(ctx, alert.ID, "bob", "alice")
+	assert.Error(t, err)
+	_, err = svc.AssignAlert(ctx, alert.ID, "alice", "carol")
+	assert.Error(t, err)
+	_, err = svc.UpdateAlertStatus(ctx, alert.ID, domain.AlertFalsePositive, "alice", "nothing to see")
+	assert.Error(t, err)
+
+	assigned, err := svc.AssignAlert(ctx, alert.ID, "bob", "carol")
+	require.NoError(t, err)
+	assert.Equal(t, "bob", assigned.AssigneeID)
+
+	acknowledged, err := svc.UpdateAlertStatus(ctx, alert.ID, domain.AlertAcknowledged, "bob", "looking")
+	require.NoError(t, err)
+	assert.False(t, acknowledged.AcknowledgedAt.IsZero())
+	assert.True(t, acknowledged.ResolvedAt.IsZero())
+
+	_, err = svc.UpdateAlertStatus(ctx, alert.ID, domain.AlertOpen, "bob", "")
+	assert.Error(t, err, "an acknowledged alert cannot be reopened before it is closed")
+	_, err = svc.UpdateAlertStatus(ctx, alert.ID, "closed", "bob", "")
+	assert.Error(t, err, "unknown statuses are rejected")
+
+	_, err = svc.UpdateAlertStatus(ctx, alert.ID, domain.AlertInvestigating, "bob", "")
+	require.NoError(t, err)
+	resolved, err := svc.UpdateAlertStatus(ctx, alert.ID, domain.AlertFalsePositive, "bob", "backup job")
+	require.NoError(t, err)
+	assert.False(t, resolved.ResolvedAt.IsZero())
+
+	stored, err := svc.GetAlert(ctx, alert.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.AlertFalsePositive, stored.Status)
+	assert.Equal(t, "bob", stored.AssigneeID)
+	assert.Equal(t, "backup job", stored.ReviewNotes)
+	assert.WithinDuration(t, acknowledged.AcknowledgedAt, stored.AcknowledgedAt, time.Second)
+
+	reopened, err := svc.UpdateAlertStatus(ctx, alert.ID, domain.AlertOpen, "carol", "happened again")
+	require.NoError(t, err)
+	assert.True(t, reopened.ResolvedAt.IsZero())
+	assert.False(t, reopened.AcknowledgedAt.IsZero(), "reopening keeps when the alert was first acknowledged")
+}
+
+func TestSecurityAlertService_SearchAlerts(t *testing.T) {
+	svc := newTestSecurityAlertService(t)
+	ctx := context.Background()
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, severity := range []string{"low", "high", "critical", "high"} {
+		require.NoError(t, svc.CreateAlert(ctx, &domain.SecurityAlert{
+			ID: "alert-" + severity + "-" + string(rune('a'+i)), SessionID: "session-1", UserID: "alice",
+			AlertType: "suspicious_command", Severity: severity, Title: severity, CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+	_, err := svc.UpdateAlertStatus(ctx, "alert-high-b", domain.AlertResolved, "bob", "")
+	require.NoError(t, err)
+
+	page, err := svc.SearchAlerts(ctx, domain.AlertFilter{Statuses: []string{domain.AlertOpen}, Severities: []string{"high", "critical"}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+	require.Len(t, page.Alerts, 2)
+	assert.Equal(t, "alert-high-d", page.Alerts[0].ID, "newest first")
+	assert.Equal(t, "alert-critical-c", page.Alerts[1].ID)
+
+	page, err = svc.SearchAlerts(ctx, domain.AlertFilter{SessionID: "session-1", Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 4, page.Total)
+	require.Len(t, page.Alerts, 1)
+	assert.Equal(t, "alert-critical-c", page.Alerts[0].ID)
+
+	_, err = svc.SearchAlerts(ctx, domain.AlertFilter{Statuses: []string{"closed"}})
+	assert.Error(t, err)
+}
@@ -2,8 +2,9 @@ package service
 
 import (
 	"context"
-	"regexp"
-	"strings"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"secretary/alpha/internal/domain"
@@ -12,14 +13,27 @@ import (
 	"github.com/google/uuid"
 )
 
+// Page sizes of command history searches
+const (
+	DefaultCommandPageSize = 100
+	MaxCommandPageSize     = 1000
+)
+
+// MaxCommandsListed caps the commands the methods that list commands
+// without paging, such as GetSessionCommands, return at once
+const MaxCommandsListed = 50000
+
 type sessionCommandService struct {
-	// This would typically have a repository for persistence
-	commands map[string][]*domain.SessionCommand
+	sessionCommandRepo domain.SessionCommandRepository
+	analyzer           *commandAnalyzer
+	mu                 sync.RWMutex
+	observers          []domain.CommandObserver
 }
 
-func NewSessionCommandService() domain.SessionCommandService {
+func NewSessionCommandService(sessionCommandRepo domain.SessionCommandRepository) domain.SessionCommandService {
 	return &sessionCommandService{
-		commands: make(map[string][]*domain.SessionCommand),
+		sessionCommandRepo: sessionCommandRepo,
+		analyzer:           defaultAnalyzer,
 	}
 }
 
@@ -34,250 +48,126 @@ func (s *sessionCommandService) RecordCommand(ctx context.Context, command *doma
 		command.Timestamp = time.Now()
 	}
 
-	// Store in memory (in production, this would go to a database)
-	if s.commands[command.SessionID] == nil {
-		s.commands[command.SessionID] = make([]*domain.SessionCommand, 0)
+	if err := s.sessionCommandRepo.Create(command); err != nil {
+		return fmt.Errorf("failed to store command: %w", err)
 	}
-	s.commands[command.SessionID] = append(s.commands[command.SessionID], command)
 
 	utils.Infof("Recorded command: %s (type: %s, risk: %s, status: %s)",
 		command.Command[:min(100, len(command.Command))],
 		command.CommandType, command.Risk, command.Status)
 
+	s.mu.RLock()
+	observers := s.observers
+	s.mu.RUnlock()
+	for _, observer := range observers {
+		observer.ObserveCommand(ctx, command)
+	}
 	return nil
 }
 
-func (s *sessionCommandService) GetSessionCommands(ctx context.Context, sessionID string) ([]*domain.SessionCommand, error) {
-	commands, exists := s.commands[sessionID]
-	if !exists {
-		return []*domain.SessionCommand{}, nil
+// AddObserver registers an observer to be notified of every command once it
+// is stored. Observers are called synchronously and must not block.
+func (s *sessionCommandService) AddObserver(observer domain.CommandObserver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+func (s *sessionCommandService) UpdateCommand(ctx context.Context, command *domain.SessionCommand) error {
+	if err := s.sessionCommandRepo.Update(command); err != nil {
+		return fmt.Errorf("failed to update command %s: %w", command.ID, err)
 	}
-	return commands, nil
+	return nil
+}
+
+func (s *sessionCommandService) GetSessionCommands(ctx context.Context, sessionID string) ([]*domain.SessionCommand, error) {
+	return s.all(domain.CommandFilter{SessionID: sessionID})
 }
 
 func (s *sessionCommandService) GetCommandsByUser(ctx context.Context, userID string) ([]*domain.SessionCommand, error) {
-	var userCommands []*domain.SessionCommand
-	for _, sessionCommands := range s.commands {
-		for _, cmd := range sessionCommands {
-			if cmd.UserID == userID {
-				userCommands = append(userCommands, cmd)
-			}
-		}
-	}
-	return userCommands, nil
+	return s.all(domain.CommandFilter{UserID: userID})
 }
 
 func (s *sessionCommandService) GetCommandsByResource(ctx context.Context, resourceID string) ([]*domain.SessionCommand, error) {
-	var resourceCommands []*domain.SessionCommand
-	for _, sessionCommands := range s.commands {
-		for _, cmd := range sessionCommands {
-			if cmd.ResourceID == resourceID {
-				resourceCommands = append(resourceCommands, cmd)
-			}
-		}
-	}
-	return resourceCommands, nil
+	return s.all(domain.CommandFilter{ResourceID: resourceID})
 }
 
 func (s *sessionCommandService) GetHighRiskCommands(ctx context.Context) ([]*domain.SessionCommand, error) {
-	var highRiskCommands []*domain.SessionCommand
-	for _, sessionCommands := range s.commands {
-		for _, cmd := range sessionCommands {
-			if cmd.Risk == "high" || cmd.Risk == "critical" {
-				highRiskCommands = append(highRiskCommands, cmd)
-			}
-		}
-	}
-	return highRiskCommands, nil
+	return s.all(domain.CommandFilter{Risks: []string{"high", "critical"}})
 }
 
-func (s *sessionCommandService) AnalyzeCommand(ctx context.Context, command string, commandType string) (risk string, shouldBlock bool, err error) {
-	command = strings.TrimSpace(command)
-	if command == "" {
-		return "low", false, nil
-	}
-
-	risk = "low"
-	shouldBlock = false
-
-	switch strings.ToLower(commandType) {
-	case "sql", "mysql", "postgresql", "postgres":
-		risk, shouldBlock = s.analyzeSQLCommand(command)
-	case "ssh", "shell", "bash":
-		risk, shouldBlock = s.analyzeShellCommand(command)
-	default:
-		risk, shouldBlock = s.analyzeGenericCommand(command)
-	}
-
-	return risk, shouldBlock, nil
+func (s *sessionCommandService) GetCommandsBetween(ctx context.Context, from, to time.Time) ([]*domain.SessionCommand, error) {
+	return s.all(domain.CommandFilter{From: from, To: to})
 }
 
-func (s *sessionCommandService) analyzeSQLCommand(command string) (string, bool) {
-	upperCommand := strings.ToUpper(command)
-
-	// Critical risk patterns
-	criticalPatterns := []string{
-		"DROP DATABASE",
-		"DROP SCHEMA",
-		"TRUNCATE",
-		"DELETE FROM.*WHERE.*1=1",
-		"UPDATE.*SET.*WHERE.*1=1",
-		"SHUTDOWN",
-	}
-
-	for _, pattern := range criticalPatterns {
-		if matched, _ := regexp.MatchString(pattern, upperCommand); matched {
-			return "critical", true // Block critical operations
-		}
-	}
-
-	// High risk patterns
-	highRiskPatterns := []string{
-		"DROP TABLE",
-		"DROP VIEW",
-		"ALTER TABLE.*DROP",
-		"DELETE FROM",
-		"UPDATE.*SET",
-		"GRANT.*ALL",
-		"REVOKE",
-		"CREATE USER",
-		"DROP USER",
-		"--.*PASSWORD",
-		"UNION.*SELECT",
-		"LOAD_FILE",
-		"INTO OUTFILE",
+// SearchCommands returns one page of the command history. The page size
+// defaults to DefaultCommandPageSize and is capped at MaxCommandPageSize.
+func (s *sessionCommandService) SearchCommands(ctx context.Context, filter domain.CommandFilter) (*domain.CommandPage, error) {
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return nil, errors.New("limit and offset cannot be negative")
 	}
-
-	for _, pattern := range highRiskPatterns {
-		if matched, _ := regexp.MatchString(pattern, upperCommand); matched {
-			return "high", false
-		}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, errors.New("from must be before to")
 	}
-
-	// Medium risk patterns
-	mediumRiskPatterns := []string{
-		"INSERT INTO",
-		"CREATE TABLE",
-		"CREATE INDEX",
-		"ALTER TABLE",
-		"CREATE VIEW",
+	if filter.Limit == 0 {
+		filter.Limit = DefaultCommandPageSize
 	}
-
-	for _, pattern := range mediumRiskPatterns {
-		if matched, _ := regexp.MatchString(pattern, upperCommand); matched {
-			return "medium", false
-		}
+	if filter.Limit > MaxCommandPageSize {
+		filter.Limit = MaxCommandPageSize
 	}
 
-	// SELECT statements are generally low risk
-	if strings.HasPrefix(upperCommand, "SELECT") {
-		// But check for potential data exfiltration
-		if strings.Contains(upperCommand, "LIMIT") || strings.Contains(upperCommand, "ORDER BY") {
-			return "low", false
-		}
-		// Large result sets might indicate data exfiltration
-		if !strings.Contains(upperCommand, "LIMIT") && strings.Contains(upperCommand, "*") {
-			return "medium", false
-		}
-		return "low", false
+	commands, total, err := s.sessionCommandRepo.Search(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search commands: %w", err)
 	}
-
-	return "low", false
+	return &domain.CommandPage{
+		Commands: commands,
+		Total:    total,
+		Limit:    filter.Limit,
+		Offset:   filter.Offset,
+	}, nil
 }
 
-func (s *sessionCommandService) analyzeShellCommand(command string) (string, bool) {
-	command = strings.TrimSpace(command)
-
-	// Critical risk patterns - these should be blocked
-	criticalPatterns := []string{
-		`rm\s+-rf\s+/`,          // rm -rf /
-		`:\(\)\{\s*:\|\:&\s*\}`, // fork bomb
-		`mkfs\.`,                // format filesystem
-		`dd\s+if=.*of=/dev/`,    // direct disk access
-		`chmod\s+777\s+/`,       // dangerous permissions on root
-	}
-
-	for _, pattern := range criticalPatterns {
-		if matched, _ := regexp.MatchString(pattern, command); matched {
-			return "critical", true
+// all returns every command matching filter, oldest first, reading them a
+// page at a time. It fails if more than MaxCommandsListed match.
+func (s *sessionCommandService) all(filter domain.CommandFilter) ([]*domain.SessionCommand, error) {
+	filter.Limit, filter.Offset = MaxCommandPageSize, 0
+	commands := make([]*domain.SessionCommand, 0)
+	for {
+		page, total, err := s.sessionCommandRepo.Search(filter)
+		if err != nil {
+			return nil, err
 		}
-	}
-
-	// High risk patterns
-	highRiskPatterns := []string{
-		`sudo\s+`,
-		`su\s+`,
-		`rm\s+-rf`,
-		`chmod\s+[0-9]+`,
-		`chown\s+`,
-		`passwd\s+`,
-		`useradd\s+`,
-		`userdel\s+`,
-		`crontab\s+`,
-		`systemctl\s+`,
-		`service\s+`,
-		`iptables\s+`,
-		`mount\s+`,
-		`umount\s+`,
-		`fdisk\s+`,
-		`/etc/passwd`,
-		`/etc/shadow`,
-		`wget\s+.*\|\s*sh`,
-		`curl\s+.*\|\s*sh`,
-		`nc\s+-l`,            // netcat listener
-		`python.*-c.*socket`, // python reverse shell
-		`bash\s+-i\s+`,       // interactive bash
-	}
-
-	for _, pattern := range highRiskPatterns {
-		if matched, _ := regexp.MatchString(pattern, command); matched {
-			return "high", false
+		if total > MaxCommandsListed {
+			return nil, fmt.Errorf("%d commands match, more than the %d listed at once", total, MaxCommandsListed)
 		}
-	}
-
-	// Medium risk patterns
-	mediumRiskPatterns := []string{
-		`ls\s+-la`,
-		`find\s+/`,
-		`grep\s+-r`,
-		`cat\s+/etc/`,
-		`ps\s+aux`,
-		`netstat\s+`,
-		`ss\s+`,
-		`lsof\s+`,
-		`history\s*$`,
-		`env\s*$`,
-		`id\s*$`,
-		`whoami\s*$`,
-	}
-
-	for _, pattern := range mediumRiskPatterns {
-		if matched, _ := regexp.MatchString(pattern, command); matched {
-			return "medium", false
+		commands = append(commands, page...)
+		if len(page) < filter.Limit {
+			return commands, nil
 		}
+		filter.Offset += len(page)
 	}
-
-	return "low", false
 }
 
-func (s *sessionCommandService) analyzeGenericCommand(command string) (string, bool) {
-	// Generic analysis for unknown command types
-	command = strings.ToLower(command)
+func (s *sessionCommandService) AnalyzeCommand(ctx context.Context, command string, commandType string) (risk string, shouldBlock bool, err error) {
+	result := s.analyzer.analyze(command, commandType, false)
+	return result.Risk, result.Block, nil
+}
 
-	// Look for suspicious patterns
-	if strings.Contains(command, "password") ||
-		strings.Contains(command, "secret") ||
-		strings.Contains(command, "token") {
-		return "medium", false
-	}
+// riskLevels orders risk labels from least to most severe.
+var riskLevels = map[string]int{
+	"low":      0,
+	"medium":   1,
+	"high":     2,
+	"critical": 3,
+}
 
-	if strings.Contains(command, "delete") ||
-		strings.Contains(command, "remove") ||
-		strings.Contains(command, "drop") {
-		return "medium", false
+// higherRisk returns the more severe of two risk labels.
+func higherRisk(a, b string) string {
+	if riskLevels[b] > riskLevels[a] {
+		return b
 	}
-
-	return "low", false
+	return a
 }
 
 func min(a, b int) int {
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+// newTestSessionCommandService returns a command service backed by an
+// in-memory database.
+func newTestSessionCommandService(t testing.TB) domain.SessionCommandService {
+	db := newTestDB(t)
+	return NewSessionCommandService(repository.NewSessionCommandRepository(db))
+}
+
+func TestSessionCommandService_RecordAndUpdate(t *testing.T) {
+	svc := newTestSessionCommandService(t)
+	ctx := context.Background()
+
+	command := &domain.SessionCommand{
+		SessionID:   "session-1",
+		UserID:      "user-1",
+		ResourceID:  "prod-db",
+		Command:     "DELETE FROM users",
+		CommandType: "postgresql",
+		Status:      "pending_approval",
+		Risk:        "high",
+		Action:      "held",
+	}
+	require.NoError(t, svc.RecordCommand(ctx, command))
+	assert.NotEmpty(t, command.ID)
+
+	command.Status = "executed"
+	command.ApprovalStatus = "approved"
+	command.ReviewerID = "reviewer-1"
+	command.DecidedAt = time.Now()
+	require.NoError(t, svc.UpdateCommand(ctx, command))
+
+	commands, err := svc.GetSessionCommands(ctx, "session-1")
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	assert.Equal(t, "executed", commands[0].Status)
+	assert.Equal(t, "approved", commands[0].ApprovalStatus)
+	assert.Equal(t, "reviewer-1", commands[0].ReviewerID)
+	assert.False(t, commands[0].DecidedAt.IsZero())
+
+	assert.Error(t, svc.UpdateCommand(ctx, &domain.SessionCommand{ID: "missing"}))
+}
+
+func TestSessionCommandService_SearchCommands(t *testing.T) {
+	svc := newTestSessionCommandService(t)
+	ctx := context.Background()
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		risk := "low"
+		if i%2 == 1 {
+			risk = "critical"
+		}
+		require.NoError(t, svc.RecordCommand(ctx, &domain.SessionCommand{
+			SessionID:   "session-1",
+			UserID:      "user-1",
+			ResourceID:  "prod-db",
+			Command:     fmt.Sprintf("SELECT * FROM orders_%d", i),
+			CommandType: "postgresql",
+			Status:      "executed",
+			Risk:        risk,
+			Timestamp:   start.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	page, err := svc.SearchCommands(ctx, domain.CommandFilter{SessionID: "session-1", Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 5, page.Total)
+	require.Len(t, page.Commands, 2)
+	assert.Equal(t, "SELECT * FROM orders_1", page.Commands[0].Command)
+
+	page, err = svc.SearchCommands(ctx, domain.CommandFilter{Risks: []string{"critical"}, Descending: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, DefaultCommandPageSize, page.Limit)
+	require.Len(t, page.Commands, 2)
+	assert.Equal(t, "SELECT * FROM orders_3", page.Commands[0].Command)
+
+	page, err = svc.SearchCommands(ctx, domain.CommandFilter{Query: "orders_4"})
+	require.NoError(t, err)
+	require.Len(t, page.Commands, 1)
+	assert.Equal(t, "SELECT * FROM orders_4", page.Commands[0].Command)
+
+	page, err = svc.SearchCommands(ctx, domain.CommandFilter{From: start.Add(time.Minute), To: start.Add(3 * time.Minute)})
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+
+	_, err = svc.SearchCommands(ctx, domain.CommandFilter{Limit: -1})
+	assert.Error(t, err)
+}
+
+func TestSessionCommandService_ConcurrentRecord(t *testing.T) {
+	svc := newTestSessionCommandService(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, svc.RecordCommand(ctx, &domain.SessionCommand{
+				SessionID:   "session-1",
+				Command:     fmt.Sprintf("echo %d", i),
+				CommandType: "shell",
+			}))
+		}(i)
+	}
+	wg.Wait()
+
+	commands, err := svc.GetSessionCommands(ctx, "session-1")
+	require.NoError(t, err)
+	assert.Len(t, commands, 20)
+}
+
+func TestSessionCommandService_ListsPageByPage(t *testing.T) {
+	svc := newTestSessionCommandService(t).(*sessionCommandService)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < MaxCommandPageSize+5; i++ {
+		require.NoError(t, svc.sessionCommandRepo.Create(&domain.SessionCommand{
+			ID:        fmt.Sprintf("command-%d", i),
+			SessionID: "session-1",
+			Command:   fmt.Sprintf("echo %d", i),
+			Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+			CreatedAt: start,
+		}))
+	}
+
+	commands, err := svc.GetSessionCommands(ctx, "session-1")
+	require.NoError(t, err)
+	require.Len(t, commands, MaxCommandPageSize+5)
+	assert.Equal(t, "echo 0", commands[0].Command)
+	assert.Equal(t, fmt.Sprintf("echo %d", MaxCommandPageSize+4), commands[len(commands)-1].Command)
+}
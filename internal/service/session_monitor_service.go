@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+type sessionMonitorService struct {
+	sessionService        domain.SessionService
+	sessionCommandService domain.SessionCommandService
+	securityAlertService  domain.SecurityAlertService
+	sessionRiskService    domain.SessionRiskService
+	proxyService          domain.ProxyService
+	mu                    sync.RWMutex
+	monitored             map[string]time.Time
+}
+
+func NewSessionMonitorService(
+	sessionService domain.SessionService,
+	sessionCommandService domain.SessionCommandService,
+	securityAlertService domain.SecurityAlertService,
+	sessionRiskService domain.SessionRiskService,
+	proxyService domain.ProxyService,
+) domain.SessionMonitorService {
+	return &sessionMonitorService{
+		sessionService:        sessionService,
+		sessionCommandService: sessionCommandService,
+		securityAlertService:  securityAlertService,
+		sessionRiskService:    sessionRiskService,
+		proxyService:          proxyService,
+		monitored:             make(map[string]time.Time),
+	}
+}
+
+func (s *sessionMonitorService) StartMonitoring(ctx context.Context, sessionID string) error {
+	if _, err := s.GetLiveSession(ctx, sessionID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.monitored[sessionID]; !exists {
+		s.monitored[sessionID] = time.Now()
+	}
+	return nil
+}
+
+func (s *sessionMonitorService) StopMonitoring(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.monitored[sessionID]; !exists {
+		return fmt.Errorf("session %s is not monitored", sessionID)
+	}
+	delete(s.monitored, sessionID)
+	return nil
+}
+
+func (s *sessionMonitorService) GetLiveSession(ctx context.Context, sessionID string) (*domain.Session, error) {
+	session, err := s.sessionService.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != "active" {
+		return nil, errors.New("session is not active")
+	}
+	return session, nil
+}
+
+// InterruptSession cuts a live session off: its proxy is stopped, which
+// closes every connection through it, and the session is terminated.
+func (s *sessionMonitorService) InterruptSession(ctx context.Context, sessionID string, reason string) error {
+	stopped := false
+	if proxy, err := s.proxyService.GetProxyBySession(ctx, sessionID); err == nil {
+		if err := s.proxyService.StopProxy(ctx, proxy.ID); err != nil {
+			return fmt.Errorf("failed to stop proxy: %w", err)
+		}
+		stopped = true
+	}
+
+	terminateErr := s.sessionService.Terminate(ctx, sessionID)
+	if terminateErr != nil && !stopped {
+		return fmt.Errorf("failed to terminate session: %w", terminateErr)
+	}
+
+	s.mu.Lock()
+	delete(s.monitored, sessionID)
+	s.mu.Unlock()
+
+	alert := &domain.SecurityAlert{
+		ID:          uuid.New().String(),
+		SessionID:   sessionID,
+		AlertType:   "session_interrupted",
+		Severity:    "critical",
+		Title:       "Session Interrupted",
+		Description: reason,
+		Action:      "terminated",
+		CreatedAt:   time.Now(),
+	}
+	if session, err := s.sessionService.GetByID(ctx, sessionID); err == nil {
+		alert.UserID = session.UserID
+		alert.ResourceID = session.ResourceID
+	}
+	if err := s.securityAlertService.CreateAlert(ctx, alert); err != nil {
+		utils.Errorf("Failed to create session interruption alert: %v", err)
+	}
+
+	utils.Warnf("Interrupted session %s: %s", sessionID, reason)
+	return nil
+}
+
+func (s *sessionMonitorService) GetSessionMetrics(ctx context.Context, sessionID string) (map[string]interface{}, error) {
+	commands, err := s.sessionCommandService.GetSessionCommands(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session commands: %w", err)
+	}
+	alerts, err := s.securityAlertService.GetAlerts(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session alerts: %w", err)
+	}
+
+	commandsByRisk := map[string]int{"low": 0, "medium": 0, "high": 0, "critical": 0}
+	commandsByStatus := make(map[string]int)
+	for _, command := range commands {
+		commandsByRisk[command.Risk]++
+		commandsByStatus[command.Status]++
+	}
+	alertsBySeverity := make(map[string]int)
+	for _, alert := range alerts {
+		alertsBySeverity[alert.Severity]++
+	}
+
+	metrics := map[string]interface{}{
+		"session_id":         sessionID,
+		"command_count":      len(commands),
+		"commands_by_risk":   commandsByRisk,
+		"commands_by_status": commandsByStatus,
+		"alert_count":        len(alerts),
+		"alerts_by_severity": alertsBySeverity,
+	}
+
+	s.mu.RLock()
+	monitoredSince, monitored := s.monitored[sessionID]
+	s.mu.RUnlock()
+	metrics["monitored"] = monitored
+	if monitored {
+		metrics["monitored_since"] = monitoredSince
+	}
+
+	if s.sessionRiskService != nil {
+		score, err := s.sessionRiskService.GetScore(ctx, sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get session risk score: %w", err)
+		}
+		metrics["risk_score"] = score.Score
+		metrics["peak_risk_score"] = score.PeakScore
+		metrics["risk_level"] = score.Level
+		metrics["interrupted"] = score.Interrupted
+	}
+
+	if proxy, err := s.proxyService.GetProxyBySession(ctx, sessionID); err == nil {
+		metrics["proxy_id"] = proxy.ID
+		metrics["proxy_status"] = proxy.Status
+	}
+
+	return metrics, nil
+}
@@ -1,10 +1,18 @@
 package service
 
 import (
+	"bufio"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,117 +21,611 @@ import (
 	"secretary/alpha/pkg/utils"
 )
 
+// Recording formats
+const (
+	RecordingFormatAsciinema = "asciinema"
+	RecordingFormatText      = "text"
+	RecordingFormatQueryLog  = "jsonl"
+)
+
+// recordingExtensions are the file extensions of the recording formats
+var recordingExtensions = map[string]string{
+	RecordingFormatAsciinema: ".cast",
+	RecordingFormatText:      ".txt",
+	RecordingFormatQueryLog:  ".jsonl",
+}
+
+// isDatabaseProtocol reports whether sessions over a protocol, or commands
+// of a type, are database queries
+func isDatabaseProtocol(protocol string) bool {
+	switch strings.ToLower(protocol) {
+	case "postgres", "postgresql", "mysql":
+		return true
+	}
+	return false
+}
+
+// RecordingOptions configures session recordings. Format is
+// RecordingFormatAsciinema (asciicast v2 .cast files, with timing) or
+// RecordingFormatText (plain transcripts); sessions over database protocols
+// are recorded as query logs (RecordingFormatQueryLog, one JSON statement
+// per line) whatever the Format. Input typed by the client is only
+// recorded when RecordInput is set. Width and Height are the terminal size
+// a recording starts with, until a resize is reported. Everything written
+// to a recording is redacted by SecretDetector first.
+//
+// With a MasterKey (32 bytes), each recording is encrypted with a data key
+// of its own, stored in the file wrapped by the master key. Without one,
+// recordings are stored in the clear.
+//
+// When a recording stops, a manifest of its file is signed with SigningKey.
+// Without a key, one is generated that lasts until the server restarts.
+// Manifests signed with a key in TrustedKeys verify too.
+//
+// Recordings are written to Store, by default files under BasePath, and
+// moved to ArchiveStore when archived, by default files under ArchivePath
+// (the archive directory under BasePath).
+type RecordingOptions struct {
+	BasePath       string
+	Format         string
+	RecordInput    bool
+	Width          int
+	Height         int
+	SecretDetector SecretDetector
+	MasterKey      []byte
+	SigningKey     ed25519.PrivateKey
+	TrustedKeys    []ed25519.PublicKey
+	ArchivePath    string
+	Store          domain.RecordingStore
+	ArchiveStore   domain.RecordingStore
+}
+
+// DefaultRecordingOptions returns the options used when none are configured.
+func DefaultRecordingOptions() RecordingOptions {
+	return RecordingOptions{
+		BasePath: "./data/recordings",
+		Format:   RecordingFormatAsciinema,
+		Width:    80,
+		Height:   24,
+	}
+}
+
 type sessionRecordingService struct {
-	recordings map[string]*domain.SessionRecording
-	basePath   string
+	mu                   sync.RWMutex
+	options              RecordingOptions
+	sessionRecordingRepo domain.SessionRecordingRepository
+	sessionService       domain.SessionService
+	// active holds the recordings still being written, by session ID; their
+	// stored records are brought up to date when they stop
+	active map[string]*activeRecording
+	// storageMu keeps compression, archiving and deletion of stopped
+	// recordings apart; they copy files without holding mu
+	storageMu sync.Mutex
+	// sleep waits between the events of a playback
+	sleep func(ctx context.Context, d time.Duration) error
 }
 
-func NewSessionRecordingService() domain.SessionRecordingService {
-	basePath := "./data/recordings"
-	if err := os.MkdirAll(basePath, 0755); err != nil {
-		utils.Errorf("Failed to create recordings directory: %v", err)
+// NewSessionRecordingService returns a service keeping recording metadata in
+// sessionRecordingRepo. The user and resource of a recording are looked up
+// with sessionService, when given.
+func NewSessionRecordingService(sessionRecordingRepo domain.SessionRecordingRepository, sessionService domain.SessionService, options RecordingOptions) domain.SessionRecordingService {
+	defaults := DefaultRecordingOptions()
+	if options.BasePath == "" {
+		options.BasePath = defaults.BasePath
+	}
+	if options.ArchivePath == "" {
+		options.ArchivePath = filepath.Join(options.BasePath, "archive")
+	}
+	switch options.Format {
+	case RecordingFormatAsciinema, RecordingFormatText:
+	case "":
+		options.Format = defaults.Format
+	default:
+		utils.Warnf("Unknown recording format %q, using %s", options.Format, defaults.Format)
+		options.Format = defaults.Format
+	}
+	if options.Width <= 0 || options.Height <= 0 {
+		options.Width, options.Height = defaults.Width, defaults.Height
+	}
+	if options.SecretDetector == nil {
+		options.SecretDetector = DefaultSecretDetector()
+	}
+	if len(options.MasterKey) == 0 {
+		utils.Warn("WARNING: No recording master key configured. Session recordings are stored unencrypted!")
+	}
+	if len(options.SigningKey) != ed25519.PrivateKeySize {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			utils.Fatalf("Failed to generate recording signing key: %v", err)
+		}
+		options.SigningKey = key
+		utils.Warn("WARNING: No recording signing key configured. Generated temporary key; manifests signed with it cannot be verified after a restart!")
+	}
+
+	if options.Store == nil {
+		if err := os.MkdirAll(options.BasePath, 0755); err != nil {
+			utils.Errorf("Failed to create recordings directory: %v", err)
+		}
+		options.Store = NewLocalRecordingStore(options.BasePath)
+	}
+	if options.ArchiveStore == nil {
+		options.ArchiveStore = NewLocalRecordingStore(options.ArchivePath)
 	}
 
 	return &sessionRecordingService{
-		recordings: make(map[string]*domain.SessionRecording),
-		basePath:   basePath,
+		options:              options,
+		sessionRecordingRepo: sessionRecordingRepo,
+		sessionService:       sessionService,
+		active:               make(map[string]*activeRecording),
+		sleep:                sleepContext,
 	}
 }
 
-func (s *sessionRecordingService) StartRecording(ctx context.Context, sessionID string) (*domain.SessionRecording, error) {
+// StartRecording starts recording a session in the format that suits its
+// protocol.
+func (s *sessionRecordingService) StartRecording(ctx context.Context, sessionID string, protocol string) (*domain.SessionRecording, error) {
+	s.mu.RLock()
+	_, exists := s.active[sessionID]
+	s.mu.RUnlock()
+	if exists {
+		return nil, fmt.Errorf("session %s is already being recorded", sessionID)
+	}
+
+	format := s.options.Format
+	if isDatabaseProtocol(protocol) {
+		format = RecordingFormatQueryLog
+	}
 	recordingID := uuid.New().String()
-	recordingPath := filepath.Join(s.basePath, fmt.Sprintf("session_%s_%s.txt", sessionID, recordingID))
+	key := fmt.Sprintf("session_%s_%s%s", sessionID, recordingID, recordingExtensions[format])
 
 	recording := &domain.SessionRecording{
 		ID:            recordingID,
 		SessionID:     sessionID,
-		RecordingPath: recordingPath,
-		Format:        "text",
+		RecordingPath: s.options.Store.Location(key),
+		StorageKey:    key,
+		Format:        format,
 		Size:          0,
 		Duration:      0,
 		CommandCount:  0,
 		CreatedAt:     time.Now(),
+		Tier:          domain.RecordingTierHot,
 	}
+	s.attachSession(ctx, recording)
 
-	s.recordings[recordingID] = recording
-
-	// Create the recording file
-	file, err := os.Create(recordingPath)
+	// Create the recording file; the store may take a while to set up an
+	// upload, so this happens outside the lock
+	file, err := s.options.Store.Create(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create recording file: %w", err)
 	}
-	defer file.Close()
+	discard := func() {
+		file.Close()
+		s.options.Store.Delete(ctx, key)
+	}
 
-	utils.Infof("Started recording for session %s: %s", sessionID, recordingPath)
-	return recording, nil
+	// The file is hashed as stored, after encryption
+	chain := newRecordingChainWriter(file)
+	var out io.WriteCloser = chain
+	if len(s.options.MasterKey) > 0 {
+		if out, err = newRecordingCipherWriter(chain, s.options.MasterKey); err != nil {
+			discard()
+			return nil, fmt.Errorf("failed to encrypt recording: %w", err)
+		}
+	}
+
+	active := &activeRecording{
+		recording: recording,
+		out:       out,
+		chain:     chain,
+		start:     recording.CreatedAt,
+		detector:  s.options.SecretDetector,
+	}
+	if err := active.writeHeader(s.options.Width, s.options.Height); err != nil {
+		discard()
+		return nil, fmt.Errorf("failed to write recording header: %w", err)
+	}
+
+	s.mu.Lock()
+	if _, exists := s.active[sessionID]; exists {
+		s.mu.Unlock()
+		discard()
+		return nil, fmt.Errorf("session %s is already being recorded", sessionID)
+	}
+	if err := s.sessionRecordingRepo.Create(recording); err != nil {
+		s.mu.Unlock()
+		discard()
+		return nil, fmt.Errorf("failed to store recording: %w", err)
+	}
+	s.active[sessionID] = active
+	result := *recording
+	s.mu.Unlock()
+
+	utils.Infof("Started recording for session %s: %s", sessionID, recording.RecordingPath)
+	return &result, nil
 }
 
 func (s *sessionRecordingService) StopRecording(ctx context.Context, sessionID string) error {
-	// Find recording by session ID
-	var recording *domain.SessionRecording
-	for _, r := range s.recordings {
-		if r.SessionID == sessionID {
-			recording = r
-			break
-		}
+	s.mu.Lock()
+	active, exists := s.active[sessionID]
+	if !exists {
+		s.mu.Unlock()
+		return fmt.Errorf("no recording found for session %s", sessionID)
 	}
+	delete(s.active, sessionID)
+	err := active.flush()
+	s.mu.Unlock()
 
-	if recording == nil {
-		return fmt.Errorf("no recording found for session %s", sessionID)
+	// Closing may wait for the store to finish an upload; nothing else
+	// writes to the recording now that it is no longer active
+	if closeErr := active.out.Close(); err == nil {
+		err = closeErr
 	}
 
-	recording.Duration = int64(time.Since(recording.CreatedAt).Seconds())
+	// The recording is no longer shared, so it is changed without the lock
+	recording := active.recording
+	recording.Size = active.chain.size
+	if err != nil {
+		s.save(recording)
+		return fmt.Errorf("failed to finish recording: %w", err)
+	}
 
-	// Update file size
-	if info, err := os.Stat(recording.RecordingPath); err == nil {
-		recording.Size = info.Size()
+	// Sign what was written, so that later changes to the file show
+	manifest := &domain.RecordingManifest{
+		Version:     recordingManifestVersion,
+		RecordingID: recording.ID,
+		SessionID:   recording.SessionID,
+		Format:      recording.Format,
+		Encrypted:   len(s.options.MasterKey) > 0,
+		CreatedAt:   recording.CreatedAt,
+		StoppedAt:   time.Now(),
+		Size:        active.chain.size,
+		ChunkSize:   recordingHashChunkSize,
+		Chunks:      active.chain.digests,
+		ChainHash:   chainHash(recording.ID, active.chain.digests),
+	}
+	if err := signManifest(manifest, s.options.SigningKey); err != nil {
+		s.save(recording)
+		return fmt.Errorf("failed to sign recording manifest: %w", err)
+	}
+	key := manifestKey(recording.StorageKey)
+	if err := writeManifest(ctx, s.options.Store, key, manifest); err != nil {
+		s.save(recording)
+		return fmt.Errorf("failed to write recording manifest: %w", err)
+	}
+	recording.ManifestPath = s.options.Store.Location(key)
+	if err := s.sessionRecordingRepo.Update(recording); err != nil {
+		return fmt.Errorf("failed to store recording: %w", err)
 	}
 
 	utils.Infof("Stopped recording for session %s", sessionID)
 	return nil
 }
 
+// WriteOutput adds terminal output sent to the client to the session's
+// recording.
+func (s *sessionRecordingService) WriteOutput(ctx context.Context, sessionID string, data []byte) error {
+	return s.write(sessionID, func(active *activeRecording) error {
+		return active.writeStream(&active.output, "o", data)
+	})
+}
+
+// WriteInput adds input typed by the client to the session's recording, if
+// input is recorded.
+func (s *sessionRecordingService) WriteInput(ctx context.Context, sessionID string, data []byte) error {
+	if !s.options.RecordInput {
+		return nil
+	}
+	return s.write(sessionID, func(active *activeRecording) error {
+		return active.writeStream(&active.input, "i", data)
+	})
+}
+
+// Resize records a change of the client's terminal size.
+func (s *sessionRecordingService) Resize(ctx context.Context, sessionID string, width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid terminal size %dx%d", width, height)
+	}
+	return s.write(sessionID, func(active *activeRecording) error {
+		return active.resize(width, height)
+	})
+}
+
+// RecordCommand marks a command in its session's recording.
+func (s *sessionRecordingService) RecordCommand(ctx context.Context, command *domain.SessionCommand) error {
+	return s.write(command.SessionID, func(active *activeRecording) error {
+		if err := active.marker(command); err != nil {
+			return err
+		}
+		active.recording.CommandCount++
+		return nil
+	})
+}
+
+// RecordQuery adds a statement to the session's query log. Recordings in
+// other formats have the statement as a command marker already, and ignore
+// it.
+func (s *sessionRecordingService) RecordQuery(ctx context.Context, sessionID string, entry *domain.QueryLogEntry) error {
+	return s.write(sessionID, func(active *activeRecording) error {
+		return active.query(entry)
+	})
+}
+
+func (s *sessionRecordingService) write(sessionID string, write func(*activeRecording) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active, exists := s.active[sessionID]
+	if !exists {
+		return fmt.Errorf("no recording found for session %s", sessionID)
+	}
+	if err := write(active); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return nil
+}
+
 func (s *sessionRecordingService) GetRecording(ctx context.Context, sessionID string) (*domain.SessionRecording, error) {
-	for _, recording := range s.recordings {
-		if recording.SessionID == sessionID {
-			return recording, nil
+	s.mu.RLock()
+	if active, exists := s.active[sessionID]; exists {
+		result := *active.recording
+		s.mu.RUnlock()
+		return &result, nil
+	}
+	s.mu.RUnlock()
+
+	recording, err := s.sessionRecordingRepo.FindLatestBySessionID(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("recording not found for session %s", sessionID)
+	}
+	return recording, nil
+}
+
+// snapshot returns a copy of a recording and whether it is still being
+// written. The record of a recording being written is brought up to date
+// from the recording itself.
+func (s *sessionRecordingService) snapshot(recordingID string) (*domain.SessionRecording, bool, error) {
+	recording, err := s.sessionRecordingRepo.FindByID(recordingID)
+	if err != nil {
+		return nil, false, fmt.Errorf("recording %s not found", recordingID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if active := s.active[recording.SessionID]; active != nil && active.recording.ID == recordingID {
+		result := *active.recording
+		return &result, true, nil
+	}
+	return recording, false, nil
+}
+
+func (s *sessionRecordingService) GetRecordingByID(ctx context.Context, recordingID string) (*domain.SessionRecording, error) {
+	recording, _, err := s.snapshot(recordingID)
+	return recording, err
+}
+
+func (s *sessionRecordingService) IsRecording(ctx context.Context, recordingID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, active := range s.active {
+		if active.recording.ID == recordingID {
+			return true
 		}
 	}
-	return nil, fmt.Errorf("recording not found for session %s", sessionID)
+	return false
 }
 
+// GetRecordingFile returns the content of a recording, decrypted and
+// decompressed.
 func (s *sessionRecordingService) GetRecordingFile(ctx context.Context, recordingID string) ([]byte, error) {
-	recording, exists := s.recordings[recordingID]
-	if !exists {
-		return nil, fmt.Errorf("recording %s not found", recordingID)
+	file, err := s.OpenRecordingFile(ctx, recordingID)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return data, nil
+}
+
+// OpenRecordingFile opens a recording for reading, decrypted and
+// decompressed as it is read.
+func (s *sessionRecordingService) OpenRecordingFile(ctx context.Context, recordingID string) (io.ReadCloser, error) {
+	recording, active, err := s.snapshot(recordingID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := s.storeFor(recording).Open(ctx, recording.StorageKey)
+	if active && errors.Is(err, fs.ErrNotExist) {
+		// Object stores show uploads once they are complete
+		return nil, fmt.Errorf("recording %s can be read once it stops", recordingID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Recordings made without a master key are read as they are
+	buffered := bufio.NewReader(file)
+	var reader io.Reader = buffered
+	if magic, _ := buffered.Peek(len(recordingMagic)); isEncryptedRecording(magic) {
+		// A recording still being written has no last chunk yet, nor has
+		// one that never stopped, such as when the server crashed
+		partial := active || recording.ManifestPath == ""
+		if reader, err = newRecordingCipherReader(buffered, s.options.MasterKey, partial); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if !recording.Compressed {
+		return struct {
+			io.Reader
+			io.Closer
+		}{reader, file}, nil
 	}
+	decompressed, err := newRecordingDecompressor(reader)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to decompress recording: %w", err)
+	}
+	return &recordingFileReader{ReadCloser: decompressed, file: file}, nil
+}
 
-	return os.ReadFile(recording.RecordingPath)
+// recordingFileReader reads a decompressed recording, closing the
+// decompressor and then the file
+type recordingFileReader struct {
+	io.ReadCloser
+	file io.Closer
+}
+
+func (r *recordingFileReader) Close() error {
+	r.ReadCloser.Close()
+	return r.file.Close()
+}
+
+// GetRecordingManifest returns the signed manifest of a finished recording.
+func (s *sessionRecordingService) GetRecordingManifest(ctx context.Context, recordingID string) (*domain.RecordingManifest, error) {
+	recording, _, err := s.snapshot(recordingID)
+	if err != nil {
+		return nil, err
+	}
+	if recording.ManifestPath == "" {
+		return nil, fmt.Errorf("recording %s has no manifest yet", recordingID)
+	}
+	manifest, _, err := readStoredManifest(ctx, s.storeFor(recording), manifestKey(recording.StorageKey))
+	return manifest, err
+}
+
+// storeFor returns the store of a recording's tier
+func (s *sessionRecordingService) storeFor(recording *domain.SessionRecording) domain.RecordingStore {
+	if recording.Tier == domain.RecordingTierArchive {
+		return s.options.ArchiveStore
+	}
+	return s.options.Store
+}
+
+// VerifyRecording checks a finished recording's file against its signed
+// manifest and keeps the result with the recording.
+func (s *sessionRecordingService) VerifyRecording(ctx context.Context, recordingID string) (*domain.RecordingVerification, error) {
+	recording, active, err := s.snapshot(recordingID)
+	if err != nil {
+		return nil, err
+	}
+	if active {
+		return nil, fmt.Errorf("recording %s is still being written", recordingID)
+	}
+
+	verification := &domain.RecordingVerification{RecordingID: recordingID, VerifiedAt: time.Now()}
+	manifest, err := s.GetRecordingManifest(ctx, recordingID)
+	if err != nil {
+		failVerification(verification, domain.RecordingUnsigned, nil, "recording has no manifest")
+	} else if file, err := s.storeFor(recording).Open(ctx, recording.StorageKey); err != nil {
+		failVerification(verification, domain.RecordingTruncated, nil, "recording file is missing")
+	} else {
+		verification, err = VerifyRecordingFile(file, manifest, signingKeys(s.options.SigningKey, s.options.TrustedKeys)...)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify recording: %w", err)
+		}
+	}
+
+	if !verification.Valid {
+		utils.Warnf("Recording %s failed verification: %s (%s)", recordingID, verification.Status, verification.Details)
+	}
+	err = s.update(recordingID, func(stored *domain.SessionRecording) {
+		stored.Verification = verification
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store recording verification: %w", err)
+	}
+
+	result := *verification
+	return &result, nil
+}
+
+// SigningPublicKey returns the public key recording manifests are signed
+// with, for verifying them elsewhere.
+func (s *sessionRecordingService) SigningPublicKey() []byte {
+	return s.options.SigningKey.Public().(ed25519.PublicKey)
 }
 
 func (s *sessionRecordingService) DeleteRecording(ctx context.Context, recordingID string) error {
-	recording, exists := s.recordings[recordingID]
-	if !exists {
+	s.storageMu.Lock()
+	defer s.storageMu.Unlock()
+
+	recording, err := s.sessionRecordingRepo.FindByID(recordingID)
+	if err != nil {
 		return fmt.Errorf("recording %s not found", recordingID)
 	}
 
+	// A recording still being written is closed first
+	s.mu.Lock()
+	active, exists := s.active[recording.SessionID]
+	if exists && active.recording.ID == recordingID {
+		active.flush()
+		delete(s.active, recording.SessionID)
+	} else {
+		active = nil
+	}
+	s.mu.Unlock()
+	if active != nil {
+		active.out.Close()
+	}
+
 	// Delete file
-	if err := os.Remove(recording.RecordingPath); err != nil {
+	store := s.storeFor(recording)
+	if err := store.Delete(ctx, recording.StorageKey); err != nil {
 		return fmt.Errorf("failed to delete recording file: %w", err)
 	}
+	if err := store.Delete(ctx, manifestKey(recording.StorageKey)); err != nil {
+		return fmt.Errorf("failed to delete recording manifest: %w", err)
+	}
 
-	// Remove from memory
-	delete(s.recordings, recordingID)
-
+	if err := s.sessionRecordingRepo.Delete(recordingID); err != nil {
+		return fmt.Errorf("failed to delete recording: %w", err)
+	}
 	return nil
 }
 
-func (s *sessionRecordingService) ListRecordings(ctx context.Context, userID string) ([]*domain.SessionRecording, error) {
-	var recordings []*domain.SessionRecording
-	for _, recording := range s.recordings {
-		// In a real implementation, you'd filter by userID
-		// For now, return all recordings
-		recordings = append(recordings, recording)
+// ListRecordings returns the recordings matching filter, newest first.
+// Recordings still being written are listed as they are so far.
+func (s *sessionRecordingService) ListRecordings(ctx context.Context, filter domain.RecordingFilter) ([]*domain.SessionRecording, error) {
+	recordings, err := s.sessionRecordingRepo.List(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recordings: %w", err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i, recording := range recordings {
+		if active := s.active[recording.SessionID]; active != nil && active.recording.ID == recording.ID {
+			result := *active.recording
+			recordings[i] = &result
+		}
 	}
 	return recordings, nil
 }
+
+// attachSession fills in the user and resource of a recording from its
+// session, if it can be found
+func (s *sessionRecordingService) attachSession(ctx context.Context, recording *domain.SessionRecording) {
+	if s.sessionService == nil {
+		return
+	}
+	session, err := s.sessionService.GetByID(ctx, recording.SessionID)
+	if err != nil || session == nil {
+		return
+	}
+	recording.UserID = session.UserID
+	recording.ResourceID = session.ResourceID
+}
+
+// save stores a recording that is stopping, logging a failure as there is
+// a more telling error to return
+func (s *sessionRecordingService) save(recording *domain.SessionRecording) {
+	if err := s.sessionRecordingRepo.Update(recording); err != nil {
+		utils.Errorf("Failed to store recording %s: %v", recording.ID, err)
+	}
+}
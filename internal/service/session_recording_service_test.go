@@ -0,0 +1,318 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+func newTestSessionRecordingService(t testing.TB, options RecordingOptions) domain.SessionRecordingService {
+	db := newTestDB(t)
+	return NewSessionRecordingService(repository.NewSessionRecordingRepository(db), nil, options)
+}
+
+// readCast parses an asciicast v2 file into its header and events.
+func readCast(t *testing.T, data []byte) (asciicastHeader, [][]interface{}) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	require.True(t, scanner.Scan())
+	var header asciicastHeader
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &header))
+
+	var events [][]interface{}
+	for scanner.Scan() {
+		var event []interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		require.Len(t, event, 3)
+		events = append(events, event)
+	}
+	return header, events
+}
+
+func TestSessionRecordingService_Asciicast(t *testing.T) {
+	svc := newTestSessionRecordingService(t, RecordingOptions{BasePath: t.TempDir(), RecordInput: true, Width: 120, Height: 40})
+	ctx := context.Background()
+
+	recording, err := svc.StartRecording(ctx, "session-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, RecordingFormatAsciinema, recording.Format)
+	assert.Equal(t, ".cast", filepath.Ext(recording.RecordingPath))
+
+	_, err = svc.StartRecording(ctx, "session-1", "")
+	assert.Error(t, err, "a session is recorded once at a time")
+
+	require.NoError(t, svc.WriteInput(ctx, "session-1", []byte("export API_TOKEN=hunter2\n")))
+	// Echoed input arrives a byte at a time and is recorded by the line
+	for _, b := range []byte("export API_TOKEN=hunter2\r\n") {
+		require.NoError(t, svc.WriteOutput(ctx, "session-1", []byte{b}))
+	}
+	require.NoError(t, svc.RecordCommand(ctx, &domain.SessionCommand{
+		SessionID: "session-1", Command: "export API_TOKEN=hunter2", CommandType: "ssh", Action: "allowed",
+	}))
+	require.NoError(t, svc.Resize(ctx, "session-1", 100, 30))
+	require.NoError(t, svc.WriteOutput(ctx, "session-1", []byte("\x1b[8;50;132t$ ")))
+
+	live, err := svc.GetRecording(ctx, "session-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, live.CommandCount)
+	assert.Positive(t, live.Size)
+
+	require.NoError(t, svc.StopRecording(ctx, "session-1"))
+	assert.Error(t, svc.WriteOutput(ctx, "session-1", []byte("late")))
+
+	data, err := svc.GetRecordingFile(ctx, recording.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+
+	header, events := readCast(t, data)
+	assert.Equal(t, 2, header.Version)
+	assert.Equal(t, 120, header.Width)
+	assert.Equal(t, 40, header.Height)
+
+	var kinds []string
+	previous := 0.0
+	for _, event := range events {
+		at := event[0].(float64)
+		assert.GreaterOrEqual(t, at, previous)
+		previous = at
+		kinds = append(kinds, event[1].(string))
+	}
+	assert.Equal(t, []string{"i", "o", "m", "r", "r", "o"}, kinds)
+	assert.Equal(t, "export API_TOKEN=[REDACTED:secret_assignment]\r\n", events[1][2])
+	assert.Equal(t, "[ssh] [allowed] export API_TOKEN=[REDACTED:secret_assignment]", events[2][2])
+	assert.Equal(t, "100x30", events[3][2])
+	assert.Equal(t, "132x50", events[4][2])
+
+	stopped, err := svc.GetRecordingByID(ctx, recording.ID)
+	require.NoError(t, err)
+	info, err := os.Stat(recording.RecordingPath)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), stopped.Size)
+}
+
+func TestSessionRecordingService_Text(t *testing.T) {
+	svc := newTestSessionRecordingService(t, RecordingOptions{BasePath: t.TempDir(), Format: RecordingFormatText})
+	ctx := context.Background()
+
+	recording, err := svc.StartRecording(ctx, "session-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, ".txt", filepath.Ext(recording.RecordingPath))
+
+	// Input is only recorded when enabled
+	require.NoError(t, svc.WriteInput(ctx, "session-1", []byte("ls\n")))
+	require.NoError(t, svc.WriteOutput(ctx, "session-1", []byte("\x1b[32mfile.txt\x1b[0m\r\n")))
+	require.NoError(t, svc.StopRecording(ctx, "session-1"))
+
+	data, err := svc.GetRecordingFile(ctx, recording.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "file.txt\n", string(data))
+}
+
+func TestSessionRecordingService_PlayRecording(t *testing.T) {
+	svc := newTestSessionRecordingService(t, RecordingOptions{BasePath: t.TempDir()}).(*sessionRecordingService)
+	ctx := context.Background()
+
+	recording, err := svc.StartRecording(ctx, "session-1", "")
+	require.NoError(t, err)
+	require.NoError(t, svc.StopRecording(ctx, "session-1"))
+	cast := `{"version": 2, "width": 80, "height": 24}
+[0.5, "o", "$ "]
+[1.0, "r", "100x30"]
+[2.0, "m", "[ssh] [allowed] ls"]
+[2.0, "o", "ls\r\n"]
+[12.0, "o", "file.txt\r\n"]
+[13.0, "o", "$ "]
+`
+	require.NoError(t, os.WriteFile(recording.RecordingPath, []byte(cast), 0644))
+
+	var waits []time.Duration
+	svc.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	play := func(options domain.PlaybackOptions) []domain.PlaybackFrame {
+		waits = nil
+		var frames []domain.PlaybackFrame
+		require.NoError(t, svc.PlayRecording(ctx, recording.ID, options, func(frame *domain.PlaybackFrame) error {
+			frames = append(frames, *frame)
+			return nil
+		}))
+		return frames
+	}
+
+	t.Run("original timing", func(t *testing.T) {
+		frames := play(domain.PlaybackOptions{})
+		require.Len(t, frames, 7)
+		assert.Equal(t, domain.PlaybackFrame{Time: 0, Type: "r", Data: "80x24"}, frames[0])
+		assert.Equal(t, "m", frames[3].Type)
+		assert.Equal(t, []time.Duration{
+			500 * time.Millisecond, 500 * time.Millisecond, time.Second, 0, 10 * time.Second, time.Second,
+		}, waits)
+	})
+
+	t.Run("speed and idle limit", func(t *testing.T) {
+		play(domain.PlaybackOptions{Speed: 2, IdleLimit: 2 * time.Second})
+		assert.Equal(t, []time.Duration{
+			250 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond, 0, time.Second, 500 * time.Millisecond,
+		}, waits)
+	})
+
+	t.Run("seek", func(t *testing.T) {
+		frames := play(domain.PlaybackOptions{Offset: 5 * time.Second})
+		require.Len(t, frames, 4)
+		// The screen so far is replayed at once, at its latest size
+		assert.Equal(t, domain.PlaybackFrame{Time: 5, Type: "r", Data: "100x30"}, frames[0])
+		assert.Equal(t, domain.PlaybackFrame{Time: 5, Type: "o", Data: "$ ls\r\n"}, frames[1])
+		assert.Equal(t, 12.0, frames[2].Time)
+		assert.Equal(t, []time.Duration{7 * time.Second, time.Second}, waits)
+	})
+
+	t.Run("invalid speed", func(t *testing.T) {
+		err := svc.PlayRecording(ctx, recording.ID, domain.PlaybackOptions{Speed: 100}, func(*domain.PlaybackFrame) error { return nil })
+		assert.Error(t, err)
+	})
+}
+
+func TestSessionRecordingService_ReconcileRecordings(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	sessions := NewSessionService(repository.NewSessionRepository(db))
+	for _, sessionID := range []string{"s-1", "s-2"} {
+		require.NoError(t, sessions.Create(ctx, &domain.Session{
+			ID:         sessionID,
+			UserID:     "alice",
+			ResourceID: "db-1",
+			StartTime:  time.Now(),
+			Status:     "completed",
+			ClientIP:   "127.0.0.1",
+		}))
+	}
+
+	basePath := t.TempDir()
+	repo := repository.NewSessionRecordingRepository(db)
+	svc := NewSessionRecordingService(repo, sessions, RecordingOptions{BasePath: basePath, MasterKey: testMasterKey(t)})
+
+	content := make(map[string]string)
+	recorded := make(map[string]*domain.SessionRecording)
+	for _, sessionID := range []string{"s-1", "s-2"} {
+		recording, err := svc.StartRecording(ctx, sessionID, "")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", recording.UserID)
+		assert.Equal(t, "db-1", recording.ResourceID)
+		require.NoError(t, svc.WriteOutput(ctx, sessionID, []byte(sessionID+" output\r\n")))
+		require.NoError(t, svc.StopRecording(ctx, sessionID))
+		data, err := svc.GetRecordingFile(ctx, recording.ID)
+		require.NoError(t, err)
+		content[sessionID] = string(data)
+		recorded[sessionID] = recording
+	}
+	require.NoError(t, svc.CompressRecording(ctx, recorded["s-2"].ID, RecordingCodecZstd))
+
+	listed, err := svc.ListRecordings(ctx, domain.RecordingFilter{UserID: "alice", MinSize: 1})
+	require.NoError(t, err)
+	assert.Len(t, listed, 2)
+
+	// A crash loses the records, and leaves a recording that never stopped
+	for _, recording := range recorded {
+		require.NoError(t, repo.Delete(recording.ID))
+	}
+	interrupted := "session_s-3_0b7e8a52-5d4c-4f0e-9d1a-8f2b6c3e4a11.txt"
+	require.NoError(t, os.WriteFile(filepath.Join(basePath, interrupted), []byte("partial\n"), 0600))
+
+	picked, err := svc.ReconcileRecordings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, picked)
+
+	recovered, err := svc.GetRecordingByID(ctx, recorded["s-1"].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", recovered.UserID)
+	assert.Equal(t, "db-1", recovered.ResourceID)
+	assert.Equal(t, RecordingFormatAsciinema, recovered.Format)
+	assert.NotEmpty(t, recovered.ManifestPath)
+	assert.WithinDuration(t, recorded["s-1"].CreatedAt, recovered.CreatedAt, time.Second)
+	verification, err := svc.VerifyRecording(ctx, recovered.ID)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid, verification.Details)
+
+	compressed, err := svc.GetRecordingByID(ctx, recorded["s-2"].ID)
+	require.NoError(t, err)
+	assert.True(t, compressed.Compressed)
+	data, err := svc.GetRecordingFile(ctx, compressed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, content["s-2"], string(data))
+
+	partial, err := svc.GetRecording(ctx, "s-3")
+	require.NoError(t, err)
+	assert.Equal(t, RecordingFormatText, partial.Format)
+	assert.Empty(t, partial.ManifestPath)
+	data, err = svc.GetRecordingFile(ctx, partial.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "partial\n", string(data))
+
+	// Recordings already recorded are left alone
+	picked, err = svc.ReconcileRecordings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, picked)
+}
+
+func TestSessionRecordingService_QueryLog(t *testing.T) {
+	svc := newTestSessionRecordingService(t, RecordingOptions{BasePath: t.TempDir()})
+	ctx := context.Background()
+
+	recording, err := svc.StartRecording(ctx, "session-1", "mysql")
+	require.NoError(t, err)
+	assert.Equal(t, RecordingFormatQueryLog, recording.Format)
+	assert.Equal(t, ".jsonl", filepath.Ext(recording.RecordingPath))
+
+	// Terminal traffic and command markers are not part of a query log
+	start := time.Now()
+	require.NoError(t, svc.WriteOutput(ctx, "session-1", []byte("mysql> \r\n")))
+	require.NoError(t, svc.RecordCommand(ctx, &domain.SessionCommand{SessionID: "session-1", Command: "SELECT 1", CommandType: "mysql"}))
+
+	// Statements are written as they complete, which need not be the order
+	// they were sent in
+	require.NoError(t, svc.RecordQuery(ctx, "session-1", &domain.QueryLogEntry{
+		CommandID: "c-2", Time: start.Add(2 * time.Second), Statement: "UPDATE accounts SET active = 0",
+		DurationMs: 12, Rows: 3, Risk: "medium", Status: "executed",
+	}))
+	require.NoError(t, svc.RecordQuery(ctx, "session-1", &domain.QueryLogEntry{
+		CommandID: "c-1", Time: start.Add(time.Second), Statement: "SELECT * FROM users WHERE token = ?",
+		Parameters: []string{"ghp_" + "aBcDeFgHiJkLmNoPqRsTuVwXyZ0123456789"}, Error: "1146: Table 'users' doesn't exist",
+		Risk: "low", Status: "failed",
+	}))
+	require.NoError(t, svc.StopRecording(ctx, "session-1"))
+
+	entries, err := svc.GetQueryLog(ctx, recording.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "c-1", entries[0].CommandID)
+	assert.NotContains(t, entries[0].Parameters[0], "aBcDeFgHiJkLmNoPqRsTuVwXyZ")
+	assert.Equal(t, "1146: Table 'users' doesn't exist", entries[0].Error)
+	assert.InDelta(t, 1, entries[0].Offset, 0.1)
+	assert.Equal(t, "c-2", entries[1].CommandID)
+	assert.Equal(t, int64(3), entries[1].Rows)
+	assert.Equal(t, int64(12), entries[1].DurationMs)
+
+	stored, err := svc.GetRecordingByID(ctx, recording.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.CommandCount)
+
+	// Terminal recordings have no query log
+	other, err := svc.StartRecording(ctx, "session-2", "ssh")
+	require.NoError(t, err)
+	assert.Equal(t, RecordingFormatAsciinema, other.Format)
+	require.NoError(t, svc.StopRecording(ctx, "session-2"))
+	_, err = svc.GetQueryLog(ctx, other.ID)
+	assert.Error(t, err)
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// SessionRiskPolicy configures cumulative session risk scoring. The score
+// halves every HalfLife without new events; a threshold of zero disables the
+// action it triggers.
+type SessionRiskPolicy struct {
+	HalfLife           time.Duration
+	AlertThreshold     float64
+	NotifyThreshold    float64
+	InterruptThreshold float64
+}
+
+// DefaultSessionRiskPolicy returns the policy used when none is configured.
+// Five medium-risk commands in quick succession raise an alert; three
+// critical ones, or about a dozen medium ones within a few minutes, interrupt
+// the session.
+func DefaultSessionRiskPolicy() SessionRiskPolicy {
+	return SessionRiskPolicy{
+		HalfLife:           10 * time.Minute,
+		AlertThreshold:     40,
+		NotifyThreshold:    70,
+		InterruptThreshold: 100,
+	}
+}
+
+// Score added by a command of each risk level
+var commandRiskWeights = map[string]float64{
+	"low":      0,
+	"medium":   8,
+	"high":     20,
+	"critical": 40,
+	"unknown":  5,
+}
+
+// Score added by an alert of each severity, on top of the command that
+// raised it
+var alertSeverityWeights = map[string]float64{
+	"low":      2,
+	"medium":   5,
+	"high":     10,
+	"critical": 20,
+}
+
+// Alerts that add nothing to the score: those raised because of the score
+// itself, and those reporting on a command that was already scored
+var unscoredAlertTypes = map[string]bool{
+	"session_risk":        true,
+	"session_interrupted": true,
+	"command_held":        true,
+	"command_approved":    true,
+}
+
+// Risk levels in increasing order of severity
+var sessionRiskLevels = []string{"normal", "alert", "notify", "interrupt"}
+
+type sessionRiskState struct {
+	score domain.SessionRiskScore
+	// acted is the highest level whose action already ran; it drops back
+	// as the score decays so that a renewed climb acts again
+	acted int
+}
+
+type sessionRiskService struct {
+	mu                   sync.Mutex
+	policy               SessionRiskPolicy
+	sessions             map[string]*sessionRiskState
+	securityAlertService domain.SecurityAlertService
+	interrupter          domain.SessionInterrupter
+	notifier             domain.AlertNotifier
+	now                  func() time.Time
+}
+
+func NewSessionRiskService(securityAlertService domain.SecurityAlertService, policy SessionRiskPolicy) domain.SessionRiskService {
+	if policy.HalfLife <= 0 {
+		policy.HalfLife = DefaultSessionRiskPolicy().HalfLife
+	}
+	return &sessionRiskService{
+		policy:               policy,
+		sessions:             make(map[string]*sessionRiskState),
+		securityAlertService: securityAlertService,
+		now:                  time.Now,
+	}
+}
+
+// SetInterrupter sets what interrupts sessions crossing the interrupt
+// threshold. The session monitor depends on the risk service for its
+// metrics, so it is wired in after both are created.
+func (s *sessionRiskService) SetInterrupter(interrupter domain.SessionInterrupter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interrupter = interrupter
+}
+
+// SetNotifier sets what notifies reviewers of sessions crossing the notify
+// threshold.
+func (s *sessionRiskService) SetNotifier(notifier domain.AlertNotifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifier = notifier
+}
+
+func (s *sessionRiskService) RecordCommand(ctx context.Context, command *domain.SessionCommand) (*domain.SessionRiskScore, error) {
+	if command.SessionID == "" {
+		return nil, errors.New("session ID is required")
+	}
+
+	weight, ok := commandRiskWeights[command.Risk]
+	if !ok {
+		weight = commandRiskWeights["unknown"]
+	}
+	return s.add(ctx, command.SessionID, command.UserID, command.ResourceID, weight,
+		fmt.Sprintf("%s risk %s command", command.Risk, command.CommandType),
+		func(score *domain.SessionRiskScore) { score.CommandCount++ })
+}
+
+func (s *sessionRiskService) RecordAlert(ctx context.Context, alert *domain.SecurityAlert) (*domain.SessionRiskScore, error) {
+	if alert.SessionID == "" {
+		return nil, errors.New("session ID is required")
+	}
+
+	weight := alertSeverityWeights[alert.Severity]
+	if unscoredAlertTypes[alert.AlertType] {
+		weight = 0
+	}
+	return s.add(ctx, alert.SessionID, alert.UserID, alert.ResourceID, weight,
+		fmt.Sprintf("%s alert", alert.AlertType),
+		func(score *domain.SessionRiskScore) { score.AlertCount++ })
+}
+
+func (s *sessionRiskService) RecordAnomaly(ctx context.Context, sessionID string, weight float64, reason string) (*domain.SessionRiskScore, error) {
+	if sessionID == "" {
+		return nil, errors.New("session ID is required")
+	}
+	if weight < 0 {
+		return nil, errors.New("anomaly weight cannot be negative")
+	}
+
+	return s.add(ctx, sessionID, "", "", weight, reason,
+		func(score *domain.SessionRiskScore) { score.AnomalyCount++ })
+}
+
+func (s *sessionRiskService) GetScore(ctx context.Context, sessionID string) (*domain.SessionRiskScore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, exists := s.sessions[sessionID]
+	if !exists {
+		return &domain.SessionRiskScore{SessionID: sessionID, Level: "normal"}, nil
+	}
+	s.decay(state, s.now())
+	result := state.score
+	return &result, nil
+}
+
+// ListScores returns the scores of all tracked sessions, riskiest first.
+func (s *sessionRiskService) ListScores(ctx context.Context) ([]*domain.SessionRiskScore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	scores := make([]*domain.SessionRiskScore, 0, len(s.sessions))
+	for _, state := range s.sessions {
+		s.decay(state, now)
+		result := state.score
+		scores = append(scores, &result)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+	return scores, nil
+}
+
+func (s *sessionRiskService) ResetScore(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessions[sessionID]; !exists {
+		return fmt.Errorf("no risk score for session %s", sessionID)
+	}
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+// ObserveSession forgets the score of a session once it ends
+func (s *sessionRiskService) ObserveSession(ctx context.Context, event string, session *domain.Session) {
+	if event == domain.EventSessionEnded {
+		s.forget(session.ID)
+	}
+}
+
+func (s *sessionRiskService) forget(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+}
+
+func (s *sessionRiskService) add(ctx context.Context, sessionID, userID, resourceID string, weight float64, reason string, count func(*domain.SessionRiskScore)) (*domain.SessionRiskScore, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	state, exists := s.sessions[sessionID]
+	if !exists {
+		state = &sessionRiskState{score: domain.SessionRiskScore{SessionID: sessionID}}
+		s.sessions[sessionID] = state
+	}
+	if state.score.UserID == "" {
+		state.score.UserID = userID
+	}
+	if state.score.ResourceID == "" {
+		state.score.ResourceID = resourceID
+	}
+
+	s.decay(state, now)
+	state.score.Score += weight
+	state.score.PeakScore = math.Max(state.score.PeakScore, state.score.Score)
+	state.score.LastEventAt = now
+	count(&state.score)
+
+	level := s.level(state.score.Score)
+	state.score.Level = sessionRiskLevels[level]
+	var actions []int
+	for l := state.acted + 1; l <= level; l++ {
+		actions = append(actions, l)
+	}
+	if level > state.acted {
+		state.acted = level
+	}
+	if level == len(sessionRiskLevels)-1 && s.interrupter != nil {
+		state.score.Interrupted = true
+	}
+	interrupter, notifier := s.interrupter, s.notifier
+	result := state.score
+	s.mu.Unlock()
+
+	// Actions run outside the lock: interrupting a session tears down its
+	// proxy, which may itself report to this service
+	for _, l := range actions {
+		s.act(ctx, &result, sessionRiskLevels[l], reason, interrupter, notifier)
+	}
+	return &result, nil
+}
+
+// decay applies exponential decay since the score was last updated. Callers
+// hold s.mu.
+func (s *sessionRiskService) decay(state *sessionRiskState, now time.Time) {
+	if !state.score.UpdatedAt.IsZero() && now.After(state.score.UpdatedAt) {
+		elapsed := now.Sub(state.score.UpdatedAt)
+		state.score.Score *= math.Pow(0.5, float64(elapsed)/float64(s.policy.HalfLife))
+	}
+	state.score.UpdatedAt = now
+
+	level := s.level(state.score.Score)
+	state.score.Level = sessionRiskLevels[level]
+	if level < state.acted {
+		state.acted = level
+	}
+}
+
+func (s *sessionRiskService) level(score float64) int {
+	thresholds := []float64{s.policy.AlertThreshold, s.policy.NotifyThreshold, s.policy.InterruptThreshold}
+	level := 0
+	for i, threshold := range thresholds {
+		if threshold > 0 && score >= threshold {
+			level = i + 1
+		}
+	}
+	return level
+}
+
+func (s *sessionRiskService) act(ctx context.Context, score *domain.SessionRiskScore, level, reason string, interrupter domain.SessionInterrupter, notifier domain.AlertNotifier) {
+	alert := &domain.SecurityAlert{
+		ID:         uuid.New().String(),
+		SessionID:  score.SessionID,
+		UserID:     score.UserID,
+		ResourceID: score.ResourceID,
+		AlertType:  "session_risk",
+		RawData:    reason,
+		CreatedAt:  time.Now(),
+	}
+
+	switch level {
+	case "alert":
+		alert.Severity = "high"
+		alert.Title = "Elevated Session Risk"
+		alert.Description = fmt.Sprintf("Cumulative session risk score reached %.1f (threshold %.1f)", score.Score, s.policy.AlertThreshold)
+		alert.Action = "logged"
+	case "notify":
+		alert.Severity = "critical"
+		alert.Title = "Session Risk Requires Review"
+		alert.Description = fmt.Sprintf("Cumulative session risk score reached %.1f (threshold %.1f)", score.Score, s.policy.NotifyThreshold)
+		alert.Action = "notified"
+	case "interrupt":
+		alert.Severity = "critical"
+		alert.Title = "Session Interrupted For Risk"
+		alert.Description = fmt.Sprintf("Cumulative session risk score reached %.1f (threshold %.1f)", score.Score, s.policy.InterruptThreshold)
+		alert.Action = "terminated"
+	}
+
+	utils.Warnf("Session %s risk score %.1f reached level %s after %s", score.SessionID, score.Score, level, reason)
+
+	if level == "interrupt" {
+		if interrupter == nil {
+			alert.Action = "logged"
+			alert.Description += "; no interrupter configured"
+		} else if err := interrupter.InterruptSession(ctx, score.SessionID, alert.Description); err != nil {
+			utils.Errorf("Failed to interrupt session %s: %v", score.SessionID, err)
+			alert.Description += fmt.Sprintf("; interruption failed: %v", err)
+		} else {
+			s.forget(score.SessionID)
+		}
+	}
+
+	if level == "notify" {
+		if notifier == nil {
+			alert.Action = "logged"
+			alert.Description += "; no notifier configured"
+		} else {
+			description := alert.Description
+			alert.Description += "; reviewers notified"
+			if err := notifier.NotifyAlert(ctx, alert); err != nil {
+				utils.Errorf("Failed to notify reviewers of session %s: %v", score.SessionID, err)
+				alert.Action = "logged"
+				alert.Description = description + fmt.Sprintf("; reviewers not notified: %v", err)
+			}
+		}
+	}
+
+	if err := s.securityAlertService.CreateAlert(ctx, alert); err != nil {
+		utils.Errorf("Failed to create session risk alert: %v", err)
+	}
+}
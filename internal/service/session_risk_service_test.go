@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+type fakeInterrupter struct {
+	sessions []string
+}
+
+func (f *fakeInterrupter) InterruptSession(ctx context.Context, sessionID string, reason string) error {
+	f.sessions = append(f.sessions, sessionID)
+	return nil
+}
+
+func newTestRiskService(t testing.TB, now *time.Time) (*sessionRiskService, domain.SecurityAlertService) {
+	alerts := newTestSecurityAlertService(t)
+	svc := NewSessionRiskService(alerts, DefaultSessionRiskPolicy()).(*sessionRiskService)
+	svc.now = func() time.Time { return *now }
+	return svc, alerts
+}
+
+func TestSessionRiskService_Accumulates(t *testing.T) {
+	now := time.Now()
+	svc, alerts := newTestRiskService(t, &now)
+	interrupter := &fakeInterrupter{}
+	svc.SetInterrupter(interrupter)
+	ctx := context.Background()
+
+	command := &domain.SessionCommand{SessionID: "session-1", UserID: "user-1", Risk: "medium", CommandType: "shell"}
+	var score *domain.SessionRiskScore
+	for i := 0; i < 5; i++ {
+		var err error
+		score, err = svc.RecordCommand(ctx, command)
+		require.NoError(t, err)
+	}
+	assert.InDelta(t, 40, score.Score, 0.001)
+	assert.Equal(t, "alert", score.Level)
+	assert.Equal(t, 5, score.CommandCount)
+	assert.Equal(t, "user-1", score.UserID)
+
+	sessionAlerts, err := alerts.GetAlerts(ctx, "session-1")
+	require.NoError(t, err)
+	require.Len(t, sessionAlerts, 1)
+	assert.Equal(t, "session_risk", sessionAlerts[0].AlertType)
+
+	// Staying above the threshold does not repeat the alert
+	_, err = svc.RecordCommand(ctx, &domain.SessionCommand{SessionID: "session-1", Risk: "low"})
+	require.NoError(t, err)
+	sessionAlerts, _ = alerts.GetAlerts(ctx, "session-1")
+	assert.Len(t, sessionAlerts, 1)
+
+	// Eight more medium commands take the session past notify and interrupt
+	for i := 0; i < 8; i++ {
+		score, err = svc.RecordCommand(ctx, command)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "interrupt", score.Level)
+	assert.True(t, score.Interrupted)
+	assert.Equal(t, []string{"session-1"}, interrupter.sessions)
+	sessionAlerts, _ = alerts.GetAlerts(ctx, "session-1")
+	assert.Len(t, sessionAlerts, 3)
+}
+
+func TestSessionRiskService_Decays(t *testing.T) {
+	now := time.Now()
+	svc, alerts := newTestRiskService(t, &now)
+	ctx := context.Background()
+
+	_, err := svc.RecordCommand(ctx, &domain.SessionCommand{SessionID: "session-1", Risk: "critical"})
+	require.NoError(t, err)
+	score, err := svc.RecordAlert(ctx, &domain.SecurityAlert{SessionID: "session-1", AlertType: "blocked_command", Severity: "critical"})
+	require.NoError(t, err)
+	assert.InDelta(t, 60, score.Score, 0.001)
+	assert.Equal(t, 1, score.AlertCount)
+
+	now = now.Add(10 * time.Minute)
+	score, err = svc.GetScore(ctx, "session-1")
+	require.NoError(t, err)
+	assert.InDelta(t, 30, score.Score, 0.001)
+	assert.InDelta(t, 60, score.PeakScore, 0.001)
+	assert.Equal(t, "normal", score.Level)
+
+	// Once the score has decayed, climbing back alerts again
+	score, err = svc.RecordAnomaly(ctx, "session-1", 15, "unusual hour")
+	require.NoError(t, err)
+	assert.Equal(t, "alert", score.Level)
+	assert.Equal(t, 1, score.AnomalyCount)
+	sessionAlerts, _ := alerts.GetAlerts(ctx, "session-1")
+	assert.Len(t, sessionAlerts, 2)
+
+	// Alerts about the score itself do not feed it
+	score, err = svc.RecordAlert(ctx, &domain.SecurityAlert{SessionID: "session-1", AlertType: "session_risk", Severity: "critical"})
+	require.NoError(t, err)
+	assert.InDelta(t, 45, score.Score, 0.001)
+
+	scores, err := svc.ListScores(ctx)
+	require.NoError(t, err)
+	assert.Len(t, scores, 1)
+
+	require.NoError(t, svc.ResetScore(ctx, "session-1"))
+	score, err = svc.GetScore(ctx, "session-1")
+	require.NoError(t, err)
+	assert.Zero(t, score.Score)
+
+	// Scores are dropped once their session ends
+	_, err = svc.RecordCommand(ctx, &domain.SessionCommand{SessionID: "session-1", Risk: "medium"})
+	require.NoError(t, err)
+	svc.ObserveSession(ctx, domain.EventSessionEnded, &domain.Session{ID: "session-1"})
+	scores, err = svc.ListScores(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, scores)
+}
+
+// criticalAlert returns the alert raised at the notify level, after the one
+// raised at the alert level
+func criticalAlert(t *testing.T, alerts []*domain.SecurityAlert) *domain.SecurityAlert {
+	require.Len(t, alerts, 2)
+	for _, alert := range alerts {
+		if alert.Severity == "critical" {
+			return alert
+		}
+	}
+	t.Fatal("no critical alert")
+	return nil
+}
+
+func TestSessionRiskService_NotifiesReviewers(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	notifications, alerts := newTestNotificationService(t, NotificationOptions{Policy: domain.NotificationPolicy{
+		Channels: []domain.NotificationChannel{{Name: "reviewers", Type: domain.NotificationWebhook, URL: server.URL}},
+		Routes:   []domain.NotificationRoute{{Name: "risk", Severities: []string{"critical"}, AlertTypes: []string{"session_risk"}, Channels: []string{"reviewers"}}},
+	}})
+	runNotifications(t, notifications)
+	ctx := context.Background()
+
+	policy := DefaultSessionRiskPolicy()
+	risk := NewSessionRiskService(alerts, policy)
+	risk.SetNotifier(notifications)
+	_, err := risk.RecordAnomaly(ctx, "session-1", 80, "unusual hour")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	sessionAlerts, err := alerts.GetAlerts(ctx, "session-1")
+	require.NoError(t, err)
+	notified := criticalAlert(t, sessionAlerts)
+	assert.Equal(t, "notified", notified.Action)
+	assert.Contains(t, notified.Description, "reviewers notified")
+
+	// Should the alert open an incident, it is not sent a second time
+	notifications.ObserveIncident(ctx, &domain.Incident{}, notified)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Without a notifier the alert does not claim anyone was told
+	unnotifiedAlerts := newTestSecurityAlertService(t)
+	_, err = NewSessionRiskService(unnotifiedAlerts, policy).RecordAnomaly(ctx, "session-2", 80, "unusual hour")
+	require.NoError(t, err)
+	sessionAlerts, err = unnotifiedAlerts.GetAlerts(ctx, "session-2")
+	require.NoError(t, err)
+	unnotified := criticalAlert(t, sessionAlerts)
+	assert.Equal(t, "logged", unnotified.Action)
+	assert.Contains(t, unnotified.Description, "no notifier configured")
+}
+
+func TestSessionMonitorService_InterruptOnRisk(t *testing.T) {
+	db := newTestDB(t)
+	sessionService := NewSessionService(repository.NewSessionRepository(db))
+	ctx := context.Background()
+
+	session := &domain.Session{
+		UserID:     "user-1",
+		ResourceID: "prod-db",
+		Status:     "active",
+		StartTime:  time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	require.NoError(t, sessionService.Create(ctx, session))
+
+	commands := newTestSessionCommandService(t)
+	alerts := newTestSecurityAlertService(t)
+	risk := NewSessionRiskService(alerts, DefaultSessionRiskPolicy())
+	sessionService.AddObserver(risk)
+	proxies := NewProxyService(commands, newTestSessionRecordingService(t, RecordingOptions{BasePath: t.TempDir()}), alerts, sessionService, nil, risk, CommandHoldPolicy{}, nil)
+	monitor := NewSessionMonitorService(sessionService, commands, alerts, risk, proxies)
+	risk.SetInterrupter(monitor)
+
+	require.NoError(t, monitor.StartMonitoring(ctx, session.ID))
+
+	proxy, err := proxies.CreateProxy(ctx, session.ID, "postgres", "127.0.0.1", 5432)
+	require.NoError(t, err)
+	internal := proxies.(*proxyService).activeConnections[proxy.ID]
+
+	var allowed bool
+	for i := 0; i < 3; i++ {
+		_, allowed, _ = proxies.(*proxyService).inspectCommand(ctx, internal, "DROP DATABASE production", "postgresql")
+		assert.False(t, allowed)
+	}
+	_, allowed, reason := proxies.(*proxyService).inspectCommand(ctx, internal, "SELECT 1", "postgresql")
+	assert.False(t, allowed)
+	assert.Contains(t, reason, "stopped")
+
+	stored, err := sessionService.GetByID(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "terminated", stored.Status)
+
+	_, err = proxies.GetProxyBySession(ctx, session.ID)
+	assert.Error(t, err)
+
+	metrics, err := monitor.GetSessionMetrics(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, metrics["command_count"])
+	assert.Equal(t, false, metrics["monitored"])
+
+	// The score of the ended session is dropped
+	assert.Equal(t, "normal", metrics["risk_level"])
+	assert.Empty(t, risk.(*sessionRiskService).sessions)
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,7 +12,9 @@ import (
 )
 
 type sessionService struct {
-	repo domain.SessionRepository
+	repo      domain.SessionRepository
+	mu        sync.RWMutex
+	observers []domain.SessionObserver
 }
 
 func NewSessionService(repo domain.SessionRepository) domain.SessionService {
@@ -30,7 +33,11 @@ func (s *sessionService) Create(ctx context.Context, session *domain.Session) er
 	session.CreatedAt = time.Now()
 	session.UpdatedAt = time.Now()
 
-	return s.repo.Create(session)
+	if err := s.repo.Create(session); err != nil {
+		return err
+	}
+	s.notify(ctx, domain.EventSessionStarted, session)
+	return nil
 }
 
 func (s *sessionService) GetByID(ctx context.Context, id string) (*domain.Session, error) {
@@ -64,7 +71,13 @@ func (s *sessionService) Update(ctx context.Context, session *domain.Session) er
 	// Update the timestamp
 	session.UpdatedAt = time.Now()
 
-	return s.repo.Update(session)
+	if err := s.repo.Update(session); err != nil {
+		return err
+	}
+	if session.Status != "active" {
+		s.notify(ctx, domain.EventSessionEnded, session)
+	}
+	return nil
 }
 
 func (s *sessionService) Terminate(ctx context.Context, id string) error {
@@ -84,9 +97,30 @@ func (s *sessionService) Terminate(ctx context.Context, id string) error {
 	session.EndTime = time.Now()
 	session.UpdatedAt = time.Now()
 
-	return s.repo.Update(session)
+	if err := s.repo.Update(session); err != nil {
+		return err
+	}
+	s.notify(ctx, domain.EventSessionEnded, session)
+	return nil
 }
 
 func (s *sessionService) List(ctx context.Context) ([]*domain.Session, error) {
 	return s.repo.FindActive() // Using FindActive as a default listing method
 }
+
+// AddObserver registers an observer to be notified when sessions start and
+// end. Observers are called synchronously and must not block.
+func (s *sessionService) AddObserver(observer domain.SessionObserver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+func (s *sessionService) notify(ctx context.Context, event string, session *domain.Session) {
+	s.mu.RLock()
+	observers := s.observers
+	s.mu.RUnlock()
+	for _, observer := range observers {
+		observer.ObserveSession(ctx, event, session)
+	}
+}
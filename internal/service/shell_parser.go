@@ -0,0 +1,752 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxShellParseDepth bounds how deep substitutions, aliases, eval and
+// "sh -c" payloads are unwrapped, so hostile input cannot make the analyzer
+// recurse forever.
+const maxShellParseDepth = 8
+
+// shellKeywords are reserved words that may precede a simple command without
+// being the command itself.
+var shellKeywords = map[string]bool{
+	"!": true, "{": true, "}": true, "if": true, "then": true, "else": true,
+	"elif": true, "fi": true, "while": true, "until": true, "do": true,
+	"done": true, "time": true, "esac": true,
+}
+
+// shellInterpreters are commands that execute a script given with -c or
+// read one from standard input.
+var shellInterpreters = map[string]bool{
+	"sh": true, "bash": true, "dash": true, "zsh": true, "ksh": true,
+	"ash": true, "busybox": true,
+}
+
+// shellToken is a lexical unit of a shell line: either the words produced by
+// a single shell word (unquoted expansions may split into several) or an
+// operator such as ";", "&&" or "|".
+type shellToken struct {
+	operator string
+	words    []string
+}
+
+// shellParser turns a shell command line into the simple commands it would
+// actually run. It is not a complete POSIX shell: it resolves quoting,
+// variables assigned earlier on the same line, aliases, command substitutions,
+// eval, "sh -c" payloads and a few well-known decoding idioms so that
+// obfuscated commands are analyzed in their effective form.
+type shellParser struct {
+	vars     map[string]string
+	aliases  map[string]string
+	depth    int
+	segments []string
+}
+
+// parseShellCommands returns the normalized text of every simple command and
+// multi-command pipeline found in line, including those hidden inside
+// substitutions or encoded payloads.
+func parseShellCommands(line string) []string {
+	p := &shellParser{
+		vars:    map[string]string{"IFS": " "},
+		aliases: make(map[string]string),
+	}
+	p.parseList(line)
+	return p.segments
+}
+
+func (p *shellParser) record(segment string) {
+	segment = strings.TrimSpace(segment)
+	if segment != "" {
+		p.segments = append(p.segments, segment)
+	}
+}
+
+// parseNested parses src as a nested shell program, honouring the depth limit.
+func (p *shellParser) parseNested(src string) [][][]string {
+	if p.depth >= maxShellParseDepth {
+		return nil
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	return p.parseList(src)
+}
+
+// parseList parses a command list and returns its pipelines, each being a
+// list of commands made of words.
+func (p *shellParser) parseList(src string) [][][]string {
+	lx := &shellLexer{parser: p, src: src}
+
+	var pipelines [][][]string
+	var pipeline [][]string
+	var words []string
+
+	flushCommand := func() {
+		if cmd := p.processCommand(words); len(cmd) > 0 {
+			pipeline = append(pipeline, cmd)
+		}
+		words = nil
+	}
+	flushPipeline := func() {
+		flushCommand()
+		if len(pipeline) > 0 {
+			p.processPipeline(pipeline)
+			pipelines = append(pipelines, pipeline)
+		}
+		pipeline = nil
+	}
+
+	for {
+		tok, ok := lx.next()
+		if !ok {
+			break
+		}
+		if tok.operator == "" {
+			words = append(words, tok.words...)
+			continue
+		}
+		switch tok.operator {
+		case "|", "|&":
+			flushCommand()
+		default:
+			flushPipeline()
+		}
+	}
+	flushPipeline()
+
+	return pipelines
+}
+
+// processCommand normalizes a simple command, records it and unwraps any
+// nested programs it executes. It returns the effective words of the command.
+func (p *shellParser) processCommand(words []string) []string {
+	for len(words) > 0 && shellKeywords[words[0]] {
+		words = words[1:]
+	}
+
+	// Leading assignments either set shell variables (when nothing follows)
+	// or only affect the environment of the command that follows.
+	assignments := 0
+	for assignments < len(words) && isShellAssignment(words[assignments]) {
+		assignments++
+	}
+	if assignments == len(words) {
+		for _, word := range words {
+			name, value, _ := strings.Cut(word, "=")
+			p.vars[name] = value
+		}
+		return nil
+	}
+	words = words[assignments:]
+
+	p.record(strings.Join(words, " "))
+
+	name := path.Base(words[0])
+	switch {
+	case name == "alias":
+		for _, arg := range words[1:] {
+			if aliasName, body, ok := strings.Cut(arg, "="); ok {
+				p.aliases[aliasName] = body
+				p.parseNested(body)
+			}
+		}
+	case name == "eval":
+		p.parseNested(strings.Join(words[1:], " "))
+	default:
+		if body, ok := p.aliases[words[0]]; ok {
+			// Remove the alias while expanding it, as the shell does, so a
+			// self-referencing alias cannot loop.
+			delete(p.aliases, words[0])
+			p.parseNested(strings.TrimSpace(body + " " + strings.Join(words[1:], " ")))
+			p.aliases[words[0]] = body
+		}
+	}
+
+	if script, ok := shellScriptArgument(words); ok {
+		p.parseNested(script)
+	}
+
+	return words
+}
+
+// processPipeline records multi-command pipelines as a whole and unwraps
+// static payloads piped into a shell, such as "echo ... | base64 -d | sh".
+func (p *shellParser) processPipeline(pipeline [][]string) {
+	if len(pipeline) < 2 {
+		return
+	}
+
+	parts := make([]string, len(pipeline))
+	for i, cmd := range pipeline {
+		parts[i] = strings.Join(cmd, " ")
+	}
+	p.record(strings.Join(parts, " | "))
+
+	last := pipeline[len(pipeline)-1]
+	if !shellInterpreters[path.Base(last[0])] {
+		return
+	}
+	if _, ok := shellScriptArgument(last); ok {
+		return
+	}
+	if payload, ok := staticPipelineOutput(pipeline[:len(pipeline)-1]); ok {
+		p.parseNested(payload)
+	}
+}
+
+// substitute evaluates a command substitution. The inner commands are always
+// analyzed; the substitution only yields a value when its output can be
+// determined statically.
+func (p *shellParser) substitute(inner string) string {
+	pipelines := p.parseNested(inner)
+	if len(pipelines) != 1 {
+		return ""
+	}
+	out, ok := staticPipelineOutput(pipelines[0])
+	if !ok {
+		return ""
+	}
+	return strings.TrimRight(out, "\n")
+}
+
+// shellScriptArgument returns the script passed to an interpreter with -c,
+// e.g. "sudo bash -c 'rm -rf /'".
+func shellScriptArgument(words []string) (string, bool) {
+	for i, word := range words {
+		if !shellInterpreters[path.Base(word)] {
+			continue
+		}
+		for j := i + 1; j < len(words)-1; j++ {
+			flag := words[j]
+			if !strings.HasPrefix(flag, "-") || strings.HasPrefix(flag, "--") {
+				continue
+			}
+			if strings.ContainsRune(flag[1:], 'c') {
+				return words[j+1], true
+			}
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// staticPipelineOutput computes the output of a pipeline made only of
+// printing commands and decoding filters.
+func staticPipelineOutput(pipeline [][]string) (string, bool) {
+	if len(pipeline) == 0 {
+		return "", false
+	}
+
+	out, ok := staticCommandOutput(pipeline[0])
+	if !ok {
+		return "", false
+	}
+
+	for _, filter := range pipeline[1:] {
+		out, ok = applyStaticFilter(filter, out)
+		if !ok {
+			return "", false
+		}
+	}
+	return out, true
+}
+
+func staticCommandOutput(words []string) (string, bool) {
+	switch path.Base(words[0]) {
+	case "echo":
+		args := words[1:]
+		newline, escapes := true, false
+		for len(args) > 0 && isEchoFlag(args[0]) {
+			newline = newline && !strings.Contains(args[0], "n")
+			escapes = escapes || strings.Contains(args[0], "e")
+			args = args[1:]
+		}
+		out := strings.Join(args, " ")
+		if escapes {
+			out = decodeShellEscapes(out)
+		}
+		if newline {
+			out += "\n"
+		}
+		return out, true
+	case "printf":
+		if len(words) < 2 {
+			return "", false
+		}
+		format := decodeShellEscapes(words[1])
+		args := words[2:]
+		if strings.Contains(format, "%") {
+			for _, verb := range []string{"%s", "%b"} {
+				for strings.Contains(format, verb) && len(args) > 0 {
+					arg := args[0]
+					if verb == "%b" {
+						arg = decodeShellEscapes(arg)
+					}
+					format = strings.Replace(format, verb, arg, 1)
+					args = args[1:]
+				}
+			}
+			if strings.Contains(strings.ReplaceAll(format, "%%", ""), "%") {
+				return "", false
+			}
+			format = strings.ReplaceAll(format, "%%", "%")
+		}
+		return format, true
+	}
+	return "", false
+}
+
+func applyStaticFilter(words []string, input string) (string, bool) {
+	args := words[1:]
+	switch path.Base(words[0]) {
+	case "base64":
+		if !containsAny(args, "-d", "--decode", "-D") {
+			return "", false
+		}
+		return decodeBase64Payload(input)
+	case "xxd":
+		if !containsAny(args, "-r") || !containsAny(args, "-p", "-ps", "-plain") {
+			return "", false
+		}
+		decoded, err := hex.DecodeString(strings.Join(strings.Fields(input), ""))
+		if err != nil {
+			return "", false
+		}
+		return string(decoded), true
+	case "rev":
+		lines := strings.Split(strings.TrimSuffix(input, "\n"), "\n")
+		for i, line := range lines {
+			runes := []rune(line)
+			for l, r := 0, len(runes)-1; l < r; l, r = l+1, r-1 {
+				runes[l], runes[r] = runes[r], runes[l]
+			}
+			lines[i] = string(runes)
+		}
+		return strings.Join(lines, "\n") + "\n", true
+	case "cat":
+		if len(args) == 0 {
+			return input, true
+		}
+	}
+	return "", false
+}
+
+func decodeBase64Payload(input string) (string, bool) {
+	data := strings.Join(strings.Fields(input), "")
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if decoded, err := enc.DecodeString(data); err == nil && utf8.Valid(decoded) {
+			return string(decoded), true
+		}
+	}
+	return "", false
+}
+
+func isEchoFlag(arg string) bool {
+	if len(arg) < 2 || arg[0] != '-' {
+		return false
+	}
+	return strings.Trim(arg[1:], "neE") == ""
+}
+
+func isShellAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	return ok && isShellName(name)
+}
+
+func isShellName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func containsAny(args []string, values ...string) bool {
+	for _, arg := range args {
+		for _, value := range values {
+			if arg == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeShellEscapes interprets the backslash escapes understood by
+// $'...' quoting, "echo -e" and printf.
+func decodeShellEscapes(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case 'e', 'E':
+			b.WriteByte(0x1b)
+		case 'x':
+			n := hexDigits(s[i+1:], 2)
+			if n == 0 {
+				b.WriteString(`\x`)
+				continue
+			}
+			v, _ := strconv.ParseUint(s[i+1:i+1+n], 16, 8)
+			b.WriteByte(byte(v))
+			i += n
+		case 'u', 'U':
+			max := 4
+			if c == 'U' {
+				max = 8
+			}
+			n := hexDigits(s[i+1:], max)
+			if n == 0 {
+				b.WriteByte('\\')
+				b.WriteByte(c)
+				continue
+			}
+			v, _ := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			b.WriteRune(rune(v))
+			i += n
+		default:
+			if c >= '0' && c <= '7' {
+				start := i
+				limit := 3
+				if c == '0' {
+					limit = 4
+				}
+				for i < len(s) && i-start < limit && s[i] >= '0' && s[i] <= '7' {
+					i++
+				}
+				v, _ := strconv.ParseUint(s[start:i], 8, 16)
+				b.WriteByte(byte(v))
+				i--
+				continue
+			}
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func hexDigits(s string, max int) int {
+	n := 0
+	for n < len(s) && n < max {
+		c := s[n]
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			n++
+			continue
+		}
+		break
+	}
+	return n
+}
+
+// shellLexer splits a shell line into tokens on demand, so that variables
+// assigned by earlier commands are visible when later words are expanded.
+type shellLexer struct {
+	parser *shellParser
+	src    string
+	pos    int
+}
+
+func isShellMeta(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', ';', '&', '|', '(', ')', '<', '>':
+		return true
+	}
+	return false
+}
+
+func (lx *shellLexer) next() (shellToken, bool) {
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			lx.pos++
+		case c == '\\' && strings.HasPrefix(lx.src[lx.pos:], "\\\n"):
+			lx.pos += 2
+		case c == '#':
+			for lx.pos < len(lx.src) && lx.src[lx.pos] != '\n' {
+				lx.pos++
+			}
+		case c == '\n':
+			lx.pos++
+			return shellToken{operator: ";"}, true
+		case c == '<' || c == '>' || strings.HasPrefix(lx.src[lx.pos:], "&>"):
+			return shellToken{words: []string{lx.redirection()}}, true
+		case isShellMeta(c):
+			return shellToken{operator: lx.operator()}, true
+		default:
+			if words := lx.word(); len(words) > 0 {
+				return shellToken{words: words}, true
+			}
+		}
+	}
+	return shellToken{}, false
+}
+
+func (lx *shellLexer) operator() string {
+	for _, op := range []string{"&&", "||", ";;", "|&", ";", "&", "|", "(", ")"} {
+		if strings.HasPrefix(lx.src[lx.pos:], op) {
+			lx.pos += len(op)
+			return op
+		}
+	}
+	lx.pos++
+	return ";"
+}
+
+func (lx *shellLexer) redirection() string {
+	start := lx.pos
+	for lx.pos < len(lx.src) && strings.IndexByte("<>&|", lx.src[lx.pos]) >= 0 && lx.pos-start < 3 {
+		lx.pos++
+	}
+	return lx.src[start:lx.pos]
+}
+
+// word lexes a single shell word, removing quotes and performing the
+// expansions the parser can resolve statically.
+func (lx *shellLexer) word() []string {
+	var b strings.Builder
+	split := false
+	quoted := false
+
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		if isShellMeta(c) {
+			break
+		}
+		switch c {
+		case '\\':
+			lx.pos++
+			if lx.pos < len(lx.src) {
+				if lx.src[lx.pos] != '\n' {
+					b.WriteByte(lx.src[lx.pos])
+				}
+				lx.pos++
+			}
+		case '\'':
+			quoted = true
+			end := strings.IndexByte(lx.src[lx.pos+1:], '\'')
+			if end < 0 {
+				b.WriteString(lx.src[lx.pos+1:])
+				lx.pos = len(lx.src)
+				continue
+			}
+			b.WriteString(lx.src[lx.pos+1 : lx.pos+1+end])
+			lx.pos += end + 2
+		case '"':
+			quoted = true
+			lx.pos++
+			lx.doubleQuoted(&b)
+		case '`':
+			b.WriteString(lx.backtick())
+			split = true
+		case '$':
+			switch {
+			case strings.HasPrefix(lx.src[lx.pos:], "$'"):
+				quoted = true
+				lx.pos += 2
+				b.WriteString(decodeShellEscapes(lx.until('\'')))
+			case strings.HasPrefix(lx.src[lx.pos:], `$"`):
+				quoted = true
+				lx.pos += 2
+				lx.doubleQuoted(&b)
+			default:
+				value, expanded := lx.dollar()
+				b.WriteString(value)
+				split = split || expanded
+			}
+		default:
+			b.WriteByte(c)
+			lx.pos++
+		}
+	}
+
+	w := b.String()
+	if split {
+		return strings.Fields(w)
+	}
+	if w == "" && !quoted {
+		return nil
+	}
+	return []string{w}
+}
+
+// until returns the text up to the next unescaped delim and consumes it.
+func (lx *shellLexer) until(delim byte) string {
+	start := lx.pos
+	for lx.pos < len(lx.src) {
+		switch lx.src[lx.pos] {
+		case '\\':
+			lx.pos += 2
+			continue
+		case delim:
+			s := lx.src[start:lx.pos]
+			lx.pos++
+			return s
+		}
+		lx.pos++
+	}
+	lx.pos = len(lx.src)
+	return lx.src[start:]
+}
+
+func (lx *shellLexer) doubleQuoted(b *strings.Builder) {
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch c {
+		case '"':
+			lx.pos++
+			return
+		case '\\':
+			if lx.pos+1 < len(lx.src) && strings.IndexByte("$`\"\\\n", lx.src[lx.pos+1]) >= 0 {
+				if lx.src[lx.pos+1] != '\n' {
+					b.WriteByte(lx.src[lx.pos+1])
+				}
+				lx.pos += 2
+				continue
+			}
+			b.WriteByte(c)
+			lx.pos++
+		case '`':
+			b.WriteString(lx.backtick())
+		case '$':
+			value, _ := lx.dollar()
+			b.WriteString(value)
+		default:
+			b.WriteByte(c)
+			lx.pos++
+		}
+	}
+}
+
+func (lx *shellLexer) backtick() string {
+	lx.pos++
+	inner := lx.until('`')
+	inner = strings.NewReplacer("\\`", "`", `\\`, `\`, `\$`, `$`).Replace(inner)
+	return lx.parser.substitute(inner)
+}
+
+// dollar expands a "$" expression at the current position. The boolean
+// reports whether a value was substituted, which makes the word subject to
+// field splitting.
+func (lx *shellLexer) dollar() (string, bool) {
+	rest := lx.src[lx.pos:]
+	switch {
+	case strings.HasPrefix(rest, "$(("):
+		lx.pos = matchingParen(lx.src, lx.pos+1)
+		return "", false
+	case strings.HasPrefix(rest, "$("):
+		end := matchingParen(lx.src, lx.pos+1)
+		inner := lx.src[lx.pos+2 : end]
+		// An unterminated substitution runs to the end of the line and has
+		// no closing paren to strip
+		if strings.HasSuffix(inner, ")") {
+			inner = inner[:len(inner)-1]
+		}
+		lx.pos = end
+		return lx.parser.substitute(inner), true
+	case strings.HasPrefix(rest, "${"):
+		end := strings.IndexByte(rest, '}')
+		if end < 0 {
+			lx.pos = len(lx.src)
+			return rest, false
+		}
+		expr := rest[2:end]
+		lx.pos += end + 1
+		name := expr
+		fallback, hasFallback := "", false
+		for _, op := range []string{":-", "-", ":=", "="} {
+			if n, f, ok := strings.Cut(expr, op); ok && isShellName(n) {
+				name, fallback, hasFallback = n, f, true
+				break
+			}
+		}
+		if value, ok := lx.parser.vars[name]; ok {
+			return value, true
+		}
+		if hasFallback {
+			return fallback, true
+		}
+		return "${" + expr + "}", false
+	}
+
+	n := 1
+	for n < len(rest) && isShellName(rest[1:n+1]) {
+		n++
+	}
+	if n == 1 {
+		lx.pos++
+		if len(rest) > 1 && strings.IndexByte("@*#?$!-0123456789", rest[1]) >= 0 {
+			lx.pos++
+			return "", true
+		}
+		return "$", false
+	}
+	name := rest[1:n]
+	lx.pos += n
+	if value, ok := lx.parser.vars[name]; ok {
+		return value, true
+	}
+	return "$" + name, false
+}
+
+// matchingParen returns the position just after the parenthesis that closes
+// the one at open, skipping quoted text and nested parentheses.
+func matchingParen(src string, open int) int {
+	depth := 0
+	for i := open; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '\'':
+			end := strings.IndexByte(src[i+1:], '\'')
+			if end < 0 {
+				return len(src)
+			}
+			i += end + 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(src)
+}
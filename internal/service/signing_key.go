@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadSigningKey returns the one key audit checkpoints, recording manifests
+// and evidence bundles are signed with. A configured key is used as is;
+// otherwise the key is read from path, or generated and saved there so that
+// it survives restarts. Its public key is added to the list of trusted keys
+// kept next to it (path + ".pub"), so what was signed before the key
+// changed still verifies. The trusted keys, oldest first, are returned with
+// it.
+func LoadSigningKey(path string, configured ed25519.PrivateKey) (ed25519.PrivateKey, []ed25519.PublicKey, error) {
+	key := configured
+	if len(key) != ed25519.PrivateKeySize {
+		var err error
+		if key, err = readSigningKey(path); errors.Is(err, os.ErrNotExist) {
+			key, err = createSigningKey(path)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	trusted, err := LoadTrustedSigningKeys(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	public := key.Public().(ed25519.PublicKey)
+	if trustedSigningKey(base64.StdEncoding.EncodeToString(public), trusted) == nil {
+		if err := appendTrustedSigningKey(path, public); err != nil {
+			return nil, nil, err
+		}
+		trusted = append(trusted, public)
+	}
+	return key, trusted, nil
+}
+
+// LoadTrustedSigningKeys returns the public keys listed next to the signing
+// key at path, every key the server has signed with
+func LoadTrustedSigningKeys(path string) ([]ed25519.PublicKey, error) {
+	file, err := os.Open(path + ".pub")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var trusted []ed25519.PublicKey
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid trusted signing key in %s: %q", file.Name(), line)
+		}
+		trusted = append(trusted, key)
+	}
+	return trusted, scanner.Err()
+}
+
+// readSigningKey reads a key saved by createSigningKey
+func readSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s must hold a 32-byte Ed25519 seed, base64 encoded", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// createSigningKey generates a key and saves its seed to path, readable by
+// its owner only
+func createSigningKey(path string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintln(file, base64.StdEncoding.EncodeToString(key.Seed())); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return key, nil
+}
+
+// appendTrustedSigningKey adds a public key to the trusted keys next to the
+// signing key at path
+func appendTrustedSigningKey(path string, key ed25519.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path+".pub", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(file, base64.StdEncoding.EncodeToString(key)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// trustedSigningKey returns the trusted key that encoded, the base64 public
+// key recorded in a checkpoint or manifest, names, or nil if none does
+func trustedSigningKey(encoded string, trusted []ed25519.PublicKey) ed25519.PublicKey {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+	for _, candidate := range trusted {
+		if bytes.Equal(key, candidate) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// signingKeys returns the trusted keys with the public half of key, which is
+// trusted whether or not it is listed
+func signingKeys(key ed25519.PrivateKey, trusted []ed25519.PublicKey) []ed25519.PublicKey {
+	return append([]ed25519.PublicKey{key.Public().(ed25519.PublicKey)}, trusted...)
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/internal/repository"
+)
+
+func TestLoadSigningKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "signing.key")
+
+	// Without a configured key, one is generated and kept
+	generated, trusted, err := LoadSigningKey(path, nil)
+	require.NoError(t, err)
+	require.Len(t, generated, ed25519.PrivateKeySize)
+	assert.Equal(t, []ed25519.PublicKey{generated.Public().(ed25519.PublicKey)}, trusted)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	again, trusted, err := LoadSigningKey(path, nil)
+	require.NoError(t, err)
+	assert.True(t, generated.Equal(again), "the key survives a restart")
+	assert.Len(t, trusted, 1)
+
+	// A configured key takes over, and the one before it stays trusted
+	_, configured, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, trusted, err := LoadSigningKey(path, configured)
+	require.NoError(t, err)
+	assert.True(t, configured.Equal(key))
+	assert.Equal(t, []ed25519.PublicKey{
+		generated.Public().(ed25519.PublicKey),
+		configured.Public().(ed25519.PublicKey),
+	}, trusted)
+
+	listed, err := LoadTrustedSigningKeys(path)
+	require.NoError(t, err)
+	assert.Equal(t, trusted, listed)
+
+	require.NoError(t, os.WriteFile(path, []byte("not a key\n"), 0600))
+	_, _, err = LoadSigningKey(path, nil)
+	assert.Error(t, err)
+}
+
+func TestAuditLogService_VerifiesAcrossSigningKeys(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	logs, checkpoints := repository.NewAuditLogRepository(db), repository.NewAuditCheckpointRepository(db)
+
+	_, before, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, after, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	// Checkpoints are signed with one key, then another after a restart
+	for _, key := range []ed25519.PrivateKey{before, after} {
+		auditLogs := NewAuditLogService(logs, checkpoints, AuditLogOptions{SigningKey: key})
+		require.NoError(t, auditLogs.Create(ctx, &domain.AuditLog{Action: "login"}))
+		_, err := auditLogs.Checkpoint(ctx)
+		require.NoError(t, err)
+	}
+
+	auditLogs := NewAuditLogService(logs, checkpoints, AuditLogOptions{
+		SigningKey:  after,
+		TrustedKeys: []ed25519.PublicKey{before.Public().(ed25519.PublicKey)},
+	})
+	verification, err := auditLogs.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid, verification.Break)
+	assert.Equal(t, 2, verification.Checkpoints)
+
+	untrusting := NewAuditLogService(logs, checkpoints, AuditLogOptions{SigningKey: after})
+	verification, err = untrusting.Verify(ctx)
+	require.NoError(t, err)
+	assert.False(t, verification.Valid)
+	require.NotNil(t, verification.Break)
+	assert.Equal(t, "checkpoint is signed with a different key", verification.Break.Reason)
+}
+
+func TestSessionRecordingService_VerifiesAcrossSigningKeys(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewSessionRecordingRepository(db)
+	basePath := t.TempDir()
+
+	_, before, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, after, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	recordings := NewSessionRecordingService(repo, nil, RecordingOptions{BasePath: basePath, SigningKey: before})
+	recording, err := recordings.StartRecording(ctx, "session-1", "")
+	require.NoError(t, err)
+	require.NoError(t, recordings.WriteOutput(ctx, "session-1", []byte("SELECT 1;\r\n")))
+	require.NoError(t, recordings.StopRecording(ctx, "session-1"))
+
+	recordings = NewSessionRecordingService(repo, nil, RecordingOptions{
+		BasePath:    basePath,
+		SigningKey:  after,
+		TrustedKeys: []ed25519.PublicKey{before.Public().(ed25519.PublicKey)},
+	})
+	verification, err := recordings.VerifyRecording(ctx, recording.ID)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid, verification.Details)
+
+	recordings = NewSessionRecordingService(repo, nil, RecordingOptions{BasePath: basePath, SigningKey: after})
+	verification, err = recordings.VerifyRecording(ctx, recording.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RecordingUntrustedKey, verification.Status)
+}
@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"secretary/alpha/internal/domain"
+	"secretary/alpha/pkg/utils"
+)
+
+// Syslog message formats
+const (
+	SyslogFormatCEF  = "cef"
+	SyslogFormatJSON = "json"
+)
+
+// SyslogOptions configures forwarding to a syslog collector. Messages are
+// RFC 5424, sent over Network ("udp", "tcp" or "tls") to Addr; over TCP and
+// TLS they are framed by octet counting (RFC 6587). While the collector is
+// unavailable up to BufferSize messages are held, dropping the oldest, and
+// it is redialled every RetryInterval. A write that takes longer than
+// WriteTimeout, such as to a collector that stopped reading, fails like any
+// other.
+type SyslogOptions struct {
+	Network   string
+	Addr      string
+	Format    string // "cef" or "json"
+	Facility  int
+	AppName   string
+	Hostname  string
+	TLSConfig *tls.Config
+
+	BufferSize    int
+	RetryInterval time.Duration
+	DialTimeout   time.Duration
+	WriteTimeout  time.Duration
+}
+
+// DefaultSyslogOptions returns the options used when none are configured:
+// CEF over UDP, as the log audit facility
+func DefaultSyslogOptions() SyslogOptions {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	return SyslogOptions{
+		Network:       "udp",
+		Format:        SyslogFormatCEF,
+		Facility:      13,
+		AppName:       "secretary",
+		Hostname:      hostname,
+		BufferSize:    10000,
+		RetryInterval: 5 * time.Second,
+		DialTimeout:   5 * time.Second,
+		WriteTimeout:  5 * time.Second,
+	}
+}
+
+// Syslog severities
+const (
+	syslogCritical      = 2
+	syslogError         = 3
+	syslogWarning       = 4
+	syslogNotice        = 5
+	syslogInformational = 6
+)
+
+// cefDeviceVersion is the device version in CEF headers
+const cefDeviceVersion = "1.0"
+
+type syslogExporter struct {
+	options  SyslogOptions
+	eventBus domain.EventBus
+	conn     net.Conn
+	// pending holds the messages not yet written, oldest first
+	pending  [][]byte
+	dropped  int
+	nextDial time.Time
+	now      func() time.Time
+}
+
+func NewSyslogExporter(eventBus domain.EventBus, options SyslogOptions) domain.SyslogExporter {
+	defaults := DefaultSyslogOptions()
+	if options.Network == "" {
+		options.Network = defaults.Network
+	}
+	if options.Format == "" {
+		options.Format = defaults.Format
+	}
+	if options.Facility == 0 {
+		options.Facility = defaults.Facility
+	}
+	if options.AppName == "" {
+		options.AppName = defaults.AppName
+	}
+	if options.Hostname == "" {
+		options.Hostname = defaults.Hostname
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = defaults.BufferSize
+	}
+	if options.RetryInterval <= 0 {
+		options.RetryInterval = defaults.RetryInterval
+	}
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = defaults.DialTimeout
+	}
+	if options.WriteTimeout <= 0 {
+		options.WriteTimeout = defaults.WriteTimeout
+	}
+	return &syslogExporter{
+		options:  options,
+		eventBus: eventBus,
+		now:      time.Now,
+	}
+}
+
+// Run forwards events until ctx is done, then makes a last attempt to send
+// whatever is still buffered
+func (e *syslogExporter) Run(ctx context.Context) {
+	events, unsubscribe := e.eventBus.Subscribe(e.options.BufferSize)
+	defer unsubscribe()
+	ticker := time.NewTicker(e.options.RetryInterval)
+	defer ticker.Stop()
+	defer e.close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.flush()
+			return
+		case event := <-events:
+			message, err := e.format(event)
+			if err != nil {
+				utils.Errorf("Failed to format %s event %s for syslog: %v", event.Type, event.ID, err)
+				continue
+			}
+			e.enqueue(message)
+			e.flush()
+		case <-ticker.C:
+			if len(e.pending) > 0 {
+				e.flush()
+			}
+		}
+	}
+}
+
+func (e *syslogExporter) enqueue(message []byte) {
+	if len(e.pending) >= e.options.BufferSize {
+		e.pending = e.pending[1:]
+		e.dropped++
+	}
+	e.pending = append(e.pending, message)
+}
+
+// flush writes the pending messages in order, dialling the collector first
+// if need be. A failed write closes the connection and keeps the message.
+func (e *syslogExporter) flush() {
+	if e.conn == nil {
+		if e.now().Before(e.nextDial) {
+			return
+		}
+		conn, err := e.dial()
+		if err != nil {
+			e.nextDial = e.now().Add(e.options.RetryInterval)
+			utils.Warnf("Failed to connect to syslog collector %s, holding %d messages: %v", e.options.Addr, len(e.pending), err)
+			return
+		}
+		e.conn = conn
+		if e.dropped > 0 {
+			utils.Warnf("Dropped %d syslog messages while the collector was unavailable", e.dropped)
+			e.dropped = 0
+		}
+	}
+
+	for len(e.pending) > 0 {
+		e.conn.SetWriteDeadline(time.Now().Add(e.options.WriteTimeout))
+		if _, err := e.conn.Write(e.frame(e.pending[0])); err != nil {
+			utils.Warnf("Failed to write to syslog collector %s, holding %d messages: %v", e.options.Addr, len(e.pending), err)
+			e.close()
+			e.nextDial = e.now().Add(e.options.RetryInterval)
+			return
+		}
+		e.pending = e.pending[1:]
+	}
+}
+
+func (e *syslogExporter) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: e.options.DialTimeout}
+	if e.options.Network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", e.options.Addr, e.options.TLSConfig)
+	}
+	return dialer.Dial(e.options.Network, e.options.Addr)
+}
+
+func (e *syslogExporter) close() {
+	if e.conn != nil {
+		e.conn.Close()
+		e.conn = nil
+	}
+}
+
+// frame is a message as written to the connection: one datagram over UDP,
+// prefixed with its length over TCP and TLS
+func (e *syslogExporter) frame(message []byte) []byte {
+	if e.options.Network == "udp" {
+		return message
+	}
+	return append([]byte(strconv.Itoa(len(message))+" "), message...)
+}
+
+// format renders an event as an RFC 5424 message whose MSGID is the event
+// type and whose body is CEF or JSON
+func (e *syslogExporter) format(event *domain.Event) ([]byte, error) {
+	var body string
+	switch e.options.Format {
+	case SyslogFormatJSON:
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		body = string(data)
+	default:
+		body = formatCEF(event)
+	}
+
+	priority := e.options.Facility*8 + syslogSeverity(event)
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		priority,
+		event.Time.UTC().Format(time.RFC3339Nano),
+		syslogField(e.options.Hostname, 255),
+		syslogField(e.options.AppName, 48),
+		os.Getpid(),
+		syslogField(event.Type, 32),
+		body,
+	)), nil
+}
+
+// syslogField makes a header field printable, without spaces and no longer
+// than the RFC 5424 limit
+func syslogField(value string, limit int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > limit {
+		value = value[:limit]
+	}
+	return value
+}
+
+// syslogSeverity maps the risk of commands and the severity of alerts onto
+// syslog severities; everything else is informational
+func syslogSeverity(event *domain.Event) int {
+	switch event.Severity {
+	case "critical":
+		return syslogCritical
+	case "high":
+		return syslogError
+	case "medium":
+		return syslogWarning
+	case "low":
+		if event.Type == domain.EventAlert {
+			return syslogNotice
+		}
+	}
+	return syslogInformational
+}
+
+// cefSeverity maps the same onto CEF's 0 to 10 scale
+func cefSeverity(event *domain.Event) int {
+	switch event.Severity {
+	case "critical":
+		return 10
+	case "high":
+		return 8
+	case "medium":
+		return 5
+	case "low":
+		return 3
+	}
+	return 1
+}
+
+// formatCEF renders an event in ArcSight Common Event Format
+func formatCEF(event *domain.Event) string {
+	signature, name := event.Type, event.Type
+	var ext [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			ext = append(ext, [2]string{key, value})
+		}
+	}
+	add("rt", strconv.FormatInt(event.Time.UnixMilli(), 10))
+	add("externalId", event.ID)
+	add("cat", event.Type)
+	add("suser", event.UserID)
+	add("cs1Label", "sessionId")
+	add("cs1", event.SessionID)
+	add("cs2Label", "resourceId")
+	add("cs2", event.ResourceID)
+
+	switch {
+	case event.Session != nil:
+		name = "Session started"
+		if event.Type == domain.EventSessionEnded {
+			name = "Session " + event.Session.Status
+		}
+		add("src", event.Session.ClientIP)
+		add("act", event.Session.Status)
+		if !event.Session.EndTime.IsZero() {
+			add("end", strconv.FormatInt(event.Session.EndTime.UnixMilli(), 10))
+		}
+	case event.Proxy != nil:
+		name = "Proxy started"
+		if event.Type == domain.EventProxyStopped {
+			name = "Proxy stopped"
+		}
+		add("app", event.Proxy.Protocol)
+		add("dhost", event.Proxy.RemoteHost)
+		add("dpt", strconv.Itoa(event.Proxy.RemotePort))
+		add("spt", strconv.Itoa(event.Proxy.LocalPort))
+		add("cs3Label", "proxyId")
+		add("cs3", event.Proxy.ID)
+	case event.Command != nil:
+		signature = "command:" + event.Command.CommandType
+		name = "Command " + event.Command.Status
+		add("act", event.Command.Status)
+		add("msg", event.Command.Command)
+		add("cs3Label", "commandId")
+		add("cs3", event.Command.ID)
+		add("reason", event.Command.DecisionReason)
+	case event.Alert != nil:
+		signature = "alert:" + event.Alert.AlertType
+		name = event.Alert.Title
+		add("act", event.Alert.Action)
+		add("msg", event.Alert.Description)
+		add("cs3Label", "alertId")
+		add("cs3", event.Alert.ID)
+		add("cs4Label", "alertStatus")
+		add("cs4", event.Alert.Status)
+	case event.AuditLog != nil:
+		signature = "audit:" + event.AuditLog.Action
+		name = event.AuditLog.Action
+		add("act", event.AuditLog.Action)
+		add("msg", event.AuditLog.Details)
+		add("src", event.AuditLog.IP)
+		add("requestClientApplication", event.AuditLog.UserAgent)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|Secretary|Secretary|%s|%s|%s|%d|",
+		cefDeviceVersion, cefHeader(signature), cefHeader(name), cefSeverity(event))
+	for i, kv := range ext {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(kv[0] + "=" + cefExtension(kv[1]))
+	}
+	return b.String()
+}
+
+// cefHeader escapes a CEF header field
+func cefHeader(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ").Replace(value)
+}
+
+// cefExtension escapes a CEF extension value
+func cefExtension(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`).Replace(value)
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"secretary/alpha/internal/domain"
+)
+
+// runSyslogExporter starts forwarding events until the test ends, once the
+// exporter has subscribed to the bus
+func runSyslogExporter(t *testing.T, bus domain.EventBus, options SyslogOptions) {
+	exporter := NewSyslogExporter(bus, options)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		exporter.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	b := bus.(*eventBus)
+	require.Eventually(t, func() bool {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		return len(b.subscribers) > 0
+	}, 5*time.Second, time.Millisecond)
+}
+
+// readOctetCounted reads one RFC 6587 octet-counted message
+func readOctetCounted(t *testing.T, reader *bufio.Reader) string {
+	length, err := reader.ReadString(' ')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	require.NoError(t, err)
+	message := make([]byte, n)
+	_, err = io.ReadFull(reader, message)
+	require.NoError(t, err)
+	return string(message)
+}
+
+func TestFormatCEF(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	alert := formatCEF(&domain.Event{
+		ID: "event-1", Type: domain.EventAlert, Time: at, UserID: "alice", SessionID: "s-1", ResourceID: "db-1", Severity: "critical",
+		Alert: &domain.SecurityAlert{ID: "alert-1", AlertType: "privilege_escalation", Title: "sudo | su", Description: "a=b\nc\\d", Action: "blocked", Status: domain.AlertOpen},
+	})
+	assert.Equal(t, `CEF:0|Secretary|Secretary|1.0|alert:privilege_escalation|sudo \| su|10|`+
+		`rt=1772366400000 externalId=event-1 cat=alert suser=alice cs1Label=sessionId cs1=s-1 cs2Label=resourceId cs2=db-1 `+
+		`act=blocked msg=a\=b\nc\\d cs3Label=alertId cs3=alert-1 cs4Label=alertStatus cs4=open`, alert)
+
+	command := formatCEF(&domain.Event{
+		ID: "event-2", Type: domain.EventCommand, Time: at, Severity: "low",
+		Command: &domain.SessionCommand{ID: "cmd-1", CommandType: "sql", Command: "SELECT 1", Status: "executed"},
+	})
+	assert.True(t, strings.HasPrefix(command, "CEF:0|Secretary|Secretary|1.0|command:sql|Command executed|3|"), command)
+	assert.Contains(t, command, "msg=SELECT 1")
+
+	ended := formatCEF(&domain.Event{
+		Type: domain.EventSessionEnded, Time: at,
+		Session: &domain.Session{ID: "s-1", Status: "terminated", ClientIP: "10.0.0.1", EndTime: at},
+	})
+	assert.Contains(t, ended, "|session.ended|Session terminated|1|")
+	assert.Contains(t, ended, "src=10.0.0.1")
+
+	proxy := formatCEF(&domain.Event{
+		Type: domain.EventProxyStopped, Time: at,
+		Proxy: &domain.ProxyConnection{ID: "p-1", Protocol: "postgres", RemoteHost: "db.internal", RemotePort: 5432, LocalPort: 40000},
+	})
+	assert.Contains(t, proxy, "|proxy.stopped|Proxy stopped|1|")
+	assert.Contains(t, proxy, "app=postgres dhost=db.internal dpt=5432 spt=40000 cs3Label=proxyId cs3=p-1")
+}
+
+func TestSyslogExporter_UDP(t *testing.T) {
+	collector, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer collector.Close()
+
+	bus := NewEventBus()
+	runSyslogExporter(t, bus, SyslogOptions{Network: "udp", Addr: collector.LocalAddr().String(), Hostname: "bastion 1"})
+
+	bus.ObserveAlert(context.Background(), &domain.SecurityAlert{ID: "alert-1", UserID: "alice", AlertType: "privilege_escalation", Severity: "critical", Title: "Privilege escalation"})
+
+	buf := make([]byte, 8192)
+	collector.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := collector.ReadFrom(buf)
+	require.NoError(t, err)
+	message := string(buf[:n])
+
+	// log audit (13) * 8 + critical (2)
+	assert.True(t, strings.HasPrefix(message, "<106>1 "), message)
+	fields := strings.SplitN(message, " ", 8)
+	require.Len(t, fields, 8)
+	assert.Equal(t, "bastion1", fields[2])
+	assert.Equal(t, "secretary", fields[3])
+	assert.Equal(t, domain.EventAlert, fields[5])
+	assert.Equal(t, "-", fields[6])
+	assert.True(t, strings.HasPrefix(fields[7], "CEF:0|Secretary|Secretary|1.0|alert:privilege_escalation|Privilege escalation|10|"), fields[7])
+}
+
+func TestSyslogExporter_BuffersUntilCollectorIsUp(t *testing.T) {
+	// Reserve an address with nothing listening on it yet
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	bus := NewEventBus()
+	runSyslogExporter(t, bus, SyslogOptions{Network: "tcp", Addr: addr, Format: SyslogFormatJSON, RetryInterval: 10 * time.Millisecond})
+
+	ctx := context.Background()
+	session := &domain.Session{ID: "s-1", UserID: "alice", ResourceID: "db-1", Status: "active"}
+	bus.ObserveSession(ctx, domain.EventSessionStarted, session)
+	bus.ObserveCommand(ctx, &domain.SessionCommand{ID: "cmd-1", SessionID: "s-1", Command: "SELECT 1", Risk: "low"})
+	bus.ObserveAuditLog(ctx, &domain.AuditLog{ID: "log-1", UserID: "alice", Action: "proxy_started"})
+
+	listener, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	defer listener.Close()
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	reader := bufio.NewReader(conn)
+	for _, want := range []string{domain.EventSessionStarted, domain.EventCommand, domain.EventAuditLog} {
+		message := readOctetCounted(t, reader)
+		fields := strings.SplitN(message, " ", 8)
+		require.Len(t, fields, 8)
+		assert.Equal(t, want, fields[5])
+
+		var event domain.Event
+		require.NoError(t, json.Unmarshal([]byte(fields[7]), &event))
+		assert.Equal(t, want, event.Type)
+	}
+}
+
+func TestSyslogExporter_TLS(t *testing.T) {
+	// Borrow the test server's certificate, which is valid for 127.0.0.1
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	bus := NewEventBus()
+	runSyslogExporter(t, bus, SyslogOptions{Network: "tls", Addr: listener.Addr().String(), TLSConfig: &tls.Config{RootCAs: roots}})
+	bus.ObserveSession(context.Background(), domain.EventSessionEnded, &domain.Session{ID: "s-1", Status: "completed"})
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	message := readOctetCounted(t, bufio.NewReader(conn))
+	assert.Contains(t, message, "|session.ended|Session completed|1|")
+}
+
+func TestSyslogExporter_TimesOutOnStalledCollector(t *testing.T) {
+	// A collector that accepts connections but never reads
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	defer func() {
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	}()
+
+	exporter := NewSyslogExporter(NewEventBus(), SyslogOptions{
+		Network:      "tcp",
+		Addr:         listener.Addr().String(),
+		WriteTimeout: 50 * time.Millisecond,
+	}).(*syslogExporter)
+	// More than the socket buffers hold
+	message := []byte(strings.Repeat("x", 1<<20))
+	for i := 0; i < 256; i++ {
+		exporter.enqueue(message)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		exporter.flush()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("flush blocked on a collector that does not read")
+	}
+	assert.Nil(t, exporter.conn, "the stalled connection is closed")
+	assert.NotEmpty(t, exporter.pending, "unwritten messages are kept")
+	assert.True(t, exporter.nextDial.After(time.Now()), "the collector is redialled later")
+}
+
+func TestSyslogExporter_DropsOldestWhenFull(t *testing.T) {
+	exporter := NewSyslogExporter(NewEventBus(), SyslogOptions{BufferSize: 2}).(*syslogExporter)
+	exporter.enqueue([]byte("one"))
+	exporter.enqueue([]byte("two"))
+	exporter.enqueue([]byte("three"))
+
+	assert.Equal(t, [][]byte{[]byte("two"), []byte("three")}, exporter.pending)
+	assert.Equal(t, 1, exporter.dropped)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -11,12 +12,19 @@ import (
 	"secretary/alpha/internal/utils"
 )
 
+// Audit log actions written for logins
+const (
+	auditLoginSucceeded = "login_succeeded"
+	auditLoginFailed    = "login_failed"
+)
+
 type userService struct {
-	repo domain.UserRepository
+	repo            domain.UserRepository
+	auditLogService domain.AuditLogService
 }
 
-func NewUserService(repo domain.UserRepository) domain.UserService {
-	return &userService{repo: repo}
+func NewUserService(repo domain.UserRepository, auditLogService domain.AuditLogService) domain.UserService {
+	return &userService{repo: repo, auditLogService: auditLogService}
 }
 
 func (s *userService) CreateUser(ctx context.Context, user *domain.User) error {
@@ -48,17 +56,22 @@ func (s *userService) CreateUser(ctx context.Context, user *domain.User) error {
 }
 
 func (s *userService) Authenticate(ctx context.Context, username, password string) (*domain.User, error) {
+	details, _ := json.Marshal(map[string]string{"username": username})
+
 	// Find user by username
 	user, err := s.repo.FindByUsername(username)
 	if err != nil {
+		recordAudit(ctx, s.auditLogService, &domain.AuditLog{Action: auditLoginFailed, Details: string(details)})
 		return nil, errors.New("invalid credentials")
 	}
 
 	// Validate password
 	if !utils.CheckPasswordHash(password, user.Password) {
+		recordAudit(ctx, s.auditLogService, &domain.AuditLog{UserID: user.ID, Action: auditLoginFailed, Details: string(details)})
 		return nil, errors.New("invalid credentials")
 	}
 
+	recordAudit(ctx, s.auditLogService, &domain.AuditLog{UserID: user.ID, Action: auditLoginSucceeded, Details: string(details)})
 	return user, nil
 }
 
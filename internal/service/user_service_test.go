@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -9,14 +10,23 @@ import (
 	"secretary/alpha/internal/repository"
 )
 
-func setupTestUserService(t *testing.T) (domain.UserService, domain.UserRepository) {
+// newTestDB returns an in-memory database, closed when the test ends
+func newTestDB(t testing.TB) *sql.DB {
 	db, err := repository.InitDB("sqlite3", ":memory:")
 	if err != nil {
 		t.Fatalf("Failed to initialize test database: %v", err)
 	}
+	// Every connection to :memory: opens a separate database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func setupTestUserService(t *testing.T) (domain.UserService, domain.UserRepository) {
+	db := newTestDB(t)
 
 	userRepo := repository.NewUserRepository(db)
-	userService := NewUserService(userRepo)
+	userService := NewUserService(userRepo, nil)
 
 	return userService, userRepo
 }